@@ -4,10 +4,22 @@ import (
 	"context"
 	"expvar"
 	"fmt"
+	"github.com/AgeroFlynn/crud/internal/buisness/core/dto"
+	outboxCore "github.com/AgeroFlynn/crud/internal/buisness/core/outbox"
+	webhookCore "github.com/AgeroFlynn/crud/internal/buisness/core/webhook"
+	"github.com/AgeroFlynn/crud/internal/buisness/repository/store/outbox"
+	"github.com/AgeroFlynn/crud/internal/buisness/repository/store/webhook"
 	"github.com/AgeroFlynn/crud/internal/buisness/sys/auth"
+	"github.com/AgeroFlynn/crud/internal/buisness/sys/lockout"
+	"github.com/AgeroFlynn/crud/internal/buisness/sys/passwd"
+	authz "github.com/AgeroFlynn/crud/internal/buisness/sys/policy"
+	"github.com/AgeroFlynn/crud/internal/foundation/bloom"
 	"github.com/AgeroFlynn/crud/internal/foundation/config"
+	"github.com/AgeroFlynn/crud/internal/foundation/database"
 	"github.com/AgeroFlynn/crud/internal/foundation/keystore"
 	"github.com/AgeroFlynn/crud/internal/foundation/logger"
+	"github.com/AgeroFlynn/crud/internal/foundation/mail"
+	"github.com/AgeroFlynn/crud/internal/foundation/worker"
 	"github.com/AgeroFlynn/crud/internal/transport/rest/handlers"
 	"github.com/ardanlabs/conf/v3"
 	"github.com/go-pg/pg/v10"
@@ -18,6 +30,7 @@ import (
 	"os"
 	"os/signal"
 	"runtime"
+	"sync"
 	"syscall"
 )
 
@@ -106,6 +119,9 @@ func run(log *zap.SugaredLogger) error {
 		PoolSize: cfg.DB.PoolSize,
 	})
 
+	// Queries not bound to a deadline by their request get the default one.
+	database.WithQueryTimeout(db, cfg.DB.QueryTimeout)
+
 	if err != nil {
 		return fmt.Errorf("connecting to db: %w", err)
 	}
@@ -114,6 +130,118 @@ func run(log *zap.SugaredLogger) error {
 		db.Close()
 	}()
 
+	// =========================================================================
+	// Mail Support
+
+	log.Infow("startup", "status", "initializing mail support", "sender", cfg.Mail.Sender)
+
+	var sender mail.Sender
+	switch cfg.Mail.Sender {
+	case "smtp":
+		sender = mail.NewSMTP(cfg.Mail.SMTPHost, cfg.Mail.SMTPPort, cfg.Mail.SMTPUser, cfg.Mail.SMTPPassword, cfg.Mail.From)
+	case "file":
+		sender = mail.NewFileDrop(cfg.Mail.DropFolder, cfg.Mail.From)
+	case "stdout":
+		sender = mail.NewStdout(cfg.Mail.From)
+	default:
+		return fmt.Errorf("unknown mail sender %q", cfg.Mail.Sender)
+	}
+
+	// =========================================================================
+	// Password Support
+
+	// Hashes made by another algorithm or with weaker parameters are
+	// upgraded when their users log in.
+	hasher, err := passwd.NewHasher(cfg.Password.Hasher, passwd.Argon2Params{
+		Time:    cfg.Password.Argon2Time,
+		Memory:  cfg.Password.Argon2Memory,
+		Threads: cfg.Password.Argon2Threads,
+	}, cfg.Password.BcryptCost)
+	if err != nil {
+		return fmt.Errorf("constructing hasher: %w", err)
+	}
+
+	policy := passwd.Policy{
+		MinLength:      cfg.Password.MinLength,
+		MinClasses:     cfg.Password.MinClasses,
+		History:        cfg.Password.History,
+		ForbidPersonal: cfg.Password.ForbidPersonal,
+	}
+
+	// The breached passwords are kept as a bloom filter, built from a list
+	// with the admin tool.
+	if cfg.Password.BreachedFile != "" {
+		log.Infow("startup", "status", "loading breached passwords", "file", cfg.Password.BreachedFile)
+
+		breached, err := bloom.Load(cfg.Password.BreachedFile)
+		if err != nil {
+			return fmt.Errorf("loading breached passwords: %w", err)
+		}
+		policy.Breached = breached
+	}
+
+	// =========================================================================
+	// Authorization Policies
+
+	// The policies the service ships with are used unless a file is set.
+	policies := authz.Default()
+	if cfg.Auth.PolicyFile != "" {
+		log.Infow("startup", "status", "loading authorization policies", "file", cfg.Auth.PolicyFile)
+
+		policies, err = authz.Load(cfg.Auth.PolicyFile)
+		if err != nil {
+			return fmt.Errorf("loading authorization policies: %w", err)
+		}
+	}
+
+	// =========================================================================
+	// Start Outbox Relay
+
+	log.Infow("startup", "status", "initializing outbox relay")
+
+	// The relay dispatches the domain events committed to the outbox to the
+	// subscribers.
+	outboxes := outbox.NewStore(log, db)
+	relay := outboxCore.NewRelay(log, outboxes, outboxCore.RelayConfig{
+		Interval:    cfg.Outbox.Interval,
+		BatchSize:   cfg.Outbox.BatchSize,
+		MaxAttempts: cfg.Outbox.MaxAttempts,
+		Backoff:     cfg.Outbox.Backoff,
+		MaxBackoff:  cfg.Outbox.MaxBackoff,
+		Lease:       cfg.Outbox.Lease,
+	})
+	relay.Subscribe(outboxCore.AllEvents, "log", func(ctx context.Context, e dto.Event) error {
+		log.Infow("event", "id", e.ID, "type", e.Type, "aggregateID", e.AggregateID)
+		return nil
+	})
+
+	// =========================================================================
+	// Start Webhook Sender
+
+	log.Infow("startup", "status", "initializing webhook sender")
+
+	// The API subscribes the webhooks to the relay, the sender posts the
+	// deliveries queued for them.
+	webhooks := webhook.NewStore(log, db)
+	whSender := webhookCore.NewSender(log, webhooks, webhookCore.SenderConfig{
+		Interval:     cfg.Webhook.Interval,
+		BatchSize:    cfg.Webhook.BatchSize,
+		MaxAttempts:  cfg.Webhook.MaxAttempts,
+		Backoff:      cfg.Webhook.Backoff,
+		MaxBackoff:   cfg.Webhook.MaxBackoff,
+		Timeout:      cfg.Webhook.Timeout,
+		AllowPrivate: cfg.Webhook.AllowPrivate,
+	})
+
+	// =========================================================================
+	// Start Mail Queue
+
+	log.Infow("startup", "status", "initializing mail queue")
+
+	// Mails the requests ask for, like password reset links, are sent after
+	// the requests are answered. The queue is drained on shutdown.
+	mails := worker.NewQueue(cfg.Mail.QueueSize, cfg.Mail.Workers, cfg.Mail.Timeout)
+
 	// =========================================================================
 	// Start API Service
 
@@ -126,12 +254,73 @@ func run(log *zap.SugaredLogger) error {
 
 	//Construct the mux for the API calls.
 	apiMux := handlers.APIMux(handlers.APIMuxConfig{
-		Shutdown: shutdown,
-		Log:      log,
-		DB:       db,
-		Auth:     auth,
+		Shutdown:        shutdown,
+		Log:             log,
+		DB:              db,
+		Auth:            auth,
+		PublicURL:       cfg.Web.PublicURL,
+		RequireIfMatch:  cfg.Web.RequireIfMatch,
+		LowercaseEmails: cfg.Auth.LowercaseEmails,
+		Mail:            sender,
+		VerifyURL:       cfg.Mail.VerifyURL,
+		RequireVerified: cfg.Auth.RequireVerified,
+		ResetURL:        cfg.Mail.ResetURL,
+		PasswordPolicy:  policy,
+		Hasher:          hasher,
+		AccountLockout: lockout.Policy{
+			Threshold: cfg.Lockout.AccountThreshold,
+			Base:      cfg.Lockout.Base,
+			Max:       cfg.Lockout.Max,
+			Reset:     cfg.Lockout.Reset,
+		},
+		AddrLockout: lockout.Policy{
+			Threshold: cfg.Lockout.AddrThreshold,
+			Base:      cfg.Lockout.Base,
+			Max:       cfg.Lockout.Max,
+			Reset:     cfg.Lockout.Reset,
+		},
+		Background: mails,
+		ResetEmailLimit: lockout.Policy{
+			Threshold: cfg.Lockout.ResetEmailThreshold,
+			Base:      cfg.Lockout.Base,
+			Max:       cfg.Lockout.Max,
+			Reset:     cfg.Lockout.Reset,
+		},
+		ResetAddrLimit: lockout.Policy{
+			Threshold: cfg.Lockout.ResetAddrThreshold,
+			Base:      cfg.Lockout.Base,
+			Max:       cfg.Lockout.Max,
+			Reset:     cfg.Lockout.Reset,
+		},
+		StatusCacheTTL: cfg.Auth.StatusCacheTTL,
+		Policy:         policies,
+		OutboxStore:    outboxes,
+		WebhookStore:   webhooks,
+		Relay:          relay,
 	})
 
+	// Start the background workers once the API subscribed to the relay,
+	// they run until the service shuts down.
+	workersCtx, stopWorkers := context.WithCancel(context.Background())
+	var workers sync.WaitGroup
+	workers.Add(3)
+	go func() {
+		defer workers.Done()
+		relay.Run(workersCtx)
+	}()
+	go func() {
+		defer workers.Done()
+		whSender.Run(workersCtx)
+	}()
+	go func() {
+		defer workers.Done()
+		mails.Run(workersCtx)
+	}()
+	defer func() {
+		stopWorkers()
+		workers.Wait()
+	}()
+
 	// Construct a server to service the requests against the mux.
 	api := http.Server{
 		Addr:         cfg.Web.APIHost,
@@ -0,0 +1,81 @@
+package commands
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/AgeroFlynn/crud/internal/foundation/bloom"
+	"os"
+)
+
+// falsePositiveRate is the share of passwords wrongly reported as breached.
+const falsePositiveRate = 0.001
+
+// Breached builds the bloom filter of breached passwords from a list with
+// one password per line.
+func Breached(listFile string, filterFile string) error {
+	if listFile == "" || filterFile == "" {
+		fmt.Println("help: breached <list file> <filter file>")
+		return ErrHelp
+	}
+
+	// The filter is sized up front, count the passwords first.
+	count, err := countLines(listFile)
+	if err != nil {
+		return err
+	}
+
+	list, err := os.Open(listFile)
+	if err != nil {
+		return fmt.Errorf("opening list: %w", err)
+	}
+	defer list.Close()
+
+	filter := bloom.New(count, falsePositiveRate)
+
+	scanner := bufio.NewScanner(list)
+	for scanner.Scan() {
+		if password := scanner.Text(); password != "" {
+			filter.Add(password)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("reading list: %w", err)
+	}
+
+	out, err := os.Create(filterFile)
+	if err != nil {
+		return fmt.Errorf("creating filter file: %w", err)
+	}
+	defer out.Close()
+
+	w := bufio.NewWriter(out)
+	if _, err := filter.WriteTo(w); err != nil {
+		return fmt.Errorf("writing filter: %w", err)
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("writing filter: %w", err)
+	}
+
+	fmt.Printf("filter of %d passwords written to %s\n", count, filterFile)
+	return nil
+}
+
+// countLines counts the lines of the file.
+func countLines(file string) (int, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return 0, fmt.Errorf("opening list: %w", err)
+	}
+	defer f.Close()
+
+	var count int
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		count++
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, fmt.Errorf("reading list: %w", err)
+	}
+
+	return count, nil
+}
@@ -3,8 +3,10 @@ package commands
 import (
 	"context"
 	"fmt"
+	"github.com/AgeroFlynn/crud/internal/buisness/repository/store/role"
 	"github.com/AgeroFlynn/crud/internal/buisness/repository/store/user"
 	"github.com/AgeroFlynn/crud/internal/buisness/sys/auth"
+	"github.com/AgeroFlynn/crud/internal/buisness/sys/passwd"
 	"github.com/AgeroFlynn/crud/internal/foundation/database"
 	"github.com/AgeroFlynn/crud/internal/foundation/keystore"
 	"github.com/go-pg/pg/v10"
@@ -16,7 +18,7 @@ import (
 )
 
 // GenToken generates a JWT for the specified user.
-func GenToken(log *zap.SugaredLogger, opt *pg.Options, userID string, kid string) error {
+func GenToken(log *zap.SugaredLogger, opt *pg.Options, hasher passwd.Hasher, userID string, kid string) error {
 	if userID == "" || kid == "" {
 		fmt.Println("help: gentoken <user_id> <kid>")
 		return ErrHelp
@@ -31,19 +33,16 @@ func GenToken(log *zap.SugaredLogger, opt *pg.Options, userID string, kid string
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	store := user.NewStore(log, db)
+	store := user.NewStore(log, db, hasher)
 
-	// The call to retrieve a user requires an Admin role by the caller.
-	claims := auth.Claims{
-		RegisteredClaims: jwt.RegisteredClaims{
-			Subject: userID,
-		},
-		Roles: []string{auth.RoleAdmin},
+	usr, err := store.FindByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("retrieve user: %w", err)
 	}
 
-	usr, err := store.FindByID(ctx, claims, userID)
+	permissions, err := role.NewStore(log, db).Resolve(ctx, usr.Roles)
 	if err != nil {
-		return fmt.Errorf("retrieve user: %w", err)
+		return fmt.Errorf("resolve permissions: %w", err)
 	}
 
 	// Construct a key store based on the key files stored in
@@ -63,7 +62,8 @@ func GenToken(log *zap.SugaredLogger, opt *pg.Options, userID string, kid string
 
 	// Generating a token requires defining a set of claims. In this applications
 	// case, we only care about defining the subject and the user in question and
-	// the roles they have on the database. This token will expire in a year.
+	// the roles they have on the database together with the permissions the
+	// roles grant. This token will expire in a year.
 	//
 	// iss (issuer): Issuer of the JWT
 	// sub (subject): Subject of the JWT (the user)
@@ -72,14 +72,17 @@ func GenToken(log *zap.SugaredLogger, opt *pg.Options, userID string, kid string
 	// nbf (not before time): Time before which the JWT must not be accepted for processing
 	// iat (issued at time): Time at which the JWT was issued; can be used to determine age of the JWT
 	// jti (JWT ID): Unique identifier; can be used to prevent the JWT from being replayed (allows a token to be used only once)
-	claims = auth.Claims{
+	issued := time.Now().UTC()
+	claims := auth.Claims{
 		RegisteredClaims: jwt.RegisteredClaims{
 			Issuer:    "service project",
 			Subject:   usr.ID,
-			ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(8760 * time.Hour)),
-			IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
+			ExpiresAt: jwt.NewNumericDate(issued.Add(8760 * time.Hour)),
+			IssuedAt:  jwt.NewNumericDate(issued),
 		},
-		Roles: usr.Roles,
+		IssuedAtMicro: issued.UnixMicro(),
+		Roles:         usr.Roles,
+		Permissions:   permissions,
 	}
 
 	// This will generate a JWT with the claims embedded in them. The database
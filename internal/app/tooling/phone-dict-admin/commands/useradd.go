@@ -6,6 +6,8 @@ import (
 	"github.com/AgeroFlynn/crud/internal/buisness/core/dto"
 	"github.com/AgeroFlynn/crud/internal/buisness/repository/store/user"
 	"github.com/AgeroFlynn/crud/internal/buisness/sys/auth"
+	"github.com/AgeroFlynn/crud/internal/buisness/sys/passwd"
+	"github.com/AgeroFlynn/crud/internal/buisness/sys/validate"
 	"github.com/AgeroFlynn/crud/internal/foundation/database"
 	"github.com/go-pg/pg/v10"
 	"go.uber.org/zap"
@@ -13,7 +15,7 @@ import (
 )
 
 // UserAdd adds new users into the database.
-func UserAdd(log *zap.SugaredLogger, opt *pg.Options, name, email, password string) error {
+func UserAdd(log *zap.SugaredLogger, opt *pg.Options, hasher passwd.Hasher, name, email, password string) error {
 	if name == "" || email == "" || password == "" {
 		fmt.Println("help: useradd <name> <email> <password>")
 		return ErrHelp
@@ -28,11 +30,11 @@ func UserAdd(log *zap.SugaredLogger, opt *pg.Options, name, email, password stri
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	store := user.NewStore(log, db)
+	store := user.NewStore(log, db, hasher)
 
 	nu := dto.NewUser{
 		Name:            name,
-		Email:           email,
+		Email:           validate.NormalizeEmail(email, false),
 		Password:        password,
 		PasswordConfirm: password,
 		Roles:           []string{auth.RoleAdmin, auth.RoleUser},
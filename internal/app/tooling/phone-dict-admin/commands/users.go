@@ -4,7 +4,10 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"github.com/AgeroFlynn/crud/internal/buisness/core/dto"
+	"github.com/AgeroFlynn/crud/internal/buisness/repository/order"
 	"github.com/AgeroFlynn/crud/internal/buisness/repository/store/user"
+	"github.com/AgeroFlynn/crud/internal/buisness/sys/passwd"
 	"github.com/AgeroFlynn/crud/internal/foundation/database"
 	"github.com/go-pg/pg/v10"
 	"go.uber.org/zap"
@@ -13,7 +16,7 @@ import (
 )
 
 // Users retrieves all users from the database.
-func Users(log *zap.SugaredLogger, opt *pg.Options) error {
+func Users(log *zap.SugaredLogger, opt *pg.Options, hasher passwd.Hasher) error {
 	db, err := database.NewPostgresConnection(opt)
 	if err != nil {
 		return fmt.Errorf("connect database: %w", err)
@@ -23,11 +26,26 @@ func Users(log *zap.SugaredLogger, opt *pg.Options) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	store := user.NewStore(log, db)
+	store := user.NewStore(log, db, hasher)
 
-	users, err := store.FindAll(ctx)
-	if err != nil {
-		return fmt.Errorf("retrieve users: %w", err)
+	// The users are read a page at a time in the order of their ids.
+	const pageSize = 500
+	orderBy := order.NewBy("user_id", order.ASC)
+
+	var users []dto.User
+	var key *order.Key
+	for {
+		usrs, more, err := store.QueryAfter(ctx, dto.UserFilter{}, orderBy, key, false, pageSize)
+		if err != nil {
+			return fmt.Errorf("retrieve users: %w", err)
+		}
+		users = append(users, usrs...)
+
+		if !more {
+			break
+		}
+		last := usrs[len(usrs)-1]
+		key = &order.Key{Value: last.ID, ID: last.ID}
 	}
 
 	return json.NewEncoder(os.Stdout).Encode(users)
@@ -6,6 +6,7 @@ import (
 	"expvar"
 	"fmt"
 	"github.com/AgeroFlynn/crud/internal/app/tooling/phone-dict-admin/commands"
+	"github.com/AgeroFlynn/crud/internal/buisness/sys/passwd"
 	"github.com/AgeroFlynn/crud/internal/foundation/config"
 	"github.com/AgeroFlynn/crud/internal/foundation/logger"
 	"github.com/ardanlabs/conf/v3"
@@ -64,12 +65,21 @@ func run(log *zap.SugaredLogger) error {
 		PoolSize: cfg.DB.PoolSize,
 	}
 
-	return processCommands(cfg.Args, log, dbOptions)
+	hasher, err := passwd.NewHasher(cfg.Password.Hasher, passwd.Argon2Params{
+		Time:    cfg.Password.Argon2Time,
+		Memory:  cfg.Password.Argon2Memory,
+		Threads: cfg.Password.Argon2Threads,
+	}, cfg.Password.BcryptCost)
+	if err != nil {
+		return fmt.Errorf("constructing hasher: %w", err)
+	}
+
+	return processCommands(cfg.Args, log, dbOptions, hasher)
 }
 
 // processCommands handles the execution of the commands specified on
 // the command line.
-func processCommands(args conf.Args, log *zap.SugaredLogger, dbOptions *pg.Options) error {
+func processCommands(args conf.Args, log *zap.SugaredLogger, dbOptions *pg.Options, hasher passwd.Hasher) error {
 	switch args.Num(0) {
 	case "migrate":
 		if err := commands.Migrate(dbOptions); err != nil {
@@ -90,12 +100,12 @@ func processCommands(args conf.Args, log *zap.SugaredLogger, dbOptions *pg.Optio
 		name := args.Num(1)
 		email := args.Num(2)
 		password := args.Num(3)
-		if err := commands.UserAdd(log, dbOptions, name, email, password); err != nil {
+		if err := commands.UserAdd(log, dbOptions, hasher, name, email, password); err != nil {
 			return fmt.Errorf("adding user: %w", err)
 		}
 
 	case "users":
-		if err := commands.Users(log, dbOptions); err != nil {
+		if err := commands.Users(log, dbOptions, hasher); err != nil {
 			return fmt.Errorf("getting users: %w", err)
 		}
 
@@ -107,10 +117,17 @@ func processCommands(args conf.Args, log *zap.SugaredLogger, dbOptions *pg.Optio
 	case "gentoken":
 		userID := args.Num(1)
 		kid := args.Num(2)
-		if err := commands.GenToken(log, dbOptions, userID, kid); err != nil {
+		if err := commands.GenToken(log, dbOptions, hasher, userID, kid); err != nil {
 			return fmt.Errorf("generating token: %w", err)
 		}
 
+	case "breached":
+		listFile := args.Num(1)
+		filterFile := args.Num(2)
+		if err := commands.Breached(listFile, filterFile); err != nil {
+			return fmt.Errorf("building breached passwords filter: %w", err)
+		}
+
 	default:
 		fmt.Println("migrate: create the schema in the database")
 		fmt.Println("seed: add data to the database")
@@ -119,6 +136,7 @@ func processCommands(args conf.Args, log *zap.SugaredLogger, dbOptions *pg.Optio
 		fmt.Println("users: get a list of users from the database")
 		fmt.Println("genkey: generate a set of private/public key files")
 		fmt.Println("gentoken: generate a JWT for a user with claims")
+		fmt.Println("breached: build the breached passwords filter from a list")
 		fmt.Println("provide a command to get more help.")
 		return commands.ErrHelp
 	}
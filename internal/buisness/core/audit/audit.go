@@ -0,0 +1,90 @@
+// Package audit provides the core business API for the append-only audit log
+// of the operations users take.
+package audit
+
+import (
+	"context"
+	"fmt"
+	"github.com/AgeroFlynn/crud/internal/buisness/core/dto"
+	"github.com/AgeroFlynn/crud/internal/foundation/web"
+	"go.uber.org/zap"
+	"net"
+	"time"
+)
+
+// AuditStorer is the behavior required by the core to append and retrieve
+// audit entries. It is implemented by the database store and by the
+// in-memory store used in tests. There is no way to modify entries.
+type AuditStorer interface {
+	Append(ctx context.Context, entry dto.AuditEntry) (dto.AuditEntry, error)
+	Query(ctx context.Context, filter dto.AuditFilter, offset int, limit int) ([]dto.AuditEntry, int, error)
+}
+
+// Core manages the set of API's for audit log access.
+type Core struct {
+	log   *zap.SugaredLogger
+	audit AuditStorer
+}
+
+// NewCore constructs a core for audit log api access.
+func NewCore(log *zap.SugaredLogger, storer AuditStorer) Core {
+	return Core{
+		log:   log,
+		audit: storer,
+	}
+}
+
+// Record appends the entry to the audit log. The trace id and the address of
+// the client are taken from the request of the context.
+func (c Core) Record(ctx context.Context, na dto.NewAuditEntry) error {
+
+	// PERFORM PRE BUSINESS OPERATIONS
+
+	entry := dto.AuditEntry{
+		ActorID:     na.ActorID,
+		Action:      na.Action,
+		TargetType:  na.TargetType,
+		TargetID:    na.TargetID,
+		Changes:     na.Changes,
+		TraceID:     web.GetTraceID(ctx),
+		Outcome:     na.Outcome,
+		Reason:      na.Reason,
+		DateCreated: time.Now().UTC(),
+	}
+	if v, err := web.GetValues(ctx); err == nil {
+		entry.RemoteAddr = host(v.RemoteAddr)
+	}
+
+	if _, err := c.audit.Append(ctx, entry); err != nil {
+		return fmt.Errorf("record: %w", err)
+	}
+
+	// PERFORM POST BUSINESS OPERATIONS
+
+	return nil
+}
+
+// Query retrieves a page of entries matching the filter, newest first,
+// together with the total number of matching entries.
+func (c Core) Query(ctx context.Context, filter dto.AuditFilter, offset int, limit int) ([]dto.AuditEntry, int, error) {
+
+	// PERFORM PRE BUSINESS OPERATIONS
+
+	entries, total, err := c.audit.Query(ctx, filter, offset, limit)
+	if err != nil {
+		return nil, 0, fmt.Errorf("query: %w", err)
+	}
+
+	// PERFORM POST BUSINESS OPERATIONS
+
+	return entries, total, nil
+}
+
+// host returns the address without the port.
+func host(addr string) string {
+	h, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	return h
+}
@@ -0,0 +1,59 @@
+package dto
+
+import "time"
+
+// Set of outcomes of the operations recorded in the audit log.
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+	OutcomeDenied  = "denied"
+)
+
+// AuditEntry records who did what to which target and how it went. Entries
+// are never modified once they are appended.
+type AuditEntry struct {
+	ID          string
+	ActorID     string
+	Action      string
+	TargetType  string
+	TargetID    string
+	Changes     []AuditChange
+	TraceID     string
+	RemoteAddr  string
+	Outcome     string
+	Reason      string
+	DateCreated time.Time
+}
+
+// NewAuditEntry contains the information needed to append an AuditEntry.
+// The trace id, the remote address and the time are taken from the request.
+type NewAuditEntry struct {
+	ActorID    string
+	Action     string
+	TargetType string
+	TargetID   string
+	Changes    []AuditChange
+	Outcome    string
+	Reason     string
+}
+
+// AuditChange is the value of a field of the target before and after the
+// operation. Values of secret fields are left out.
+type AuditChange struct {
+	Field  string `json:"field"`
+	Before string `json:"before,omitempty"`
+	After  string `json:"after,omitempty"`
+}
+
+// AuditFilter holds the available fields the audit log can be filtered on.
+// All fields match exactly, the dates bound the time the entries were
+// appended.
+type AuditFilter struct {
+	ActorID   *string
+	Action    *string
+	TargetID  *string
+	Outcome   *string
+	TraceID   *string
+	StartDate *time.Time
+	EndDate   *time.Time
+}
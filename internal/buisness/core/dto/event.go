@@ -0,0 +1,67 @@
+package dto
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Set of domain events emitted by the cores. RoleChanged is only emitted for
+// changes of the roles themselves, assigning roles to a user changes the user
+// and is emitted as UserUpdated with a roles change. EntryChanged is emitted
+// next to UserUpdated when the name, the email or the status of a user, as
+// shown in the directory, change.
+const (
+	EventUserCreated  = "UserCreated"
+	EventUserUpdated  = "UserUpdated"
+	EventUserDeleted  = "UserDeleted"
+	EventRoleChanged  = "RoleChanged"
+	EventEntryChanged = "EntryChanged"
+)
+
+// Set of changes a RoleChanged event describes.
+const (
+	RoleCreated = "created"
+	RoleUpdated = "updated"
+	RoleDeleted = "deleted"
+)
+
+// Event is a domain event held in the outbox until it is dispatched to the
+// subscribers. Payload is the JSON encoded payload of the event type.
+type Event struct {
+	ID             string
+	Type           string
+	AggregateID    string
+	Payload        json.RawMessage
+	Attempts       int
+	LastError      string
+	DateCreated    time.Time
+	DateAvailable  time.Time
+	DateDispatched *time.Time
+	DateDead       *time.Time
+}
+
+// NewEvent contains the information needed to publish an Event. Payload is
+// encoded to JSON.
+type NewEvent struct {
+	Type        string
+	AggregateID string
+	Payload     any
+}
+
+// UserEvent is the payload of the UserCreated, UserUpdated, UserDeleted and
+// EntryChanged events. Changes lists what an update changed.
+type UserEvent struct {
+	UserID  string        `json:"user_id"`
+	Name    string        `json:"name,omitempty"`
+	Email   string        `json:"email,omitempty"`
+	Roles   []string      `json:"roles,omitempty"`
+	Status  string        `json:"status,omitempty"`
+	Changes []AuditChange `json:"changes,omitempty"`
+}
+
+// RoleEvent is the payload of the RoleChanged event.
+type RoleEvent struct {
+	Role        string   `json:"role"`
+	Change      string   `json:"change"`
+	Permissions []string `json:"permissions,omitempty"`
+}
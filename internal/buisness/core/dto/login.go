@@ -0,0 +1,36 @@
+package dto
+
+import "time"
+
+// Set of reasons of failed logins.
+const (
+	LoginInvalidCredentials = "invalid_credentials"
+	LoginLockedOut          = "locked_out"
+	LoginInactive           = "inactive"
+	LoginUnverified         = "unverified"
+)
+
+// Login records an attempt to log in and how it went. The outcome is either
+// OutcomeSuccess or OutcomeFailure, UserID is empty when the email doesn't
+// belong to any user.
+type Login struct {
+	ID          string
+	UserID      string
+	Email       string
+	RemoteAddr  string
+	UserAgent   string
+	Outcome     string
+	Reason      string
+	DateCreated time.Time
+}
+
+// LoginFilter holds the available fields a query of the login history can
+// be filtered on.
+type LoginFilter struct {
+	UserID     *string
+	Email      *string
+	Outcome    *string
+	RemoteAddr *string
+	StartDate  *time.Time
+	EndDate    *time.Time
+}
@@ -0,0 +1,33 @@
+package dto
+
+import "time"
+
+// Role is a named set of permissions that can be assigned to users.
+type Role struct {
+	Name        string
+	Description string
+	Permissions []string
+	DateCreated time.Time
+	DateUpdated time.Time
+}
+
+// NewRole contains information needed to create a new Role.
+type NewRole struct {
+	Name        string
+	Description string
+	Permissions []string
+}
+
+// UpdateRole defines what information may be provided to modify an existing
+// Role. All fields are optional, Permissions replaces all the permissions of
+// the role when it is set.
+type UpdateRole struct {
+	Description *string
+	Permissions []string
+}
+
+// Permission is an action roles can grant.
+type Permission struct {
+	Name        string
+	Description string
+}
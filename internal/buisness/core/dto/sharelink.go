@@ -0,0 +1,38 @@
+package dto
+
+import "time"
+
+// ShareLink represents a signed link that exposes a user's contact card.
+type ShareLink struct {
+	ID          string
+	UserID      string
+	SingleUse   bool
+	UseCount    int
+	ExpiresAt   time.Time
+	DateRevoked *time.Time
+	DateCreated time.Time
+}
+
+// NewShareLink contains information needed to create a new ShareLink.
+type NewShareLink struct {
+	UserID    string
+	SingleUse bool
+	ExpiresIn time.Duration
+}
+
+// ShareLinkAccess represents a single attempt to open a ShareLink.
+type ShareLinkAccess struct {
+	ID           string
+	ShareLinkID  string
+	RemoteAddr   string
+	UserAgent    string
+	Granted      bool
+	DateAccessed time.Time
+}
+
+// PublicCard is the restricted view of a user exposed through a ShareLink.
+type PublicCard struct {
+	ID    string
+	Name  string
+	Email string
+}
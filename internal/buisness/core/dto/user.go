@@ -5,15 +5,30 @@ import (
 	"time"
 )
 
+// Set of statuses of user accounts. Only active users can log in.
+const (
+	StatusActive    = "ACTIVE"
+	StatusPending   = "PENDING"
+	StatusSuspended = "SUSPENDED"
+	StatusDisabled  = "DISABLED"
+)
+
 // User represents an individual user.
 type User struct {
-	ID           string
-	Name         string
-	Email        string
-	Roles        pq.StringArray
-	PasswordHash []byte
-	DateCreated  time.Time
-	DateUpdated  time.Time
+	ID                string
+	Name              string
+	Email             string
+	Roles             pq.StringArray
+	PasswordHash      []byte
+	DateCreated       time.Time
+	DateUpdated       time.Time
+	DateVerified      *time.Time
+	DateTokensRevoked *time.Time
+	Status            string
+	StatusReason      string
+	DateStatusChanged *time.Time
+	LastLoginAt       *time.Time
+	Version           int
 }
 
 // NewUser contains information needed to create a new User.
@@ -23,6 +38,7 @@ type NewUser struct {
 	Roles           []string
 	Password        string
 	PasswordConfirm string
+	Status          string
 }
 
 // UpdateUser defines what information may be provided to modify an existing
@@ -38,3 +54,35 @@ type UpdateUser struct {
 	Password        *string
 	PasswordConfirm *string
 }
+
+// Set of operations a batch of user modifications can hold.
+const (
+	BatchCreate   = "create"
+	BatchUpdate   = "update"
+	BatchDelete   = "delete"
+	BatchSetRoles = "set_roles"
+)
+
+// BatchOperation is one operation of a batch of user modifications. Create
+// uses NewUser, update and set_roles use UpdateUser. Every operation but
+// create acts on the user with UserID, only if it still has Version when
+// that is provided.
+type BatchOperation struct {
+	Op         string
+	UserID     string
+	NewUser    NewUser
+	UpdateUser UpdateUser
+	Version    *int
+}
+
+// UserFilter holds the available fields a query can be filtered on. Name and
+// Email match partially and case-insensitively, Role has to be one of the
+// roles of the user and Status has to be the status of the user.
+type UserFilter struct {
+	Name             *string
+	Email            *string
+	Role             *string
+	StartCreatedDate *time.Time
+	EndCreatedDate   *time.Time
+	Status           *string
+}
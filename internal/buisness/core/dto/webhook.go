@@ -0,0 +1,66 @@
+package dto
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// WebhookAllEvents subscribes a webhook to every event type.
+const WebhookAllEvents = "*"
+
+// Set of statuses of a webhook delivery.
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+// Webhook subscribes a downstream system to domain events. Events lists the
+// event types it receives, or WebhookAllEvents for all of them. Secret signs
+// the deliveries.
+type Webhook struct {
+	ID          string
+	URL         string
+	Secret      string
+	Events      []string
+	Description string
+	Active      bool
+	DateCreated time.Time
+	DateUpdated time.Time
+}
+
+// NewWebhook contains information needed to create a new Webhook. A secret is
+// generated when none is provided.
+type NewWebhook struct {
+	URL         string
+	Secret      string
+	Events      []string
+	Description string
+}
+
+// UpdateWebhook defines what information may be provided to modify an
+// existing Webhook. All fields are optional.
+type UpdateWebhook struct {
+	URL         *string
+	Secret      *string
+	Events      []string
+	Description *string
+	Active      *bool
+}
+
+// Delivery is an event queued for a webhook together with the outcome of the
+// attempts to send it. Payload is the body sent to the receiver.
+type Delivery struct {
+	ID              string
+	WebhookID       string
+	EventID         string
+	EventType       string
+	Payload         json.RawMessage
+	Status          string
+	Attempts        int
+	ResponseCode    int
+	LastError       string
+	DateCreated     time.Time
+	DateNextAttempt time.Time
+	DateDelivered   *time.Time
+}
@@ -0,0 +1,79 @@
+// Package outbox provides the core business API for publishing domain events
+// through the transactional outbox and relaying them to the subscribers.
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/AgeroFlynn/crud/internal/buisness/core/dto"
+	"go.uber.org/zap"
+	"time"
+)
+
+// OutboxStorer is the behavior required by the core to hold the events until
+// they are dispatched. It is implemented by the database store and by the
+// in-memory store used in tests. Add has to join the transaction of the
+// context.
+type OutboxStorer interface {
+	Add(ctx context.Context, events ...dto.Event) error
+	Claim(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]dto.Event, error)
+	Dispatched(ctx context.Context, eventID string, now time.Time) error
+	Retry(ctx context.Context, eventID string, attempts int, lastErr string, next time.Time) error
+	Dead(ctx context.Context, eventID string, attempts int, lastErr string, now time.Time) error
+	QueryDead(ctx context.Context, offset int, limit int) ([]dto.Event, error)
+}
+
+// Core manages the set of API's for publishing events.
+type Core struct {
+	log    *zap.SugaredLogger
+	outbox OutboxStorer
+}
+
+// NewCore constructs a core for publishing events.
+func NewCore(log *zap.SugaredLogger, storer OutboxStorer) Core {
+	return Core{
+		log:    log,
+		outbox: storer,
+	}
+}
+
+// Publish writes the event to the outbox. Called within a transaction the
+// event is only published when the transaction is committed.
+func (c Core) Publish(ctx context.Context, ne dto.NewEvent) error {
+
+	// PERFORM PRE BUSINESS OPERATIONS
+
+	payload, err := json.Marshal(ne.Payload)
+	if err != nil {
+		return fmt.Errorf("publish: encoding %s payload: %w", ne.Type, err)
+	}
+
+	now := time.Now().UTC()
+	e := dto.Event{
+		Type:          ne.Type,
+		AggregateID:   ne.AggregateID,
+		Payload:       payload,
+		DateCreated:   now,
+		DateAvailable: now,
+	}
+
+	if err := c.outbox.Add(ctx, e); err != nil {
+		return fmt.Errorf("publish: %w", err)
+	}
+
+	// PERFORM POST BUSINESS OPERATIONS
+
+	return nil
+}
+
+// QueryDead retrieves a page of the events that couldn't be dispatched,
+// newest first.
+func (c Core) QueryDead(ctx context.Context, offset int, limit int) ([]dto.Event, error) {
+	events, err := c.outbox.QueryDead(ctx, offset, limit)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+
+	return events, nil
+}
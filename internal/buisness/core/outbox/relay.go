@@ -0,0 +1,200 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"github.com/AgeroFlynn/crud/internal/buisness/core/dto"
+	"go.uber.org/zap"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AllEvents subscribes to every event type.
+const AllEvents = "*"
+
+// Set of defaults of the relay configuration.
+const (
+	DefaultInterval    = time.Second
+	DefaultBatchSize   = 100
+	DefaultMaxAttempts = 10
+	DefaultBackoff     = time.Second
+	DefaultMaxBackoff  = 5 * time.Minute
+	DefaultLease       = time.Minute
+)
+
+// Subscriber handles an event. Events are delivered at least once, a
+// subscriber sees an event again when it or another subscriber of the event
+// failed, so it has to be idempotent.
+type Subscriber func(ctx context.Context, event dto.Event) error
+
+// RelayConfig holds the settings of the relay. Zero values are replaced by
+// the defaults.
+type RelayConfig struct {
+
+	// Interval is how long the relay waits before looking for events again
+	// once the outbox is drained.
+	Interval time.Duration
+
+	// BatchSize is the most events claimed at once.
+	BatchSize int
+
+	// MaxAttempts is how many times an event is dispatched before it goes to
+	// the dead letters.
+	MaxAttempts int
+
+	// Backoff is the wait before the first retry, it doubles with every
+	// attempt up to MaxBackoff.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+
+	// Lease is how long claimed events are hidden from other relays. Events
+	// of a relay that stops while dispatching are dispatched again after it.
+	Lease time.Duration
+}
+
+// subscription is a named subscriber.
+type subscription struct {
+	name string
+	fn   Subscriber
+}
+
+// Relay dispatches the events of the outbox to the subscribers in the order
+// they were published. Several relays can share an outbox.
+type Relay struct {
+	log    *zap.SugaredLogger
+	outbox OutboxStorer
+	cfg    RelayConfig
+
+	mu   sync.RWMutex
+	subs map[string][]subscription
+}
+
+// NewRelay constructs a relay for the outbox.
+func NewRelay(log *zap.SugaredLogger, storer OutboxStorer, cfg RelayConfig) *Relay {
+	if cfg.Interval <= 0 {
+		cfg.Interval = DefaultInterval
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = DefaultBatchSize
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = DefaultMaxAttempts
+	}
+	if cfg.Backoff <= 0 {
+		cfg.Backoff = DefaultBackoff
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = DefaultMaxBackoff
+	}
+	if cfg.Lease <= 0 {
+		cfg.Lease = DefaultLease
+	}
+
+	return &Relay{
+		log:    log,
+		outbox: storer,
+		cfg:    cfg,
+		subs:   make(map[string][]subscription),
+	}
+}
+
+// Subscribe registers the subscriber for the event type, or for every event
+// with AllEvents. The name identifies the subscriber in errors and logs.
+func (r *Relay) Subscribe(eventType string, name string, fn Subscriber) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.subs[eventType] = append(r.subs[eventType], subscription{name: name, fn: fn})
+}
+
+// Run dispatches events until the context is canceled.
+func (r *Relay) Run(ctx context.Context) {
+	for {
+		n, err := r.Dispatch(ctx, time.Now().UTC())
+		if err != nil {
+			r.log.Errorw("outbox relay", "ERROR", err)
+		}
+
+		// Keep going while there is a backlog.
+		if err == nil && n == r.cfg.BatchSize && ctx.Err() == nil {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(r.cfg.Interval):
+		}
+	}
+}
+
+// Dispatch claims a batch of events and hands them to their subscribers. It
+// returns the number of events claimed.
+func (r *Relay) Dispatch(ctx context.Context, now time.Time) (int, error) {
+	events, err := r.outbox.Claim(ctx, now, r.cfg.Lease, r.cfg.BatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("dispatch: %w", err)
+	}
+
+	for _, e := range events {
+		if err := r.dispatch(ctx, e, now); err != nil {
+			return len(events), fmt.Errorf("dispatch: eventID[%s]: %w", e.ID, err)
+		}
+	}
+
+	return len(events), nil
+}
+
+// dispatch hands the event to its subscribers and records the outcome. The
+// event is retried with an exponential backoff when a subscriber fails and
+// goes to the dead letters after MaxAttempts.
+func (r *Relay) dispatch(ctx context.Context, e dto.Event, now time.Time) error {
+	r.mu.RLock()
+	subs := append(append([]subscription(nil), r.subs[e.Type]...), r.subs[AllEvents]...)
+	r.mu.RUnlock()
+
+	var failures []string
+	for _, sub := range subs {
+		if err := deliver(ctx, sub.fn, e); err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %s", sub.name, err))
+		}
+	}
+
+	if len(failures) == 0 {
+		return r.outbox.Dispatched(ctx, e.ID, now)
+	}
+
+	attempts := e.Attempts + 1
+	lastErr := strings.Join(failures, "; ")
+
+	if attempts >= r.cfg.MaxAttempts {
+		r.log.Errorw("outbox relay", "status", "dead letter", "eventID", e.ID, "type", e.Type, "attempts", attempts, "ERROR", lastErr)
+		return r.outbox.Dead(ctx, e.ID, attempts, lastErr, now)
+	}
+
+	return r.outbox.Retry(ctx, e.ID, attempts, lastErr, now.Add(r.backoff(attempts)))
+}
+
+// backoff returns the wait before the next attempt after the attempts.
+func (r *Relay) backoff(attempts int) time.Duration {
+	d := r.cfg.Backoff
+	for i := 1; i < attempts && d < r.cfg.MaxBackoff; i++ {
+		d *= 2
+	}
+	if d > r.cfg.MaxBackoff {
+		d = r.cfg.MaxBackoff
+	}
+	return d
+}
+
+// deliver calls the subscriber, a panic counts as a failure.
+func deliver(ctx context.Context, fn Subscriber, e dto.Event) (err error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			err = fmt.Errorf("panic: %v", rec)
+		}
+	}()
+
+	return fn(ctx, e)
+}
@@ -0,0 +1,294 @@
+// Package role provides the core business API for managing the roles users
+// are assigned and the permissions the roles grant.
+package role
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/AgeroFlynn/crud/internal/buisness/core/dto"
+	"github.com/AgeroFlynn/crud/internal/buisness/sys/auth"
+	"github.com/AgeroFlynn/crud/internal/buisness/sys/validate"
+	"go.uber.org/zap"
+	"time"
+)
+
+// ErrBuiltin occurs when a built-in role is deleted or the admin role is
+// modified, the service relies on them.
+var ErrBuiltin = errors.New("built-in roles can't be changed")
+
+// RoleStorer is the behavior required by the core to persist and retrieve
+// roles. It is implemented by the database store and by the in-memory store
+// used in tests.
+type RoleStorer interface {
+	Create(ctx context.Context, nr dto.NewRole, now time.Time) (dto.Role, error)
+	Update(ctx context.Context, name string, ur dto.UpdateRole, now time.Time) error
+	Delete(ctx context.Context, name string) error
+	Query(ctx context.Context) ([]dto.Role, error)
+	FindByName(ctx context.Context, name string) (dto.Role, error)
+	Exists(ctx context.Context, names []string) ([]string, error)
+	Permissions(ctx context.Context) ([]dto.Permission, error)
+	Resolve(ctx context.Context, roles []string) ([]string, error)
+	WithinTran(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// Publisher is the behavior required by the core to emit domain events.
+type Publisher interface {
+	Publish(ctx context.Context, ne dto.NewEvent) error
+}
+
+// Core manages the set of API's for role access.
+type Core struct {
+	log    *zap.SugaredLogger
+	role   RoleStorer
+	events Publisher
+}
+
+// NewCore constructs a core for role api access. Changes of roles are
+// published as RoleChanged events through events unless it is nil.
+func NewCore(log *zap.SugaredLogger, storer RoleStorer, events Publisher) Core {
+	return Core{
+		log:    log,
+		role:   storer,
+		events: events,
+	}
+}
+
+// Create inserts a new role granting the permissions.
+func (c Core) Create(ctx context.Context, nr dto.NewRole, now time.Time) (dto.Role, error) {
+
+	// PERFORM PRE BUSINESS OPERATIONS
+
+	if err := c.checkPermissions(ctx, nr.Permissions); err != nil {
+		return dto.Role{}, fmt.Errorf("create: %w", err)
+	}
+
+	var role dto.Role
+	err := c.withEvents(ctx, func(ctx context.Context) (dto.NewEvent, error) {
+		var err error
+		if role, err = c.role.Create(ctx, nr, now); err != nil {
+			return dto.NewEvent{}, err
+		}
+		return roleChanged(role.Name, dto.RoleCreated, role.Permissions), nil
+	})
+	if err != nil {
+		return dto.Role{}, fmt.Errorf("create: %w", err)
+	}
+
+	// PERFORM POST BUSINESS OPERATIONS
+
+	return role, nil
+}
+
+// Update modifies a role. The admin role always grants every permission and
+// can't be modified.
+func (c Core) Update(ctx context.Context, name string, ur dto.UpdateRole, now time.Time) error {
+
+	// PERFORM PRE BUSINESS OPERATIONS
+
+	if name == auth.RoleAdmin {
+		return ErrBuiltin
+	}
+
+	if err := c.checkPermissions(ctx, ur.Permissions); err != nil {
+		return fmt.Errorf("update: %w", err)
+	}
+
+	err := c.withEvents(ctx, func(ctx context.Context) (dto.NewEvent, error) {
+		if err := c.role.Update(ctx, name, ur, now); err != nil {
+			return dto.NewEvent{}, err
+		}
+
+		role, err := c.role.FindByName(ctx, name)
+		if err != nil {
+			return dto.NewEvent{}, err
+		}
+		return roleChanged(name, dto.RoleUpdated, role.Permissions), nil
+	})
+	if err != nil {
+		return fmt.Errorf("update: %w", err)
+	}
+
+	// PERFORM POST BUSINESS OPERATIONS
+
+	return nil
+}
+
+// Delete removes a role. Users keep the name of the role but it doesn't
+// grant them anything anymore. The built-in roles can't be deleted.
+func (c Core) Delete(ctx context.Context, name string) error {
+
+	// PERFORM PRE BUSINESS OPERATIONS
+
+	if name == auth.RoleAdmin || name == auth.RoleUser {
+		return ErrBuiltin
+	}
+
+	err := c.withEvents(ctx, func(ctx context.Context) (dto.NewEvent, error) {
+		if err := c.role.Delete(ctx, name); err != nil {
+			return dto.NewEvent{}, err
+		}
+		return roleChanged(name, dto.RoleDeleted, nil), nil
+	})
+	if err != nil {
+		return fmt.Errorf("delete: %w", err)
+	}
+
+	// PERFORM POST BUSINESS OPERATIONS
+
+	return nil
+}
+
+// Query retrieves all roles.
+func (c Core) Query(ctx context.Context) ([]dto.Role, error) {
+
+	// PERFORM PRE BUSINESS OPERATIONS
+
+	roles, err := c.role.Query(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+
+	// PERFORM POST BUSINESS OPERATIONS
+
+	return roles, nil
+}
+
+// FindByName gets the specified role.
+func (c Core) FindByName(ctx context.Context, name string) (dto.Role, error) {
+
+	// PERFORM PRE BUSINESS OPERATIONS
+
+	role, err := c.role.FindByName(ctx, name)
+	if err != nil {
+		return dto.Role{}, fmt.Errorf("query: %w", err)
+	}
+
+	// PERFORM POST BUSINESS OPERATIONS
+
+	return role, nil
+}
+
+// Permissions retrieves all permissions roles can grant.
+func (c Core) Permissions(ctx context.Context) ([]dto.Permission, error) {
+
+	// PERFORM PRE BUSINESS OPERATIONS
+
+	permissions, err := c.role.Permissions(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+
+	// PERFORM POST BUSINESS OPERATIONS
+
+	return permissions, nil
+}
+
+// Resolve returns the permissions granted by the roles.
+func (c Core) Resolve(ctx context.Context, roles []string) ([]string, error) {
+	permissions, err := c.role.Resolve(ctx, roles)
+	if err != nil {
+		return nil, fmt.Errorf("resolve: %w", err)
+	}
+
+	return permissions, nil
+}
+
+// CheckRoles fails with a field error on roles when one of the roles
+// doesn't exist.
+func (c Core) CheckRoles(ctx context.Context, roles []string) error {
+	found, err := c.role.Exists(ctx, roles)
+	if err != nil {
+		return fmt.Errorf("check roles: %w", err)
+	}
+
+	if unknown := missing(roles, found); len(unknown) > 0 {
+		msg := fmt.Sprintf("unknown roles %v", unknown)
+		return validate.FieldErrors{{Field: "roles", Error: msg}}
+	}
+
+	return nil
+}
+
+// checkPermissions fails with a field error on permissions when one of the
+// permissions doesn't exist.
+func (c Core) checkPermissions(ctx context.Context, permissions []string) error {
+	if len(permissions) == 0 {
+		return nil
+	}
+
+	all, err := c.role.Permissions(ctx)
+	if err != nil {
+		return fmt.Errorf("check permissions: %w", err)
+	}
+
+	known := make([]string, len(all))
+	for i, p := range all {
+		known[i] = p.Name
+	}
+
+	if unknown := missing(permissions, known); len(unknown) > 0 {
+		msg := fmt.Sprintf("unknown permissions %v", unknown)
+		return validate.FieldErrors{{Field: "permissions", Error: msg}}
+	}
+
+	return nil
+}
+
+// missing returns the names that aren't in found.
+func missing(names []string, found []string) []string {
+	exists := make(map[string]bool, len(found))
+	for _, name := range found {
+		exists[name] = true
+	}
+
+	var unknown []string
+	for _, name := range names {
+		if !exists[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	return unknown
+}
+
+// withEvents runs fn and publishes the event it returns in the same
+// transaction, the change is rolled back when it can't be published. Errors
+// of fn are returned as they are.
+func (c Core) withEvents(ctx context.Context, fn func(ctx context.Context) (dto.NewEvent, error)) error {
+	if c.events == nil {
+		_, err := fn(ctx)
+		return err
+	}
+
+	var opErr error
+	err := c.role.WithinTran(ctx, func(ctx context.Context) error {
+		ne, err := fn(ctx)
+		if err != nil {
+			opErr = err
+			return err
+		}
+
+		if err := c.events.Publish(ctx, ne); err != nil {
+			return fmt.Errorf("publishing %s: %w", ne.Type, err)
+		}
+		return nil
+	})
+	if opErr != nil {
+		return opErr
+	}
+
+	return err
+}
+
+// roleChanged describes the change of the role.
+func roleChanged(name string, change string, permissions []string) dto.NewEvent {
+	return dto.NewEvent{
+		Type:        dto.EventRoleChanged,
+		AggregateID: name,
+		Payload: dto.RoleEvent{
+			Role:        name,
+			Change:      change,
+			Permissions: permissions,
+		},
+	}
+}
@@ -0,0 +1,195 @@
+// Package sharelink provides the core business API for sharing contact cards
+// with people that don't have an account through signed, expiring links.
+package sharelink
+
+import (
+	"context"
+	"fmt"
+	"github.com/AgeroFlynn/crud/internal/buisness/core/dto"
+	"github.com/AgeroFlynn/crud/internal/buisness/repository/store/sharelink"
+	"github.com/AgeroFlynn/crud/internal/buisness/sys/auth"
+	"github.com/golang-jwt/jwt/v4"
+	"go.uber.org/zap"
+	"time"
+)
+
+// Audience is the audience of the tokens embedded in share links.
+const Audience = "share"
+
+// DefaultExpiresIn is used when a share link is created without a lifetime.
+const DefaultExpiresIn = 24 * time.Hour
+
+// UserStorer declares the behavior share links need from the user store to
+// look up the owners of the cards.
+type UserStorer interface {
+	FindByID(ctx context.Context, userID string) (dto.User, error)
+}
+
+// ShareStorer is the behavior required by the core to persist the share
+// links and their accesses. It is implemented by the database store and by
+// the in-memory store used in tests. Links that can't be opened anymore are
+// refused by Consume with sharelink.ErrUnavailable.
+type ShareStorer interface {
+	Create(ctx context.Context, nsl dto.NewShareLink, now time.Time) (dto.ShareLink, error)
+	Revoke(ctx context.Context, claims auth.Claims, linkID string, now time.Time) error
+	Consume(ctx context.Context, linkID string, now time.Time) (dto.ShareLink, error)
+	RecordAccess(ctx context.Context, access dto.ShareLinkAccess) error
+	FindByUser(ctx context.Context, claims auth.Claims, userID string) ([]dto.ShareLink, error)
+	FindByID(ctx context.Context, claims auth.Claims, linkID string) (dto.ShareLink, error)
+	FindAccesses(ctx context.Context, claims auth.Claims, linkID string) ([]dto.ShareLinkAccess, error)
+}
+
+// Core manages the set of API's for share link access.
+type Core struct {
+	log       *zap.SugaredLogger
+	auth      *auth.Auth
+	sharelink ShareStorer
+	user      UserStorer
+}
+
+// NewCore constructs a core for share link api access.
+func NewCore(log *zap.SugaredLogger, storer ShareStorer, a *auth.Auth, users UserStorer) Core {
+	return Core{
+		log:       log,
+		auth:      a,
+		sharelink: storer,
+		user:      users,
+	}
+}
+
+// Create mints a new share link for the user and returns it together with
+// the signed token that has to be presented to open it.
+func (c Core) Create(ctx context.Context, nsl dto.NewShareLink, now time.Time) (dto.ShareLink, string, error) {
+
+	// PERFORM PRE BUSINESS OPERATIONS
+
+	if nsl.ExpiresIn == 0 {
+		nsl.ExpiresIn = DefaultExpiresIn
+	}
+
+	sl, err := c.sharelink.Create(ctx, nsl, now)
+	if err != nil {
+		return dto.ShareLink{}, "", fmt.Errorf("create: %w", err)
+	}
+
+	claims := jwt.RegisteredClaims{
+		ID:        sl.ID,
+		Subject:   sl.UserID,
+		ExpiresAt: jwt.NewNumericDate(sl.ExpiresAt),
+		IssuedAt:  jwt.NewNumericDate(now),
+	}
+
+	token, err := c.auth.GenerateScopedToken(Audience, claims)
+	if err != nil {
+		return dto.ShareLink{}, "", fmt.Errorf("generating token: %w", err)
+	}
+
+	// PERFORM POST BUSINESS OPERATIONS
+
+	return sl, token, nil
+}
+
+// Open validates the token of a share link, records the access and returns
+// the restricted view of the shared contact card. Every attempt is recorded,
+// the ones with a token that isn't valid too. Only the expired tokens tell
+// which link they were for, the others are recorded without a link. The
+// cards of owners who aren't active can't be opened.
+func (c Core) Open(ctx context.Context, token string, access dto.ShareLinkAccess, now time.Time) (dto.PublicCard, error) {
+
+	// PERFORM PRE BUSINESS OPERATIONS
+
+	claims, err := c.auth.ValidateScopedToken(Audience, token)
+	access.ShareLinkID = claims.ID
+	access.DateAccessed = now
+
+	if err != nil {
+		c.recordAccess(ctx, access, false)
+		return dto.PublicCard{}, fmt.Errorf("validating token: %w", sharelink.ErrUnavailable)
+	}
+
+	// The owner is checked first so a single use link isn't used up by an
+	// attempt which is refused.
+	usr, err := c.user.FindByID(ctx, claims.Subject)
+	if err != nil {
+		c.recordAccess(ctx, access, false)
+		return dto.PublicCard{}, fmt.Errorf("query: %w", err)
+	}
+	if usr.Status != dto.StatusActive {
+		c.recordAccess(ctx, access, false)
+		return dto.PublicCard{}, fmt.Errorf("open: owner is %s: %w", usr.Status, sharelink.ErrUnavailable)
+	}
+
+	if _, err := c.sharelink.Consume(ctx, claims.ID, now); err != nil {
+		c.recordAccess(ctx, access, false)
+		return dto.PublicCard{}, fmt.Errorf("open: %w", err)
+	}
+
+	c.recordAccess(ctx, access, true)
+
+	// PERFORM POST BUSINESS OPERATIONS
+
+	card := dto.PublicCard{
+		ID:    usr.ID,
+		Name:  usr.Name,
+		Email: usr.Email,
+	}
+
+	return card, nil
+}
+
+// Revoke makes the share link unusable.
+func (c Core) Revoke(ctx context.Context, claims auth.Claims, linkID string, now time.Time) error {
+
+	// PERFORM PRE BUSINESS OPERATIONS
+
+	if err := c.sharelink.Revoke(ctx, claims, linkID, now); err != nil {
+		return fmt.Errorf("revoke: %w", err)
+	}
+
+	// PERFORM POST BUSINESS OPERATIONS
+
+	return nil
+}
+
+// FindByUser retrieves the share links owned by the specified user.
+func (c Core) FindByUser(ctx context.Context, claims auth.Claims, userID string) ([]dto.ShareLink, error) {
+
+	// PERFORM PRE BUSINESS OPERATIONS
+
+	links, err := c.sharelink.FindByUser(ctx, claims, userID)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+
+	// PERFORM POST BUSINESS OPERATIONS
+
+	return links, nil
+}
+
+// FindAccesses retrieves the access log of the specified share link.
+func (c Core) FindAccesses(ctx context.Context, claims auth.Claims, linkID string) ([]dto.ShareLinkAccess, error) {
+
+	// PERFORM PRE BUSINESS OPERATIONS
+
+	accesses, err := c.sharelink.FindAccesses(ctx, claims, linkID)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+
+	// PERFORM POST BUSINESS OPERATIONS
+
+	return accesses, nil
+}
+
+// recordAccess stores the access attempt. A failure to store it is logged
+// but doesn't fail the request.
+func (c Core) recordAccess(ctx context.Context, access dto.ShareLinkAccess, granted bool) {
+	access.Granted = granted
+
+	c.log.Infow("share link access", "linkid", access.ShareLinkID, "remoteaddr", access.RemoteAddr,
+		"useragent", access.UserAgent, "granted", granted)
+
+	if err := c.sharelink.RecordAccess(ctx, access); err != nil {
+		c.log.Errorw("share link access", "linkid", access.ShareLinkID, "ERROR", err)
+	}
+}
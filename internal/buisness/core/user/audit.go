@@ -0,0 +1,86 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"github.com/AgeroFlynn/crud/internal/buisness/core/dto"
+	"github.com/AgeroFlynn/crud/internal/buisness/sys/policy"
+	"strings"
+)
+
+// Set of actions recorded in the audit log besides the ones the policies
+// are asked about.
+const (
+	ActionCreate         = "create"
+	ActionChangePassword = "change_password"
+	ActionResetPassword  = "reset_password"
+)
+
+// Auditor is the behavior required by the core to record the operations on
+// users in the audit log.
+type Auditor interface {
+	Record(ctx context.Context, na dto.NewAuditEntry) error
+}
+
+// record appends the outcome of the actor taking the action on the user to
+// the audit log. Denials are skipped, they are recorded when the policies
+// decide. Successful changes are recorded by withChanges, in the transaction
+// of the change.
+func (c Core) record(ctx context.Context, actorID string, action string, userID string, changes []dto.AuditChange, err error) {
+	na := dto.NewAuditEntry{
+		ActorID:    actorID,
+		Action:     action,
+		TargetType: ResourceUser,
+		TargetID:   userID,
+		Changes:    changes,
+		Outcome:    dto.OutcomeSuccess,
+	}
+
+	if err != nil {
+		var denied *policy.DeniedError
+		if errors.As(err, &denied) {
+			return
+		}
+		na.Changes = nil
+		na.Outcome = dto.OutcomeFailure
+		na.Reason = err.Error()
+	}
+
+	c.audit(ctx, na)
+}
+
+// audit appends the entry to the audit log when there is one. Failures and
+// denials are kept even when the transaction of the context is rolled back,
+// failing to record them is only logged.
+func (c Core) audit(ctx context.Context, na dto.NewAuditEntry) {
+	if c.cfg.Audit == nil {
+		return
+	}
+
+	if err := c.cfg.Audit.Record(ctx, na); err != nil {
+		c.log.Errorw("audit", "action", na.Action, "userID", na.TargetID, "ERROR", err)
+	}
+}
+
+// diff returns the fields of the user that differ between before and after.
+// Changed passwords are reported without their hashes.
+func diff(before dto.User, after dto.User) []dto.AuditChange {
+	var changes []dto.AuditChange
+	add := func(field string, b string, a string) {
+		if b != a {
+			changes = append(changes, dto.AuditChange{Field: field, Before: b, After: a})
+		}
+	}
+
+	add("name", before.Name, after.Name)
+	add("email", before.Email, after.Email)
+	add("roles", strings.Join(before.Roles, ","), strings.Join(after.Roles, ","))
+	add("status", before.Status, after.Status)
+	add("status_reason", before.StatusReason, after.StatusReason)
+
+	if string(before.PasswordHash) != string(after.PasswordHash) {
+		changes = append(changes, dto.AuditChange{Field: "password"})
+	}
+
+	return changes
+}
@@ -0,0 +1,94 @@
+package user
+
+import (
+	"context"
+	"fmt"
+	"github.com/AgeroFlynn/crud/internal/buisness/core/dto"
+	"github.com/AgeroFlynn/crud/internal/buisness/sys/auth"
+	"github.com/AgeroFlynn/crud/internal/buisness/sys/policy"
+	"github.com/AgeroFlynn/crud/internal/foundation/database"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// ResourceUser is the type of the resources the policies see for users.
+const ResourceUser = "user"
+
+// Set of actions the core asks the policies about before acting on a user.
+// Creating users is asked about as ActionCreate.
+const (
+	ActionList      = "list"
+	ActionDirectory = "directory"
+	ActionRead      = "read"
+	ActionUpdate    = "update"
+	ActionDelete    = "delete"
+	ActionSetStatus = "set_status"
+	ActionUnlock    = "unlock"
+	ActionExport    = "export"
+
+	// ActionAssignRoles is asked about on top of the other actions when
+	// roles are given to a user, so managing accounts doesn't suffice to
+	// escalate privileges.
+	ActionAssignRoles = "assign_roles"
+)
+
+// authorize evaluates the policies for the claims taking the action on the
+// user. Denials are recorded in the audit log and reported as
+// database.ErrForbidden carrying the decision of the policies.
+func (c Core) authorize(ctx context.Context, claims auth.Claims, action string, userID string, attrs map[string]string) error {
+	d := c.policy.Evaluate(userRequest(claims, action, userID, attrs))
+	if !d.Allowed {
+		c.audit(ctx, dto.NewAuditEntry{
+			ActorID:    claims.Subject,
+			Action:     action,
+			TargetType: ResourceUser,
+			TargetID:   userID,
+			Outcome:    dto.OutcomeDenied,
+			Reason:     d.Reason,
+		})
+		return &policy.DeniedError{Decision: d, Err: database.ErrForbidden}
+	}
+
+	return nil
+}
+
+// Explain dry-runs the policies for the user with subjectID taking the
+// action on the user with userID. The subject gets the permissions of their
+// roles, like they would when logging in.
+func (c Core) Explain(ctx context.Context, subjectID string, action string, userID string, attrs map[string]string) (policy.Explanation, error) {
+	sub, err := c.user.FindByID(ctx, subjectID)
+	if err != nil {
+		return policy.Explanation{}, fmt.Errorf("explain: subject: %w", err)
+	}
+
+	claims := auth.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{Subject: sub.ID},
+		Roles:            sub.Roles,
+	}
+	if c.cfg.Roles != nil {
+		claims.Permissions, err = c.cfg.Roles.Resolve(ctx, sub.Roles)
+		if err != nil {
+			return policy.Explanation{}, fmt.Errorf("explain: %w", err)
+		}
+	}
+
+	return c.policy.Explain(userRequest(claims, action, userID, attrs)), nil
+}
+
+// userRequest describes the claims taking the action on the user to the
+// policies. Users own themselves.
+func userRequest(claims auth.Claims, action string, userID string, attrs map[string]string) policy.Request {
+	return policy.Request{
+		Subject: policy.Subject{
+			ID:          claims.Subject,
+			Roles:       claims.Roles,
+			Permissions: claims.Permissions,
+		},
+		Action: action,
+		Resource: policy.Resource{
+			Type:    ResourceUser,
+			ID:      userID,
+			OwnerID: userID,
+		},
+		Attributes: attrs,
+	}
+}
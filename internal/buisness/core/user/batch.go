@@ -0,0 +1,144 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/AgeroFlynn/crud/internal/buisness/core/dto"
+	"github.com/AgeroFlynn/crud/internal/buisness/sys/auth"
+	"time"
+)
+
+// ErrBatchAborted is reported for the operations of an all-or-nothing batch
+// that weren't applied because another operation failed.
+var ErrBatchAborted = errors.New("not applied, another operation of the batch failed")
+
+// BatchResult is the outcome of an operation of a batch. UserID is the id of
+// the user the operation acted on, the id of the new user for creates.
+type BatchResult struct {
+	UserID string
+	Err    error
+}
+
+// Batch applies the operations in order and reports the outcome of every
+// operation. When atomic is set either all operations are applied or none,
+// the batch stops at the first failure. Otherwise every operation that
+// succeeds is applied. Verification mails are only sent for what was
+// applied. Applied operations are recorded in the audit log together with
+// their changes, the failed and the aborted ones once the batch is over. The
+// returned error is set when the batch as a whole failed.
+func (c Core) Batch(ctx context.Context, claims auth.Claims, ops []dto.BatchOperation, atomic bool, now time.Time) ([]BatchResult, error) {
+	results := make([]BatchResult, len(ops))
+
+	// The post business operations wait for the batch to be applied.
+	var after []func(ctx context.Context)
+
+	apply := func(ctx context.Context, i int) error {
+		op := ops[i]
+		results[i].UserID = op.UserID
+
+		switch op.Op {
+		case dto.BatchCreate:
+			usr, err := c.create(ctx, claims, op.NewUser, now)
+			if err != nil {
+				return err
+			}
+			results[i].UserID = usr.ID
+			after = append(after, func(ctx context.Context) {
+				c.sendVerification(ctx, usr, now)
+			})
+
+		case dto.BatchUpdate, dto.BatchSetRoles:
+			if err := c.update(ctx, claims, op.UserID, op.UpdateUser, op.Version, now); err != nil {
+				return err
+			}
+			if op.UpdateUser.Roles != nil || op.UpdateUser.Password != nil {
+				after = append(after, func(ctx context.Context) {
+					c.status.forget(op.UserID)
+				})
+			}
+			if op.UpdateUser.Email != nil {
+				after = append(after, func(ctx context.Context) {
+					if err := c.reverify(ctx, op.UserID, now); err != nil {
+						c.log.Errorw("batch", "userID", op.UserID, "ERROR", err)
+					}
+				})
+			}
+
+		case dto.BatchDelete:
+			if err := c.delete(ctx, claims, op.UserID, op.Version); err != nil {
+				return err
+			}
+			after = append(after, func(ctx context.Context) {
+				c.status.forget(op.UserID)
+			})
+
+		default:
+			return fmt.Errorf("unknown batch operation %q", op.Op)
+		}
+
+		return nil
+	}
+
+	if !atomic {
+		for i := range ops {
+			results[i].Err = apply(ctx, i)
+		}
+	} else {
+		failed := -1
+		var opErr error
+
+		err := c.user.WithinTran(ctx, func(ctx context.Context) error {
+			for i := range ops {
+				if opErr = apply(ctx, i); opErr != nil {
+					failed = i
+					return opErr
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			if failed < 0 {
+				return nil, fmt.Errorf("batch: %w", err)
+			}
+
+			// Nothing was applied, the ids of created users are gone too.
+			for i := range results {
+				results[i] = BatchResult{UserID: ops[i].UserID, Err: ErrBatchAborted}
+			}
+			results[failed].Err = opErr
+
+			c.recordBatch(ctx, claims, ops, results)
+
+			return results, nil
+		}
+	}
+
+	c.recordBatch(ctx, claims, ops, results)
+
+	for _, fn := range after {
+		fn(ctx)
+	}
+
+	return results, nil
+}
+
+// recordBatch records the operations of the batch that failed in the audit
+// log, the applied ones were recorded with their changes.
+func (c Core) recordBatch(ctx context.Context, claims auth.Claims, ops []dto.BatchOperation, results []BatchResult) {
+	for i, op := range ops {
+		if results[i].Err == nil {
+			continue
+		}
+
+		action := ActionUpdate
+		switch op.Op {
+		case dto.BatchCreate:
+			action = ActionCreate
+		case dto.BatchDelete:
+			action = ActionDelete
+		}
+
+		c.record(ctx, claims.Subject, action, results[i].UserID, nil, results[i].Err)
+	}
+}
@@ -0,0 +1,142 @@
+package user
+
+import (
+	"context"
+	"fmt"
+	"github.com/AgeroFlynn/crud/internal/buisness/core/dto"
+)
+
+// Publisher is the behavior required by the core to emit domain events.
+type Publisher interface {
+	Publish(ctx context.Context, ne dto.NewEvent) error
+}
+
+// applied is what an operation did to a user: the changes recorded in the
+// audit log and the events published about them.
+type applied struct {
+	userID  string
+	changes []dto.AuditChange
+	events  []dto.NewEvent
+}
+
+// withChanges runs fn, records the actor taking the action in the audit log
+// and publishes the events fn returns in the same transaction. The change
+// is rolled back when it can't be recorded or the events can't be
+// published. Errors of fn are returned as they are, recording them is left
+// to the caller.
+func (c Core) withChanges(ctx context.Context, actorID string, action string, fn func(ctx context.Context) (applied, error)) error {
+	if !c.tracking() {
+		_, err := fn(ctx)
+		return err
+	}
+
+	var opErr error
+	err := c.user.WithinTran(ctx, func(ctx context.Context) error {
+		a, err := fn(ctx)
+		if err != nil {
+			opErr = err
+			return err
+		}
+
+		if c.cfg.Audit != nil {
+			na := dto.NewAuditEntry{
+				ActorID:    actorID,
+				Action:     action,
+				TargetType: ResourceUser,
+				TargetID:   a.userID,
+				Changes:    a.changes,
+				Outcome:    dto.OutcomeSuccess,
+			}
+			if err := c.cfg.Audit.Record(ctx, na); err != nil {
+				return fmt.Errorf("recording %s: %w", action, err)
+			}
+		}
+
+		if c.cfg.Events != nil {
+			for _, ne := range a.events {
+				if err := c.cfg.Events.Publish(ctx, ne); err != nil {
+					return fmt.Errorf("publishing %s: %w", ne.Type, err)
+				}
+			}
+		}
+		return nil
+	})
+	if opErr != nil {
+		return opErr
+	}
+
+	return err
+}
+
+// tracking reports whether the changes of operations are needed, for the
+// audit log or for the events.
+func (c Core) tracking() bool {
+	return c.cfg.Audit != nil || c.cfg.Events != nil
+}
+
+// userCreated describes the new user.
+func userCreated(usr dto.User) dto.NewEvent {
+	return dto.NewEvent{
+		Type:        dto.EventUserCreated,
+		AggregateID: usr.ID,
+		Payload: dto.UserEvent{
+			UserID: usr.ID,
+			Name:   usr.Name,
+			Email:  usr.Email,
+			Roles:  usr.Roles,
+			Status: usr.Status,
+		},
+	}
+}
+
+// userUpdated describes the changes of the user.
+func userUpdated(userID string, changes []dto.AuditChange) dto.NewEvent {
+	return dto.NewEvent{
+		Type:        dto.EventUserUpdated,
+		AggregateID: userID,
+		Payload: dto.UserEvent{
+			UserID:  userID,
+			Changes: changes,
+		},
+	}
+}
+
+// updateEvents describes the changes of the user. Changes of the name, the
+// email or the status are shown in the directory, they are emitted as an
+// EntryChanged event too.
+func updateEvents(userID string, changes []dto.AuditChange) []dto.NewEvent {
+	events := []dto.NewEvent{userUpdated(userID, changes)}
+
+	var entry []dto.AuditChange
+	for _, ch := range changes {
+		switch ch.Field {
+		case "name", "email", "status":
+			entry = append(entry, ch)
+		}
+	}
+	if len(entry) > 0 {
+		events = append(events, dto.NewEvent{
+			Type:        dto.EventEntryChanged,
+			AggregateID: userID,
+			Payload: dto.UserEvent{
+				UserID:  userID,
+				Changes: entry,
+			},
+		})
+	}
+
+	return events
+}
+
+// userDeleted describes the removed user.
+func userDeleted(usr dto.User) dto.NewEvent {
+	return dto.NewEvent{
+		Type:        dto.EventUserDeleted,
+		AggregateID: usr.ID,
+		Payload: dto.UserEvent{
+			UserID: usr.ID,
+			Name:   usr.Name,
+			Email:  usr.Email,
+		},
+	}
+}
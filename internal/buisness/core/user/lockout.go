@@ -0,0 +1,107 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/AgeroFlynn/crud/internal/buisness/sys/auth"
+	"strings"
+	"time"
+)
+
+// ErrLocked is returned when logins are refused after too many failures.
+var ErrLocked = errors.New("too many failed logins")
+
+// LockedError is returned when logins or requests for reset links for an
+// email or from an address are locked out. It wraps ErrLocked, or
+// ErrThrottled for reset links.
+type LockedError struct {
+	Until time.Time
+
+	err error
+}
+
+// Error implements the error interface.
+func (e *LockedError) Error() string {
+	return e.Unwrap().Error()
+}
+
+// Unwrap returns the reason of the lockout so it can be checked with
+// errors.Is.
+func (e *LockedError) Unwrap() error {
+	if e.err == nil {
+		return ErrLocked
+	}
+	return e.err
+}
+
+// Unlock lifts the lockout of the user after failed logins.
+func (c Core) Unlock(ctx context.Context, claims auth.Claims, userID string) error {
+
+	// PERFORM PRE BUSINESS OPERATIONS
+
+	if err := c.authorize(ctx, claims, ActionUnlock, userID, nil); err != nil {
+		return fmt.Errorf("unlock: %w", err)
+	}
+
+	usr, err := c.user.FindByID(ctx, userID)
+	if err != nil {
+		c.record(ctx, claims.Subject, ActionUnlock, userID, nil, err)
+		return fmt.Errorf("unlock: %w", err)
+	}
+
+	// PERFORM POST BUSINESS OPERATIONS
+
+	c.clearLockout(usr.Email)
+	c.record(ctx, claims.Subject, ActionUnlock, userID, nil, nil)
+
+	return nil
+}
+
+// checkLockout fails when logins for the email or from the address are
+// locked out.
+func (c Core) checkLockout(email string, remoteAddr string, now time.Time) error {
+	if c.cfg.AccountLockout != nil {
+		if until, locked := c.cfg.AccountLockout.Locked(accountKey(email), now); locked {
+			return &LockedError{Until: until}
+		}
+	}
+
+	if c.cfg.AddrLockout != nil && remoteAddr != "" {
+		if until, locked := c.cfg.AddrLockout.Locked(remoteAddr, now); locked {
+			return &LockedError{Until: until}
+		}
+	}
+
+	return nil
+}
+
+// failLogin counts a failed login for the email and the address. Unknown
+// emails are counted too, a lockout must not tell whether a user exists.
+func (c Core) failLogin(email string, remoteAddr string, now time.Time) {
+	if c.cfg.AccountLockout != nil {
+		if until, locked := c.cfg.AccountLockout.Fail(accountKey(email), now); locked {
+			c.log.Infow("login locked", "email", email, "until", until)
+		}
+	}
+
+	if c.cfg.AddrLockout != nil && remoteAddr != "" {
+		if until, locked := c.cfg.AddrLockout.Fail(remoteAddr, now); locked {
+			c.log.Infow("login locked", "remoteAddr", remoteAddr, "until", until)
+		}
+	}
+}
+
+// clearLockout forgets the failed logins for the email. The failures of
+// addresses are kept, a valid account must not unlock an address.
+func (c Core) clearLockout(email string) {
+	if c.cfg.AccountLockout != nil {
+		c.cfg.AccountLockout.Clear(accountKey(email))
+	}
+}
+
+// accountKey is the key failures of an email are counted under. Emails
+// differing in case count as the same account.
+func accountKey(email string) string {
+	return strings.ToLower(email)
+}
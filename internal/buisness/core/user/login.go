@@ -0,0 +1,78 @@
+package user
+
+import (
+	"context"
+	"fmt"
+	"github.com/AgeroFlynn/crud/internal/buisness/core/dto"
+	"time"
+	"unicode/utf8"
+)
+
+// MaxUserAgent is the most bytes of the user agent of a login which are
+// kept.
+const MaxUserAgent = 512
+
+// LoginStorer is the behavior required by the core to keep the login
+// history. It is implemented by the database store and by the in-memory
+// store used in tests. Add attributes attempts without a user id to the user
+// the email belongs to.
+type LoginStorer interface {
+	Add(ctx context.Context, login dto.Login) (dto.Login, error)
+	Query(ctx context.Context, filter dto.LoginFilter, offset int, limit int) ([]dto.Login, int, error)
+}
+
+// QueryLogins retrieves a page of the login attempts matching the filter,
+// newest first, together with the total number of matching attempts.
+func (c Core) QueryLogins(ctx context.Context, filter dto.LoginFilter, offset int, limit int) ([]dto.Login, int, error) {
+	if c.cfg.Logins == nil {
+		return nil, 0, nil
+	}
+
+	logins, total, err := c.cfg.Logins.Query(ctx, filter, offset, limit)
+	if err != nil {
+		return nil, 0, fmt.Errorf("query: %w", err)
+	}
+
+	return logins, total, nil
+}
+
+// recordLogin adds the attempt to the login history, it failed unless the
+// reason is empty. The store attributes attempts without a user id to the
+// user the email belongs to, looking the user up here would make failed
+// logins of known emails slower than those of unknown ones. The login
+// already happened, failing to record it is only logged.
+func (c Core) recordLogin(ctx context.Context, now time.Time, userID string, email string, remoteAddr string, userAgent string, reason string) {
+	if c.cfg.Logins == nil {
+		return
+	}
+
+	userAgent = truncate(userAgent, MaxUserAgent)
+
+	login := dto.Login{
+		UserID:      userID,
+		Email:       email,
+		RemoteAddr:  remoteAddr,
+		UserAgent:   userAgent,
+		Outcome:     dto.OutcomeSuccess,
+		DateCreated: now,
+	}
+	if reason != "" {
+		login.Outcome = dto.OutcomeFailure
+		login.Reason = reason
+	}
+
+	if _, err := c.cfg.Logins.Add(ctx, login); err != nil {
+		c.log.Errorw("login history", "email", email, "ERROR", err)
+	}
+}
+
+// truncate cuts s to at most n bytes without splitting a character.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
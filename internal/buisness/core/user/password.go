@@ -0,0 +1,280 @@
+package user
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/AgeroFlynn/crud/internal/buisness/core/dto"
+	"github.com/AgeroFlynn/crud/internal/buisness/sys/auth"
+	"github.com/AgeroFlynn/crud/internal/buisness/sys/lockout"
+	"github.com/AgeroFlynn/crud/internal/buisness/sys/passwd"
+	"github.com/AgeroFlynn/crud/internal/buisness/sys/validate"
+	"github.com/AgeroFlynn/crud/internal/foundation/database"
+	"github.com/golang-jwt/jwt/v4"
+	"strings"
+	"time"
+)
+
+// ResetAudience is the audience of the tokens in password reset links.
+const ResetAudience = "reset"
+
+// DefaultResetExpiresIn is how long a password reset link can be used.
+const DefaultResetExpiresIn = time.Hour
+
+// ErrThrottled is returned when requests for reset links are refused after
+// too many of them.
+var ErrThrottled = errors.New("too many requests")
+
+// ForgotPassword mails a password reset link to the user with the email.
+// The link is mailed in the background so the call takes the same time
+// whether the user exists or not, failures are only logged. Nothing happens
+// when there is no such user, callers must not tell the difference to the
+// client. Requests beyond the limits of the email or the remote address are
+// refused with a LockedError wrapping ErrThrottled.
+func (c Core) ForgotPassword(ctx context.Context, email string, remoteAddr string, now time.Time) error {
+	if c.cfg.Mail == nil || c.cfg.Auth == nil || c.cfg.Background == nil {
+		return errors.New("password reset is not configured")
+	}
+
+	email = c.normalizeEmail(email)
+
+	if err := c.throttleReset(email, remoteAddr, now); err != nil {
+		return err
+	}
+
+	// When the queue is full the link isn't sent, the user can ask again.
+	err := c.cfg.Background.Submit(func(ctx context.Context) {
+		if err := c.mailReset(ctx, email, now); err != nil {
+			c.log.Errorw("forgot password", "ERROR", err)
+		}
+	})
+	if err != nil {
+		c.log.Errorw("forgot password", "email", email, "ERROR", err)
+	}
+
+	return nil
+}
+
+// throttleReset counts the request for a reset link for the email and the
+// address. It fails once either of them asked too often. Unknown emails are
+// counted too, the limit must not tell whether a user exists.
+func (c Core) throttleReset(email string, remoteAddr string, now time.Time) error {
+	limits := []struct {
+		counter *lockout.Counter
+		key     string
+	}{
+		{c.cfg.ResetEmailLimit, accountKey(email)},
+		{c.cfg.ResetAddrLimit, remoteAddr},
+	}
+
+	for _, l := range limits {
+		if l.counter == nil || l.key == "" {
+			continue
+		}
+		if until, locked := l.counter.Locked(l.key, now); locked {
+			return &LockedError{Until: until, err: ErrThrottled}
+		}
+	}
+
+	for _, l := range limits {
+		if l.counter != nil && l.key != "" {
+			l.counter.Fail(l.key, now)
+		}
+	}
+
+	return nil
+}
+
+// mailReset mails the reset link to the active user with the email.
+func (c Core) mailReset(ctx context.Context, email string, now time.Time) error {
+	usr, err := c.user.FindByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return nil
+		}
+		return fmt.Errorf("query: %w", err)
+	}
+
+	// Users who can't log in have no use for a new password.
+	if usr.Status != dto.StatusActive {
+		return nil
+	}
+
+	expiresIn := c.cfg.ResetExpiresIn
+	if expiresIn <= 0 {
+		expiresIn = DefaultResetExpiresIn
+	}
+
+	// The token is bound to the current password, once the password is
+	// replaced the token can't be used anymore.
+	claims := jwt.RegisteredClaims{
+		ID:        fingerprint(usr.PasswordHash),
+		Subject:   usr.ID,
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(expiresIn)),
+	}
+
+	if err := c.mailLink(ctx, usr, "reset.tmpl", c.cfg.ResetURL, ResetAudience, claims); err != nil {
+		return fmt.Errorf("mailing reset link: %w", err)
+	}
+
+	return nil
+}
+
+// ResetPassword replaces the password of the user the reset token was issued
+// for. All tokens issued to the user so far are revoked. Users who aren't
+// active, like disabled or suspended ones, fail with ErrInactive.
+func (c Core) ResetPassword(ctx context.Context, token string, password string, now time.Time) error {
+	if c.cfg.Auth == nil {
+		return ErrInvalidToken
+	}
+
+	claims, err := c.cfg.Auth.ValidateScopedToken(ResetAudience, token)
+	if err != nil {
+		return ErrInvalidToken
+	}
+
+	usr, err := c.user.FindByID(ctx, claims.Subject)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrNotFound), errors.Is(err, database.ErrInvalidID):
+			return ErrInvalidToken
+		default:
+			return fmt.Errorf("query: %w", err)
+		}
+	}
+
+	if subtle.ConstantTimeCompare([]byte(claims.ID), []byte(fingerprint(usr.PasswordHash))) != 1 {
+		return ErrInvalidToken
+	}
+
+	// A link mailed before the user was disabled or suspended must not bring
+	// the account back under someone's control.
+	if usr.Status != dto.StatusActive {
+		return fmt.Errorf("%w: %s", ErrInactive, strings.ToLower(usr.Status))
+	}
+
+	if err := c.checkPassword(ctx, usr, password); err != nil {
+		return fmt.Errorf("reset: %w", err)
+	}
+
+	// A concurrent reset with the same token changed the version.
+	if err := c.setPassword(ctx, usr.ID, ActionResetPassword, usr.ID, password, usr.Version, now); err != nil {
+		if errors.Is(err, database.ErrConflict) {
+			return ErrInvalidToken
+		}
+		return fmt.Errorf("reset: %w", err)
+	}
+
+	c.status.forget(usr.ID)
+
+	return nil
+}
+
+// ChangePassword replaces the password of the user the claims belong to
+// after checking the current password. All tokens issued to the user so far
+// are revoked, including the one of the request. Wrong current passwords
+// count as failed logins of the account.
+func (c Core) ChangePassword(ctx context.Context, claims auth.Claims, current string, password string, now time.Time) error {
+
+	// PERFORM PRE BUSINESS OPERATIONS
+
+	usr, err := c.user.FindByID(ctx, claims.Subject)
+	if err != nil {
+		return fmt.Errorf("change password: %w", err)
+	}
+
+	if err := c.checkLockout(usr.Email, "", now); err != nil {
+		return fmt.Errorf("change password: %w", err)
+	}
+
+	if err := passwd.Compare(usr.PasswordHash, current); err != nil {
+		if !errors.Is(err, passwd.ErrMismatch) {
+			return fmt.Errorf("change password: %w", err)
+		}
+		c.failLogin(usr.Email, "", now)
+		fields := validate.FieldErrors{{Field: "current_password", Error: "current password is wrong"}}
+		c.record(ctx, claims.Subject, ActionChangePassword, usr.ID, nil, fields)
+		return fields
+	}
+
+	if err := c.checkPassword(ctx, usr, password); err != nil {
+		return fmt.Errorf("change password: %w", err)
+	}
+
+	if err := c.setPassword(ctx, claims.Subject, ActionChangePassword, usr.ID, password, usr.Version, now); err != nil {
+		return fmt.Errorf("change password: %w", err)
+	}
+
+	// PERFORM POST BUSINESS OPERATIONS
+
+	c.status.forget(usr.ID)
+	c.clearLockout(usr.Email)
+
+	return nil
+}
+
+// setPassword replaces the password of the user if it still has the
+// version. The actor taking the action is recorded in the audit log.
+func (c Core) setPassword(ctx context.Context, actorID string, action string, userID string, password string, version int, now time.Time) error {
+	return c.withChanges(ctx, actorID, action, func(ctx context.Context) (applied, error) {
+		if err := c.user.SetPassword(ctx, userID, password, version, now); err != nil {
+			return applied{}, err
+		}
+		changes := []dto.AuditChange{{Field: "password"}}
+		return applied{
+			userID:  userID,
+			changes: changes,
+			events:  []dto.NewEvent{userUpdated(userID, changes)},
+		}, nil
+	})
+}
+
+// Revoked reports whether the tokens of the user the claims belong to were
+// revoked after the claims were issued. The claims of removed users and of
+// users who aren't active anymore are revoked as well. The status of users
+// is cached for StatusCacheTTL.
+func (c Core) Revoked(ctx context.Context, claims auth.Claims) (bool, error) {
+	now := time.Now()
+
+	us, cached := c.status.get(claims.Subject, now)
+	if !cached {
+		usr, err := c.user.FindByID(ctx, claims.Subject)
+		switch {
+		case errors.Is(err, database.ErrNotFound), errors.Is(err, database.ErrInvalidID):
+			us = userStatus{fetched: now}
+		case err != nil:
+			return false, fmt.Errorf("query: %w", err)
+		default:
+			us = userStatus{status: usr.Status, dateTokensRevoked: usr.DateTokensRevoked, fetched: now}
+		}
+		c.status.put(claims.Subject, us)
+	}
+
+	if us.status != dto.StatusActive {
+		return true, nil
+	}
+
+	if us.dateTokensRevoked == nil {
+		return false, nil
+	}
+
+	// Tokens carry the issue time to the microsecond, the revocation time is
+	// compared with the same precision. Tokens with whole seconds only count
+	// from the start of their second.
+	issued, ok := claims.Issued()
+	if !ok || issued.Before(us.dateTokensRevoked.Truncate(time.Microsecond)) {
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// fingerprint identifies a password hash without revealing it.
+func fingerprint(hash []byte) string {
+	sum := sha256.Sum256(hash)
+	return base64.RawURLEncoding.EncodeToString(sum[:16])
+}
@@ -0,0 +1,186 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/AgeroFlynn/crud/internal/buisness/core/dto"
+	"github.com/AgeroFlynn/crud/internal/buisness/sys/auth"
+	"github.com/AgeroFlynn/crud/internal/foundation/database"
+	"sync"
+	"time"
+)
+
+// Set of error variables for the status of users.
+var (
+	ErrInactive         = errors.New("account is not active")
+	ErrStatusTransition = errors.New("status change is not allowed")
+)
+
+// statusTransitions lists the statuses a user can be moved to from each
+// status. Pending users wait for an admin to activate them, suspended users
+// can be reinstated and disabled users only come back through an admin.
+var statusTransitions = map[string][]string{
+	dto.StatusPending:   {dto.StatusActive, dto.StatusDisabled},
+	dto.StatusActive:    {dto.StatusSuspended, dto.StatusDisabled},
+	dto.StatusSuspended: {dto.StatusActive, dto.StatusDisabled},
+	dto.StatusDisabled:  {dto.StatusActive},
+}
+
+// SetStatus moves the user to the status for the reason. The tokens issued
+// to the user so far are revoked. When version is provided the status is
+// only changed if the user still has that version. The policies decide who
+// may change a status, by default nobody can change their own.
+func (c Core) SetStatus(ctx context.Context, claims auth.Claims, userID string, status string, reason string, version *int, now time.Time) error {
+	usr, err := c.setStatus(ctx, claims, userID, status, reason, version, now)
+	if err != nil {
+		c.record(ctx, claims.Subject, ActionSetStatus, userID, nil, err)
+		return err
+	}
+
+	// PERFORM POST BUSINESS OPERATIONS
+
+	c.status.forget(usr.ID)
+
+	c.log.Infow("user status changed", "userID", usr.ID, "from", usr.Status, "to", status, "reason", reason, "by", claims.Subject)
+
+	return nil
+}
+
+// setStatus moves the user to the status without the post business
+// operations of SetStatus. It returns the user as it was.
+func (c Core) setStatus(ctx context.Context, claims auth.Claims, userID string, status string, reason string, version *int, now time.Time) (dto.User, error) {
+
+	// PERFORM PRE BUSINESS OPERATIONS
+
+	if err := c.authorize(ctx, claims, ActionSetStatus, userID, map[string]string{"status": status}); err != nil {
+		return dto.User{}, fmt.Errorf("set status: %w", err)
+	}
+
+	usr, err := c.user.FindByID(ctx, userID)
+	if err != nil {
+		return dto.User{}, fmt.Errorf("set status: %w", err)
+	}
+
+	if version != nil && *version != usr.Version {
+		return dto.User{}, fmt.Errorf("set status: %w", database.ErrConflict)
+	}
+
+	if !canTransition(usr.Status, status) {
+		return dto.User{}, fmt.Errorf("%w: from %s to %s", ErrStatusTransition, usr.Status, status)
+	}
+
+	after := usr
+	after.Status = status
+	after.StatusReason = reason
+	changes := diff(usr, after)
+
+	err = c.withChanges(ctx, claims.Subject, ActionSetStatus, func(ctx context.Context) (applied, error) {
+		if err := c.user.SetStatus(ctx, usr.ID, status, reason, usr.Version, now); err != nil {
+			return applied{}, err
+		}
+		return applied{
+			userID:  usr.ID,
+			changes: changes,
+			events:  updateEvents(usr.ID, changes),
+		}, nil
+	})
+	if err != nil {
+		return dto.User{}, fmt.Errorf("set status: %w", err)
+	}
+
+	return usr, nil
+}
+
+// canTransition reports whether a user can be moved between the statuses.
+func canTransition(from string, to string) bool {
+	for _, status := range statusTransitions[from] {
+		if status == to {
+			return true
+		}
+	}
+	return false
+}
+
+// =============================================================================
+
+// userStatus is what the revocation check needs to know about a user.
+type userStatus struct {
+	status            string
+	dateTokensRevoked *time.Time
+	fetched           time.Time
+}
+
+// statusCache keeps the status of users for a while so not every request
+// has to look up its user. A nil cache caches nothing.
+type statusCache struct {
+	ttl time.Duration
+
+	mu        sync.Mutex
+	entries   map[string]userStatus
+	lastSweep time.Time
+}
+
+// newStatusCache constructs a cache keeping the status of users for the ttl,
+// none when the ttl isn't positive.
+func newStatusCache(ttl time.Duration) *statusCache {
+	if ttl <= 0 {
+		return nil
+	}
+
+	return &statusCache{
+		ttl:     ttl,
+		entries: make(map[string]userStatus),
+	}
+}
+
+// get returns the status of the user when it was cached less than the ttl
+// ago.
+func (sc *statusCache) get(userID string, now time.Time) (userStatus, bool) {
+	if sc == nil {
+		return userStatus{}, false
+	}
+
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	us, exists := sc.entries[userID]
+	if !exists || now.Sub(us.fetched) >= sc.ttl {
+		return userStatus{}, false
+	}
+
+	return us, true
+}
+
+// put caches the status of the user. Expired entries are dropped once per
+// ttl so users that are never seen again don't pile up.
+func (sc *statusCache) put(userID string, us userStatus) {
+	if sc == nil {
+		return
+	}
+
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	if us.fetched.Sub(sc.lastSweep) >= sc.ttl {
+		sc.lastSweep = us.fetched
+		for id, e := range sc.entries {
+			if us.fetched.Sub(e.fetched) >= sc.ttl {
+				delete(sc.entries, id)
+			}
+		}
+	}
+	sc.entries[userID] = us
+}
+
+// forget drops the cached status of the user after it changed.
+func (sc *statusCache) forget(userID string) {
+	if sc == nil {
+		return
+	}
+
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	delete(sc.entries, userID)
+}
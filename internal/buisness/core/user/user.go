@@ -5,85 +5,510 @@ package user
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/AgeroFlynn/crud/internal/buisness/core/dto"
-	"github.com/AgeroFlynn/crud/internal/buisness/repository/store/user"
+	"github.com/AgeroFlynn/crud/internal/buisness/repository/order"
 	"github.com/AgeroFlynn/crud/internal/buisness/sys/auth"
-	"github.com/go-pg/pg/v10"
+	"github.com/AgeroFlynn/crud/internal/buisness/sys/lockout"
+	"github.com/AgeroFlynn/crud/internal/buisness/sys/passwd"
+	"github.com/AgeroFlynn/crud/internal/buisness/sys/policy"
+	"github.com/AgeroFlynn/crud/internal/buisness/sys/validate"
+	"github.com/AgeroFlynn/crud/internal/foundation/database"
+	"github.com/AgeroFlynn/crud/internal/foundation/mail"
+	"github.com/AgeroFlynn/crud/internal/foundation/worker"
 	"go.uber.org/zap"
+	"strings"
 	"time"
 )
 
+// OrderByFields maps the fields clients can order users by to the columns.
+var OrderByFields = map[string]string{
+	"id":           "user_id",
+	"name":         "name",
+	"email":        "email",
+	"date_created": "date_created",
+	"date_updated": "date_updated",
+}
+
+// DefaultOrderBy is the ordering used when the client doesn't ask for one.
+var DefaultOrderBy = order.NewBy("user_id", order.ASC)
+
+// DirectoryOrderByFields maps the fields the directory can be ordered by to
+// the columns. Only the fields of the cards are exposed.
+var DirectoryOrderByFields = map[string]string{
+	"id":    "user_id",
+	"name":  "name",
+	"email": "email",
+}
+
+// DefaultDirectoryOrderBy is the ordering of the directory when the client
+// doesn't ask for one.
+var DefaultDirectoryOrderBy = order.NewBy("name", order.ASC)
+
+// UserStorer is the behavior required by the core to persist and retrieve
+// users. It is implemented by the database store and by the in-memory store
+// used in tests.
+type UserStorer interface {
+	Create(ctx context.Context, nu dto.NewUser, now time.Time) (dto.User, error)
+	Update(ctx context.Context, userID string, uu dto.UpdateUser, version *int, now time.Time) error
+	Delete(ctx context.Context, userID string, version *int) error
+	Query(ctx context.Context, filter dto.UserFilter, orderBy order.By, offset int, limit int) ([]dto.User, int, error)
+	QueryAfter(ctx context.Context, filter dto.UserFilter, orderBy order.By, key *order.Key, backward bool, limit int) ([]dto.User, bool, error)
+	FindByID(ctx context.Context, userID string) (dto.User, error)
+	FindByEmail(ctx context.Context, email string) (dto.User, error)
+	Authenticate(ctx context.Context, now time.Time, email, password string) (auth.Claims, error)
+	Verify(ctx context.Context, userID string, email string, now time.Time) error
+	SetPassword(ctx context.Context, userID string, password string, version int, now time.Time) error
+	SetStatus(ctx context.Context, userID string, status string, reason string, version int, now time.Time) error
+	PasswordHistory(ctx context.Context, userID string, limit int) ([][]byte, error)
+	SetLastLogin(ctx context.Context, userID string, now time.Time) error
+	WithinTran(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// RoleResolver is the behavior required by the core to turn the roles of
+// users into permissions and to make sure only existing roles are assigned.
+type RoleResolver interface {
+	Resolve(ctx context.Context, roles []string) ([]string, error)
+	CheckRoles(ctx context.Context, roles []string) error
+}
+
+// Queuer is the behavior required by the core to run work after the request
+// is answered.
+type Queuer interface {
+	Submit(job worker.Job) error
+}
+
+// Config holds the settings of the user core.
+type Config struct {
+
+	// LowercaseEmails lowercases the whole email instead of just the domain.
+	LowercaseEmails bool
+
+	// RequireVerified refuses to authenticate users who haven't verified
+	// their email yet.
+	RequireVerified bool
+
+	// Password holds the rules new passwords have to follow.
+	Password passwd.Policy
+
+	// AccountLockout and AddrLockout count the failed logins per email and
+	// per remote address. Logins aren't throttled when they are nil.
+	AccountLockout *lockout.Counter
+	AddrLockout    *lockout.Counter
+
+	// Auth signs the verification links and Mail delivers them. No links
+	// are sent without a mail sender.
+	Auth *auth.Auth
+	Mail mail.Sender
+
+	// VerifyURL is the page users open to verify their email, the token is
+	// added as the `token` query parameter. VerifyExpiresIn defaults to
+	// DefaultVerifyExpiresIn.
+	VerifyURL       string
+	VerifyExpiresIn time.Duration
+
+	// ResetURL is the page users open to choose a new password, the token
+	// is added as the `token` query parameter. ResetExpiresIn defaults to
+	// DefaultResetExpiresIn.
+	ResetURL       string
+	ResetExpiresIn time.Duration
+
+	// Background mails the reset links after the request is answered, so
+	// requests for known and unknown emails take the same time. Reset links
+	// aren't sent without it.
+	Background Queuer
+
+	// ResetEmailLimit and ResetAddrLimit count the requests for reset links
+	// per email and per remote address, requests beyond the threshold are
+	// refused until the lockout ends. Requests aren't throttled when they
+	// are nil.
+	ResetEmailLimit *lockout.Counter
+	ResetAddrLimit  *lockout.Counter
+
+	// StatusCacheTTL is how long the status of a user is cached when tokens
+	// are checked for revocation. Changes made through another instance of
+	// the service take up to that long to reject tokens. Nothing is cached
+	// when it is zero.
+	StatusCacheTTL time.Duration
+
+	// Roles resolves the permissions carried by the tokens and checks the
+	// roles assigned to users. Tokens carry no permissions and roles aren't
+	// checked when it is nil.
+	Roles RoleResolver
+
+	// Policy decides whether the claims may act on a user before the store
+	// is touched. It defaults to the policies the service ships with.
+	Policy *policy.Engine
+
+	// Audit records the modifications of users and the denials of the
+	// policies. Nothing is recorded when it is nil.
+	Audit Auditor
+
+	// Events publishes the domain events of the modifications of users in
+	// the transaction of the modification. No events are emitted when it is
+	// nil.
+	Events Publisher
+
+	// Logins keeps the history of the login attempts. Nothing is kept when
+	// it is nil.
+	Logins LoginStorer
+}
+
 // Core manages the set of API's for user access.
 type Core struct {
-	log  *zap.SugaredLogger
-	user user.Store
+	log    *zap.SugaredLogger
+	user   UserStorer
+	cfg    Config
+	status *statusCache
+	policy *policy.Engine
 }
 
 // NewCore constructs a core for user api access.
-func NewCore(log *zap.SugaredLogger, db *pg.DB) Core {
+func NewCore(log *zap.SugaredLogger, storer UserStorer, cfg Config) Core {
+	engine := cfg.Policy
+	if engine == nil {
+		engine = policy.Default()
+	}
+
 	return Core{
-		log:  log,
-		user: user.NewStore(log, db),
+		log:    log,
+		user:   storer,
+		cfg:    cfg,
+		status: newStatusCache(cfg.StatusCacheTTL),
+		policy: engine,
 	}
 }
 
 // Create inserts a new user into the database.
 func (c Core) Create(ctx context.Context, nu dto.NewUser, now time.Time) (dto.User, error) {
 
+	// Users are created by admins, their claims are in the context.
+	claims, _ := auth.GetClaims(ctx)
+
+	var usr dto.User
+	err := c.authorize(ctx, claims, ActionCreate, "", nil)
+	if err == nil {
+		usr, err = c.create(ctx, claims, nu, now)
+	}
+	if err != nil {
+		c.record(ctx, claims.Subject, ActionCreate, "", nil, err)
+		return dto.User{}, err
+	}
+
+	// PERFORM POST BUSINESS OPERATIONS
+
+	c.sendVerification(ctx, usr, now)
+
+	return usr, nil
+}
+
+// create inserts a new user without the post business operations of Create,
+// so a batch can run them once its transaction is committed.
+func (c Core) create(ctx context.Context, claims auth.Claims, nu dto.NewUser, now time.Time) (dto.User, error) {
+
 	// PERFORM PRE BUSINESS OPERATIONS
 
-	usr, err := c.user.Create(ctx, nu, now)
+	// Every account may have the USER role, any other role has to be
+	// assigned.
+	if !auth.SameRoles(nu.Roles, []string{auth.RoleUser}) {
+		if err := c.authorize(ctx, claims, ActionAssignRoles, "", nil); err != nil {
+			return dto.User{}, fmt.Errorf("create: %w", err)
+		}
+	}
+
+	nu.Email = c.normalizeEmail(nu.Email)
+
+	if err := c.checkRoles(ctx, nu.Roles); err != nil {
+		return dto.User{}, fmt.Errorf("create: %w", err)
+	}
+
+	if err := c.checkPassword(ctx, dto.User{Name: nu.Name, Email: nu.Email}, nu.Password); err != nil {
+		return dto.User{}, fmt.Errorf("create: %w", err)
+	}
+
+	var usr dto.User
+	err := c.withChanges(ctx, claims.Subject, ActionCreate, func(ctx context.Context) (applied, error) {
+		var err error
+		if usr, err = c.user.Create(ctx, nu, now); err != nil {
+			return applied{}, err
+		}
+		return applied{
+			userID:  usr.ID,
+			changes: diff(dto.User{}, usr),
+			events:  []dto.NewEvent{userCreated(usr)},
+		}, nil
+	})
 	if err != nil {
 		return dto.User{}, fmt.Errorf("create: %w", err)
 	}
 
+	return usr, nil
+}
+
+// Update replaces a user document in the database. When version is provided
+// the update only succeeds if the user still has that version.
+func (c Core) Update(ctx context.Context, claims auth.Claims, userID string, uu dto.UpdateUser, version *int, now time.Time) error {
+	if err := c.update(ctx, claims, userID, uu, version, now); err != nil {
+		c.record(ctx, claims.Subject, ActionUpdate, userID, nil, err)
+		return err
+	}
+
 	// PERFORM POST BUSINESS OPERATIONS
 
-	return usr, nil
+	// New roles or a new password revoke the tokens issued so far.
+	if uu.Roles != nil || uu.Password != nil {
+		c.status.forget(userID)
+	}
+
+	if uu.Email != nil {
+		if err := c.reverify(ctx, userID, now); err != nil {
+			return fmt.Errorf("update: %w", err)
+		}
+	}
+
+	return nil
 }
 
-// Update replaces a user document in the database.
-func (c Core) Update(ctx context.Context, claims auth.Claims, userID string, uu dto.UpdateUser, now time.Time) error {
+// update replaces a user document without the post business operations of
+// Update, so a batch can run them once its transaction is committed.
+func (c Core) update(ctx context.Context, claims auth.Claims, userID string, uu dto.UpdateUser, version *int, now time.Time) error {
 
 	// PERFORM PRE BUSINESS OPERATIONS
 
-	if err := c.user.Update(ctx, claims, userID, uu, now); err != nil {
-		return fmt.Errorf("udpate: %w", err)
+	if err := c.authorize(ctx, claims, ActionUpdate, userID, nil); err != nil {
+		return fmt.Errorf("update: %w", err)
+	}
+
+	if uu.Email != nil {
+		email := c.normalizeEmail(*uu.Email)
+		uu.Email = &email
+	}
+
+	if uu.Roles != nil {
+		if err := c.checkRoles(ctx, uu.Roles); err != nil {
+			return fmt.Errorf("update: %w", err)
+		}
+	}
+
+	// The user as it was is needed to check the new password against and to
+	// tell what changed.
+	var before dto.User
+	if uu.Password != nil || uu.Roles != nil || c.tracking() {
+		usr, err := c.user.FindByID(ctx, userID)
+		if err != nil {
+			return fmt.Errorf("update: %w", err)
+		}
+		before = usr
+	}
+
+	if uu.Roles != nil && !auth.SameRoles(uu.Roles, before.Roles) {
+		if err := c.authorize(ctx, claims, ActionAssignRoles, userID, nil); err != nil {
+			return fmt.Errorf("update: %w", err)
+		}
+	}
+
+	if uu.Password != nil {
+		usr := before
+		if uu.Name != nil {
+			usr.Name = *uu.Name
+		}
+		if uu.Email != nil {
+			usr.Email = *uu.Email
+		}
+		if err := c.checkPassword(ctx, usr, *uu.Password); err != nil {
+			return fmt.Errorf("update: %w", err)
+		}
+	}
+
+	err := c.withChanges(ctx, claims.Subject, ActionUpdate, func(ctx context.Context) (applied, error) {
+		if err := c.user.Update(ctx, userID, uu, version, now); err != nil {
+			return applied{}, err
+		}
+		if !c.tracking() {
+			return applied{}, nil
+		}
+
+		after, err := c.user.FindByID(ctx, userID)
+		if err != nil {
+			return applied{}, err
+		}
+		changes := diff(before, after)
+
+		return applied{
+			userID:  userID,
+			changes: changes,
+			events:  updateEvents(userID, changes),
+		}, nil
+	})
+	if err != nil {
+		return fmt.Errorf("update: %w", err)
+	}
+
+	return nil
+}
+
+// reverify mails a verification link to the user when the email of the
+// user isn't verified, like after it was changed.
+func (c Core) reverify(ctx context.Context, userID string, now time.Time) error {
+	usr, err := c.user.FindByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if usr.DateVerified == nil {
+		c.sendVerification(ctx, usr, now)
+	}
+
+	return nil
+}
+
+// Delete removes a user from the database. When version is provided the
+// user is only removed if it still has that version.
+func (c Core) Delete(ctx context.Context, claims auth.Claims, userID string, version *int) error {
+	if err := c.delete(ctx, claims, userID, version); err != nil {
+		c.record(ctx, claims.Subject, ActionDelete, userID, nil, err)
+		return err
 	}
 
 	// PERFORM POST BUSINESS OPERATIONS
 
+	c.status.forget(userID)
+
 	return nil
 }
 
-// Delete removes a user from the database.
-func (c Core) Delete(ctx context.Context, claims auth.Claims, userID string) error {
+// delete removes a user without the post business operations of Delete, so
+// a batch can run them once its transaction is committed.
+func (c Core) delete(ctx context.Context, claims auth.Claims, userID string, version *int) error {
 
 	// PERFORM PRE BUSINESS OPERATIONS
 
-	if err := c.user.Delete(ctx, claims, userID); err != nil {
+	if err := c.authorize(ctx, claims, ActionDelete, userID, nil); err != nil {
 		return fmt.Errorf("delete: %w", err)
 	}
 
-	// PERFORM POST BUSINESS OPERATIONS
+	// Removing a user that doesn't exist is left to the store.
+	var before dto.User
+	if c.tracking() {
+		usr, err := c.user.FindByID(ctx, userID)
+		if err != nil && !errors.Is(err, database.ErrNotFound) {
+			return fmt.Errorf("delete: %w", err)
+		}
+		before = usr
+	}
+
+	err := c.withChanges(ctx, claims.Subject, ActionDelete, func(ctx context.Context) (applied, error) {
+		if err := c.user.Delete(ctx, userID, version); err != nil {
+			return applied{}, err
+		}
+
+		a := applied{userID: userID, changes: diff(before, dto.User{})}
+		if before.ID != "" {
+			a.events = []dto.NewEvent{userDeleted(before)}
+		}
+		return a, nil
+	})
+	if err != nil {
+		return fmt.Errorf("delete: %w", err)
+	}
 
 	return nil
 }
 
-// FindAll retrieves a list of existing users from the database.
-func (c Core) FindAll(ctx context.Context) ([]dto.User, error) {
+// Query retrieves a page of users matching the filter together with the
+// total number of matching users.
+func (c Core) Query(ctx context.Context, claims auth.Claims, filter dto.UserFilter, orderBy order.By, offset int, limit int) ([]dto.User, int, error) {
+
+	// PERFORM PRE BUSINESS OPERATIONS
+
+	if err := c.authorize(ctx, claims, ActionList, "", nil); err != nil {
+		return nil, 0, fmt.Errorf("query: %w", err)
+	}
+
+	users, total, err := c.user.Query(ctx, filter, orderBy, offset, limit)
+	if err != nil {
+		return nil, 0, fmt.Errorf("query: %w", err)
+	}
+
+	// PERFORM POST BUSINESS OPERATIONS
+
+	return users, total, nil
+}
+
+// QueryAfter retrieves a page of users following the key in the ordering, or
+// preceding it when backward is set. It reports whether there are more users
+// beyond the page.
+func (c Core) QueryAfter(ctx context.Context, claims auth.Claims, filter dto.UserFilter, orderBy order.By, key *order.Key, backward bool, limit int) ([]dto.User, bool, error) {
 
 	// PERFORM PRE BUSINESS OPERATIONS
 
-	users, err := c.user.FindAll(ctx)
+	if err := c.authorize(ctx, claims, ActionList, "", nil); err != nil {
+		return nil, false, fmt.Errorf("query: %w", err)
+	}
+
+	users, more, err := c.user.QueryAfter(ctx, filter, orderBy, key, backward, limit)
+	if err != nil {
+		return nil, false, fmt.Errorf("query: %w", err)
+	}
+
+	// PERFORM POST BUSINESS OPERATIONS
+
+	return users, more, nil
+}
+
+// QueryDirectory retrieves a page of the contact cards of the active users
+// following the key in the ordering, or preceding it when backward is set.
+// It reports whether there are more cards beyond the page.
+func (c Core) QueryDirectory(ctx context.Context, claims auth.Claims, filter dto.UserFilter, orderBy order.By, key *order.Key, backward bool, limit int) ([]dto.PublicCard, bool, error) {
+
+	// PERFORM PRE BUSINESS OPERATIONS
+
+	if err := c.authorize(ctx, claims, ActionDirectory, "", nil); err != nil {
+		return nil, false, fmt.Errorf("query: %w", err)
+	}
+
+	active := dto.StatusActive
+	filter.Status = &active
+
+	users, more, err := c.user.QueryAfter(ctx, filter, orderBy, key, backward, limit)
 	if err != nil {
-		return nil, fmt.Errorf("query: %w", err)
+		return nil, false, fmt.Errorf("query: %w", err)
 	}
 
 	// PERFORM POST BUSINESS OPERATIONS
 
-	return users, nil
+	cards := make([]dto.PublicCard, len(users))
+	for i, usr := range users {
+		cards[i] = dto.PublicCard{ID: usr.ID, Name: usr.Name, Email: usr.Email}
+	}
+
+	return cards, more, nil
+}
+
+// KeyOf returns the position of the user in an ordering by the specified
+// column.
+func KeyOf(usr dto.User, field string) order.Key {
+	var value string
+	switch field {
+	case "name":
+		value = usr.Name
+	case "email":
+		value = usr.Email
+	case "date_created":
+		value = usr.DateCreated.Format(time.RFC3339Nano)
+	case "date_updated":
+		value = usr.DateUpdated.Format(time.RFC3339Nano)
+	default:
+		value = usr.ID
+	}
+
+	return order.Key{Value: value, ID: usr.ID}
+}
+
+// KeyOfCard returns the position of the contact card in an ordering by the
+// specified column.
+func KeyOfCard(card dto.PublicCard, field string) order.Key {
+	return KeyOf(dto.User{ID: card.ID, Name: card.Name, Email: card.Email}, field)
 }
 
 // FindByID gets the specified user from the database.
@@ -91,7 +516,11 @@ func (c Core) FindByID(ctx context.Context, claims auth.Claims, userID string) (
 
 	// PERFORM PRE BUSINESS OPERATIONS
 
-	usr, err := c.user.FindByID(ctx, claims, userID)
+	if err := c.authorize(ctx, claims, ActionRead, userID, nil); err != nil {
+		return dto.User{}, fmt.Errorf("query: %w", err)
+	}
+
+	usr, err := c.user.FindByID(ctx, userID)
 	if err != nil {
 		return dto.User{}, fmt.Errorf("query: %w", err)
 	}
@@ -101,13 +530,48 @@ func (c Core) FindByID(ctx context.Context, claims auth.Claims, userID string) (
 	return usr, nil
 }
 
+// Export gets the specified user whose contact card is exported. Users can
+// export their own card, the cards of others take the export action.
+func (c Core) Export(ctx context.Context, claims auth.Claims, userID string) (dto.User, error) {
+
+	// PERFORM PRE BUSINESS OPERATIONS
+
+	if err := c.authorize(ctx, claims, ActionExport, userID, nil); err != nil {
+		return dto.User{}, fmt.Errorf("export: %w", err)
+	}
+
+	usr, err := c.user.FindByID(ctx, userID)
+	if err != nil {
+		return dto.User{}, fmt.Errorf("export: %w", err)
+	}
+
+	// PERFORM POST BUSINESS OPERATIONS
+
+	return usr, nil
+}
+
 // FindByEmail gets the specified user from the database by email.
 func (c Core) FindByEmail(ctx context.Context, claims auth.Claims, email string) (dto.User, error) {
 
 	// PERFORM PRE BUSINESS OPERATIONS
 
-	usr, err := c.user.FindByEmail(ctx, claims, email)
+	email = c.normalizeEmail(email)
+
+	usr, err := c.user.FindByEmail(ctx, email)
 	if err != nil {
+
+		// Only subjects who may list users learn that no user has the
+		// email, to others it looks like the email of someone else.
+		if errors.Is(err, database.ErrNotFound) {
+			if err := c.authorize(ctx, claims, ActionList, "", nil); err != nil {
+				return dto.User{}, fmt.Errorf("query: %w", err)
+			}
+		}
+		return dto.User{}, fmt.Errorf("query: %w", err)
+	}
+
+	// The owner of an email is only known once the user is loaded.
+	if err := c.authorize(ctx, claims, ActionRead, usr.ID, nil); err != nil {
 		return dto.User{}, fmt.Errorf("query: %w", err)
 	}
 
@@ -118,17 +582,102 @@ func (c Core) FindByEmail(ctx context.Context, claims auth.Claims, email string)
 
 // Authenticate finds a user by their email and verifies their password. On
 // success it returns a Claims User representing this user. The claims can be
-// used to generate a token for future authentication.
-func (c Core) Authenticate(ctx context.Context, now time.Time, email, password string) (auth.Claims, error) {
+// used to generate a token for future authentication. Unknown emails fail
+// like wrong passwords, and repeated failures from the email or the remote
+// address lock them out. Users who aren't active are refused with
+// ErrInactive. The claims carry the permissions granted by the roles of the
+// user.
+func (c Core) Authenticate(ctx context.Context, now time.Time, email, password string, remoteAddr string, userAgent string) (auth.Claims, error) {
 
 	// PERFORM PRE BUSINESS OPERATIONS
 
+	email = c.normalizeEmail(email)
+
+	if err := c.checkLockout(email, remoteAddr, now); err != nil {
+		c.recordLogin(ctx, now, "", email, remoteAddr, userAgent, dto.LoginLockedOut)
+		return auth.Claims{}, err
+	}
+
 	claims, err := c.user.Authenticate(ctx, now, email, password)
 	if err != nil {
-		return auth.Claims{}, fmt.Errorf("query: %w", err)
+		switch {
+		case errors.Is(err, database.ErrNotFound), errors.Is(err, database.ErrAuthenticationFailure):
+			c.failLogin(email, remoteAddr, now)
+			c.recordLogin(ctx, now, "", email, remoteAddr, userAgent, dto.LoginInvalidCredentials)
+			return auth.Claims{}, database.ErrAuthenticationFailure
+		default:
+			return auth.Claims{}, fmt.Errorf("query: %w", err)
+		}
 	}
 
 	// PERFORM POST BUSINESS OPERATIONS
 
+	usr, err := c.user.FindByID(ctx, claims.Subject)
+	if err != nil {
+		return auth.Claims{}, fmt.Errorf("query: %w", err)
+	}
+
+	if usr.Status != dto.StatusActive {
+		c.recordLogin(ctx, now, usr.ID, email, remoteAddr, userAgent, dto.LoginInactive)
+		return auth.Claims{}, fmt.Errorf("%w: %s", ErrInactive, strings.ToLower(usr.Status))
+	}
+
+	if c.cfg.RequireVerified && usr.DateVerified == nil {
+		c.recordLogin(ctx, now, usr.ID, email, remoteAddr, userAgent, dto.LoginUnverified)
+		return auth.Claims{}, ErrUnverified
+	}
+
+	// Only a login which gets a token forgives the earlier failures, the
+	// right password of an account which can't log in doesn't.
+	c.clearLockout(email)
+
+	if c.cfg.Roles != nil {
+		permissions, err := c.cfg.Roles.Resolve(ctx, claims.Roles)
+		if err != nil {
+			return auth.Claims{}, fmt.Errorf("query: %w", err)
+		}
+		claims.Permissions = permissions
+	}
+
+	c.recordLogin(ctx, now, usr.ID, email, remoteAddr, userAgent, "")
+	if err := c.user.SetLastLogin(ctx, usr.ID, now); err != nil {
+		c.log.Errorw("last login", "userID", usr.ID, "ERROR", err)
+	}
+
 	return claims, nil
 }
+
+// checkRoles makes sure the roles exist.
+func (c Core) checkRoles(ctx context.Context, roles []string) error {
+	if c.cfg.Roles == nil {
+		return nil
+	}
+	return c.cfg.Roles.CheckRoles(ctx, roles)
+}
+
+// checkPassword validates a new password of the user against the password
+// policy. The history is only checked for existing users.
+func (c Core) checkPassword(ctx context.Context, usr dto.User, password string) error {
+	fields := c.cfg.Password.Check(password, usr.Name, usr.Email)
+
+	if usr.ID != "" && c.cfg.Password.History > 0 {
+		history, err := c.user.PasswordHistory(ctx, usr.ID, c.cfg.Password.History)
+		if err != nil {
+			return fmt.Errorf("password history: %w", err)
+		}
+
+		// The current password counts even if it predates the history.
+		fields = append(fields, c.cfg.Password.CheckHistory(password, append([][]byte{usr.PasswordHash}, history...))...)
+	}
+
+	if len(fields) > 0 {
+		return fields
+	}
+
+	return nil
+}
+
+// normalizeEmail converts the email into the form it is stored in.
+func (c Core) normalizeEmail(email string) string {
+	return validate.NormalizeEmail(email, c.cfg.LowercaseEmails)
+}
@@ -0,0 +1,128 @@
+package user
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"github.com/AgeroFlynn/crud/internal/buisness/core/dto"
+	"github.com/AgeroFlynn/crud/internal/foundation/database"
+	"github.com/AgeroFlynn/crud/internal/foundation/mail"
+	"github.com/golang-jwt/jwt/v4"
+	"net/url"
+	"time"
+)
+
+// VerifyAudience is the audience of the tokens in verification links.
+const VerifyAudience = "verify"
+
+// DefaultVerifyExpiresIn is how long a verification link can be used.
+const DefaultVerifyExpiresIn = 48 * time.Hour
+
+// Set of error variables for the email verification.
+var (
+	ErrUnverified   = errors.New("email is not verified")
+	ErrInvalidToken = errors.New("token is not valid")
+)
+
+//go:embed templates/*.tmpl
+var templateFS embed.FS
+
+// templates holds the emails sent to users.
+var templates = func() *mail.Templates {
+	t, err := mail.ParseTemplates(templateFS, "templates/*.tmpl")
+	if err != nil {
+		panic(err)
+	}
+	return t
+}()
+
+// Verify marks the email of the user the token was issued for as verified.
+func (c Core) Verify(ctx context.Context, token string, now time.Time) error {
+	if c.cfg.Auth == nil {
+		return ErrInvalidToken
+	}
+
+	// The token carries the email it was sent to, it can't verify an email
+	// the user changed to later on.
+	claims, err := c.cfg.Auth.ValidateScopedToken(VerifyAudience, token)
+	if err != nil {
+		return ErrInvalidToken
+	}
+
+	if err := c.user.Verify(ctx, claims.Subject, claims.ID, now); err != nil {
+		switch {
+		case errors.Is(err, database.ErrNotFound), errors.Is(err, database.ErrInvalidID):
+			return ErrInvalidToken
+		default:
+			return fmt.Errorf("verify: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// sendVerification mails a verification link to the user. The user exists
+// by now so a failure is only logged.
+func (c Core) sendVerification(ctx context.Context, usr dto.User, now time.Time) {
+	if c.cfg.Mail == nil || c.cfg.Auth == nil {
+		return
+	}
+
+	if err := c.mailVerification(ctx, usr, now); err != nil {
+		c.log.Errorw("sending verification", "userID", usr.ID, "ERROR", err)
+	}
+}
+
+// mailVerification signs a verification token and mails the link.
+func (c Core) mailVerification(ctx context.Context, usr dto.User, now time.Time) error {
+	expiresIn := c.cfg.VerifyExpiresIn
+	if expiresIn <= 0 {
+		expiresIn = DefaultVerifyExpiresIn
+	}
+
+	claims := jwt.RegisteredClaims{
+		ID:        usr.Email,
+		Subject:   usr.ID,
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(expiresIn)),
+	}
+
+	return c.mailLink(ctx, usr, "verify.tmpl", c.cfg.VerifyURL, VerifyAudience, claims)
+}
+
+// mailLink signs a token with the claims and mails the link to the page
+// with the token to the user using the template.
+func (c Core) mailLink(ctx context.Context, usr dto.User, tmpl string, page string, audience string, claims jwt.RegisteredClaims) error {
+	token, err := c.cfg.Auth.GenerateScopedToken(audience, claims)
+	if err != nil {
+		return fmt.Errorf("signing token: %w", err)
+	}
+
+	link, err := url.Parse(page)
+	if err != nil {
+		return fmt.Errorf("parsing url: %w", err)
+	}
+	q := link.Query()
+	q.Set("token", token)
+	link.RawQuery = q.Encode()
+
+	data := struct {
+		Name      string
+		Email     string
+		URL       string
+		ExpiresIn time.Duration
+	}{
+		Name:      usr.Name,
+		Email:     usr.Email,
+		URL:       link.String(),
+		ExpiresIn: claims.ExpiresAt.Sub(claims.IssuedAt.Time),
+	}
+
+	msg, err := templates.Render(tmpl, usr.Email, data)
+	if err != nil {
+		return err
+	}
+
+	return c.cfg.Mail.Send(ctx, msg)
+}
@@ -0,0 +1,379 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/AgeroFlynn/crud/internal/buisness/core/dto"
+	"github.com/AgeroFlynn/crud/internal/foundation/database"
+	"go.uber.org/zap"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// Set of headers sent with every delivery. The signature is computed over
+// the timestamp and the body, receivers should reject old timestamps to
+// prevent replays.
+const (
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderDelivery  = "X-Webhook-Delivery"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// Set of defaults of the sender configuration.
+const (
+	DefaultInterval    = time.Second
+	DefaultBatchSize   = 50
+	DefaultMaxAttempts = 8
+	DefaultBackoff     = 10 * time.Second
+	DefaultMaxBackoff  = time.Hour
+	DefaultLease       = time.Minute
+	DefaultTimeout     = 10 * time.Second
+)
+
+// Set of errors returned by Verify.
+var (
+	ErrInvalidSignature = errors.New("invalid signature")
+	ErrExpiredSignature = errors.New("signature timestamp outside the tolerance")
+)
+
+// ErrForbiddenAddress is the cause of deliveries that weren't sent because
+// the webhook resolves to a private, loopback or link-local address.
+var ErrForbiddenAddress = errors.New("destination address not allowed")
+
+// SenderConfig holds the settings of the sender. Zero values are replaced by
+// the defaults.
+type SenderConfig struct {
+
+	// Interval is how long the sender waits before looking for deliveries
+	// again once none is due.
+	Interval time.Duration
+
+	// BatchSize is the most deliveries claimed at once.
+	BatchSize int
+
+	// MaxAttempts is how many times a delivery is sent before it fails.
+	MaxAttempts int
+
+	// Backoff is the wait before the first retry, it doubles with every
+	// attempt up to MaxBackoff.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+
+	// Lease is how long claimed deliveries are hidden from other senders. It
+	// is raised to cover sending a whole batch, so no other sender claims
+	// deliveries still waiting their turn.
+	Lease time.Duration
+
+	// Timeout is the time limit of sending a delivery.
+	Timeout time.Duration
+
+	// AllowPrivate lets deliveries go to private, loopback and link-local
+	// addresses, which are refused otherwise so webhooks can't reach the
+	// internal network. It is meant for tests with local receivers.
+	AllowPrivate bool
+}
+
+// Sender sends the queued deliveries to the webhooks. Several senders can
+// share a store.
+type Sender struct {
+	log     *zap.SugaredLogger
+	webhook WebhookStorer
+	cfg     SenderConfig
+	client  *http.Client
+}
+
+// NewSender constructs a sender for the deliveries of the store.
+func NewSender(log *zap.SugaredLogger, storer WebhookStorer, cfg SenderConfig) *Sender {
+	if cfg.Interval <= 0 {
+		cfg.Interval = DefaultInterval
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = DefaultBatchSize
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = DefaultMaxAttempts
+	}
+	if cfg.Backoff <= 0 {
+		cfg.Backoff = DefaultBackoff
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = DefaultMaxBackoff
+	}
+	if cfg.Lease <= 0 {
+		cfg.Lease = DefaultLease
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultTimeout
+	}
+	if batch := time.Duration(cfg.BatchSize+1) * cfg.Timeout; cfg.Lease < batch {
+		cfg.Lease = batch
+	}
+
+	return &Sender{
+		log:     log,
+		webhook: storer,
+		cfg:     cfg,
+		client:  newClient(cfg.Timeout, cfg.AllowPrivate),
+	}
+}
+
+// newClient returns the client sending the deliveries. Redirects aren't
+// followed and no proxy is used, the response of the webhook is the outcome
+// of the delivery. Unless private addresses are allowed they are refused
+// when dialing, once the host is resolved, so names resolving to them are
+// refused as well.
+func newClient(timeout time.Duration, allowPrivate bool) *http.Client {
+	dialer := net.Dialer{
+		Timeout:   timeout,
+		KeepAlive: 30 * time.Second,
+	}
+	if !allowPrivate {
+		dialer.Control = checkAddress
+	}
+
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			ForceAttemptHTTP2:   true,
+			MaxIdleConns:        100,
+			IdleConnTimeout:     90 * time.Second,
+			TLSHandshakeTimeout: 10 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// deniedNets are the address ranges deliveries can't be sent to: the ones
+// which aren't globally reachable, the ones shared with the carrier or used
+// for benchmarks and documentation, and the ones translating to them. The
+// IPv4 ranges also cover the IPv4-mapped IPv6 addresses.
+var deniedNets = parseNets(
+	"0.0.0.0/8",       // This network.
+	"10.0.0.0/8",      // Private.
+	"100.64.0.0/10",   // Carrier-grade NAT, also cloud metadata (100.100.100.200).
+	"127.0.0.0/8",     // Loopback.
+	"169.254.0.0/16",  // Link-local, also cloud metadata (169.254.169.254).
+	"172.16.0.0/12",   // Private.
+	"192.0.0.0/24",    // IETF protocol assignments.
+	"192.0.2.0/24",    // Documentation.
+	"192.168.0.0/16",  // Private.
+	"198.18.0.0/15",   // Benchmarking.
+	"198.51.100.0/24", // Documentation.
+	"203.0.113.0/24",  // Documentation.
+	"224.0.0.0/4",     // Multicast.
+	"240.0.0.0/4",     // Reserved, also broadcast.
+	"::/128",          // Unspecified.
+	"::1/128",         // Loopback.
+	"64:ff9b::/96",    // NAT64.
+	"64:ff9b:1::/48",  // Local-use NAT64.
+	"100::/64",        // Discard-only.
+	"2001:db8::/32",   // Documentation.
+	"fc00::/7",        // Unique local, also cloud metadata (fd00:ec2::254).
+	"fe80::/10",       // Link-local.
+	"ff00::/8",        // Multicast.
+)
+
+// parseNets parses the CIDR prefixes, they are constants so a bad one panics.
+func parseNets(prefixes ...string) []*net.IPNet {
+	nets := make([]*net.IPNet, len(prefixes))
+	for i, prefix := range prefixes {
+		_, n, err := net.ParseCIDR(prefix)
+		if err != nil {
+			panic(err)
+		}
+		nets[i] = n
+	}
+	return nets
+}
+
+// checkAddress returns ErrForbiddenAddress unless the resolved address about
+// to be dialed is a public one.
+func checkAddress(network string, address string, c syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, address)
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, host)
+	}
+	for _, n := range deniedNets {
+		if n.Contains(ip) {
+			return fmt.Errorf("%w: %s", ErrForbiddenAddress, host)
+		}
+	}
+
+	return nil
+}
+
+// Run sends deliveries until the context is canceled.
+func (s *Sender) Run(ctx context.Context) {
+	for {
+		n, err := s.Deliver(ctx, time.Now().UTC())
+		if err != nil {
+			s.log.Errorw("webhook sender", "ERROR", err)
+		}
+
+		// Keep going while there is a backlog.
+		if err == nil && n == s.cfg.BatchSize && ctx.Err() == nil {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(s.cfg.Interval):
+		}
+	}
+}
+
+// Deliver claims a batch of due deliveries and sends them. It returns the
+// number of deliveries claimed. A delivery whose outcome can't be recorded
+// is logged and sent again once its lease expires, the rest of the batch
+// is sent regardless.
+func (s *Sender) Deliver(ctx context.Context, now time.Time) (int, error) {
+	deliveries, err := s.webhook.ClaimDeliveries(ctx, now, s.cfg.Lease, s.cfg.BatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("deliver: %w", err)
+	}
+
+	for _, d := range deliveries {
+		if err := s.deliver(ctx, d, now); err != nil {
+			s.log.Errorw("webhook sender", "status", "recording delivery", "deliveryID", d.ID, "webhookID", d.WebhookID, "ERROR", err)
+		}
+	}
+
+	return len(deliveries), nil
+}
+
+// deliver sends the delivery and records the outcome. A failed delivery is
+// retried with an exponential backoff and fails for good after MaxAttempts.
+func (s *Sender) deliver(ctx context.Context, d dto.Delivery, now time.Time) error {
+	webhook, err := s.webhook.QueryByID(ctx, d.WebhookID)
+	switch {
+	case errors.Is(err, database.ErrNotFound):
+
+		// The webhook was deleted, its deliveries are gone with it.
+		return nil
+	case err != nil:
+		return err
+	}
+
+	d.Attempts++
+	d.ResponseCode = 0
+	d.LastError = ""
+
+	if webhook.Active {
+		d.ResponseCode, err = s.send(ctx, webhook, d)
+	} else {
+		err = errors.New("webhook is inactive")
+	}
+
+	switch {
+	case err == nil:
+		d.Status = dto.DeliverySucceeded
+		d.DateDelivered = &now
+
+	case d.Attempts >= s.cfg.MaxAttempts || !webhook.Active:
+		s.log.Errorw("webhook sender", "status", "delivery failed", "deliveryID", d.ID, "webhookID", d.WebhookID, "attempts", d.Attempts, "ERROR", err)
+		d.Status = dto.DeliveryFailed
+		d.LastError = err.Error()
+
+	default:
+		d.LastError = err.Error()
+		d.DateNextAttempt = now.Add(s.backoff(d.Attempts))
+	}
+
+	return s.webhook.UpdateDelivery(ctx, d)
+}
+
+// send posts the signed payload of the delivery to the webhook. Responses
+// other than 2xx are errors. The signature is dated when the delivery is
+// sent rather than when the batch was claimed, receivers checking the age
+// of the timestamp accept the deliveries at the end of a slow batch too.
+func (s *Sender) send(ctx context.Context, webhook dto.Webhook, d dto.Delivery) (int, error) {
+	timestamp := time.Now().Unix()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		return 0, fmt.Errorf("creating request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, d.EventType)
+	req.Header.Set(HeaderDelivery, d.ID)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(webhook.Secret, timestamp, d.Payload))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("sending request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Drain a bit of the body so the connection can be reused.
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("receiver responded %s", resp.Status)
+	}
+
+	return resp.StatusCode, nil
+}
+
+// backoff returns the wait before the next attempt after the attempts.
+func (s *Sender) backoff(attempts int) time.Duration {
+	d := s.cfg.Backoff
+	for i := 1; i < attempts && d < s.cfg.MaxBackoff; i++ {
+		d *= 2
+	}
+	if d > s.cfg.MaxBackoff {
+		d = s.cfg.MaxBackoff
+	}
+	return d
+}
+
+// Sign returns the signature of the body sent at the unix timestamp, the
+// value of the HeaderSignature header. It is the hex encoded HMAC-SHA256 of
+// "<timestamp>.<body>" keyed with the secret of the webhook.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return "v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks the signature of a delivery the way receivers are expected
+// to. Timestamps further than the tolerance from now are rejected.
+func Verify(secret string, timestamp string, signature string, body []byte, now time.Time, tolerance time.Duration) error {
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+
+	if d := now.Sub(time.Unix(ts, 0)); d > tolerance || d < -tolerance {
+		return ErrExpiredSignature
+	}
+
+	if !hmac.Equal([]byte(signature), []byte(Sign(secret, ts, body))) {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
@@ -0,0 +1,308 @@
+// Package webhook provides the core business API for managing the webhooks
+// and delivering the domain events to them.
+package webhook
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/AgeroFlynn/crud/internal/buisness/core/dto"
+	"go.uber.org/zap"
+	"net/url"
+	"time"
+)
+
+// Set of error variables for webhook management.
+var (
+	ErrUnknownEvent = errors.New("unknown event type")
+	ErrInvalidURL   = errors.New("webhook url must be absolute http or https")
+)
+
+// events are the event types webhooks can subscribe to.
+var events = []string{
+	dto.WebhookAllEvents,
+	dto.EventUserCreated,
+	dto.EventUserUpdated,
+	dto.EventUserDeleted,
+	dto.EventRoleChanged,
+	dto.EventEntryChanged,
+}
+
+// WebhookStorer is the behavior required by the core to persist the webhooks
+// and their deliveries. It is implemented by the database store and by the
+// in-memory store used in tests.
+type WebhookStorer interface {
+	Create(ctx context.Context, webhook dto.Webhook) (dto.Webhook, error)
+	Update(ctx context.Context, webhook dto.Webhook) error
+	Delete(ctx context.Context, webhookID string) error
+	Query(ctx context.Context) ([]dto.Webhook, error)
+	QueryByID(ctx context.Context, webhookID string) (dto.Webhook, error)
+	QuerySubscribed(ctx context.Context, eventType string) ([]dto.Webhook, error)
+	AddDeliveries(ctx context.Context, deliveries ...dto.Delivery) error
+	ClaimDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]dto.Delivery, error)
+	UpdateDelivery(ctx context.Context, delivery dto.Delivery) error
+	QueryDeliveryByID(ctx context.Context, webhookID string, deliveryID string) (dto.Delivery, error)
+	QueryDeliveries(ctx context.Context, webhookID string, offset int, limit int) ([]dto.Delivery, int, error)
+}
+
+// Core manages the set of API's for webhook access.
+type Core struct {
+	log     *zap.SugaredLogger
+	webhook WebhookStorer
+}
+
+// NewCore constructs a core for webhook api access.
+func NewCore(log *zap.SugaredLogger, storer WebhookStorer) Core {
+	return Core{
+		log:     log,
+		webhook: storer,
+	}
+}
+
+// Create adds a new webhook. It is active right away.
+func (c Core) Create(ctx context.Context, nw dto.NewWebhook, now time.Time) (dto.Webhook, error) {
+
+	// PERFORM PRE BUSINESS OPERATIONS
+
+	if err := checkURL(nw.URL); err != nil {
+		return dto.Webhook{}, fmt.Errorf("create: %w", err)
+	}
+	if err := checkEvents(nw.Events); err != nil {
+		return dto.Webhook{}, fmt.Errorf("create: %w", err)
+	}
+
+	secret := nw.Secret
+	if secret == "" {
+		var err error
+		if secret, err = generateSecret(); err != nil {
+			return dto.Webhook{}, fmt.Errorf("create: %w", err)
+		}
+	}
+
+	webhook, err := c.webhook.Create(ctx, dto.Webhook{
+		URL:         nw.URL,
+		Secret:      secret,
+		Events:      nw.Events,
+		Description: nw.Description,
+		Active:      true,
+		DateCreated: now,
+		DateUpdated: now,
+	})
+	if err != nil {
+		return dto.Webhook{}, fmt.Errorf("create: %w", err)
+	}
+
+	// PERFORM POST BUSINESS OPERATIONS
+
+	return webhook, nil
+}
+
+// Update modifies a webhook. Deliveries already queued are sent with the
+// new URL and secret.
+func (c Core) Update(ctx context.Context, webhookID string, uw dto.UpdateWebhook, now time.Time) error {
+
+	// PERFORM PRE BUSINESS OPERATIONS
+
+	if uw.URL != nil {
+		if err := checkURL(*uw.URL); err != nil {
+			return fmt.Errorf("update: %w", err)
+		}
+	}
+	if uw.Events != nil {
+		if err := checkEvents(uw.Events); err != nil {
+			return fmt.Errorf("update: %w", err)
+		}
+	}
+
+	webhook, err := c.webhook.QueryByID(ctx, webhookID)
+	if err != nil {
+		return fmt.Errorf("update: webhookID[%s]: %w", webhookID, err)
+	}
+
+	if uw.URL != nil {
+		webhook.URL = *uw.URL
+	}
+	if uw.Secret != nil {
+		webhook.Secret = *uw.Secret
+	}
+	if uw.Events != nil {
+		webhook.Events = uw.Events
+	}
+	if uw.Description != nil {
+		webhook.Description = *uw.Description
+	}
+	if uw.Active != nil {
+		webhook.Active = *uw.Active
+	}
+	webhook.DateUpdated = now
+
+	if err := c.webhook.Update(ctx, webhook); err != nil {
+		return fmt.Errorf("update: webhookID[%s]: %w", webhookID, err)
+	}
+
+	// PERFORM POST BUSINESS OPERATIONS
+
+	return nil
+}
+
+// Delete removes a webhook together with its deliveries.
+func (c Core) Delete(ctx context.Context, webhookID string) error {
+
+	// PERFORM PRE BUSINESS OPERATIONS
+
+	if err := c.webhook.Delete(ctx, webhookID); err != nil {
+		return fmt.Errorf("delete: webhookID[%s]: %w", webhookID, err)
+	}
+
+	// PERFORM POST BUSINESS OPERATIONS
+
+	return nil
+}
+
+// Query retrieves all webhooks.
+func (c Core) Query(ctx context.Context) ([]dto.Webhook, error) {
+	webhooks, err := c.webhook.Query(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+
+	return webhooks, nil
+}
+
+// QueryByID gets the specified webhook.
+func (c Core) QueryByID(ctx context.Context, webhookID string) (dto.Webhook, error) {
+	webhook, err := c.webhook.QueryByID(ctx, webhookID)
+	if err != nil {
+		return dto.Webhook{}, fmt.Errorf("query: webhookID[%s]: %w", webhookID, err)
+	}
+
+	return webhook, nil
+}
+
+// QueryDeliveries retrieves a page of the deliveries of the webhook, newest
+// first, together with the total number of its deliveries.
+func (c Core) QueryDeliveries(ctx context.Context, webhookID string, offset int, limit int) ([]dto.Delivery, int, error) {
+	if _, err := c.webhook.QueryByID(ctx, webhookID); err != nil {
+		return nil, 0, fmt.Errorf("query: webhookID[%s]: %w", webhookID, err)
+	}
+
+	deliveries, total, err := c.webhook.QueryDeliveries(ctx, webhookID, offset, limit)
+	if err != nil {
+		return nil, 0, fmt.Errorf("query: webhookID[%s]: %w", webhookID, err)
+	}
+
+	return deliveries, total, nil
+}
+
+// Redeliver queues a delivery of the webhook again, whatever its outcome
+// was. It is sent with a fresh set of attempts.
+func (c Core) Redeliver(ctx context.Context, webhookID string, deliveryID string, now time.Time) (dto.Delivery, error) {
+	delivery, err := c.webhook.QueryDeliveryByID(ctx, webhookID, deliveryID)
+	if err != nil {
+		return dto.Delivery{}, fmt.Errorf("redeliver: deliveryID[%s]: %w", deliveryID, err)
+	}
+
+	delivery.Status = dto.DeliveryPending
+	delivery.Attempts = 0
+	delivery.DateNextAttempt = now
+	delivery.DateDelivered = nil
+
+	if err := c.webhook.UpdateDelivery(ctx, delivery); err != nil {
+		return dto.Delivery{}, fmt.Errorf("redeliver: deliveryID[%s]: %w", deliveryID, err)
+	}
+
+	return delivery, nil
+}
+
+// Enqueue queues a delivery of the event for every active webhook subscribed
+// to its type. It is meant to be subscribed to the outbox relay, queuing an
+// event again is a no-op.
+func (c Core) Enqueue(ctx context.Context, e dto.Event) error {
+	webhooks, err := c.webhook.QuerySubscribed(ctx, e.Type)
+	if err != nil {
+		return fmt.Errorf("enqueue: %w", err)
+	}
+	if len(webhooks) == 0 {
+		return nil
+	}
+
+	payload, err := json.Marshal(body{
+		ID:          e.ID,
+		Type:        e.Type,
+		AggregateID: e.AggregateID,
+		DateCreated: e.DateCreated,
+		Data:        e.Payload,
+	})
+	if err != nil {
+		return fmt.Errorf("enqueue: encoding eventID[%s]: %w", e.ID, err)
+	}
+
+	now := time.Now().UTC()
+	deliveries := make([]dto.Delivery, len(webhooks))
+	for i, webhook := range webhooks {
+		deliveries[i] = dto.Delivery{
+			WebhookID:       webhook.ID,
+			EventID:         e.ID,
+			EventType:       e.Type,
+			Payload:         payload,
+			Status:          dto.DeliveryPending,
+			DateCreated:     now,
+			DateNextAttempt: now,
+		}
+	}
+
+	if err := c.webhook.AddDeliveries(ctx, deliveries...); err != nil {
+		return fmt.Errorf("enqueue: %w", err)
+	}
+
+	return nil
+}
+
+// body is what receivers get for an event.
+type body struct {
+	ID          string          `json:"id"`
+	Type        string          `json:"type"`
+	AggregateID string          `json:"aggregate_id"`
+	DateCreated time.Time       `json:"date_created"`
+	Data        json.RawMessage `json:"data"`
+}
+
+// checkEvents returns ErrUnknownEvent when one of the event types doesn't
+// exist.
+func checkEvents(types []string) error {
+next:
+	for _, t := range types {
+		for _, e := range events {
+			if t == e {
+				continue next
+			}
+		}
+		return fmt.Errorf("%w: %s", ErrUnknownEvent, t)
+	}
+
+	return nil
+}
+
+// checkURL returns ErrInvalidURL unless the url is an absolute http or https
+// url.
+func checkURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ErrInvalidURL
+	}
+
+	return nil
+}
+
+// generateSecret returns a random secret for signing the deliveries.
+func generateSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generating secret: %w", err)
+	}
+
+	return hex.EncodeToString(b), nil
+}
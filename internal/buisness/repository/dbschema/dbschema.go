@@ -27,12 +27,12 @@ func Migrate(ctx context.Context, db *pg.DB) error {
 		return fmt.Errorf("status check database: %w", err)
 	}
 
-	tx, err := db.Begin()
+	tx, err := db.BeginContext(ctx)
 	if err != nil {
 		return err
 	}
 
-	if _, err := tx.Exec(schemaDoc); err != nil {
+	if _, err := tx.ExecContext(ctx, schemaDoc); err != nil {
 		if err := tx.Rollback(); err != nil {
 			return err
 		}
@@ -49,12 +49,12 @@ func Seed(ctx context.Context, db *pg.DB) error {
 		return fmt.Errorf("status check database: %w", err)
 	}
 
-	tx, err := db.Begin()
+	tx, err := db.BeginContext(ctx)
 	if err != nil {
 		return err
 	}
 
-	if _, err := tx.Exec(seedDoc); err != nil {
+	if _, err := tx.ExecContext(ctx, seedDoc); err != nil {
 		if err := tx.Rollback(); err != nil {
 			return err
 		}
@@ -1,2 +1,14 @@
+DROP TABLE IF EXISTS login_history;
+DROP TABLE IF EXISTS webhook_deliveries;
+DROP TABLE IF EXISTS webhooks;
+DROP TABLE IF EXISTS outbox;
+DROP TABLE IF EXISTS audit_log;
+DROP FUNCTION IF EXISTS audit_log_immutable;
+DROP TABLE IF EXISTS role_permissions;
+DROP TABLE IF EXISTS roles;
+DROP TABLE IF EXISTS permissions;
+DROP TABLE IF EXISTS share_link_accesses;
+DROP TABLE IF EXISTS share_links;
+DROP TABLE IF EXISTS password_history;
 DROP TABLE IF EXISTS phone_dict;
 DROP TABLE IF EXISTS users;
@@ -23,4 +23,251 @@ CREATE TABLE IF NOT EXISTS phone_dict (
 
                           PRIMARY KEY (phone_dict_id),
                           FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE
-);
\ No newline at end of file
+);
+
+CREATE TABLE IF NOT EXISTS share_links (
+                          share_link_id UUID DEFAULT uuid_generate_v4 (),
+                          user_id       UUID NOT NULL,
+                          single_use    BOOLEAN NOT NULL DEFAULT FALSE,
+                          use_count     INT NOT NULL DEFAULT 0,
+                          expires_at    TIMESTAMP NOT NULL,
+                          date_revoked  TIMESTAMP,
+                          date_created  TIMESTAMP,
+
+                          PRIMARY KEY (share_link_id),
+                          FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS share_link_accesses (
+                          share_link_access_id UUID DEFAULT uuid_generate_v4 (),
+                          share_link_id        UUID NOT NULL,
+                          remote_addr          TEXT,
+                          user_agent           TEXT,
+                          granted              BOOLEAN NOT NULL,
+                          date_accessed        TIMESTAMP,
+
+                          PRIMARY KEY (share_link_access_id),
+                          FOREIGN KEY (share_link_id) REFERENCES share_links(share_link_id) ON DELETE CASCADE
+);
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS version INT NOT NULL DEFAULT 1;
+
+-- Emails are unique regardless of their case. Accounts that only differ by
+-- the case of their email must be merged by hand before the index can be
+-- built, they are listed in the error.
+DO $$
+DECLARE
+    collisions TEXT;
+BEGIN
+    SELECT string_agg(emails, '; ') INTO collisions FROM (
+        SELECT string_agg(email || ' (' || user_id || ')', ', ' ORDER BY email) AS emails
+        FROM users
+        GROUP BY lower(trim(email))
+        HAVING count(*) > 1
+    ) AS c;
+
+    IF collisions IS NOT NULL THEN
+        RAISE EXCEPTION 'users with colliding emails: %', collisions;
+    END IF;
+END $$;
+
+-- Stored emails are trimmed and their domain is lowercased.
+UPDATE users SET email = substring(trim(email) from '^(.*)@') || lower(substring(trim(email) from '(@[^@]*)$'))
+WHERE email LIKE '%@%'
+  AND email <> substring(trim(email) from '^(.*)@') || lower(substring(trim(email) from '(@[^@]*)$'));
+
+CREATE UNIQUE INDEX IF NOT EXISTS users_email_lower_key ON users (lower(email));
+
+-- Accounts created before emails were verified are trusted.
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM information_schema.columns WHERE table_name = 'users' AND column_name = 'date_verified') THEN
+        ALTER TABLE users ADD COLUMN date_verified TIMESTAMP;
+        UPDATE users SET date_verified = date_created;
+    END IF;
+END $$;
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS date_tokens_revoked TIMESTAMP;
+
+CREATE TABLE IF NOT EXISTS password_history (
+                          password_history_id UUID DEFAULT uuid_generate_v4 (),
+                          user_id             UUID NOT NULL,
+                          password_hash       bytea,
+                          date_created        TIMESTAMP,
+
+                          PRIMARY KEY (password_history_id),
+                          FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS password_history_user_idx ON password_history (user_id, date_created DESC);
+
+-- Accounts are active unless an admin changes their status.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'ACTIVE';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS status_reason TEXT;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS date_status_changed TIMESTAMP;
+
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_status_check;
+ALTER TABLE users ADD CONSTRAINT users_status_check CHECK (status IN ('ACTIVE', 'PENDING', 'SUSPENDED', 'DISABLED'));
+
+-- Roles grant permissions, the roles of a user are resolved into the
+-- permissions of their token.
+CREATE TABLE IF NOT EXISTS permissions (
+                          permission_id TEXT,
+                          description   TEXT NOT NULL DEFAULT '',
+
+                          PRIMARY KEY (permission_id)
+);
+
+CREATE TABLE IF NOT EXISTS roles (
+                          role_id      TEXT,
+                          description  TEXT NOT NULL DEFAULT '',
+                          date_created TIMESTAMP,
+                          date_updated TIMESTAMP,
+
+                          PRIMARY KEY (role_id)
+);
+
+CREATE TABLE IF NOT EXISTS role_permissions (
+                          role_id       TEXT NOT NULL,
+                          permission_id TEXT NOT NULL,
+
+                          PRIMARY KEY (role_id, permission_id),
+                          FOREIGN KEY (role_id) REFERENCES roles(role_id) ON DELETE CASCADE,
+                          FOREIGN KEY (permission_id) REFERENCES permissions(permission_id) ON DELETE CASCADE
+);
+
+INSERT INTO permissions (permission_id, description) VALUES
+                          ('users:read', 'Read the accounts of other users'),
+                          ('users:write', 'Create, modify and delete the accounts of other users'),
+                          ('directory:export', 'Export the contact cards of other users'),
+                          ('roles:read', 'Read roles and permissions'),
+                          ('roles:write', 'Create, modify and delete roles'),
+                          ('roles:assign', 'Assign roles to users'),
+                          ('audit:read', 'Read and export the audit log'),
+                          ('webhooks:manage', 'Manage the webhooks and their deliveries')
+ON CONFLICT DO NOTHING;
+
+-- The built-in roles. Admins are granted every permission, users only have
+-- access to their own account.
+INSERT INTO roles (role_id, description, date_created, date_updated) VALUES
+                          ('ADMIN', 'Administrators', now(), now()),
+                          ('USER', 'Users', now(), now())
+ON CONFLICT DO NOTHING;
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT 'ADMIN', permission_id FROM permissions
+ON CONFLICT DO NOTHING;
+
+-- The audit log is append-only, entries can't be modified or removed once
+-- they are written.
+CREATE TABLE IF NOT EXISTS audit_log (
+                          audit_id     UUID DEFAULT uuid_generate_v4 (),
+                          actor_id     TEXT NOT NULL DEFAULT '',
+                          action       TEXT NOT NULL,
+                          target_type  TEXT NOT NULL,
+                          target_id    TEXT NOT NULL DEFAULT '',
+                          changes      JSONB,
+                          trace_id     TEXT NOT NULL DEFAULT '',
+                          remote_addr  TEXT NOT NULL DEFAULT '',
+                          outcome      TEXT NOT NULL,
+                          reason       TEXT NOT NULL DEFAULT '',
+                          date_created TIMESTAMP NOT NULL,
+
+                          PRIMARY KEY (audit_id)
+);
+
+CREATE INDEX IF NOT EXISTS audit_log_date_idx ON audit_log (date_created, audit_id);
+CREATE INDEX IF NOT EXISTS audit_log_actor_idx ON audit_log (actor_id, date_created);
+CREATE INDEX IF NOT EXISTS audit_log_target_idx ON audit_log (target_id, date_created);
+
+CREATE OR REPLACE FUNCTION audit_log_immutable() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit log entries can not be modified';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS audit_log_immutable ON audit_log;
+CREATE TRIGGER audit_log_immutable BEFORE UPDATE OR DELETE ON audit_log
+    FOR EACH ROW EXECUTE FUNCTION audit_log_immutable();
+
+-- Domain events are written to the outbox in the transaction of the change
+-- they describe, the relay dispatches them afterwards.
+CREATE TABLE IF NOT EXISTS outbox (
+                          outbox_id       UUID DEFAULT uuid_generate_v4 (),
+                          event_type      TEXT NOT NULL,
+                          aggregate_id    TEXT NOT NULL DEFAULT '',
+                          payload         JSONB,
+                          attempts        INT NOT NULL DEFAULT 0,
+                          last_error      TEXT NOT NULL DEFAULT '',
+                          date_created    TIMESTAMP NOT NULL,
+                          date_available  TIMESTAMP NOT NULL,
+                          date_dispatched TIMESTAMP,
+                          date_dead       TIMESTAMP,
+
+                          PRIMARY KEY (outbox_id)
+);
+
+CREATE INDEX IF NOT EXISTS outbox_pending_idx ON outbox (date_available) WHERE date_dispatched IS NULL AND date_dead IS NULL;
+
+-- Webhooks subscribe downstream systems to the domain events. Every event a
+-- webhook subscribes to is queued as a delivery, the deliveries are the log
+-- of what was sent and how the receiver answered.
+CREATE TABLE IF NOT EXISTS webhooks (
+                          webhook_id   UUID DEFAULT uuid_generate_v4 (),
+                          url          TEXT NOT NULL,
+                          secret       TEXT NOT NULL,
+                          events       TEXT[] NOT NULL,
+                          description  TEXT NOT NULL DEFAULT '',
+                          active       BOOLEAN NOT NULL DEFAULT TRUE,
+                          date_created TIMESTAMP NOT NULL,
+                          date_updated TIMESTAMP NOT NULL,
+
+                          PRIMARY KEY (webhook_id)
+);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+                          delivery_id       UUID DEFAULT uuid_generate_v4 (),
+                          webhook_id        UUID NOT NULL,
+                          event_id          TEXT NOT NULL,
+                          event_type        TEXT NOT NULL,
+                          payload           JSONB,
+                          status            TEXT NOT NULL,
+                          attempts          INT NOT NULL DEFAULT 0,
+                          response_code     INT NOT NULL DEFAULT 0,
+                          last_error        TEXT NOT NULL DEFAULT '',
+                          date_created      TIMESTAMP NOT NULL,
+                          date_next_attempt TIMESTAMP NOT NULL,
+                          date_delivered    TIMESTAMP,
+
+                          PRIMARY KEY (delivery_id),
+                          UNIQUE (webhook_id, event_id),
+                          FOREIGN KEY (webhook_id) REFERENCES webhooks(webhook_id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS webhook_deliveries_pending_idx ON webhook_deliveries (date_next_attempt) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS webhook_deliveries_webhook_idx ON webhook_deliveries (webhook_id, date_created);
+
+-- Every login attempt is kept to spot compromised accounts. Attempts with an
+-- unknown email have no user, the attempts of deleted users keep their email.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS last_login_at TIMESTAMP;
+
+CREATE TABLE IF NOT EXISTS login_history (
+                          login_id     UUID DEFAULT uuid_generate_v4 (),
+                          user_id      UUID,
+                          email        TEXT NOT NULL,
+                          remote_addr  TEXT NOT NULL DEFAULT '',
+                          user_agent   TEXT NOT NULL DEFAULT '',
+                          outcome      TEXT NOT NULL,
+                          reason       TEXT NOT NULL DEFAULT '',
+                          date_created TIMESTAMP NOT NULL,
+
+                          PRIMARY KEY (login_id),
+                          FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS login_history_user_idx ON login_history (user_id, date_created);
+CREATE INDEX IF NOT EXISTS login_history_date_idx ON login_history (date_created, login_id);
+
+-- Attempts to open a share link with a token that isn't valid are kept too,
+-- they have no link.
+ALTER TABLE share_link_accesses ALTER COLUMN share_link_id DROP NOT NULL;
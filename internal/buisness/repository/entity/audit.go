@@ -0,0 +1,64 @@
+package entity
+
+import (
+	"github.com/AgeroFlynn/crud/internal/buisness/core/dto"
+	"time"
+)
+
+// AuditEntry represents an entry of the append-only audit log.
+type AuditEntry struct {
+	tableName struct{} `pg:"audit_log"`
+
+	ID          string            `pg:"audit_id,pk,type:uuid"`
+	ActorID     string            `pg:"actor_id,use_zero"`
+	Action      string            `pg:"action"`
+	TargetType  string            `pg:"target_type"`
+	TargetID    string            `pg:"target_id,use_zero"`
+	Changes     []dto.AuditChange `pg:"changes,type:jsonb"`
+	TraceID     string            `pg:"trace_id,use_zero"`
+	RemoteAddr  string            `pg:"remote_addr,use_zero"`
+	Outcome     string            `pg:"outcome"`
+	Reason      string            `pg:"reason,use_zero"`
+	DateCreated time.Time         `pg:"date_created"`
+}
+
+func (a *AuditEntry) ToDTOAuditEntry() *dto.AuditEntry {
+	return &dto.AuditEntry{
+		ID:          a.ID,
+		ActorID:     a.ActorID,
+		Action:      a.Action,
+		TargetType:  a.TargetType,
+		TargetID:    a.TargetID,
+		Changes:     a.Changes,
+		TraceID:     a.TraceID,
+		RemoteAddr:  a.RemoteAddr,
+		Outcome:     a.Outcome,
+		Reason:      a.Reason,
+		DateCreated: a.DateCreated,
+	}
+}
+
+func FromDTOAuditEntry(a *dto.AuditEntry) *AuditEntry {
+	return &AuditEntry{
+		ID:          a.ID,
+		ActorID:     a.ActorID,
+		Action:      a.Action,
+		TargetType:  a.TargetType,
+		TargetID:    a.TargetID,
+		Changes:     a.Changes,
+		TraceID:     a.TraceID,
+		RemoteAddr:  a.RemoteAddr,
+		Outcome:     a.Outcome,
+		Reason:      a.Reason,
+		DateCreated: a.DateCreated,
+	}
+}
+
+func ToDTOAuditEntrySlice(entries *[]AuditEntry) *[]dto.AuditEntry {
+	var dtoEntries []dto.AuditEntry
+
+	for _, entry := range *entries {
+		dtoEntries = append(dtoEntries, *entry.ToDTOAuditEntry())
+	}
+	return &dtoEntries
+}
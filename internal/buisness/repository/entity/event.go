@@ -0,0 +1,62 @@
+package entity
+
+import (
+	"encoding/json"
+	"github.com/AgeroFlynn/crud/internal/buisness/core/dto"
+	"time"
+)
+
+// Event represents a domain event held in the outbox.
+type Event struct {
+	tableName struct{} `pg:"outbox"`
+
+	ID             string          `pg:"outbox_id,pk,type:uuid"`
+	Type           string          `pg:"event_type"`
+	AggregateID    string          `pg:"aggregate_id,use_zero"`
+	Payload        json.RawMessage `pg:"payload,type:jsonb"`
+	Attempts       int             `pg:"attempts,use_zero"`
+	LastError      string          `pg:"last_error,use_zero"`
+	DateCreated    time.Time       `pg:"date_created"`
+	DateAvailable  time.Time       `pg:"date_available"`
+	DateDispatched *time.Time      `pg:"date_dispatched"`
+	DateDead       *time.Time      `pg:"date_dead"`
+}
+
+func (e *Event) ToDTOEvent() *dto.Event {
+	return &dto.Event{
+		ID:             e.ID,
+		Type:           e.Type,
+		AggregateID:    e.AggregateID,
+		Payload:        e.Payload,
+		Attempts:       e.Attempts,
+		LastError:      e.LastError,
+		DateCreated:    e.DateCreated,
+		DateAvailable:  e.DateAvailable,
+		DateDispatched: e.DateDispatched,
+		DateDead:       e.DateDead,
+	}
+}
+
+func FromDTOEvent(e *dto.Event) *Event {
+	return &Event{
+		ID:             e.ID,
+		Type:           e.Type,
+		AggregateID:    e.AggregateID,
+		Payload:        e.Payload,
+		Attempts:       e.Attempts,
+		LastError:      e.LastError,
+		DateCreated:    e.DateCreated,
+		DateAvailable:  e.DateAvailable,
+		DateDispatched: e.DateDispatched,
+		DateDead:       e.DateDead,
+	}
+}
+
+func ToDTOEventSlice(events *[]Event) *[]dto.Event {
+	var dtoEvents []dto.Event
+
+	for _, event := range *events {
+		dtoEvents = append(dtoEvents, *event.ToDTOEvent())
+	}
+	return &dtoEvents
+}
@@ -0,0 +1,65 @@
+package entity
+
+import (
+	"github.com/AgeroFlynn/crud/internal/buisness/core/dto"
+	"time"
+)
+
+// Login represents an attempt to log in.
+type Login struct {
+	tableName struct{} `pg:"login_history"`
+
+	ID          string    `pg:"login_id,pk,type:uuid"`
+	UserID      *string   `pg:"user_id,type:uuid"`
+	Email       string    `pg:"email"`
+	RemoteAddr  string    `pg:"remote_addr,use_zero"`
+	UserAgent   string    `pg:"user_agent,use_zero"`
+	Outcome     string    `pg:"outcome"`
+	Reason      string    `pg:"reason,use_zero"`
+	DateCreated time.Time `pg:"date_created"`
+}
+
+func (l *Login) ToDTOLogin() *dto.Login {
+	var userID string
+	if l.UserID != nil {
+		userID = *l.UserID
+	}
+
+	return &dto.Login{
+		ID:          l.ID,
+		UserID:      userID,
+		Email:       l.Email,
+		RemoteAddr:  l.RemoteAddr,
+		UserAgent:   l.UserAgent,
+		Outcome:     l.Outcome,
+		Reason:      l.Reason,
+		DateCreated: l.DateCreated,
+	}
+}
+
+func FromDTOLogin(l *dto.Login) *Login {
+	var userID *string
+	if l.UserID != "" {
+		userID = &l.UserID
+	}
+
+	return &Login{
+		ID:          l.ID,
+		UserID:      userID,
+		Email:       l.Email,
+		RemoteAddr:  l.RemoteAddr,
+		UserAgent:   l.UserAgent,
+		Outcome:     l.Outcome,
+		Reason:      l.Reason,
+		DateCreated: l.DateCreated,
+	}
+}
+
+func ToDTOLoginSlice(logins *[]Login) *[]dto.Login {
+	var dtoLogins []dto.Login
+
+	for _, login := range *logins {
+		dtoLogins = append(dtoLogins, *login.ToDTOLogin())
+	}
+	return &dtoLogins
+}
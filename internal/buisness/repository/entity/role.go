@@ -0,0 +1,58 @@
+package entity
+
+import (
+	"github.com/AgeroFlynn/crud/internal/buisness/core/dto"
+	"time"
+)
+
+// Role represents a named set of permissions.
+type Role struct {
+	tableName struct{} `pg:"roles"`
+
+	Name        string    `pg:"role_id,pk"`
+	Description string    `pg:"description,use_zero"`
+	DateCreated time.Time `pg:"date_created"`
+	DateUpdated time.Time `pg:"date_updated"`
+}
+
+func (r *Role) ToDTORole(permissions []string) *dto.Role {
+	return &dto.Role{
+		Name:        r.Name,
+		Description: r.Description,
+		Permissions: permissions,
+		DateCreated: r.DateCreated,
+		DateUpdated: r.DateUpdated,
+	}
+}
+
+// Permission represents an action roles can grant.
+type Permission struct {
+	tableName struct{} `pg:"permissions"`
+
+	Name        string `pg:"permission_id,pk"`
+	Description string `pg:"description,use_zero"`
+}
+
+func (p *Permission) ToDTOPermission() *dto.Permission {
+	return &dto.Permission{
+		Name:        p.Name,
+		Description: p.Description,
+	}
+}
+
+func ToDTOPermissionSlice(permissions *[]Permission) *[]dto.Permission {
+	var dtoPermissions []dto.Permission
+
+	for _, p := range *permissions {
+		dtoPermissions = append(dtoPermissions, *p.ToDTOPermission())
+	}
+	return &dtoPermissions
+}
+
+// RolePermission grants a permission to a role.
+type RolePermission struct {
+	tableName struct{} `pg:"role_permissions"`
+
+	RoleName       string `pg:"role_id,pk"`
+	PermissionName string `pg:"permission_id,pk"`
+}
@@ -0,0 +1,83 @@
+package entity
+
+import (
+	"github.com/AgeroFlynn/crud/internal/buisness/core/dto"
+	"time"
+)
+
+// ShareLink represents a signed link that exposes a user's contact card.
+type ShareLink struct {
+	tableName struct{} `pg:"share_links"`
+
+	ID          string     `pg:"share_link_id,pk,type:uuid"`
+	UserID      string     `pg:"user_id,type:uuid"`
+	SingleUse   bool       `pg:"single_use,use_zero"`
+	UseCount    int        `pg:"use_count,use_zero"`
+	ExpiresAt   time.Time  `pg:"expires_at"`
+	DateRevoked *time.Time `pg:"date_revoked"`
+	DateCreated time.Time  `pg:"date_created"`
+}
+
+func (sl *ShareLink) ToDTOShareLink() *dto.ShareLink {
+	return &dto.ShareLink{
+		ID:          sl.ID,
+		UserID:      sl.UserID,
+		SingleUse:   sl.SingleUse,
+		UseCount:    sl.UseCount,
+		ExpiresAt:   sl.ExpiresAt,
+		DateRevoked: sl.DateRevoked,
+		DateCreated: sl.DateCreated,
+	}
+}
+
+func ToDTOShareLinkSlice(links *[]ShareLink) *[]dto.ShareLink {
+	var dtoLinks []dto.ShareLink
+
+	for _, link := range *links {
+		dtoLinks = append(dtoLinks, *link.ToDTOShareLink())
+	}
+	return &dtoLinks
+}
+
+// ShareLinkAccess represents a single attempt to open a ShareLink.
+type ShareLinkAccess struct {
+	tableName struct{} `pg:"share_link_accesses"`
+
+	ID           string    `pg:"share_link_access_id,pk,type:uuid"`
+	ShareLinkID  string    `pg:"share_link_id,type:uuid"`
+	RemoteAddr   string    `pg:"remote_addr"`
+	UserAgent    string    `pg:"user_agent"`
+	Granted      bool      `pg:"granted,use_zero"`
+	DateAccessed time.Time `pg:"date_accessed"`
+}
+
+func (a *ShareLinkAccess) ToDTOShareLinkAccess() *dto.ShareLinkAccess {
+	return &dto.ShareLinkAccess{
+		ID:           a.ID,
+		ShareLinkID:  a.ShareLinkID,
+		RemoteAddr:   a.RemoteAddr,
+		UserAgent:    a.UserAgent,
+		Granted:      a.Granted,
+		DateAccessed: a.DateAccessed,
+	}
+}
+
+func FromDTOShareLinkAccess(a *dto.ShareLinkAccess) *ShareLinkAccess {
+	return &ShareLinkAccess{
+		ID:           a.ID,
+		ShareLinkID:  a.ShareLinkID,
+		RemoteAddr:   a.RemoteAddr,
+		UserAgent:    a.UserAgent,
+		Granted:      a.Granted,
+		DateAccessed: a.DateAccessed,
+	}
+}
+
+func ToDTOShareLinkAccessSlice(accesses *[]ShareLinkAccess) *[]dto.ShareLinkAccess {
+	var dtoAccesses []dto.ShareLinkAccess
+
+	for _, access := range *accesses {
+		dtoAccesses = append(dtoAccesses, *access.ToDTOShareLinkAccess())
+	}
+	return &dtoAccesses
+}
@@ -9,36 +9,57 @@ import "github.com/lib/pq"
 
 // User represents an individual user.
 type User struct {
-	ID           string         `pg:"user_id,pk,type:uuid"`
-	Name         string         `pg:"name"`
-	Email        string         `pg:"email"`
-	Roles        pq.StringArray `pg:"roles"`
-	PasswordHash []byte         `pg:"password_hash"`
-	DateCreated  time.Time      `pg:"date_created"`
-	DateUpdated  time.Time      `pg:"date_updated"`
+	ID                string         `pg:"user_id,pk,type:uuid"`
+	Name              string         `pg:"name"`
+	Email             string         `pg:"email"`
+	Roles             pq.StringArray `pg:"roles"`
+	PasswordHash      []byte         `pg:"password_hash"`
+	DateCreated       time.Time      `pg:"date_created"`
+	DateUpdated       time.Time      `pg:"date_updated"`
+	DateVerified      *time.Time     `pg:"date_verified"`
+	DateTokensRevoked *time.Time     `pg:"date_tokens_revoked"`
+	Status            string         `pg:"status"`
+	StatusReason      string         `pg:"status_reason"`
+	DateStatusChanged *time.Time     `pg:"date_status_changed"`
+	LastLoginAt       *time.Time     `pg:"last_login_at"`
+	Version           int            `pg:"version,use_zero"`
 }
 
 func (u *User) ToDTOUser() *dto.User {
 	return &dto.User{
-		ID:           u.ID,
-		Name:         u.Name,
-		Email:        u.Email,
-		Roles:        u.Roles,
-		PasswordHash: u.PasswordHash,
-		DateCreated:  u.DateCreated,
-		DateUpdated:  u.DateUpdated,
+		ID:                u.ID,
+		Name:              u.Name,
+		Email:             u.Email,
+		Roles:             u.Roles,
+		PasswordHash:      u.PasswordHash,
+		DateCreated:       u.DateCreated,
+		DateUpdated:       u.DateUpdated,
+		DateVerified:      u.DateVerified,
+		DateTokensRevoked: u.DateTokensRevoked,
+		Status:            u.Status,
+		StatusReason:      u.StatusReason,
+		DateStatusChanged: u.DateStatusChanged,
+		LastLoginAt:       u.LastLoginAt,
+		Version:           u.Version,
 	}
 }
 
 func FromDTOUser(user *dto.User) *User {
 	return &User{
-		ID:           user.ID,
-		Name:         user.Name,
-		Email:        user.Email,
-		Roles:        user.Roles,
-		PasswordHash: user.PasswordHash,
-		DateCreated:  user.DateCreated,
-		DateUpdated:  user.DateUpdated,
+		ID:                user.ID,
+		Name:              user.Name,
+		Email:             user.Email,
+		Roles:             user.Roles,
+		PasswordHash:      user.PasswordHash,
+		DateCreated:       user.DateCreated,
+		DateUpdated:       user.DateUpdated,
+		DateVerified:      user.DateVerified,
+		DateTokensRevoked: user.DateTokensRevoked,
+		Status:            user.Status,
+		StatusReason:      user.StatusReason,
+		DateStatusChanged: user.DateStatusChanged,
+		LastLoginAt:       user.LastLoginAt,
+		Version:           user.Version,
 	}
 }
 
@@ -50,3 +71,13 @@ func ToDTOUserSlice(users *[]User) *[]dto.User {
 	}
 	return &dtoUsers
 }
+
+// PasswordHistory represents a password a user had at some point.
+type PasswordHistory struct {
+	tableName struct{} `pg:"password_history"`
+
+	ID           string    `pg:"password_history_id,pk,type:uuid"`
+	UserID       string    `pg:"user_id,type:uuid"`
+	PasswordHash []byte    `pg:"password_hash"`
+	DateCreated  time.Time `pg:"date_created"`
+}
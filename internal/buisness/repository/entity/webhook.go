@@ -0,0 +1,118 @@
+package entity
+
+import (
+	"encoding/json"
+	"github.com/AgeroFlynn/crud/internal/buisness/core/dto"
+	"github.com/lib/pq"
+	"time"
+)
+
+// Webhook represents a subscription of a downstream system to domain events.
+type Webhook struct {
+	tableName struct{} `pg:"webhooks"`
+
+	ID          string         `pg:"webhook_id,pk,type:uuid"`
+	URL         string         `pg:"url"`
+	Secret      string         `pg:"secret"`
+	Events      pq.StringArray `pg:"events"`
+	Description string         `pg:"description,use_zero"`
+	Active      bool           `pg:"active,use_zero"`
+	DateCreated time.Time      `pg:"date_created"`
+	DateUpdated time.Time      `pg:"date_updated"`
+}
+
+func (w *Webhook) ToDTOWebhook() *dto.Webhook {
+	return &dto.Webhook{
+		ID:          w.ID,
+		URL:         w.URL,
+		Secret:      w.Secret,
+		Events:      w.Events,
+		Description: w.Description,
+		Active:      w.Active,
+		DateCreated: w.DateCreated,
+		DateUpdated: w.DateUpdated,
+	}
+}
+
+func FromDTOWebhook(w *dto.Webhook) *Webhook {
+	return &Webhook{
+		ID:          w.ID,
+		URL:         w.URL,
+		Secret:      w.Secret,
+		Events:      w.Events,
+		Description: w.Description,
+		Active:      w.Active,
+		DateCreated: w.DateCreated,
+		DateUpdated: w.DateUpdated,
+	}
+}
+
+func ToDTOWebhookSlice(webhooks *[]Webhook) *[]dto.Webhook {
+	var dtoWebhooks []dto.Webhook
+
+	for _, webhook := range *webhooks {
+		dtoWebhooks = append(dtoWebhooks, *webhook.ToDTOWebhook())
+	}
+	return &dtoWebhooks
+}
+
+// Delivery represents an event queued for a webhook.
+type Delivery struct {
+	tableName struct{} `pg:"webhook_deliveries"`
+
+	ID              string          `pg:"delivery_id,pk,type:uuid"`
+	WebhookID       string          `pg:"webhook_id,type:uuid"`
+	EventID         string          `pg:"event_id"`
+	EventType       string          `pg:"event_type"`
+	Payload         json.RawMessage `pg:"payload,type:jsonb"`
+	Status          string          `pg:"status"`
+	Attempts        int             `pg:"attempts,use_zero"`
+	ResponseCode    int             `pg:"response_code,use_zero"`
+	LastError       string          `pg:"last_error,use_zero"`
+	DateCreated     time.Time       `pg:"date_created"`
+	DateNextAttempt time.Time       `pg:"date_next_attempt"`
+	DateDelivered   *time.Time      `pg:"date_delivered"`
+}
+
+func (d *Delivery) ToDTODelivery() *dto.Delivery {
+	return &dto.Delivery{
+		ID:              d.ID,
+		WebhookID:       d.WebhookID,
+		EventID:         d.EventID,
+		EventType:       d.EventType,
+		Payload:         d.Payload,
+		Status:          d.Status,
+		Attempts:        d.Attempts,
+		ResponseCode:    d.ResponseCode,
+		LastError:       d.LastError,
+		DateCreated:     d.DateCreated,
+		DateNextAttempt: d.DateNextAttempt,
+		DateDelivered:   d.DateDelivered,
+	}
+}
+
+func FromDTODelivery(d *dto.Delivery) *Delivery {
+	return &Delivery{
+		ID:              d.ID,
+		WebhookID:       d.WebhookID,
+		EventID:         d.EventID,
+		EventType:       d.EventType,
+		Payload:         d.Payload,
+		Status:          d.Status,
+		Attempts:        d.Attempts,
+		ResponseCode:    d.ResponseCode,
+		LastError:       d.LastError,
+		DateCreated:     d.DateCreated,
+		DateNextAttempt: d.DateNextAttempt,
+		DateDelivered:   d.DateDelivered,
+	}
+}
+
+func ToDTODeliverySlice(deliveries *[]Delivery) *[]dto.Delivery {
+	var dtoDeliveries []dto.Delivery
+
+	for _, delivery := range *deliveries {
+		dtoDeliveries = append(dtoDeliveries, *delivery.ToDTODelivery())
+	}
+	return &dtoDeliveries
+}
@@ -0,0 +1,86 @@
+// Package order provides support for describing the ordering of data.
+package order
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Set of directions for data ordering.
+const (
+	ASC  = "ASC"
+	DESC = "DESC"
+)
+
+var directions = map[string]string{
+	ASC:  "ASC",
+	DESC: "DESC",
+}
+
+// By represents a field used to order by and direction.
+type By struct {
+	Field     string
+	Direction string
+}
+
+// NewBy constructs a new By value with no checks.
+func NewBy(field string, direction string) By {
+	return By{
+		Field:     field,
+		Direction: direction,
+	}
+}
+
+// Parse constructs an order.By value by parsing a string in the form of
+// "field,direction". The field has to be one of the keys of the whitelist
+// which maps the names used by clients to database columns. An empty string
+// returns the default value.
+func Parse(orderBy string, whitelist map[string]string, defaultOrder By) (By, error) {
+	if orderBy == "" {
+		return defaultOrder, nil
+	}
+
+	orderParts := strings.Split(orderBy, ",")
+
+	field, exists := whitelist[strings.TrimSpace(orderParts[0])]
+	if !exists {
+		return By{}, fmt.Errorf("unknown order field %q", orderParts[0])
+	}
+
+	switch len(orderParts) {
+	case 1:
+		return NewBy(field, ASC), nil
+
+	case 2:
+		dir, exists := directions[strings.ToUpper(strings.TrimSpace(orderParts[1]))]
+		if !exists {
+			return By{}, fmt.Errorf("unknown direction %q", orderParts[1])
+		}
+		return NewBy(field, dir), nil
+
+	default:
+		return By{}, errors.New("unknown order field")
+	}
+}
+
+// Reverse returns the opposite of the direction.
+func Reverse(direction string) string {
+	if direction == DESC {
+		return ASC
+	}
+	return DESC
+}
+
+// Clause returns the ordering as a SQL ORDER BY expression. The field is
+// expected to come from a whitelist so it is safe to use verbatim.
+func (b By) Clause() string {
+	return b.Field + " " + b.Direction
+}
+
+// Key is a position inside an ordering, the value of the ordering field and
+// the id breaking ties between equal values. It is used for keyset paging.
+type Key struct {
+	Value string
+	ID    string
+}
@@ -0,0 +1,89 @@
+// Package audit contains the append-only audit log functionality.
+package audit
+
+import (
+	"context"
+	"fmt"
+	"github.com/AgeroFlynn/crud/internal/buisness/core/dto"
+	"github.com/AgeroFlynn/crud/internal/buisness/repository/entity"
+	"github.com/AgeroFlynn/crud/internal/buisness/sys/validate"
+	"github.com/AgeroFlynn/crud/internal/foundation/database"
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+	"go.uber.org/zap"
+)
+
+// Store manages the set of API's for audit log access. Entries can only be
+// appended, the database rejects modifications.
+type Store struct {
+	log *zap.SugaredLogger
+	db  *pg.DB
+}
+
+// NewStore constructs an audit store for api access.
+func NewStore(log *zap.SugaredLogger, db *pg.DB) Store {
+	return Store{
+		log: log,
+		db:  db,
+	}
+}
+
+// Append adds the entry to the audit log. Successful operations are recorded
+// in the transaction of the context, they are kept only when the operation
+// is committed. Failures and denials don't join it, they are kept even when
+// the operation is rolled back.
+func (s Store) Append(ctx context.Context, entry dto.AuditEntry) (dto.AuditEntry, error) {
+	a := entity.FromDTOAuditEntry(&entry)
+	a.ID = validate.GenerateID()
+
+	var conn orm.DB = s.db
+	if entry.Outcome == dto.OutcomeSuccess {
+		conn = database.Conn(ctx, s.db)
+	}
+
+	if _, err := conn.ModelContext(ctx, a).Insert(); err != nil {
+		return dto.AuditEntry{}, fmt.Errorf("inserting audit entry: %w", database.MapError(err))
+	}
+
+	return *a.ToDTOAuditEntry(), nil
+}
+
+// Query retrieves a page of entries matching the filter, newest first,
+// together with the total number of matching entries.
+func (s Store) Query(ctx context.Context, filter dto.AuditFilter, offset int, limit int) ([]dto.AuditEntry, int, error) {
+	var entries []entity.AuditEntry
+	q := database.Conn(ctx, s.db).ModelContext(ctx, &entries)
+	applyFilter(q, filter)
+
+	total, err := q.Order("date_created DESC", "audit_id DESC").Offset(offset).Limit(limit).SelectAndCount()
+	if err != nil {
+		return nil, 0, fmt.Errorf("selecting audit entries: %w", err)
+	}
+
+	return *entity.ToDTOAuditEntrySlice(&entries), total, nil
+}
+
+// applyFilter adds the conditions of the filter to the query.
+func applyFilter(q *orm.Query, filter dto.AuditFilter) {
+	if filter.ActorID != nil {
+		q.Where("actor_id = ?", *filter.ActorID)
+	}
+	if filter.Action != nil {
+		q.Where("action = ?", *filter.Action)
+	}
+	if filter.TargetID != nil {
+		q.Where("target_id = ?", *filter.TargetID)
+	}
+	if filter.Outcome != nil {
+		q.Where("outcome = ?", *filter.Outcome)
+	}
+	if filter.TraceID != nil {
+		q.Where("trace_id = ?", *filter.TraceID)
+	}
+	if filter.StartDate != nil {
+		q.Where("date_created >= ?", *filter.StartDate)
+	}
+	if filter.EndDate != nil {
+		q.Where("date_created <= ?", *filter.EndDate)
+	}
+}
@@ -0,0 +1,103 @@
+// Package auditmem contains an in-memory implementation of the audit store.
+// It follows the semantics of the database store and is meant for tests that
+// don't need a real database.
+package auditmem
+
+import (
+	"context"
+	"github.com/AgeroFlynn/crud/internal/buisness/core/dto"
+	"github.com/AgeroFlynn/crud/internal/buisness/repository/store/memtx"
+	"github.com/AgeroFlynn/crud/internal/buisness/sys/validate"
+	"go.uber.org/zap"
+	"sync"
+)
+
+// Store manages the set of API's for audit log access held in memory.
+type Store struct {
+	log *zap.SugaredLogger
+
+	mu      sync.RWMutex
+	entries []dto.AuditEntry
+}
+
+// NewStore constructs an empty in-memory audit store.
+func NewStore(log *zap.SugaredLogger) *Store {
+	return &Store{
+		log: log,
+	}
+}
+
+// Append adds the entry to the audit log. Like the database store, entries
+// of successful operations are removed again when the transaction of the
+// context is rolled back.
+func (s *Store) Append(ctx context.Context, entry dto.AuditEntry) (dto.AuditEntry, error) {
+	entry.ID = validate.GenerateID()
+	entry.Changes = append([]dto.AuditChange(nil), entry.Changes...)
+
+	s.mu.Lock()
+	s.entries = append(s.entries, entry)
+	s.mu.Unlock()
+
+	if entry.Outcome == dto.OutcomeSuccess {
+		memtx.OnRollback(ctx, func() {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+
+			kept := s.entries[:0]
+			for _, e := range s.entries {
+				if e.ID != entry.ID {
+					kept = append(kept, e)
+				}
+			}
+			s.entries = kept
+		})
+	}
+
+	return entry, nil
+}
+
+// Query retrieves a page of entries matching the filter, newest first,
+// together with the total number of matching entries.
+func (s *Store) Query(ctx context.Context, filter dto.AuditFilter, offset int, limit int) ([]dto.AuditEntry, int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var entries []dto.AuditEntry
+	for i := len(s.entries) - 1; i >= 0; i-- {
+		if matches(s.entries[i], filter) {
+			entries = append(entries, s.entries[i])
+		}
+	}
+
+	total := len(entries)
+	if offset > total {
+		offset = total
+	}
+	end := offset + limit
+	if end > total {
+		end = total
+	}
+
+	return entries[offset:end], total, nil
+}
+
+// matches reports whether the entry matches the filter.
+func matches(entry dto.AuditEntry, filter dto.AuditFilter) bool {
+	switch {
+	case filter.ActorID != nil && entry.ActorID != *filter.ActorID:
+		return false
+	case filter.Action != nil && entry.Action != *filter.Action:
+		return false
+	case filter.TargetID != nil && entry.TargetID != *filter.TargetID:
+		return false
+	case filter.Outcome != nil && entry.Outcome != *filter.Outcome:
+		return false
+	case filter.TraceID != nil && entry.TraceID != *filter.TraceID:
+		return false
+	case filter.StartDate != nil && entry.DateCreated.Before(*filter.StartDate):
+		return false
+	case filter.EndDate != nil && entry.DateCreated.After(*filter.EndDate):
+		return false
+	}
+	return true
+}
@@ -0,0 +1,84 @@
+// Package login contains the login history functionality.
+package login
+
+import (
+	"context"
+	"fmt"
+	"github.com/AgeroFlynn/crud/internal/buisness/core/dto"
+	"github.com/AgeroFlynn/crud/internal/buisness/repository/entity"
+	"github.com/AgeroFlynn/crud/internal/buisness/sys/validate"
+	"github.com/AgeroFlynn/crud/internal/foundation/database"
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+	"go.uber.org/zap"
+)
+
+// Store manages the set of API's for login history access.
+type Store struct {
+	log *zap.SugaredLogger
+	db  *pg.DB
+}
+
+// NewStore constructs a login history store for api access.
+func NewStore(log *zap.SugaredLogger, db *pg.DB) Store {
+	return Store{
+		log: log,
+		db:  db,
+	}
+}
+
+// Add records the login attempt. Attempts without a user id are attributed
+// to the user the email belongs to, if any, as part of the insert.
+func (s Store) Add(ctx context.Context, login dto.Login) (dto.Login, error) {
+	l := entity.FromDTOLogin(&login)
+	l.ID = validate.GenerateID()
+
+	q := database.Conn(ctx, s.db).ModelContext(ctx, l)
+	if l.UserID == nil {
+		q.Value("user_id", "(SELECT user_id FROM users WHERE lower(email) = lower(?) LIMIT 1)", l.Email).
+			Returning("user_id")
+	}
+
+	if _, err := q.Insert(); err != nil {
+		return dto.Login{}, fmt.Errorf("inserting login: %w", database.MapError(err))
+	}
+
+	return *l.ToDTOLogin(), nil
+}
+
+// Query retrieves a page of the login attempts matching the filter, newest
+// first, together with the total number of matching attempts.
+func (s Store) Query(ctx context.Context, filter dto.LoginFilter, offset int, limit int) ([]dto.Login, int, error) {
+	var logins []entity.Login
+	q := database.Conn(ctx, s.db).ModelContext(ctx, &logins)
+	applyFilter(q, filter)
+
+	total, err := q.Order("date_created DESC", "login_id DESC").Offset(offset).Limit(limit).SelectAndCount()
+	if err != nil {
+		return nil, 0, fmt.Errorf("selecting logins: %w", err)
+	}
+
+	return *entity.ToDTOLoginSlice(&logins), total, nil
+}
+
+// applyFilter adds the conditions of the filter to the query.
+func applyFilter(q *orm.Query, filter dto.LoginFilter) {
+	if filter.UserID != nil {
+		q.Where("user_id = ?", *filter.UserID)
+	}
+	if filter.Email != nil {
+		q.Where("lower(email) = lower(?)", *filter.Email)
+	}
+	if filter.Outcome != nil {
+		q.Where("outcome = ?", *filter.Outcome)
+	}
+	if filter.RemoteAddr != nil {
+		q.Where("remote_addr = ?", *filter.RemoteAddr)
+	}
+	if filter.StartDate != nil {
+		q.Where("date_created >= ?", *filter.StartDate)
+	}
+	if filter.EndDate != nil {
+		q.Where("date_created <= ?", *filter.EndDate)
+	}
+}
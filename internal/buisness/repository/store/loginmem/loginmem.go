@@ -0,0 +1,101 @@
+// Package loginmem contains an in-memory implementation of the login history
+// store. It follows the semantics of the database store and is meant for
+// tests that don't need a real database.
+package loginmem
+
+import (
+	"context"
+	"github.com/AgeroFlynn/crud/internal/buisness/core/dto"
+	"github.com/AgeroFlynn/crud/internal/buisness/sys/validate"
+	"go.uber.org/zap"
+	"strings"
+	"sync"
+)
+
+// UserFinder is the behavior required by the store to attribute attempts to
+// the users the emails belong to.
+type UserFinder interface {
+	FindByEmail(ctx context.Context, email string) (dto.User, error)
+}
+
+// Store manages the set of API's for login history access held in memory.
+// The attempts of deleted users keep their user id.
+type Store struct {
+	log   *zap.SugaredLogger
+	users UserFinder
+
+	mu     sync.RWMutex
+	logins []dto.Login
+}
+
+// NewStore constructs an empty in-memory login history store. The emails of
+// the attempts are looked up in users.
+func NewStore(log *zap.SugaredLogger, users UserFinder) *Store {
+	return &Store{
+		log:   log,
+		users: users,
+	}
+}
+
+// Add records the login attempt. Attempts without a user id are attributed
+// to the user the email belongs to, if any.
+func (s *Store) Add(ctx context.Context, login dto.Login) (dto.Login, error) {
+	login.ID = validate.GenerateID()
+
+	if login.UserID == "" {
+		if usr, err := s.users.FindByEmail(ctx, login.Email); err == nil {
+			login.UserID = usr.ID
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.logins = append(s.logins, login)
+
+	return login, nil
+}
+
+// Query retrieves a page of the login attempts matching the filter, newest
+// first, together with the total number of matching attempts.
+func (s *Store) Query(ctx context.Context, filter dto.LoginFilter, offset int, limit int) ([]dto.Login, int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var logins []dto.Login
+	for i := len(s.logins) - 1; i >= 0; i-- {
+		if matches(s.logins[i], filter) {
+			logins = append(logins, s.logins[i])
+		}
+	}
+
+	total := len(logins)
+	if offset > total {
+		offset = total
+	}
+	end := offset + limit
+	if end > total {
+		end = total
+	}
+
+	return logins[offset:end], total, nil
+}
+
+// matches reports whether the login attempt matches the filter.
+func matches(login dto.Login, filter dto.LoginFilter) bool {
+	switch {
+	case filter.UserID != nil && login.UserID != *filter.UserID:
+		return false
+	case filter.Email != nil && !strings.EqualFold(login.Email, *filter.Email):
+		return false
+	case filter.Outcome != nil && login.Outcome != *filter.Outcome:
+		return false
+	case filter.RemoteAddr != nil && login.RemoteAddr != *filter.RemoteAddr:
+		return false
+	case filter.StartDate != nil && login.DateCreated.Before(*filter.StartDate):
+		return false
+	case filter.EndDate != nil && login.DateCreated.After(*filter.EndDate):
+		return false
+	}
+	return true
+}
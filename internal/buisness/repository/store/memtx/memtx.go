@@ -0,0 +1,71 @@
+// Package memtx provides the transactions shared by the in-memory stores. A
+// store joins the transaction of the context by registering how to undo its
+// modifications, all of them are undone together when the transaction is
+// rolled back.
+package memtx
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// tran holds the undo functions registered during a transaction.
+type tran struct {
+	mu    sync.Mutex
+	undos []func()
+}
+
+// ctxKey is how the transaction is stored in the context.
+type ctxKey struct{}
+
+// WithinTran runs fn inside a transaction. The context handed to fn carries
+// the transaction, the modifications of the stores registered with OnRollback
+// are undone when fn fails or panics. Calling WithinTran with a context
+// already carrying a transaction runs fn in it.
+func WithinTran(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(ctxKey{}).(*tran); ok {
+		return fn(ctx)
+	}
+
+	tx := &tran{}
+
+	// A panicking fn leaves the stores as they were too.
+	defer func() {
+		if r := recover(); r != nil {
+			tx.rollback()
+			panic(r)
+		}
+	}()
+
+	if err := fn(context.WithValue(ctx, ctxKey{}, tx)); err != nil {
+		tx.rollback()
+		return fmt.Errorf("exec tran: %w", err)
+	}
+
+	return nil
+}
+
+// OnRollback registers undo to run when the transaction of the context is
+// rolled back. Outside of a transaction it does nothing.
+func OnRollback(ctx context.Context, undo func()) {
+	tx, ok := ctx.Value(ctxKey{}).(*tran)
+	if !ok {
+		return
+	}
+
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	tx.undos = append(tx.undos, undo)
+}
+
+// rollback runs the undo functions, the last registered first.
+func (tx *tran) rollback() {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	for i := len(tx.undos) - 1; i >= 0; i-- {
+		tx.undos[i]()
+	}
+}
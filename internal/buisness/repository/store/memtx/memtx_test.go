@@ -0,0 +1,78 @@
+package memtx_test
+
+import (
+	"context"
+	"errors"
+	"github.com/AgeroFlynn/crud/internal/buisness/repository/store/memtx"
+	"github.com/AgeroFlynn/crud/internal/buisness/sys/tests"
+	"testing"
+)
+
+func TestWithinTran(t *testing.T) {
+	t.Log("Given the need to undo the modifications of a failed transaction.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen the transaction fails.", testID)
+		{
+			var undone []int
+			err := memtx.WithinTran(context.Background(), func(ctx context.Context) error {
+				memtx.OnRollback(ctx, func() { undone = append(undone, 1) })
+				memtx.OnRollback(ctx, func() { undone = append(undone, 2) })
+				return errors.New("failed")
+			})
+			if err == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould return the error of the transaction.", tests.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould return the error of the transaction.", tests.Success, testID)
+
+			if len(undone) != 2 || undone[0] != 2 || undone[1] != 1 {
+				t.Fatalf("\t%s\tTest %d:\tShould undo the modifications, the last first : %v", tests.Failed, testID, undone)
+			}
+			t.Logf("\t%s\tTest %d:\tShould undo the modifications, the last first.", tests.Success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen the transaction panics.", testID)
+		{
+			var undone bool
+			recovered := func() (r any) {
+				defer func() { r = recover() }()
+
+				memtx.WithinTran(context.Background(), func(ctx context.Context) error {
+					memtx.OnRollback(ctx, func() { undone = true })
+					panic("boom")
+				})
+				return nil
+			}()
+
+			if recovered != "boom" {
+				t.Fatalf("\t%s\tTest %d:\tShould pass the panic on : %v", tests.Failed, testID, recovered)
+			}
+			t.Logf("\t%s\tTest %d:\tShould pass the panic on.", tests.Success, testID)
+
+			if !undone {
+				t.Fatalf("\t%s\tTest %d:\tShould undo the modifications.", tests.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould undo the modifications.", tests.Success, testID)
+		}
+
+		testID = 2
+		t.Logf("\tTest %d:\tWhen the transaction succeeds.", testID)
+		{
+			var undone bool
+			err := memtx.WithinTran(context.Background(), func(ctx context.Context) error {
+				memtx.OnRollback(ctx, func() { undone = true })
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould commit the transaction : %v", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould commit the transaction.", tests.Success, testID)
+
+			if undone {
+				t.Fatalf("\t%s\tTest %d:\tShould keep the modifications.", tests.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould keep the modifications.", tests.Success, testID)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+// Package outbox contains the transactional outbox of the domain events.
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"github.com/AgeroFlynn/crud/internal/buisness/core/dto"
+	"github.com/AgeroFlynn/crud/internal/buisness/repository/entity"
+	"github.com/AgeroFlynn/crud/internal/buisness/sys/validate"
+	"github.com/AgeroFlynn/crud/internal/foundation/database"
+	"github.com/go-pg/pg/v10"
+	"go.uber.org/zap"
+	"time"
+)
+
+// Store manages the set of API's for outbox access.
+type Store struct {
+	log *zap.SugaredLogger
+	db  *pg.DB
+}
+
+// NewStore constructs an outbox store for api access.
+func NewStore(log *zap.SugaredLogger, db *pg.DB) Store {
+	return Store{
+		log: log,
+		db:  db,
+	}
+}
+
+// Add inserts the events into the outbox. It joins the transaction of the
+// context so the events are only kept when the change they describe is.
+func (s Store) Add(ctx context.Context, events ...dto.Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	es := make([]entity.Event, len(events))
+	for i := range events {
+		es[i] = *entity.FromDTOEvent(&events[i])
+		es[i].ID = validate.GenerateID()
+	}
+
+	if _, err := database.Conn(ctx, s.db).ModelContext(ctx, &es).Insert(); err != nil {
+		return fmt.Errorf("inserting events: %w", database.MapError(err))
+	}
+
+	return nil
+}
+
+// Claim retrieves up to limit events waiting to be dispatched, oldest first,
+// and hides them from other claims for the lease. Events that aren't marked
+// before the lease expires are claimed again.
+func (s Store) Claim(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]dto.Event, error) {
+	var events []entity.Event
+
+	err := database.WithinTran(ctx, s.log, s.db, func(ctx context.Context) error {
+		err := database.Conn(ctx, s.db).ModelContext(ctx, &events).
+			Where("date_dispatched IS NULL").
+			Where("date_dead IS NULL").
+			Where("date_available <= ?", now).
+			Order("date_created ASC").
+			Limit(limit).
+			For("UPDATE SKIP LOCKED").
+			Select()
+		if err != nil {
+			return fmt.Errorf("selecting events: %w", err)
+		}
+		if len(events) == 0 {
+			return nil
+		}
+
+		ids := make([]string, len(events))
+		for i, e := range events {
+			ids[i] = e.ID
+		}
+
+		_, err = database.Conn(ctx, s.db).ModelContext(ctx, (*entity.Event)(nil)).
+			Set("date_available = ?", now.Add(lease)).
+			Where("outbox_id IN (?)", pg.In(ids)).
+			Update()
+		if err != nil {
+			return fmt.Errorf("leasing events: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return *entity.ToDTOEventSlice(&events), nil
+}
+
+// Dispatched marks the event as delivered to all its subscribers.
+func (s Store) Dispatched(ctx context.Context, eventID string, now time.Time) error {
+	_, err := database.Conn(ctx, s.db).ModelContext(ctx, (*entity.Event)(nil)).
+		Set("date_dispatched = ?", now).
+		Where("outbox_id = ?", eventID).
+		Update()
+	if err != nil {
+		return fmt.Errorf("updating eventID[%s]: %w", eventID, err)
+	}
+
+	return nil
+}
+
+// Retry records the failed attempt to dispatch the event, it is dispatched
+// again once next has passed.
+func (s Store) Retry(ctx context.Context, eventID string, attempts int, lastErr string, next time.Time) error {
+	_, err := database.Conn(ctx, s.db).ModelContext(ctx, (*entity.Event)(nil)).
+		Set("attempts = ?", attempts).
+		Set("last_error = ?", lastErr).
+		Set("date_available = ?", next).
+		Where("outbox_id = ?", eventID).
+		Update()
+	if err != nil {
+		return fmt.Errorf("updating eventID[%s]: %w", eventID, err)
+	}
+
+	return nil
+}
+
+// Dead moves the event to the dead letters, it isn't dispatched anymore.
+func (s Store) Dead(ctx context.Context, eventID string, attempts int, lastErr string, now time.Time) error {
+	_, err := database.Conn(ctx, s.db).ModelContext(ctx, (*entity.Event)(nil)).
+		Set("attempts = ?", attempts).
+		Set("last_error = ?", lastErr).
+		Set("date_dead = ?", now).
+		Where("outbox_id = ?", eventID).
+		Update()
+	if err != nil {
+		return fmt.Errorf("updating eventID[%s]: %w", eventID, err)
+	}
+
+	return nil
+}
+
+// QueryDead retrieves a page of the dead letters, newest first.
+func (s Store) QueryDead(ctx context.Context, offset int, limit int) ([]dto.Event, error) {
+	var events []entity.Event
+	err := database.Conn(ctx, s.db).ModelContext(ctx, &events).
+		Where("date_dead IS NOT NULL").
+		Order("date_dead DESC").
+		Offset(offset).
+		Limit(limit).
+		Select()
+	if err != nil {
+		return nil, fmt.Errorf("selecting dead events: %w", err)
+	}
+
+	return *entity.ToDTOEventSlice(&events), nil
+}
@@ -0,0 +1,149 @@
+// Package outboxmem contains an in-memory implementation of the outbox. It
+// follows the semantics of the database store and is meant for tests that
+// don't need a real database.
+package outboxmem
+
+import (
+	"context"
+	"github.com/AgeroFlynn/crud/internal/buisness/core/dto"
+	"github.com/AgeroFlynn/crud/internal/buisness/repository/store/memtx"
+	"github.com/AgeroFlynn/crud/internal/buisness/sys/validate"
+	"go.uber.org/zap"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Store manages the set of API's for outbox access held in memory. It takes
+// part in the transactions of the other in-memory stores, events added inside
+// a transaction that is rolled back are removed.
+type Store struct {
+	log *zap.SugaredLogger
+
+	mu     sync.Mutex
+	events []dto.Event
+}
+
+// NewStore constructs an empty in-memory outbox.
+func NewStore(log *zap.SugaredLogger) *Store {
+	return &Store{
+		log: log,
+	}
+}
+
+// Add inserts the events into the outbox. Within a transaction the events
+// are removed again when it is rolled back.
+func (s *Store) Add(ctx context.Context, events ...dto.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	added := make(map[string]bool, len(events))
+	for _, e := range events {
+		e.ID = validate.GenerateID()
+		s.events = append(s.events, e)
+		added[e.ID] = true
+	}
+
+	memtx.OnRollback(ctx, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		kept := s.events[:0]
+		for _, e := range s.events {
+			if !added[e.ID] {
+				kept = append(kept, e)
+			}
+		}
+		s.events = kept
+	})
+
+	return nil
+}
+
+// Claim retrieves up to limit events waiting to be dispatched, oldest first,
+// and hides them from other claims for the lease.
+func (s *Store) Claim(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]dto.Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var events []dto.Event
+	for i := range s.events {
+		e := &s.events[i]
+		if e.DateDispatched != nil || e.DateDead != nil || e.DateAvailable.After(now) {
+			continue
+		}
+		if len(events) == limit {
+			break
+		}
+		e.DateAvailable = now.Add(lease)
+		events = append(events, *e)
+	}
+
+	return events, nil
+}
+
+// Dispatched marks the event as delivered to all its subscribers.
+func (s *Store) Dispatched(ctx context.Context, eventID string, now time.Time) error {
+	s.update(eventID, func(e *dto.Event) {
+		e.DateDispatched = &now
+	})
+	return nil
+}
+
+// Retry records the failed attempt to dispatch the event, it is dispatched
+// again once next has passed.
+func (s *Store) Retry(ctx context.Context, eventID string, attempts int, lastErr string, next time.Time) error {
+	s.update(eventID, func(e *dto.Event) {
+		e.Attempts = attempts
+		e.LastError = lastErr
+		e.DateAvailable = next
+	})
+	return nil
+}
+
+// Dead moves the event to the dead letters, it isn't dispatched anymore.
+func (s *Store) Dead(ctx context.Context, eventID string, attempts int, lastErr string, now time.Time) error {
+	s.update(eventID, func(e *dto.Event) {
+		e.Attempts = attempts
+		e.LastError = lastErr
+		e.DateDead = &now
+	})
+	return nil
+}
+
+// QueryDead retrieves a page of the dead letters, newest first.
+func (s *Store) QueryDead(ctx context.Context, offset int, limit int) ([]dto.Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var events []dto.Event
+	for _, e := range s.events {
+		if e.DateDead != nil {
+			events = append(events, e)
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].DateDead.After(*events[j].DateDead) })
+
+	if offset > len(events) {
+		offset = len(events)
+	}
+	end := offset + limit
+	if end > len(events) {
+		end = len(events)
+	}
+
+	return events[offset:end], nil
+}
+
+// update applies fn to the event with the id.
+func (s *Store) update(eventID string, fn func(e *dto.Event)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.events {
+		if s.events[i].ID == eventID {
+			fn(&s.events[i])
+			return
+		}
+	}
+}
@@ -0,0 +1,230 @@
+// Package role contains role and permission related CRUD functionality.
+package role
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/AgeroFlynn/crud/internal/buisness/core/dto"
+	"github.com/AgeroFlynn/crud/internal/buisness/repository/entity"
+	"github.com/AgeroFlynn/crud/internal/foundation/database"
+	"github.com/go-pg/pg/v10"
+	"go.uber.org/zap"
+	"time"
+)
+
+// Store manages the set of API's for role access.
+type Store struct {
+	log *zap.SugaredLogger
+	db  *pg.DB
+}
+
+// NewStore constructs a role store for api access.
+func NewStore(log *zap.SugaredLogger, db *pg.DB) Store {
+	return Store{
+		log: log,
+		db:  db,
+	}
+}
+
+// WithinTran runs fn inside a transaction, every call of the store made
+// with the context handed to fn joins it.
+func (s Store) WithinTran(ctx context.Context, fn func(ctx context.Context) error) error {
+	return database.WithinTran(ctx, s.log, s.db, fn)
+}
+
+// Create inserts a new role together with its permissions.
+func (s Store) Create(ctx context.Context, nr dto.NewRole, now time.Time) (dto.Role, error) {
+	role := entity.Role{
+		Name:        nr.Name,
+		Description: nr.Description,
+		DateCreated: now,
+		DateUpdated: now,
+	}
+
+	err := database.WithinTran(ctx, s.log, s.db, func(ctx context.Context) error {
+		if _, err := database.Conn(ctx, s.db).ModelContext(ctx, &role).Insert(); err != nil {
+			return fmt.Errorf("inserting role: %w", nameError(database.MapError(err)))
+		}
+		return s.grant(ctx, role.Name, nr.Permissions)
+	})
+	if err != nil {
+		return dto.Role{}, err
+	}
+
+	return *role.ToDTORole(nr.Permissions), nil
+}
+
+// Update modifies the description of a role and replaces its permissions
+// when they are provided.
+func (s Store) Update(ctx context.Context, name string, ur dto.UpdateRole, now time.Time) error {
+	return database.WithinTran(ctx, s.log, s.db, func(ctx context.Context) error {
+		q := database.Conn(ctx, s.db).ModelContext(ctx, (*entity.Role)(nil)).Set("date_updated = ?", now).Where("role_id = ?", name)
+		if ur.Description != nil {
+			q.Set("description = ?", *ur.Description)
+		}
+
+		res, err := q.Update()
+		if err != nil {
+			return fmt.Errorf("updating role[%s]: %w", name, err)
+		}
+		if res.RowsAffected() == 0 {
+			return database.ErrNotFound
+		}
+
+		if ur.Permissions == nil {
+			return nil
+		}
+
+		if _, err := database.Conn(ctx, s.db).ModelContext(ctx, (*entity.RolePermission)(nil)).Where("role_id = ?", name).Delete(); err != nil {
+			return fmt.Errorf("revoking permissions of role[%s]: %w", name, err)
+		}
+		return s.grant(ctx, name, ur.Permissions)
+	})
+}
+
+// grant adds the permissions to the role.
+func (s Store) grant(ctx context.Context, name string, permissions []string) error {
+	if len(permissions) == 0 {
+		return nil
+	}
+
+	rps := make([]entity.RolePermission, len(permissions))
+	for i, p := range permissions {
+		rps[i] = entity.RolePermission{RoleName: name, PermissionName: p}
+	}
+
+	if _, err := database.Conn(ctx, s.db).ModelContext(ctx, &rps).Insert(); err != nil {
+		return fmt.Errorf("granting permissions to role[%s]: %w", name, database.MapError(err))
+	}
+
+	return nil
+}
+
+// Delete removes a role, the permissions it grants go with it.
+func (s Store) Delete(ctx context.Context, name string) error {
+	res, err := database.Conn(ctx, s.db).ModelContext(ctx, (*entity.Role)(nil)).Where("role_id = ?", name).Delete()
+	if err != nil {
+		return fmt.Errorf("deleting role[%s]: %w", name, err)
+	}
+	if res.RowsAffected() == 0 {
+		return database.ErrNotFound
+	}
+
+	return nil
+}
+
+// Query retrieves all roles ordered by name.
+func (s Store) Query(ctx context.Context) ([]dto.Role, error) {
+	var roles []entity.Role
+	if err := database.Conn(ctx, s.db).ModelContext(ctx, &roles).Order("role_id").Select(); err != nil {
+		return nil, fmt.Errorf("selecting roles: %w", err)
+	}
+
+	names := make([]string, len(roles))
+	for i, role := range roles {
+		names[i] = role.Name
+	}
+
+	granted, err := s.granted(ctx, names)
+	if err != nil {
+		return nil, err
+	}
+
+	dtoRoles := make([]dto.Role, len(roles))
+	for i, role := range roles {
+		dtoRoles[i] = *role.ToDTORole(granted[role.Name])
+	}
+
+	return dtoRoles, nil
+}
+
+// FindByName gets the specified role from the database.
+func (s Store) FindByName(ctx context.Context, name string) (dto.Role, error) {
+	var role entity.Role
+	if err := database.Conn(ctx, s.db).ModelContext(ctx, &role).Where("role_id = ?", name).Limit(1).Select(); err != nil {
+		if err == pg.ErrNoRows {
+			return dto.Role{}, database.ErrNotFound
+		}
+		return dto.Role{}, fmt.Errorf("selecting role[%q]: %w", name, err)
+	}
+
+	granted, err := s.granted(ctx, []string{name})
+	if err != nil {
+		return dto.Role{}, err
+	}
+
+	return *role.ToDTORole(granted[name]), nil
+}
+
+// Exists returns the names of the roles that exist out of the provided ones.
+func (s Store) Exists(ctx context.Context, names []string) ([]string, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	var found []string
+	if err := database.Conn(ctx, s.db).ModelContext(ctx, (*entity.Role)(nil)).Column("role_id").Where("role_id IN (?)", pg.In(names)).Select(&found); err != nil {
+		return nil, fmt.Errorf("selecting roles: %w", err)
+	}
+
+	return found, nil
+}
+
+// Permissions retrieves all permissions ordered by name.
+func (s Store) Permissions(ctx context.Context) ([]dto.Permission, error) {
+	var permissions []entity.Permission
+	if err := database.Conn(ctx, s.db).ModelContext(ctx, &permissions).Order("permission_id").Select(); err != nil {
+		return nil, fmt.Errorf("selecting permissions: %w", err)
+	}
+
+	return *entity.ToDTOPermissionSlice(&permissions), nil
+}
+
+// Resolve returns the permissions granted by the roles, each permission
+// once and ordered by name. Unknown roles grant nothing.
+func (s Store) Resolve(ctx context.Context, roles []string) ([]string, error) {
+	if len(roles) == 0 {
+		return nil, nil
+	}
+
+	var permissions []string
+	if err := database.Conn(ctx, s.db).ModelContext(ctx, (*entity.RolePermission)(nil)).
+		ColumnExpr("DISTINCT permission_id").
+		Where("role_id IN (?)", pg.In(roles)).
+		Order("permission_id").
+		Select(&permissions); err != nil {
+		return nil, fmt.Errorf("resolving permissions of roles%v: %w", roles, err)
+	}
+
+	return permissions, nil
+}
+
+// granted returns the permissions of the roles by role name.
+func (s Store) granted(ctx context.Context, names []string) (map[string][]string, error) {
+	granted := make(map[string][]string, len(names))
+	if len(names) == 0 {
+		return granted, nil
+	}
+
+	var rps []entity.RolePermission
+	if err := database.Conn(ctx, s.db).ModelContext(ctx, &rps).Where("role_id IN (?)", pg.In(names)).Order("role_id", "permission_id").Select(); err != nil {
+		return nil, fmt.Errorf("selecting permissions of roles: %w", err)
+	}
+
+	for _, rp := range rps {
+		granted[rp.RoleName] = append(granted[rp.RoleName], rp.PermissionName)
+	}
+
+	return granted, nil
+}
+
+// nameError reports a duplicate role under the name field clients know it
+// by.
+func nameError(err error) error {
+	var ce *database.ConstraintError
+	if errors.As(err, &ce) && ce.Field == "role_id" {
+		ce.Field = "name"
+	}
+	return err
+}
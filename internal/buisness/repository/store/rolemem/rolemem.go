@@ -0,0 +1,240 @@
+// Package rolemem contains an in-memory implementation of the role store. It
+// follows the semantics of the database store and is meant for tests that
+// don't need a real database.
+package rolemem
+
+import (
+	"context"
+	"fmt"
+	"github.com/AgeroFlynn/crud/internal/buisness/core/dto"
+	"github.com/AgeroFlynn/crud/internal/buisness/repository/store/memtx"
+	"github.com/AgeroFlynn/crud/internal/foundation/database"
+	"go.uber.org/zap"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Store manages the set of API's for role access held in memory.
+type Store struct {
+	log *zap.SugaredLogger
+
+	mu          sync.RWMutex
+	roles       map[string]dto.Role
+	permissions map[string]dto.Permission
+}
+
+// NewStore constructs an empty in-memory role store.
+func NewStore(log *zap.SugaredLogger) *Store {
+	return &Store{
+		log:         log,
+		roles:       make(map[string]dto.Role),
+		permissions: make(map[string]dto.Permission),
+	}
+}
+
+// Seed adds the permissions and the roles as they are.
+func (s *Store) Seed(permissions []dto.Permission, roles ...dto.Role) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, p := range permissions {
+		s.permissions[p.Name] = p
+	}
+	for _, role := range roles {
+		s.roles[role.Name] = clone(role)
+	}
+}
+
+// WithinTran runs fn and restores the roles as they were before when fn
+// fails. The other in-memory stores called with the context of fn take part
+// in the transaction. Modifications made outside of fn while it runs are lost
+// on a rollback, the store is meant for tests.
+func (s *Store) WithinTran(ctx context.Context, fn func(ctx context.Context) error) error {
+	return memtx.WithinTran(ctx, func(ctx context.Context) error {
+		s.join(ctx)
+		return fn(ctx)
+	})
+}
+
+// join takes a snapshot of the roles which is restored when the transaction
+// of the context is rolled back.
+func (s *Store) join(ctx context.Context) {
+	s.mu.RLock()
+	roles := make(map[string]dto.Role, len(s.roles))
+	for name, role := range s.roles {
+		roles[name] = clone(role)
+	}
+	s.mu.RUnlock()
+
+	memtx.OnRollback(ctx, func() {
+		s.mu.Lock()
+		s.roles = roles
+		s.mu.Unlock()
+	})
+}
+
+// Create inserts a new role together with its permissions.
+func (s *Store) Create(ctx context.Context, nr dto.NewRole, now time.Time) (dto.Role, error) {
+	role := dto.Role{
+		Name:        nr.Name,
+		Description: nr.Description,
+		Permissions: nr.Permissions,
+		DateCreated: now,
+		DateUpdated: now,
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.roles[role.Name]; exists {
+		return dto.Role{}, fmt.Errorf("inserting role: %w", &database.ConstraintError{
+			Err:        database.ErrDuplicate,
+			Field:      "name",
+			Constraint: "roles_pkey",
+		})
+	}
+	if err := s.checkPermissions(role.Permissions); err != nil {
+		return dto.Role{}, fmt.Errorf("granting permissions to role[%s]: %w", role.Name, err)
+	}
+	s.roles[role.Name] = clone(role)
+
+	return clone(role), nil
+}
+
+// Update modifies the description of a role and replaces its permissions
+// when they are provided.
+func (s *Store) Update(ctx context.Context, name string, ur dto.UpdateRole, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	role, exists := s.roles[name]
+	if !exists {
+		return database.ErrNotFound
+	}
+
+	if ur.Description != nil {
+		role.Description = *ur.Description
+	}
+	if ur.Permissions != nil {
+		if err := s.checkPermissions(ur.Permissions); err != nil {
+			return fmt.Errorf("granting permissions to role[%s]: %w", name, err)
+		}
+		role.Permissions = ur.Permissions
+	}
+	role.DateUpdated = now
+	s.roles[name] = clone(role)
+
+	return nil
+}
+
+// Delete removes a role, the permissions it grants go with it.
+func (s *Store) Delete(ctx context.Context, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.roles[name]; !exists {
+		return database.ErrNotFound
+	}
+	delete(s.roles, name)
+
+	return nil
+}
+
+// Query retrieves all roles ordered by name.
+func (s *Store) Query(ctx context.Context) ([]dto.Role, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	roles := make([]dto.Role, 0, len(s.roles))
+	for _, role := range s.roles {
+		roles = append(roles, clone(role))
+	}
+	sort.Slice(roles, func(i, j int) bool { return roles[i].Name < roles[j].Name })
+
+	return roles, nil
+}
+
+// FindByName gets the specified role from the store.
+func (s *Store) FindByName(ctx context.Context, name string) (dto.Role, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	role, exists := s.roles[name]
+	if !exists {
+		return dto.Role{}, database.ErrNotFound
+	}
+
+	return clone(role), nil
+}
+
+// Exists returns the names of the roles that exist out of the provided ones.
+func (s *Store) Exists(ctx context.Context, names []string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var found []string
+	for _, name := range names {
+		if _, exists := s.roles[name]; exists {
+			found = append(found, name)
+		}
+	}
+
+	return found, nil
+}
+
+// Permissions retrieves all permissions ordered by name.
+func (s *Store) Permissions(ctx context.Context) ([]dto.Permission, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	permissions := make([]dto.Permission, 0, len(s.permissions))
+	for _, p := range s.permissions {
+		permissions = append(permissions, p)
+	}
+	sort.Slice(permissions, func(i, j int) bool { return permissions[i].Name < permissions[j].Name })
+
+	return permissions, nil
+}
+
+// Resolve returns the permissions granted by the roles, each permission
+// once and ordered by name. Unknown roles grant nothing.
+func (s *Store) Resolve(ctx context.Context, roles []string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	seen := make(map[string]bool)
+	var permissions []string
+	for _, name := range roles {
+		for _, p := range s.roles[name].Permissions {
+			if !seen[p] {
+				seen[p] = true
+				permissions = append(permissions, p)
+			}
+		}
+	}
+	sort.Strings(permissions)
+
+	return permissions, nil
+}
+
+// checkPermissions fails if a permission doesn't exist. It mirrors the
+// foreign key of the role_permissions table. The caller must hold the lock.
+func (s *Store) checkPermissions(permissions []string) error {
+	for _, p := range permissions {
+		if _, exists := s.permissions[p]; !exists {
+			return &database.ConstraintError{
+				Err:        database.ErrForeignKey,
+				Field:      "permission_id",
+				Constraint: "role_permissions_permission_id_fkey",
+			}
+		}
+	}
+	return nil
+}
+
+// clone returns a copy of the role that doesn't share its permissions.
+func clone(role dto.Role) dto.Role {
+	role.Permissions = append([]string(nil), role.Permissions...)
+	return role
+}
@@ -0,0 +1,176 @@
+// Package sharelink contains share link related CRUD functionality.
+package sharelink
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/AgeroFlynn/crud/internal/buisness/core/dto"
+	"github.com/AgeroFlynn/crud/internal/buisness/repository/entity"
+	"github.com/AgeroFlynn/crud/internal/buisness/sys/auth"
+	"github.com/AgeroFlynn/crud/internal/buisness/sys/validate"
+	"github.com/AgeroFlynn/crud/internal/foundation/database"
+	"github.com/go-pg/pg/v10"
+	"go.uber.org/zap"
+	"time"
+)
+
+// ErrUnavailable occurs when a share link is expired, revoked or was already
+// used up.
+var ErrUnavailable = errors.New("share link is no longer available")
+
+// Store manages the set of API's for share link access.
+type Store struct {
+	log *zap.SugaredLogger
+	db  *pg.DB
+}
+
+// NewStore constructs a share link store for api access.
+func NewStore(log *zap.SugaredLogger, db *pg.DB) Store {
+	return Store{
+		log: log,
+		db:  db,
+	}
+}
+
+// Create inserts a new share link into the database.
+func (s Store) Create(ctx context.Context, nsl dto.NewShareLink, now time.Time) (dto.ShareLink, error) {
+	sl := entity.ShareLink{
+		ID:          validate.GenerateID(),
+		UserID:      nsl.UserID,
+		SingleUse:   nsl.SingleUse,
+		ExpiresAt:   now.Add(nsl.ExpiresIn),
+		DateCreated: now,
+	}
+
+	if _, err := database.Conn(ctx, s.db).ModelContext(ctx, &sl).Insert(); err != nil {
+		return dto.ShareLink{}, fmt.Errorf("inserting share link: %w", database.MapError(err))
+	}
+
+	return *sl.ToDTOShareLink(), nil
+}
+
+// Revoke marks a share link as revoked so it can't be opened anymore.
+func (s Store) Revoke(ctx context.Context, claims auth.Claims, linkID string, now time.Time) error {
+	sl, err := s.FindByID(ctx, claims, linkID)
+	if err != nil {
+		return fmt.Errorf("revoking share link linkID[%s]: %w", linkID, err)
+	}
+
+	// If you are not allowed to modify users and looking to revoke a link owned by someone else.
+	if !claims.HasPermission(auth.PermUsersWrite) && claims.Subject != sl.UserID {
+		return database.ErrForbidden
+	}
+
+	// Revoking a link twice keeps the original revocation date.
+	if sl.DateRevoked != nil {
+		return nil
+	}
+
+	if _, err := database.Conn(ctx, s.db).ModelContext(ctx, (*entity.ShareLink)(nil)).Set("date_revoked = ?", now).Where("share_link_id = ?", linkID).Update(); err != nil {
+		return fmt.Errorf("revoking linkID[%s]: %w", linkID, err)
+	}
+
+	return nil
+}
+
+// Consume records a use of the share link. It fails with ErrUnavailable if the
+// link is expired, revoked or is a single use link that was already opened.
+func (s Store) Consume(ctx context.Context, linkID string, now time.Time) (dto.ShareLink, error) {
+	if err := validate.CheckID(linkID); err != nil {
+		return dto.ShareLink{}, database.ErrInvalidID
+	}
+
+	// The checks are part of the update so concurrent requests can't open a
+	// single use link more than once.
+	var sl entity.ShareLink
+	res, err := database.Conn(ctx, s.db).ModelContext(ctx, &sl).
+		Set("use_count = use_count + 1").
+		Where("share_link_id = ?", linkID).
+		Where("date_revoked IS NULL").
+		Where("expires_at > ?", now).
+		Where("(NOT single_use OR use_count = 0)").
+		Returning("*").
+		Update()
+	if err != nil {
+		if err == pg.ErrNoRows {
+			return dto.ShareLink{}, ErrUnavailable
+		}
+		return dto.ShareLink{}, fmt.Errorf("consuming linkID[%s]: %w", linkID, err)
+	}
+	if res.RowsAffected() == 0 {
+		return dto.ShareLink{}, ErrUnavailable
+	}
+
+	return *sl.ToDTOShareLink(), nil
+}
+
+// RecordAccess stores an attempt to open a share link.
+func (s Store) RecordAccess(ctx context.Context, access dto.ShareLinkAccess) error {
+	a := entity.FromDTOShareLinkAccess(&access)
+	a.ID = validate.GenerateID()
+
+	if _, err := database.Conn(ctx, s.db).ModelContext(ctx, a).Insert(); err != nil {
+		return fmt.Errorf("inserting share link access: %w", database.MapError(err))
+	}
+
+	return nil
+}
+
+// FindByUser retrieves the share links owned by the specified user.
+func (s Store) FindByUser(ctx context.Context, claims auth.Claims, userID string) ([]dto.ShareLink, error) {
+
+	// If you are not allowed to read users and looking to retrieve someone other than yourself.
+	if !claims.HasPermission(auth.PermUsersRead) && claims.Subject != userID {
+		return nil, database.ErrForbidden
+	}
+
+	var links []entity.ShareLink
+	if err := database.Conn(ctx, s.db).ModelContext(ctx, &links).Where("user_id = ?", userID).Order("date_created DESC").Select(); err != nil {
+		if err == pg.ErrNoRows {
+			return nil, database.ErrNotFound
+		}
+		return nil, fmt.Errorf("selecting share links userID[%s]: %w", userID, err)
+	}
+
+	return *entity.ToDTOShareLinkSlice(&links), nil
+}
+
+// FindByID gets the specified share link from the database.
+func (s Store) FindByID(ctx context.Context, claims auth.Claims, linkID string) (dto.ShareLink, error) {
+	if err := validate.CheckID(linkID); err != nil {
+		return dto.ShareLink{}, database.ErrInvalidID
+	}
+
+	var sl entity.ShareLink
+	if err := database.Conn(ctx, s.db).ModelContext(ctx, &sl).Where("share_link_id = ?", linkID).Limit(1).Select(); err != nil {
+		if err == pg.ErrNoRows {
+			return dto.ShareLink{}, database.ErrNotFound
+		}
+		return dto.ShareLink{}, fmt.Errorf("selecting linkID[%q]: %w", linkID, err)
+	}
+
+	// If you are not allowed to read users and looking to retrieve a link owned by someone else.
+	if !claims.HasPermission(auth.PermUsersRead) && claims.Subject != sl.UserID {
+		return dto.ShareLink{}, database.ErrForbidden
+	}
+
+	return *sl.ToDTOShareLink(), nil
+}
+
+// FindAccesses retrieves the access log of the specified share link.
+func (s Store) FindAccesses(ctx context.Context, claims auth.Claims, linkID string) ([]dto.ShareLinkAccess, error) {
+	if _, err := s.FindByID(ctx, claims, linkID); err != nil {
+		return nil, fmt.Errorf("selecting accesses linkID[%s]: %w", linkID, err)
+	}
+
+	var accesses []entity.ShareLinkAccess
+	if err := database.Conn(ctx, s.db).ModelContext(ctx, &accesses).Where("share_link_id = ?", linkID).Order("date_accessed DESC").Select(); err != nil {
+		if err == pg.ErrNoRows {
+			return nil, database.ErrNotFound
+		}
+		return nil, fmt.Errorf("selecting accesses linkID[%s]: %w", linkID, err)
+	}
+
+	return *entity.ToDTOShareLinkAccessSlice(&accesses), nil
+}
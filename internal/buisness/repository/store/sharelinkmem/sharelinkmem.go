@@ -0,0 +1,189 @@
+// Package sharelinkmem contains an in-memory implementation of the share link
+// store. It follows the semantics of the database store and is meant for
+// tests that don't need a real database.
+package sharelinkmem
+
+import (
+	"context"
+	"fmt"
+	"github.com/AgeroFlynn/crud/internal/buisness/core/dto"
+	"github.com/AgeroFlynn/crud/internal/buisness/repository/store/sharelink"
+	"github.com/AgeroFlynn/crud/internal/buisness/sys/auth"
+	"github.com/AgeroFlynn/crud/internal/buisness/sys/validate"
+	"github.com/AgeroFlynn/crud/internal/foundation/database"
+	"go.uber.org/zap"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Store manages the set of API's for share link access held in memory.
+type Store struct {
+	log *zap.SugaredLogger
+
+	mu       sync.Mutex
+	links    map[string]dto.ShareLink
+	accesses []dto.ShareLinkAccess
+}
+
+// NewStore constructs an empty in-memory share link store.
+func NewStore(log *zap.SugaredLogger) *Store {
+	return &Store{
+		log:   log,
+		links: make(map[string]dto.ShareLink),
+	}
+}
+
+// Create inserts a new share link.
+func (s *Store) Create(ctx context.Context, nsl dto.NewShareLink, now time.Time) (dto.ShareLink, error) {
+	sl := dto.ShareLink{
+		ID:          validate.GenerateID(),
+		UserID:      nsl.UserID,
+		SingleUse:   nsl.SingleUse,
+		ExpiresAt:   now.Add(nsl.ExpiresIn),
+		DateCreated: now,
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.links[sl.ID] = sl
+
+	return sl, nil
+}
+
+// Revoke marks a share link as revoked so it can't be opened anymore.
+func (s *Store) Revoke(ctx context.Context, claims auth.Claims, linkID string, now time.Time) error {
+	sl, err := s.FindByID(ctx, claims, linkID)
+	if err != nil {
+		return fmt.Errorf("revoking share link linkID[%s]: %w", linkID, err)
+	}
+
+	// If you are not allowed to modify users and looking to revoke a link owned by someone else.
+	if !claims.HasPermission(auth.PermUsersWrite) && claims.Subject != sl.UserID {
+		return database.ErrForbidden
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Revoking a link twice keeps the original revocation date.
+	sl, exists := s.links[linkID]
+	if !exists || sl.DateRevoked != nil {
+		return nil
+	}
+
+	sl.DateRevoked = &now
+	s.links[linkID] = sl
+
+	return nil
+}
+
+// Consume records a use of the share link. It fails with ErrUnavailable if the
+// link is expired, revoked or is a single use link that was already opened.
+func (s *Store) Consume(ctx context.Context, linkID string, now time.Time) (dto.ShareLink, error) {
+	if err := validate.CheckID(linkID); err != nil {
+		return dto.ShareLink{}, database.ErrInvalidID
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sl, exists := s.links[linkID]
+	switch {
+	case !exists,
+		sl.DateRevoked != nil,
+		!sl.ExpiresAt.After(now),
+		sl.SingleUse && sl.UseCount > 0:
+		return dto.ShareLink{}, sharelink.ErrUnavailable
+	}
+
+	sl.UseCount++
+	s.links[linkID] = sl
+
+	return sl, nil
+}
+
+// RecordAccess stores an attempt to open a share link.
+func (s *Store) RecordAccess(ctx context.Context, access dto.ShareLinkAccess) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Like the foreign key of the database, accesses are only kept for links
+	// that exist. Attempts which don't name a link are kept too.
+	if access.ShareLinkID != "" {
+		if _, exists := s.links[access.ShareLinkID]; !exists {
+			return fmt.Errorf("inserting share link access: %w", database.ErrForeignKey)
+		}
+	}
+
+	access.ID = validate.GenerateID()
+	s.accesses = append(s.accesses, access)
+
+	return nil
+}
+
+// FindByUser retrieves the share links owned by the specified user.
+func (s *Store) FindByUser(ctx context.Context, claims auth.Claims, userID string) ([]dto.ShareLink, error) {
+
+	// If you are not allowed to read users and looking to retrieve someone other than yourself.
+	if !claims.HasPermission(auth.PermUsersRead) && claims.Subject != userID {
+		return nil, database.ErrForbidden
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var links []dto.ShareLink
+	for _, sl := range s.links {
+		if sl.UserID == userID {
+			links = append(links, sl)
+		}
+	}
+	sort.SliceStable(links, func(i, j int) bool {
+		return links[i].DateCreated.After(links[j].DateCreated)
+	})
+
+	return links, nil
+}
+
+// FindByID gets the specified share link.
+func (s *Store) FindByID(ctx context.Context, claims auth.Claims, linkID string) (dto.ShareLink, error) {
+	if err := validate.CheckID(linkID); err != nil {
+		return dto.ShareLink{}, database.ErrInvalidID
+	}
+
+	s.mu.Lock()
+	sl, exists := s.links[linkID]
+	s.mu.Unlock()
+
+	if !exists {
+		return dto.ShareLink{}, database.ErrNotFound
+	}
+
+	// If you are not allowed to read users and looking to retrieve a link owned by someone else.
+	if !claims.HasPermission(auth.PermUsersRead) && claims.Subject != sl.UserID {
+		return dto.ShareLink{}, database.ErrForbidden
+	}
+
+	return sl, nil
+}
+
+// FindAccesses retrieves the access log of the specified share link.
+func (s *Store) FindAccesses(ctx context.Context, claims auth.Claims, linkID string) ([]dto.ShareLinkAccess, error) {
+	if _, err := s.FindByID(ctx, claims, linkID); err != nil {
+		return nil, fmt.Errorf("selecting accesses linkID[%s]: %w", linkID, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var accesses []dto.ShareLinkAccess
+	for i := len(s.accesses) - 1; i >= 0; i-- {
+		if s.accesses[i].ShareLinkID == linkID {
+			accesses = append(accesses, s.accesses[i])
+		}
+	}
+
+	return accesses, nil
+}
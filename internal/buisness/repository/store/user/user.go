@@ -6,37 +6,56 @@ import (
 	"fmt"
 	"github.com/AgeroFlynn/crud/internal/buisness/core/dto"
 	"github.com/AgeroFlynn/crud/internal/buisness/repository/entity"
+	"github.com/AgeroFlynn/crud/internal/buisness/repository/order"
 	"github.com/AgeroFlynn/crud/internal/buisness/sys/auth"
+	"github.com/AgeroFlynn/crud/internal/buisness/sys/passwd"
 	"github.com/AgeroFlynn/crud/internal/buisness/sys/validate"
 	"github.com/AgeroFlynn/crud/internal/foundation/database"
 	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
 	"github.com/golang-jwt/jwt/v4"
 	"go.uber.org/zap"
-	"golang.org/x/crypto/bcrypt"
+	"strings"
 	"time"
 )
 
 // Store manages the set of API's for user access.
 type Store struct {
-	log *zap.SugaredLogger
-	db  *pg.DB
+	log    *zap.SugaredLogger
+	db     *pg.DB
+	hasher passwd.Hasher
+	dummy  []byte
 }
 
-// NewStore constructs a user store for api access.
-func NewStore(log *zap.SugaredLogger, db *pg.DB) Store {
+// NewStore constructs a user store for api access. New passwords are hashed
+// with the hasher.
+func NewStore(log *zap.SugaredLogger, db *pg.DB, hasher passwd.Hasher) Store {
 	return Store{
-		log: log,
-		db:  db,
+		log:    log,
+		db:     db,
+		hasher: hasher,
+		dummy:  passwd.DummyHash(hasher),
 	}
 }
 
+// WithinTran runs fn inside a transaction, every call of the store made
+// with the context handed to fn joins it.
+func (s Store) WithinTran(ctx context.Context, fn func(ctx context.Context) error) error {
+	return database.WithinTran(ctx, s.log, s.db, fn)
+}
+
 // Create inserts a new user into the database.
 func (s Store) Create(ctx context.Context, nu dto.NewUser, now time.Time) (dto.User, error) {
-	hash, err := bcrypt.GenerateFromPassword([]byte(nu.Password), bcrypt.DefaultCost)
+	hash, err := s.hasher.Hash(nu.Password)
 	if err != nil {
 		return dto.User{}, fmt.Errorf("generating password hash: %w", err)
 	}
 
+	status := nu.Status
+	if status == "" {
+		status = dto.StatusActive
+	}
+
 	usr := entity.User{
 		ID:           validate.GenerateID(),
 		Name:         nu.Name,
@@ -45,90 +64,402 @@ func (s Store) Create(ctx context.Context, nu dto.NewUser, now time.Time) (dto.U
 		Roles:        nu.Roles,
 		DateCreated:  now,
 		DateUpdated:  now,
+		Status:       status,
+		Version:      1,
 	}
 
-	_, err = s.db.Model(&usr).Insert()
+	err = database.WithinTran(ctx, s.log, s.db, func(ctx context.Context) error {
+		if _, err := database.Conn(ctx, s.db).ModelContext(ctx, &usr).Insert(); err != nil {
+			return fmt.Errorf("inserting user: %w", database.MapError(err))
+		}
+		return s.recordPassword(ctx, usr.ID, hash, now)
+	})
 	if err != nil {
-		return dto.User{}, fmt.Errorf("inserting user: %w", err)
+		return dto.User{}, err
 	}
 
 	return *usr.ToDTOUser(), nil
 }
 
-// Update replaces a user document in the database.
-func (s Store) Update(ctx context.Context, claims auth.Claims, userID string, uu dto.UpdateUser, now time.Time) error {
-	usr, err := s.FindByID(ctx, claims, userID)
+// Update replaces a user document in the database. When version is provided
+// the update only succeeds if the stored user still has that version. A
+// concurrent modification of the user always fails with ErrConflict. Changing
+// the roles or the password revokes the tokens issued to the user so far.
+func (s Store) Update(ctx context.Context, userID string, uu dto.UpdateUser, version *int, now time.Time) error {
+	dtoUsr, err := s.FindByID(ctx, userID)
 	if err != nil {
 		return fmt.Errorf("updating user userID[%s]: %w", userID, err)
 	}
 
+	if version != nil && *version != dtoUsr.Version {
+		return fmt.Errorf("updating user userID[%s]: %w", userID, database.ErrConflict)
+	}
+
+	usr := entity.FromDTOUser(&dtoUsr)
 	if uu.Name != nil {
 		usr.Name = *uu.Name
 	}
 	if uu.Email != nil {
+
+		// A new email has to be verified again.
+		if !strings.EqualFold(usr.Email, *uu.Email) {
+			usr.DateVerified = nil
+		}
 		usr.Email = *uu.Email
 	}
 	if uu.Roles != nil {
+
+		// The tokens issued so far carry the permissions of the old roles.
+		if !auth.SameRoles(usr.Roles, uu.Roles) {
+			usr.DateTokensRevoked = &now
+		}
 		usr.Roles = uu.Roles
 	}
 	if uu.Password != nil {
-		pw, err := bcrypt.GenerateFromPassword([]byte(*uu.Password), bcrypt.DefaultCost)
+		pw, err := s.hasher.Hash(*uu.Password)
 		if err != nil {
 			return fmt.Errorf("generating password hash: %w", err)
 		}
 		usr.PasswordHash = pw
+
+		// Whoever knew the old password may hold a token issued with it.
+		usr.DateTokensRevoked = &now
 	}
 	usr.DateUpdated = now
+	usr.Version = dtoUsr.Version + 1
+
+	// The version read above guards against someone else modifying the user
+	// between the read and the write. Logins don't change the version, the
+	// time of the last one is left alone.
+	return database.WithinTran(ctx, s.log, s.db, func(ctx context.Context) error {
+		res, err := database.Conn(ctx, s.db).ModelContext(ctx, usr).ExcludeColumn("last_login_at").WherePK().Where("version = ?", dtoUsr.Version).Update()
+		if err != nil {
+			return fmt.Errorf("updating userID[%s]: %w", userID, database.MapError(err))
+		}
+		if res.RowsAffected() == 0 {
+			return fmt.Errorf("updating userID[%s]: %w", userID, database.ErrConflict)
+		}
 
-	if _, err := s.db.Model(&usr).Where("user_id = ?", userID).Update(); err != nil {
-		return fmt.Errorf("updating userID[%s]: %w", userID, err)
+		if uu.Password != nil {
+			return s.recordPassword(ctx, userID, usr.PasswordHash, now)
+		}
+		return nil
+	})
+}
+
+// Delete removes a user from the database. When version is provided the
+// user is only removed if the stored user still has that version, a user
+// that doesn't exist fails with ErrConflict.
+func (s Store) Delete(ctx context.Context, userID string, version *int) error {
+	q := database.Conn(ctx, s.db).ModelContext(ctx, (*entity.User)(nil)).Where("user_id = ?", userID)
+	if version != nil {
+		q.Where("version = ?", *version)
+	}
+
+	res, err := q.Delete()
+	if err != nil {
+		return fmt.Errorf("deleting userID[%s]: %w", userID, database.MapError(err))
+	}
+
+	// Nothing was removed. Either the user is gone already or it was
+	// modified by someone else, the version doesn't match in both cases.
+	if version != nil && res.RowsAffected() == 0 {
+		return fmt.Errorf("deleting userID[%s]: %w", userID, database.ErrConflict)
 	}
 
 	return nil
 }
 
-// Delete removes a user from the database.
-func (s Store) Delete(ctx context.Context, claims auth.Claims, userID string) error {
-	// If you are not an admin and looking to delete someone other than yourself.
-	if !claims.Authorized(auth.RoleAdmin) && claims.Subject != userID {
-		return database.ErrForbidden
+// SetPassword replaces the password of the user and revokes the tokens
+// issued to the user so far. The password is only replaced if the user
+// still has the version, otherwise ErrConflict is returned.
+func (s Store) SetPassword(ctx context.Context, userID string, password string, version int, now time.Time) error {
+	if err := validate.CheckID(userID); err != nil {
+		return database.ErrInvalidID
+	}
+
+	hash, err := s.hasher.Hash(password)
+	if err != nil {
+		return fmt.Errorf("generating password hash: %w", err)
 	}
 
+	return database.WithinTran(ctx, s.log, s.db, func(ctx context.Context) error {
+		res, err := database.Conn(ctx, s.db).ModelContext(ctx, (*entity.User)(nil)).
+			Set("password_hash = ?", hash).
+			Set("date_tokens_revoked = ?", now).
+			Set("date_updated = ?", now).
+			Set("version = version + 1").
+			Where("user_id = ?", userID).
+			Where("version = ?", version).
+			Update()
+		if err != nil {
+			return fmt.Errorf("setting password userID[%s]: %w", userID, err)
+		}
+		if res.RowsAffected() == 0 {
+			return fmt.Errorf("setting password userID[%s]: %w", userID, database.ErrConflict)
+		}
+
+		return s.recordPassword(ctx, userID, hash, now)
+	})
+}
+
+// SetStatus changes the status of the user and revokes the tokens issued to
+// the user so far. The status is only changed if the user still has the
+// version, otherwise ErrConflict is returned.
+func (s Store) SetStatus(ctx context.Context, userID string, status string, reason string, version int, now time.Time) error {
+	if err := validate.CheckID(userID); err != nil {
+		return database.ErrInvalidID
+	}
+
+	res, err := database.Conn(ctx, s.db).ModelContext(ctx, (*entity.User)(nil)).
+		Set("status = ?", status).
+		Set("status_reason = ?", reason).
+		Set("date_status_changed = ?", now).
+		Set("date_tokens_revoked = ?", now).
+		Set("date_updated = ?", now).
+		Set("version = version + 1").
+		Where("user_id = ?", userID).
+		Where("version = ?", version).
+		Update()
+	if err != nil {
+		return fmt.Errorf("setting status userID[%s]: %w", userID, database.MapError(err))
+	}
+	if res.RowsAffected() == 0 {
+		return fmt.Errorf("setting status userID[%s]: %w", userID, database.ErrConflict)
+	}
+
+	return nil
+}
+
+// SetLastLogin records when the user last logged in. It isn't a modification
+// of the user, the version stays the same.
+func (s Store) SetLastLogin(ctx context.Context, userID string, now time.Time) error {
+	if err := validate.CheckID(userID); err != nil {
+		return database.ErrInvalidID
+	}
+
+	_, err := database.Conn(ctx, s.db).ModelContext(ctx, (*entity.User)(nil)).
+		Set("last_login_at = ?", now).
+		Where("user_id = ?", userID).
+		Update()
+	if err != nil {
+		return fmt.Errorf("setting last login userID[%s]: %w", userID, err)
+	}
+
+	return nil
+}
+
+// PasswordHistory retrieves the hashes of the most recent passwords of the
+// user, the most recent first. Passwords set before the history was kept
+// are missing.
+func (s Store) PasswordHistory(ctx context.Context, userID string, limit int) ([][]byte, error) {
+	if err := validate.CheckID(userID); err != nil {
+		return nil, database.ErrInvalidID
+	}
+
+	var history []entity.PasswordHistory
+	err := database.Conn(ctx, s.db).ModelContext(ctx, &history).
+		Where("user_id = ?", userID).
+		Order("date_created DESC").
+		Limit(limit).
+		Select()
+	if err != nil {
+		return nil, fmt.Errorf("selecting password history userID[%s]: %w", userID, err)
+	}
+
+	hashes := make([][]byte, len(history))
+	for i, h := range history {
+		hashes[i] = h.PasswordHash
+	}
+
+	return hashes, nil
+}
+
+// recordPassword adds the password hash to the history of the user.
+func (s Store) recordPassword(ctx context.Context, userID string, hash []byte, now time.Time) error {
+	h := entity.PasswordHistory{
+		ID:           validate.GenerateID(),
+		UserID:       userID,
+		PasswordHash: hash,
+		DateCreated:  now,
+	}
+
+	if _, err := database.Conn(ctx, s.db).ModelContext(ctx, &h).Insert(); err != nil {
+		return fmt.Errorf("recording password userID[%s]: %w", userID, err)
+	}
+
+	return nil
+}
+
+// Verify marks the email of the user as verified. The email has to be the
+// current email of the user. Verifying a user twice keeps the original
+// verification date.
+func (s Store) Verify(ctx context.Context, userID string, email string, now time.Time) error {
+	if err := validate.CheckID(userID); err != nil {
+		return database.ErrInvalidID
+	}
+
+	res, err := database.Conn(ctx, s.db).ModelContext(ctx, (*entity.User)(nil)).
+		Set("date_verified = ?", now).
+		Set("version = version + 1").
+		Where("user_id = ?", userID).
+		Where("lower(email) = lower(?)", email).
+		Where("date_verified IS NULL").
+		Update()
+	if err != nil {
+		return fmt.Errorf("verifying userID[%s]: %w", userID, err)
+	}
+	if res.RowsAffected() > 0 {
+		return nil
+	}
+
+	// Nothing was updated, find out if the user was verified already.
 	var usr entity.User
-	if _, err := s.db.Model(&usr).Where("user_id = ?", userID).Delete(); err != nil {
-		return fmt.Errorf("deleting userID[%s]: %w", userID, err)
+	if err := database.Conn(ctx, s.db).ModelContext(ctx, &usr).Column("user_id").Where("user_id = ?", userID).Where("lower(email) = lower(?)", email).Limit(1).Select(); err != nil {
+		if err == pg.ErrNoRows {
+			return database.ErrNotFound
+		}
+		return fmt.Errorf("verifying userID[%s]: %w", userID, err)
 	}
 
 	return nil
 }
 
-// FindAll retrieves a list of existing users from the database.
-func (s Store) FindAll(ctx context.Context) ([]dto.User, error) {
+// rehash replaces the hash of the password of the user. It leaves the hash
+// alone if the password was changed in the meantime. A failure is only
+// logged since the user authenticated already.
+func (s Store) rehash(ctx context.Context, userID string, oldHash []byte, password string) {
+	hash, err := s.hasher.Hash(password)
+	if err != nil {
+		s.log.Errorw("rehashing password", "userID", userID, "ERROR", err)
+		return
+	}
+
+	_, err = database.Conn(ctx, s.db).ModelContext(ctx, (*entity.User)(nil)).
+		Set("password_hash = ?", hash).
+		Where("user_id = ?", userID).
+		Where("password_hash = ?", oldHash).
+		Update()
+	if err != nil {
+		s.log.Errorw("rehashing password", "userID", userID, "ERROR", err)
+	}
+}
+
+// Query retrieves a page of users matching the filter from the database. It
+// also returns the total number of users matching the filter.
+func (s Store) Query(ctx context.Context, filter dto.UserFilter, orderBy order.By, offset int, limit int) ([]dto.User, int, error) {
 
 	var users []entity.User
-	if err := s.db.Model(&users).Select(); err != nil {
-		if err == pg.ErrNoRows {
-			return nil, database.ErrNotFound
+	q := database.Conn(ctx, s.db).ModelContext(ctx, &users)
+	applyFilter(q, filter)
+
+	// The id is always the last ordering so pages are stable when the
+	// ordering column holds duplicates.
+	q.OrderExpr(orderBy.Clause())
+	if orderBy.Field != "user_id" {
+		q.Order("user_id ASC")
+	}
+
+	total, err := q.Offset(offset).Limit(limit).SelectAndCount()
+	if err != nil {
+		return nil, 0, fmt.Errorf("selecting users: %w", err)
+	}
+
+	return *entity.ToDTOUserSlice(&users), total, nil
+}
+
+// QueryAfter retrieves the users following the key in the ordering. When
+// backward is set the users preceding the key are retrieved instead, they
+// are still returned in the order asked for. A nil key starts at the
+// beginning of the ordering. It reports whether there are more users beyond
+// the returned ones.
+func (s Store) QueryAfter(ctx context.Context, filter dto.UserFilter, orderBy order.By, key *order.Key, backward bool, limit int) ([]dto.User, bool, error) {
+
+	// Walking backward is the same as walking forward in the reversed
+	// ordering. The id breaks ties using the same direction as the ordering
+	// so the row comparison matches the ORDER BY.
+	dir := orderBy.Direction
+	if backward {
+		dir = order.Reverse(dir)
+	}
+
+	var users []entity.User
+	q := database.Conn(ctx, s.db).ModelContext(ctx, &users)
+	applyFilter(q, filter)
+
+	if key != nil {
+		op := ">"
+		if dir == order.DESC {
+			op = "<"
+		}
+		q.Where(fmt.Sprintf("(%s, user_id) %s (?, ?)", orderBy.Field, op), key.Value, key.ID)
+	}
+
+	q.OrderExpr(order.NewBy(orderBy.Field, dir).Clause())
+	if orderBy.Field != "user_id" {
+		q.OrderExpr(order.NewBy("user_id", dir).Clause())
+	}
+
+	// Ask for one more row to find out if there is another page.
+	if err := q.Limit(limit + 1).Select(); err != nil {
+		return nil, false, fmt.Errorf("selecting users: %w", err)
+	}
+
+	more := len(users) > limit
+	if more {
+		users = users[:limit]
+	}
+
+	if backward {
+		for i, j := 0, len(users)-1; i < j; i, j = i+1, j-1 {
+			users[i], users[j] = users[j], users[i]
 		}
-		return nil, fmt.Errorf("selecting users: %w", err)
 	}
 
-	return *entity.ToDTOUserSlice(&users), nil
+	return *entity.ToDTOUserSlice(&users), more, nil
+}
+
+// applyFilter adds the conditions of the filter to the query.
+func applyFilter(q *orm.Query, filter dto.UserFilter) {
+	if filter.Name != nil {
+		q.Where("name ILIKE ?", "%"+escapeLike(*filter.Name)+"%")
+	}
+	if filter.Email != nil {
+		q.Where("email ILIKE ?", "%"+escapeLike(*filter.Email)+"%")
+	}
+	if filter.Role != nil {
+		q.Where("? = ANY(roles)", *filter.Role)
+	}
+	if filter.StartCreatedDate != nil {
+		q.Where("date_created >= ?", *filter.StartCreatedDate)
+	}
+	if filter.EndCreatedDate != nil {
+		q.Where("date_created <= ?", *filter.EndCreatedDate)
+	}
+	if filter.Status != nil {
+		q.Where("status = ?", *filter.Status)
+	}
+}
+
+// escapeLike escapes the wildcards of LIKE patterns, the filters match the
+// text as it is given.
+func escapeLike(s string) string {
+	r := strings.NewReplacer(
+		`\`, `\\`,
+		"%", `\%`,
+		"_", `\_`,
+	)
+	return r.Replace(s)
 }
 
 // FindByID gets the specified user from the database.
-func (s Store) FindByID(ctx context.Context, claims auth.Claims, userID string) (dto.User, error) {
+func (s Store) FindByID(ctx context.Context, userID string) (dto.User, error) {
 	if err := validate.CheckID(userID); err != nil {
 		return dto.User{}, database.ErrInvalidID
 	}
 
-	// If you are not an admin and looking to retrieve someone other than yourself.
-	if !claims.Authorized(auth.RoleAdmin) && claims.Subject != userID {
-		return dto.User{}, database.ErrForbidden
-	}
-
 	var usr entity.User
-	if err := s.db.Model(&usr).Where("user_id = ?", userID).Limit(1).Select(); err != nil {
+	if err := database.Conn(ctx, s.db).ModelContext(ctx, &usr).Where("user_id = ?", userID).Limit(1).Select(); err != nil {
 		if err == pg.ErrNoRows {
 			return dto.User{}, database.ErrNotFound
 		}
@@ -138,22 +469,18 @@ func (s Store) FindByID(ctx context.Context, claims auth.Claims, userID string)
 	return *usr.ToDTOUser(), nil
 }
 
-// FindByEmail gets the specified user from the database by email.
-func (s Store) FindByEmail(ctx context.Context, claims auth.Claims, email string) (dto.User, error) {
+// FindByEmail gets the specified user from the database by email. Emails
+// are compared case insensitively.
+func (s Store) FindByEmail(ctx context.Context, email string) (dto.User, error) {
 
 	var usr entity.User
-	if err := s.db.Model(&usr).Where("email = ?", email).Limit(1).Select(); err != nil {
+	if err := database.Conn(ctx, s.db).ModelContext(ctx, &usr).Where("lower(email) = lower(?)", email).Limit(1).Select(); err != nil {
 		if err == pg.ErrNoRows {
 			return dto.User{}, database.ErrNotFound
 		}
 		return dto.User{}, fmt.Errorf("selecting email[%q]: %w", email, err)
 	}
 
-	// If you are not an admin and looking to retrieve someone other than yourself.
-	if !claims.Authorized(auth.RoleAdmin) && claims.Subject != usr.ID {
-		return dto.User{}, database.ErrForbidden
-	}
-
 	return *usr.ToDTOUser(), nil
 }
 
@@ -163,29 +490,41 @@ func (s Store) FindByEmail(ctx context.Context, claims auth.Claims, email string
 func (s Store) Authenticate(ctx context.Context, now time.Time, email, password string) (auth.Claims, error) {
 
 	var usr entity.User
-	if err := s.db.Model(&usr).Where("email = ?", email).Limit(1).Select(); err != nil {
+	if err := database.Conn(ctx, s.db).ModelContext(ctx, &usr).Where("lower(email) = lower(?)", email).Limit(1).Select(); err != nil {
 		if err == pg.ErrNoRows {
+
+			// Take as long as for a wrong password, so unknown emails
+			// can't be told apart by the response time.
+			passwd.Compare(s.dummy, password)
 			return auth.Claims{}, database.ErrNotFound
 		}
 		return auth.Claims{}, fmt.Errorf("selecting user[%q]: %w", email, err)
 	}
 
-	// Compare the provided password with the saved hash. Use the bcrypt
-	// comparison function - so it is cryptographically secure.
-	if err := bcrypt.CompareHashAndPassword(usr.PasswordHash, []byte(password)); err != nil {
+	// Compare the provided password with the saved hash. The comparison
+	// functions of the hash algorithms are cryptographically secure.
+	if err := passwd.Compare(usr.PasswordHash, password); err != nil {
 		return auth.Claims{}, database.ErrAuthenticationFailure
 	}
 
+	// The password is only known now, upgrade a hash made by an outdated
+	// algorithm or with weaker parameters.
+	if s.hasher.NeedsRehash(usr.PasswordHash) {
+		s.rehash(ctx, usr.ID, usr.PasswordHash, password)
+	}
+
 	// If we are this far the request is valid. Create some claims for the user
 	// and generate their token.
+	issued := time.Now().UTC()
 	claims := auth.Claims{
 		RegisteredClaims: jwt.RegisteredClaims{
 			Issuer:    "service project",
 			Subject:   usr.ID,
-			ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(8760 * time.Hour)),
-			IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
+			ExpiresAt: jwt.NewNumericDate(issued.Add(8760 * time.Hour)),
+			IssuedAt:  jwt.NewNumericDate(issued),
 		},
-		Roles: usr.Roles,
+		IssuedAtMicro: issued.UnixMicro(),
+		Roles:         usr.Roles,
 	}
 
 	return claims, nil
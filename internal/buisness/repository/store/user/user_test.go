@@ -4,11 +4,11 @@ import (
 	"context"
 	"errors"
 	"github.com/AgeroFlynn/crud/internal/buisness/core/dto"
+	"github.com/AgeroFlynn/crud/internal/buisness/repository/order"
 	"github.com/AgeroFlynn/crud/internal/buisness/repository/store/user"
 	"github.com/AgeroFlynn/crud/internal/buisness/sys/auth"
 	"github.com/AgeroFlynn/crud/internal/buisness/sys/tests"
 	"github.com/AgeroFlynn/crud/internal/foundation/database"
-	"github.com/golang-jwt/jwt/v4"
 	"github.com/google/go-cmp/cmp"
 	"testing"
 	"time"
@@ -30,7 +30,7 @@ func TestUser(t *testing.T) {
 	log, db, teardown := tests.NewUnit(t, dbc)
 	t.Cleanup(teardown)
 
-	store := user.NewStore(log, db)
+	store := user.NewStore(log, db, tests.Hasher)
 
 	t.Log("Given the need to work with User records.")
 	{
@@ -54,17 +54,7 @@ func TestUser(t *testing.T) {
 			}
 			t.Logf("\t%s\tTest %d:\tShould be able to create user.", tests.Success, testID)
 
-			claims := auth.Claims{
-				RegisteredClaims: jwt.RegisteredClaims{
-					Issuer:    "service project",
-					Subject:   usr.ID,
-					ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(8760 * time.Hour)),
-					IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
-				},
-				Roles: []string{auth.RoleUser},
-			}
-
-			saved, err := store.FindByID(ctx, claims, usr.ID)
+			saved, err := store.FindByID(ctx, usr.ID)
 			if err != nil {
 				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve user by ID: %s.", tests.Failed, testID, err)
 			}
@@ -80,21 +70,18 @@ func TestUser(t *testing.T) {
 				Email: tests.StringPointer("updateduser@google.com"),
 			}
 
-			claims = auth.Claims{
-				RegisteredClaims: jwt.RegisteredClaims{
-					Issuer:    "service project",
-					ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(8760 * time.Hour)),
-					IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
-				},
-				Roles: []string{auth.RoleAdmin},
-			}
-
-			if err := store.Update(ctx, claims, usr.ID, upd, now); err != nil {
+			if err := store.Update(ctx, usr.ID, upd, nil, now); err != nil {
 				t.Fatalf("\t%s\tTest %d:\tShould be able to update user : %s.", tests.Failed, testID, err)
 			}
 			t.Logf("\t%s\tTest %d:\tShould be able to update user.", tests.Success, testID)
 
-			saved, err = store.FindByEmail(ctx, claims, *upd.Email)
+			stale := usr.Version
+			if err := store.Update(ctx, usr.ID, upd, &stale, now); !errors.Is(err, database.ErrConflict) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to update user with a stale version : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to update user with a stale version.", tests.Success, testID)
+
+			saved, err = store.FindByEmail(ctx, *upd.Email)
 			if err != nil {
 				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve user by Email : %s.", tests.Failed, testID, err)
 			}
@@ -116,16 +103,101 @@ func TestUser(t *testing.T) {
 				t.Logf("\t%s\tTest %d:\tShould be able to see updates to Email.", tests.Success, testID)
 			}
 
-			if err := store.Delete(ctx, claims, usr.ID); err != nil {
+			if err := store.Delete(ctx, usr.ID, nil); err != nil {
 				t.Fatalf("\t%s\tTest %d:\tShould be able to delete user : %s.", tests.Failed, testID, err)
 			}
 			t.Logf("\t%s\tTest %d:\tShould be able to delete user.", tests.Success, testID)
 
-			_, err = store.FindByID(ctx, claims, usr.ID)
+			_, err = store.FindByID(ctx, usr.ID)
 			if !errors.Is(err, database.ErrNotFound) {
 				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to retrieve user : %s.", tests.Failed, testID, err)
 			}
 			t.Logf("\t%s\tTest %d:\tShould NOT be able to retrieve user.", tests.Success, testID)
 		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen creating a User inside a failing transaction.", testID)
+		{
+			ctx := context.Background()
+			now := time.Date(2018, time.October, 1, 0, 0, 0, 0, time.UTC)
+
+			nu := dto.NewUser{
+				Name:            "Rolled Back",
+				Email:           "rolledback@example.com",
+				Roles:           []string{auth.RoleUser},
+				Password:        "passw0rd",
+				PasswordConfirm: "passw0rd",
+			}
+
+			errAbort := errors.New("abort")
+			err := database.WithinTran(ctx, log, db, func(ctx context.Context) error {
+				if _, err := store.Create(ctx, nu, now); err != nil {
+					return err
+				}
+				return errAbort
+			})
+			if !errors.Is(err, errAbort) {
+				t.Fatalf("\t%s\tTest %d:\tShould get the error of the transaction : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get the error of the transaction.", tests.Success, testID)
+
+			if _, err := store.FindByEmail(ctx, nu.Email); !errors.Is(err, database.ErrNotFound) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT find the user after the rollback : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT find the user after the rollback.", tests.Success, testID)
+		}
+
+		testID = 2
+		t.Logf("\tTest %d:\tWhen authenticating a User with an outdated hash.", testID)
+		{
+			ctx := context.Background()
+			now := time.Date(2018, time.October, 1, 0, 0, 0, 0, time.UTC)
+
+			// The seeded users have bcrypt hashes.
+			if _, err := store.Authenticate(ctx, now, "admin@example.com", "gophers"); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to authenticate : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to authenticate.", tests.Success, testID)
+
+			usr, err := store.FindByEmail(ctx, "admin@example.com")
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve user : %s.", tests.Failed, testID, err)
+			}
+			if tests.Hasher.NeedsRehash(usr.PasswordHash) {
+				t.Fatalf("\t%s\tTest %d:\tShould have upgraded the hash : %s.", tests.Failed, testID, usr.PasswordHash)
+			}
+			t.Logf("\t%s\tTest %d:\tShould have upgraded the hash.", tests.Success, testID)
+
+			if _, err := store.Authenticate(ctx, now, "admin@example.com", "gophers"); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to authenticate with the new hash : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to authenticate with the new hash.", tests.Success, testID)
+		}
+
+		testID = 3
+		t.Logf("\tTest %d:\tWhen filtering Users with LIKE wildcards.", testID)
+		{
+			ctx := context.Background()
+			orderBy := order.NewBy("name", order.ASC)
+
+			for _, name := range []string{"%", "_", `\`} {
+				filter := dto.UserFilter{Name: tests.StringPointer(name)}
+
+				_, total, err := store.Query(ctx, filter, orderBy, 0, 10)
+				if err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to query users : %s.", tests.Failed, testID, err)
+				}
+				if total != 0 {
+					t.Fatalf("\t%s\tTest %d:\tShould match %q literally : got %d users.", tests.Failed, testID, name, total)
+				}
+			}
+			t.Logf("\t%s\tTest %d:\tShould match the wildcards literally.", tests.Success, testID)
+
+			_, total, err := store.Query(ctx, dto.UserFilter{Name: tests.StringPointer("gopher")}, orderBy, 0, 10)
+			if err != nil || total != 2 {
+				t.Fatalf("\t%s\tTest %d:\tShould still match names case insensitively : got %d users, %v.", tests.Failed, testID, total, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould still match names case insensitively.", tests.Success, testID)
+		}
 	}
 }
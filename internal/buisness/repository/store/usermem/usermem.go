@@ -0,0 +1,643 @@
+// Package usermem contains an in-memory implementation of the user store. It
+// follows the semantics of the database store and is meant for tests that
+// don't need a real database.
+package usermem
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/AgeroFlynn/crud/internal/buisness/core/dto"
+	"github.com/AgeroFlynn/crud/internal/buisness/repository/order"
+	"github.com/AgeroFlynn/crud/internal/buisness/repository/store/memtx"
+	"github.com/AgeroFlynn/crud/internal/buisness/sys/auth"
+	"github.com/AgeroFlynn/crud/internal/buisness/sys/passwd"
+	"github.com/AgeroFlynn/crud/internal/buisness/sys/validate"
+	"github.com/AgeroFlynn/crud/internal/foundation/database"
+	"github.com/golang-jwt/jwt/v4"
+	"go.uber.org/zap"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Store manages the set of API's for user access held in memory.
+type Store struct {
+	log    *zap.SugaredLogger
+	hasher passwd.Hasher
+	dummy  []byte
+
+	mu        sync.RWMutex
+	users     map[string]dto.User
+	passwords map[string][][]byte
+}
+
+// NewStore constructs an empty in-memory user store. New passwords are
+// hashed with the hasher.
+func NewStore(log *zap.SugaredLogger, hasher passwd.Hasher) *Store {
+	return &Store{
+		log:       log,
+		hasher:    hasher,
+		dummy:     passwd.DummyHash(hasher),
+		users:     make(map[string]dto.User),
+		passwords: make(map[string][][]byte),
+	}
+}
+
+// Seed adds the users as they are, including their ids and password hashes.
+func (s *Store) Seed(users ...dto.User) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, usr := range users {
+		s.users[usr.ID] = clone(usr)
+	}
+}
+
+// WithinTran runs fn and restores the users as they were before when fn
+// fails. The other in-memory stores called with the context of fn take part
+// in the transaction. Modifications made outside of fn while it runs are lost
+// on a rollback, the store is meant for tests.
+func (s *Store) WithinTran(ctx context.Context, fn func(ctx context.Context) error) error {
+	return memtx.WithinTran(ctx, func(ctx context.Context) error {
+		s.join(ctx)
+		return fn(ctx)
+	})
+}
+
+// join takes a snapshot of the users which is restored when the transaction
+// of the context is rolled back.
+func (s *Store) join(ctx context.Context) {
+	s.mu.RLock()
+	users := make(map[string]dto.User, len(s.users))
+	for id, usr := range s.users {
+		users[id] = clone(usr)
+	}
+	passwords := make(map[string][][]byte, len(s.passwords))
+	for id, hashes := range s.passwords {
+		passwords[id] = append([][]byte(nil), hashes...)
+	}
+	s.mu.RUnlock()
+
+	memtx.OnRollback(ctx, func() {
+		s.mu.Lock()
+		s.users = users
+		s.passwords = passwords
+		s.mu.Unlock()
+	})
+}
+
+// Create inserts a new user into the store.
+func (s *Store) Create(ctx context.Context, nu dto.NewUser, now time.Time) (dto.User, error) {
+	hash, err := s.hasher.Hash(nu.Password)
+	if err != nil {
+		return dto.User{}, fmt.Errorf("generating password hash: %w", err)
+	}
+
+	status := nu.Status
+	if status == "" {
+		status = dto.StatusActive
+	}
+
+	usr := dto.User{
+		ID:           validate.GenerateID(),
+		Name:         nu.Name,
+		Email:        nu.Email,
+		PasswordHash: hash,
+		Roles:        nu.Roles,
+		DateCreated:  now,
+		DateUpdated:  now,
+		Status:       status,
+		Version:      1,
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.checkEmail(usr.ID, usr.Email); err != nil {
+		return dto.User{}, fmt.Errorf("inserting user: %w", err)
+	}
+	s.users[usr.ID] = clone(usr)
+	s.recordPassword(usr.ID, hash)
+
+	return clone(usr), nil
+}
+
+// Update replaces a user document in the store. When version is provided
+// the update only succeeds if the stored user still has that version. Changing
+// the roles or the password revokes the tokens issued to the user so far.
+func (s *Store) Update(ctx context.Context, userID string, uu dto.UpdateUser, version *int, now time.Time) error {
+	if err := validate.CheckID(userID); err != nil {
+		return fmt.Errorf("updating user userID[%s]: %w", userID, database.ErrInvalidID)
+	}
+
+	// Hashing is slow, keep it outside of the lock.
+	var hash []byte
+	if uu.Password != nil {
+		pw, err := s.hasher.Hash(*uu.Password)
+		if err != nil {
+			return fmt.Errorf("generating password hash: %w", err)
+		}
+		hash = pw
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	usr, exists := s.users[userID]
+	if !exists {
+		return fmt.Errorf("updating user userID[%s]: %w", userID, database.ErrNotFound)
+	}
+
+	if version != nil && *version != usr.Version {
+		return fmt.Errorf("updating user userID[%s]: %w", userID, database.ErrConflict)
+	}
+
+	if uu.Name != nil {
+		usr.Name = *uu.Name
+	}
+	if uu.Email != nil {
+		if err := s.checkEmail(userID, *uu.Email); err != nil {
+			return fmt.Errorf("updating userID[%s]: %w", userID, err)
+		}
+
+		// A new email has to be verified again.
+		if !strings.EqualFold(usr.Email, *uu.Email) {
+			usr.DateVerified = nil
+		}
+		usr.Email = *uu.Email
+	}
+	if uu.Roles != nil {
+
+		// The tokens issued so far carry the permissions of the old roles.
+		if !auth.SameRoles(usr.Roles, uu.Roles) {
+			usr.DateTokensRevoked = &now
+		}
+		usr.Roles = uu.Roles
+	}
+	if hash != nil {
+		usr.PasswordHash = hash
+		s.recordPassword(userID, hash)
+
+		// Whoever knew the old password may hold a token issued with it.
+		usr.DateTokensRevoked = &now
+	}
+	usr.DateUpdated = now
+	usr.Version++
+
+	s.users[userID] = clone(usr)
+
+	return nil
+}
+
+// Delete removes a user from the store. When version is provided the user
+// is only removed if the stored user still has that version.
+func (s *Store) Delete(ctx context.Context, userID string, version *int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// A user that is gone can't match the version.
+	usr, exists := s.users[userID]
+	if !exists && version == nil {
+		return nil
+	}
+
+	if version != nil && (!exists || *version != usr.Version) {
+		return fmt.Errorf("deleting userID[%s]: %w", userID, database.ErrConflict)
+	}
+	delete(s.users, userID)
+	delete(s.passwords, userID)
+
+	return nil
+}
+
+// SetPassword replaces the password of the user and revokes the tokens
+// issued to the user so far. The password is only replaced if the user
+// still has the version, otherwise ErrConflict is returned.
+func (s *Store) SetPassword(ctx context.Context, userID string, password string, version int, now time.Time) error {
+	if err := validate.CheckID(userID); err != nil {
+		return database.ErrInvalidID
+	}
+
+	hash, err := s.hasher.Hash(password)
+	if err != nil {
+		return fmt.Errorf("generating password hash: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	usr, exists := s.users[userID]
+	if !exists || usr.Version != version {
+		return fmt.Errorf("setting password userID[%s]: %w", userID, database.ErrConflict)
+	}
+
+	usr.PasswordHash = hash
+	usr.DateTokensRevoked = &now
+	usr.DateUpdated = now
+	usr.Version++
+	s.users[userID] = usr
+	s.recordPassword(userID, hash)
+
+	return nil
+}
+
+// SetStatus changes the status of the user and revokes the tokens issued to
+// the user so far. The status is only changed if the user still has the
+// version, otherwise ErrConflict is returned.
+func (s *Store) SetStatus(ctx context.Context, userID string, status string, reason string, version int, now time.Time) error {
+	if err := validate.CheckID(userID); err != nil {
+		return database.ErrInvalidID
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	usr, exists := s.users[userID]
+	if !exists || usr.Version != version {
+		return fmt.Errorf("setting status userID[%s]: %w", userID, database.ErrConflict)
+	}
+
+	usr.Status = status
+	usr.StatusReason = reason
+	usr.DateStatusChanged = &now
+	usr.DateTokensRevoked = &now
+	usr.DateUpdated = now
+	usr.Version++
+	s.users[userID] = usr
+
+	return nil
+}
+
+// SetLastLogin records when the user last logged in. It isn't a modification
+// of the user, the version stays the same.
+func (s *Store) SetLastLogin(ctx context.Context, userID string, now time.Time) error {
+	if err := validate.CheckID(userID); err != nil {
+		return database.ErrInvalidID
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if usr, exists := s.users[userID]; exists {
+		usr.LastLoginAt = &now
+		s.users[userID] = usr
+	}
+
+	return nil
+}
+
+// PasswordHistory retrieves the hashes of the most recent passwords of the
+// user, the most recent first. Passwords of seeded users are missing.
+func (s *Store) PasswordHistory(ctx context.Context, userID string, limit int) ([][]byte, error) {
+	if err := validate.CheckID(userID); err != nil {
+		return nil, database.ErrInvalidID
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	history := s.passwords[userID]
+
+	var hashes [][]byte
+	for i := len(history) - 1; i >= 0 && len(hashes) < limit; i-- {
+		hashes = append(hashes, append([]byte(nil), history[i]...))
+	}
+
+	return hashes, nil
+}
+
+// recordPassword adds the password hash to the history of the user. The
+// caller must hold the lock.
+func (s *Store) recordPassword(userID string, hash []byte) {
+	s.passwords[userID] = append(s.passwords[userID], append([]byte(nil), hash...))
+}
+
+// Verify marks the email of the user as verified. The email has to be the
+// current email of the user. Verifying a user twice keeps the original
+// verification date.
+func (s *Store) Verify(ctx context.Context, userID string, email string, now time.Time) error {
+	if err := validate.CheckID(userID); err != nil {
+		return database.ErrInvalidID
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	usr, exists := s.users[userID]
+	if !exists || !strings.EqualFold(usr.Email, email) {
+		return database.ErrNotFound
+	}
+
+	if usr.DateVerified == nil {
+		usr.DateVerified = &now
+		usr.Version++
+		s.users[userID] = usr
+	}
+
+	return nil
+}
+
+// Query retrieves a page of users matching the filter from the store. It
+// also returns the total number of users matching the filter.
+func (s *Store) Query(ctx context.Context, filter dto.UserFilter, orderBy order.By, offset int, limit int) ([]dto.User, int, error) {
+	users := s.match(filter)
+	sortUsers(users, orderBy.Field, orderBy.Direction)
+
+	total := len(users)
+	if offset > total {
+		offset = total
+	}
+	end := offset + limit
+	if end > total {
+		end = total
+	}
+
+	return users[offset:end], total, nil
+}
+
+// QueryAfter retrieves the users following the key in the ordering. When
+// backward is set the users preceding the key are retrieved instead, they
+// are still returned in the order asked for. A nil key starts at the
+// beginning of the ordering. It reports whether there are more users beyond
+// the returned ones.
+func (s *Store) QueryAfter(ctx context.Context, filter dto.UserFilter, orderBy order.By, key *order.Key, backward bool, limit int) ([]dto.User, bool, error) {
+	dir := orderBy.Direction
+	if backward {
+		dir = order.Reverse(dir)
+	}
+
+	users := s.match(filter)
+	sortUsers(users, orderBy.Field, dir)
+
+	if key != nil {
+		kv, err := parseValue(orderBy.Field, key.Value)
+		if err != nil {
+			return nil, false, fmt.Errorf("selecting users: %w", err)
+		}
+
+		start := len(users)
+		for i, usr := range users {
+			c := compare(fieldValue(usr, orderBy.Field), kv)
+			if c == 0 {
+				c = strings.Compare(usr.ID, key.ID)
+			}
+			if (dir == order.ASC && c > 0) || (dir == order.DESC && c < 0) {
+				start = i
+				break
+			}
+		}
+		users = users[start:]
+	}
+
+	more := len(users) > limit
+	if more {
+		users = users[:limit]
+	}
+
+	if backward {
+		for i, j := 0, len(users)-1; i < j; i, j = i+1, j-1 {
+			users[i], users[j] = users[j], users[i]
+		}
+	}
+
+	return users, more, nil
+}
+
+// FindByID gets the specified user from the store.
+func (s *Store) FindByID(ctx context.Context, userID string) (dto.User, error) {
+	if err := validate.CheckID(userID); err != nil {
+		return dto.User{}, database.ErrInvalidID
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	usr, exists := s.users[userID]
+	if !exists {
+		return dto.User{}, database.ErrNotFound
+	}
+
+	return clone(usr), nil
+}
+
+// FindByEmail gets the specified user from the store by email.
+func (s *Store) FindByEmail(ctx context.Context, email string) (dto.User, error) {
+	return s.byEmail(email)
+}
+
+// Authenticate finds a user by their email and verifies their password. On
+// success, it returns a Claims User representing this user.
+func (s *Store) Authenticate(ctx context.Context, now time.Time, email, password string) (auth.Claims, error) {
+	usr, err := s.byEmail(email)
+	if err != nil {
+
+		// Take as long as for a wrong password, so unknown emails can't be
+		// told apart by the response time.
+		passwd.Compare(s.dummy, password)
+		return auth.Claims{}, err
+	}
+
+	if err := passwd.Compare(usr.PasswordHash, password); err != nil {
+		return auth.Claims{}, database.ErrAuthenticationFailure
+	}
+
+	// Upgrade a hash made by an outdated algorithm or with weaker
+	// parameters, unless the password was changed in the meantime.
+	if s.hasher.NeedsRehash(usr.PasswordHash) {
+		s.rehash(usr.ID, usr.PasswordHash, password)
+	}
+
+	issued := time.Now().UTC()
+	claims := auth.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    "service project",
+			Subject:   usr.ID,
+			ExpiresAt: jwt.NewNumericDate(issued.Add(8760 * time.Hour)),
+			IssuedAt:  jwt.NewNumericDate(issued),
+		},
+		IssuedAtMicro: issued.UnixMicro(),
+		Roles:         usr.Roles,
+	}
+
+	return claims, nil
+}
+
+// =============================================================================
+
+// rehash replaces the hash of the password of the user. It leaves the hash
+// alone if the password was changed in the meantime.
+func (s *Store) rehash(userID string, oldHash []byte, password string) {
+	hash, err := s.hasher.Hash(password)
+	if err != nil {
+		s.log.Errorw("rehashing password", "userID", userID, "ERROR", err)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	usr, exists := s.users[userID]
+	if !exists || !bytes.Equal(usr.PasswordHash, oldHash) {
+		return
+	}
+	usr.PasswordHash = hash
+	s.users[userID] = usr
+}
+
+// byEmail looks up a user by email ignoring the case like the database.
+func (s *Store) byEmail(email string) (dto.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, usr := range s.users {
+		if strings.EqualFold(usr.Email, email) {
+			return clone(usr), nil
+		}
+	}
+
+	return dto.User{}, database.ErrNotFound
+}
+
+// checkEmail fails if another user already uses the email. It mirrors the
+// unique index on the lowercased email of the users table. The caller must
+// hold the lock.
+func (s *Store) checkEmail(userID string, email string) error {
+	for _, usr := range s.users {
+		if usr.ID != userID && strings.EqualFold(usr.Email, email) {
+			return &database.ConstraintError{
+				Err:        database.ErrDuplicate,
+				Field:      "email",
+				Constraint: "users_email_key",
+			}
+		}
+	}
+	return nil
+}
+
+// match returns copies of the users matching the filter.
+func (s *Store) match(filter dto.UserFilter) []dto.User {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	contains := func(s string, sub string) bool {
+		return strings.Contains(strings.ToLower(s), strings.ToLower(sub))
+	}
+
+	var users []dto.User
+	for _, usr := range s.users {
+		if filter.Name != nil && !contains(usr.Name, *filter.Name) {
+			continue
+		}
+		if filter.Email != nil && !contains(usr.Email, *filter.Email) {
+			continue
+		}
+		if filter.Role != nil && !hasRole(usr.Roles, *filter.Role) {
+			continue
+		}
+		if filter.StartCreatedDate != nil && usr.DateCreated.Before(*filter.StartCreatedDate) {
+			continue
+		}
+		if filter.EndCreatedDate != nil && usr.DateCreated.After(*filter.EndCreatedDate) {
+			continue
+		}
+		if filter.Status != nil && usr.Status != *filter.Status {
+			continue
+		}
+		users = append(users, clone(usr))
+	}
+
+	return users
+}
+
+// sortUsers orders the users by the column and breaks ties with the id in
+// the same direction.
+func sortUsers(users []dto.User, field string, dir string) {
+	sort.Slice(users, func(i, j int) bool {
+		c := compare(fieldValue(users[i], field), fieldValue(users[j], field))
+		if c == 0 {
+			c = strings.Compare(users[i].ID, users[j].ID)
+		}
+		if dir == order.DESC {
+			return c > 0
+		}
+		return c < 0
+	})
+}
+
+// fieldValue returns the value of the user for the ordering column.
+func fieldValue(usr dto.User, field string) interface{} {
+	switch field {
+	case "name":
+		return usr.Name
+	case "email":
+		return usr.Email
+	case "date_created":
+		return usr.DateCreated
+	case "date_updated":
+		return usr.DateUpdated
+	default:
+		return usr.ID
+	}
+}
+
+// parseValue converts the value of a cursor key for the ordering column.
+func parseValue(field string, value string) (interface{}, error) {
+	switch field {
+	case "date_created", "date_updated":
+		t, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return nil, fmt.Errorf("parsing key %q: %w", value, err)
+		}
+		return t, nil
+	default:
+		return value, nil
+	}
+}
+
+// compare returns -1, 0 or 1 depending on the order of the values, which
+// are either both strings or both times.
+func compare(a interface{}, b interface{}) int {
+	if at, ok := a.(time.Time); ok {
+		bt := b.(time.Time)
+		switch {
+		case at.Before(bt):
+			return -1
+		case at.After(bt):
+			return 1
+		}
+		return 0
+	}
+
+	return strings.Compare(a.(string), b.(string))
+}
+
+// hasRole reports whether the role is in the list.
+func hasRole(roles []string, role string) bool {
+	for _, r := range roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// clone copies the user so callers can't modify the stored slices.
+func clone(usr dto.User) dto.User {
+	usr.Roles = append([]string(nil), usr.Roles...)
+	usr.PasswordHash = append([]byte(nil), usr.PasswordHash...)
+	if usr.DateVerified != nil {
+		verified := *usr.DateVerified
+		usr.DateVerified = &verified
+	}
+	if usr.DateTokensRevoked != nil {
+		revoked := *usr.DateTokensRevoked
+		usr.DateTokensRevoked = &revoked
+	}
+	if usr.DateStatusChanged != nil {
+		changed := *usr.DateStatusChanged
+		usr.DateStatusChanged = &changed
+	}
+	return usr
+}
@@ -0,0 +1,220 @@
+// Package webhook contains webhook related CRUD functionality and the log of
+// their deliveries.
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"github.com/AgeroFlynn/crud/internal/buisness/core/dto"
+	"github.com/AgeroFlynn/crud/internal/buisness/repository/entity"
+	"github.com/AgeroFlynn/crud/internal/buisness/sys/validate"
+	"github.com/AgeroFlynn/crud/internal/foundation/database"
+	"github.com/go-pg/pg/v10"
+	"go.uber.org/zap"
+	"time"
+)
+
+// Store manages the set of API's for webhook access.
+type Store struct {
+	log *zap.SugaredLogger
+	db  *pg.DB
+}
+
+// NewStore constructs a webhook store for api access.
+func NewStore(log *zap.SugaredLogger, db *pg.DB) Store {
+	return Store{
+		log: log,
+		db:  db,
+	}
+}
+
+// Create inserts a new webhook into the database.
+func (s Store) Create(ctx context.Context, webhook dto.Webhook) (dto.Webhook, error) {
+	w := entity.FromDTOWebhook(&webhook)
+	w.ID = validate.GenerateID()
+
+	if _, err := database.Conn(ctx, s.db).ModelContext(ctx, w).Insert(); err != nil {
+		return dto.Webhook{}, fmt.Errorf("inserting webhook: %w", database.MapError(err))
+	}
+
+	return *w.ToDTOWebhook(), nil
+}
+
+// Update replaces a webhook document in the database.
+func (s Store) Update(ctx context.Context, webhook dto.Webhook) error {
+	res, err := database.Conn(ctx, s.db).ModelContext(ctx, entity.FromDTOWebhook(&webhook)).WherePK().Update()
+	if err != nil {
+		return fmt.Errorf("updating webhookID[%s]: %w", webhook.ID, database.MapError(err))
+	}
+	if res.RowsAffected() == 0 {
+		return database.ErrNotFound
+	}
+
+	return nil
+}
+
+// Delete removes a webhook from the database, its deliveries go with it.
+func (s Store) Delete(ctx context.Context, webhookID string) error {
+	res, err := database.Conn(ctx, s.db).ModelContext(ctx, (*entity.Webhook)(nil)).Where("webhook_id = ?", webhookID).Delete()
+	if err != nil {
+		return fmt.Errorf("deleting webhookID[%s]: %w", webhookID, err)
+	}
+	if res.RowsAffected() == 0 {
+		return database.ErrNotFound
+	}
+
+	return nil
+}
+
+// Query retrieves all webhooks, oldest first.
+func (s Store) Query(ctx context.Context) ([]dto.Webhook, error) {
+	var webhooks []entity.Webhook
+	if err := database.Conn(ctx, s.db).ModelContext(ctx, &webhooks).Order("date_created", "webhook_id").Select(); err != nil {
+		return nil, fmt.Errorf("selecting webhooks: %w", err)
+	}
+
+	return *entity.ToDTOWebhookSlice(&webhooks), nil
+}
+
+// QueryByID gets the specified webhook from the database.
+func (s Store) QueryByID(ctx context.Context, webhookID string) (dto.Webhook, error) {
+	var w entity.Webhook
+	if err := database.Conn(ctx, s.db).ModelContext(ctx, &w).Where("webhook_id = ?", webhookID).Select(); err != nil {
+		if err == pg.ErrNoRows {
+			return dto.Webhook{}, database.ErrNotFound
+		}
+		return dto.Webhook{}, fmt.Errorf("selecting webhookID[%s]: %w", webhookID, err)
+	}
+
+	return *w.ToDTOWebhook(), nil
+}
+
+// QuerySubscribed retrieves the active webhooks subscribed to the event type.
+func (s Store) QuerySubscribed(ctx context.Context, eventType string) ([]dto.Webhook, error) {
+	var webhooks []entity.Webhook
+	err := database.Conn(ctx, s.db).ModelContext(ctx, &webhooks).
+		Where("active").
+		Where("(? = ANY(events) OR ? = ANY(events))", eventType, dto.WebhookAllEvents).
+		Order("date_created", "webhook_id").
+		Select()
+	if err != nil {
+		return nil, fmt.Errorf("selecting webhooks subscribed to %s: %w", eventType, err)
+	}
+
+	return *entity.ToDTOWebhookSlice(&webhooks), nil
+}
+
+// AddDeliveries queues the deliveries. An event is queued only once per
+// webhook, deliveries of events that are queued already are skipped.
+func (s Store) AddDeliveries(ctx context.Context, deliveries ...dto.Delivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+
+	ds := make([]entity.Delivery, len(deliveries))
+	for i := range deliveries {
+		ds[i] = *entity.FromDTODelivery(&deliveries[i])
+		ds[i].ID = validate.GenerateID()
+	}
+
+	if _, err := database.Conn(ctx, s.db).ModelContext(ctx, &ds).OnConflict("(webhook_id, event_id) DO NOTHING").Insert(); err != nil {
+		return fmt.Errorf("inserting deliveries: %w", database.MapError(err))
+	}
+
+	return nil
+}
+
+// ClaimDeliveries retrieves up to limit pending deliveries which are due,
+// oldest first, and hides them from other claims for the lease. Deliveries
+// that aren't updated before the lease expires are claimed again.
+func (s Store) ClaimDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]dto.Delivery, error) {
+	var deliveries []entity.Delivery
+
+	err := database.WithinTran(ctx, s.log, s.db, func(ctx context.Context) error {
+		err := database.Conn(ctx, s.db).ModelContext(ctx, &deliveries).
+			Where("status = ?", dto.DeliveryPending).
+			Where("date_next_attempt <= ?", now).
+			Order("date_created ASC").
+			Limit(limit).
+			For("UPDATE SKIP LOCKED").
+			Select()
+		if err != nil {
+			return fmt.Errorf("selecting deliveries: %w", err)
+		}
+		if len(deliveries) == 0 {
+			return nil
+		}
+
+		ids := make([]string, len(deliveries))
+		for i, d := range deliveries {
+			ids[i] = d.ID
+		}
+
+		_, err = database.Conn(ctx, s.db).ModelContext(ctx, (*entity.Delivery)(nil)).
+			Set("date_next_attempt = ?", now.Add(lease)).
+			Where("delivery_id IN (?)", pg.In(ids)).
+			Update()
+		if err != nil {
+			return fmt.Errorf("leasing deliveries: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return *entity.ToDTODeliverySlice(&deliveries), nil
+}
+
+// UpdateDelivery records the outcome of the attempts to send the delivery.
+func (s Store) UpdateDelivery(ctx context.Context, delivery dto.Delivery) error {
+	_, err := database.Conn(ctx, s.db).ModelContext(ctx, (*entity.Delivery)(nil)).
+		Set("status = ?", delivery.Status).
+		Set("attempts = ?", delivery.Attempts).
+		Set("response_code = ?", delivery.ResponseCode).
+		Set("last_error = ?", delivery.LastError).
+		Set("date_next_attempt = ?", delivery.DateNextAttempt).
+		Set("date_delivered = ?", delivery.DateDelivered).
+		Where("delivery_id = ?", delivery.ID).
+		Update()
+	if err != nil {
+		return fmt.Errorf("updating deliveryID[%s]: %w", delivery.ID, err)
+	}
+
+	return nil
+}
+
+// QueryDeliveryByID gets the specified delivery of the webhook.
+func (s Store) QueryDeliveryByID(ctx context.Context, webhookID string, deliveryID string) (dto.Delivery, error) {
+	var d entity.Delivery
+	err := database.Conn(ctx, s.db).ModelContext(ctx, &d).
+		Where("delivery_id = ?", deliveryID).
+		Where("webhook_id = ?", webhookID).
+		Select()
+	if err != nil {
+		if err == pg.ErrNoRows {
+			return dto.Delivery{}, database.ErrNotFound
+		}
+		return dto.Delivery{}, fmt.Errorf("selecting deliveryID[%s]: %w", deliveryID, err)
+	}
+
+	return *d.ToDTODelivery(), nil
+}
+
+// QueryDeliveries retrieves a page of the deliveries of the webhook, newest
+// first, together with the total number of its deliveries.
+func (s Store) QueryDeliveries(ctx context.Context, webhookID string, offset int, limit int) ([]dto.Delivery, int, error) {
+	var deliveries []entity.Delivery
+	total, err := database.Conn(ctx, s.db).ModelContext(ctx, &deliveries).
+		Where("webhook_id = ?", webhookID).
+		Order("date_created DESC", "delivery_id DESC").
+		Offset(offset).
+		Limit(limit).
+		SelectAndCount()
+	if err != nil {
+		return nil, 0, fmt.Errorf("selecting deliveries of webhookID[%s]: %w", webhookID, err)
+	}
+
+	return *entity.ToDTODeliverySlice(&deliveries), total, nil
+}
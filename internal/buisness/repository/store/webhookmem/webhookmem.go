@@ -0,0 +1,229 @@
+// Package webhookmem contains an in-memory implementation of the webhook
+// store. It follows the semantics of the database store and is meant for
+// tests that don't need a real database.
+package webhookmem
+
+import (
+	"context"
+	"github.com/AgeroFlynn/crud/internal/buisness/core/dto"
+	"github.com/AgeroFlynn/crud/internal/buisness/sys/validate"
+	"github.com/AgeroFlynn/crud/internal/foundation/database"
+	"go.uber.org/zap"
+	"sync"
+	"time"
+)
+
+// Store manages the set of API's for webhook access held in memory.
+type Store struct {
+	log *zap.SugaredLogger
+
+	mu         sync.Mutex
+	webhooks   []dto.Webhook
+	deliveries []dto.Delivery
+}
+
+// NewStore constructs an empty in-memory webhook store.
+func NewStore(log *zap.SugaredLogger) *Store {
+	return &Store{
+		log: log,
+	}
+}
+
+// Create inserts a new webhook.
+func (s *Store) Create(ctx context.Context, webhook dto.Webhook) (dto.Webhook, error) {
+	webhook.ID = validate.GenerateID()
+	webhook.Events = append([]string(nil), webhook.Events...)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.webhooks = append(s.webhooks, webhook)
+
+	return webhook, nil
+}
+
+// Update replaces a webhook.
+func (s *Store) Update(ctx context.Context, webhook dto.Webhook) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.webhooks {
+		if s.webhooks[i].ID == webhook.ID {
+			webhook.Events = append([]string(nil), webhook.Events...)
+			s.webhooks[i] = webhook
+			return nil
+		}
+	}
+
+	return database.ErrNotFound
+}
+
+// Delete removes a webhook, its deliveries go with it.
+func (s *Store) Delete(ctx context.Context, webhookID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.webhooks {
+		if s.webhooks[i].ID != webhookID {
+			continue
+		}
+		s.webhooks = append(s.webhooks[:i], s.webhooks[i+1:]...)
+
+		deliveries := s.deliveries[:0]
+		for _, d := range s.deliveries {
+			if d.WebhookID != webhookID {
+				deliveries = append(deliveries, d)
+			}
+		}
+		s.deliveries = deliveries
+
+		return nil
+	}
+
+	return database.ErrNotFound
+}
+
+// Query retrieves all webhooks, oldest first.
+func (s *Store) Query(ctx context.Context) ([]dto.Webhook, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]dto.Webhook(nil), s.webhooks...), nil
+}
+
+// QueryByID gets the specified webhook.
+func (s *Store) QueryByID(ctx context.Context, webhookID string) (dto.Webhook, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, w := range s.webhooks {
+		if w.ID == webhookID {
+			return w, nil
+		}
+	}
+
+	return dto.Webhook{}, database.ErrNotFound
+}
+
+// QuerySubscribed retrieves the active webhooks subscribed to the event type.
+func (s *Store) QuerySubscribed(ctx context.Context, eventType string) ([]dto.Webhook, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var webhooks []dto.Webhook
+	for _, w := range s.webhooks {
+		if !w.Active {
+			continue
+		}
+		for _, e := range w.Events {
+			if e == eventType || e == dto.WebhookAllEvents {
+				webhooks = append(webhooks, w)
+				break
+			}
+		}
+	}
+
+	return webhooks, nil
+}
+
+// AddDeliveries queues the deliveries. An event is queued only once per
+// webhook, deliveries of events that are queued already are skipped.
+func (s *Store) AddDeliveries(ctx context.Context, deliveries ...dto.Delivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+next:
+	for _, d := range deliveries {
+		for _, queued := range s.deliveries {
+			if queued.WebhookID == d.WebhookID && queued.EventID == d.EventID {
+				continue next
+			}
+		}
+		d.ID = validate.GenerateID()
+		s.deliveries = append(s.deliveries, d)
+	}
+
+	return nil
+}
+
+// ClaimDeliveries retrieves up to limit pending deliveries which are due,
+// oldest first, and hides them from other claims for the lease.
+func (s *Store) ClaimDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]dto.Delivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var deliveries []dto.Delivery
+	for i := range s.deliveries {
+		d := &s.deliveries[i]
+		if d.Status != dto.DeliveryPending || d.DateNextAttempt.After(now) {
+			continue
+		}
+		if len(deliveries) == limit {
+			break
+		}
+		d.DateNextAttempt = now.Add(lease)
+		deliveries = append(deliveries, *d)
+	}
+
+	return deliveries, nil
+}
+
+// UpdateDelivery records the outcome of the attempts to send the delivery.
+func (s *Store) UpdateDelivery(ctx context.Context, delivery dto.Delivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.deliveries {
+		d := &s.deliveries[i]
+		if d.ID == delivery.ID {
+			d.Status = delivery.Status
+			d.Attempts = delivery.Attempts
+			d.ResponseCode = delivery.ResponseCode
+			d.LastError = delivery.LastError
+			d.DateNextAttempt = delivery.DateNextAttempt
+			d.DateDelivered = delivery.DateDelivered
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// QueryDeliveryByID gets the specified delivery of the webhook.
+func (s *Store) QueryDeliveryByID(ctx context.Context, webhookID string, deliveryID string) (dto.Delivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, d := range s.deliveries {
+		if d.ID == deliveryID && d.WebhookID == webhookID {
+			return d, nil
+		}
+	}
+
+	return dto.Delivery{}, database.ErrNotFound
+}
+
+// QueryDeliveries retrieves a page of the deliveries of the webhook, newest
+// first, together with the total number of its deliveries.
+func (s *Store) QueryDeliveries(ctx context.Context, webhookID string, offset int, limit int) ([]dto.Delivery, int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var deliveries []dto.Delivery
+	for i := len(s.deliveries) - 1; i >= 0; i-- {
+		if s.deliveries[i].WebhookID == webhookID {
+			deliveries = append(deliveries, s.deliveries[i])
+		}
+	}
+
+	total := len(deliveries)
+	if offset > total {
+		offset = total
+	}
+	end := offset + limit
+	if end > total {
+		end = total
+	}
+
+	return deliveries[offset:end], total, nil
+}
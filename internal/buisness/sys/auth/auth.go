@@ -2,6 +2,7 @@
 package auth
 
 import (
+	"context"
 	"crypto/rsa"
 	"errors"
 	"fmt"
@@ -15,6 +16,18 @@ type KeyLookup interface {
 	PublicKey(kid string) (*rsa.PublicKey, error)
 }
 
+// Revoker declares the behavior for checking if the tokens of a user were
+// revoked after the claims were issued.
+type Revoker interface {
+	Revoked(ctx context.Context, claims Claims) (bool, error)
+}
+
+// Resolver declares the behavior for resolving the permissions granted by
+// roles.
+type Resolver interface {
+	Resolve(ctx context.Context, roles []string) ([]string, error)
+}
+
 // Auth is used to authenticate clients. It can generate a token for a
 // set of user claims and recreate the claims by parsing the token.
 type Auth struct {
@@ -84,3 +97,55 @@ func (a *Auth) GenerateToken(claims Claims) (string, error) {
 
 	return str, nil
 }
+
+// GenerateScopedToken generates a signed JWT token string that is only valid
+// for the specified audience. Scoped tokens are used for things like share
+// links and can never be used to authenticate against the API.
+func (a *Auth) GenerateScopedToken(audience string, claims jwt.RegisteredClaims) (string, error) {
+	claims.Audience = jwt.ClaimStrings{audience}
+
+	token := jwt.NewWithClaims(a.method, claims)
+	token.Header["kid"] = a.activeKID
+
+	privateKey, err := a.keyLookup.PrivateKey(a.activeKID)
+	if err != nil {
+		return "", errors.New("kid lookup failed")
+	}
+
+	str, err := token.SignedString(privateKey)
+	if err != nil {
+		return "", fmt.Errorf("signing token: %w", err)
+	}
+
+	return str, nil
+}
+
+// ValidateScopedToken recreates the claims of a scoped token. It verifies the
+// token was signed using our key and was issued for the specified audience.
+// A token which is only expired fails with an error matching
+// jwt.ErrTokenExpired, its claims are returned with it so the caller can tell
+// which token it was.
+func (a *Auth) ValidateScopedToken(audience string, tokenStr string) (jwt.RegisteredClaims, error) {
+	var claims jwt.RegisteredClaims
+	token, err := a.parser.ParseWithClaims(tokenStr, &claims, a.keyFunc)
+	if err != nil {
+
+		// The signature is checked even when the claims aren't valid, an
+		// expiry as the only error means the token is genuine.
+		var verr *jwt.ValidationError
+		if errors.As(err, &verr) && verr.Errors == jwt.ValidationErrorExpired && claims.VerifyAudience(audience, true) {
+			return claims, fmt.Errorf("parsing token: %w", err)
+		}
+		return jwt.RegisteredClaims{}, fmt.Errorf("parsing token: %w", err)
+	}
+
+	if !token.Valid {
+		return jwt.RegisteredClaims{}, errors.New("invalid token")
+	}
+
+	if !claims.VerifyAudience(audience, true) {
+		return jwt.RegisteredClaims{}, fmt.Errorf("token is not valid for audience %q", audience)
+	}
+
+	return claims, nil
+}
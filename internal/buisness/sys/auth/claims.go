@@ -5,18 +5,50 @@ import (
 	"errors"
 	"fmt"
 	"github.com/golang-jwt/jwt/v4"
+	"time"
 )
 
-// These are the expected values for Claims.Roles.
+// These are the built-in roles, more roles can be managed through the API.
 const (
 	RoleAdmin = "ADMIN"
 	RoleUser  = "USER"
 )
 
+// These are the permissions routes and stores check for. Roles grant them,
+// they are resolved into Claims.Permissions when a token is issued and again
+// for every request.
+const (
+	PermUsersRead       = "users:read"
+	PermUsersWrite      = "users:write"
+	PermDirectoryExport = "directory:export"
+	PermRolesRead       = "roles:read"
+	PermRolesWrite      = "roles:write"
+	PermRolesAssign     = "roles:assign"
+	PermAuditRead       = "audit:read"
+	PermWebhooksManage  = "webhooks:manage"
+)
+
 // Claims represents the authorization claims transmitted via a JWT.
+// IssuedAtMicro is the issue time in microseconds since the epoch, IssuedAt
+// only has whole seconds and can't tell a token issued right after the
+// tokens of a user were revoked from one issued right before.
 type Claims struct {
 	jwt.RegisteredClaims
-	Roles []string `json:"roles"`
+	IssuedAtMicro int64    `json:"iat_us,omitempty"`
+	Roles         []string `json:"roles"`
+	Permissions   []string `json:"permissions,omitempty"`
+}
+
+// Issued returns when the token was issued, to the microsecond when the
+// claims carry it. It returns false when the issue time is unknown.
+func (c Claims) Issued() (time.Time, bool) {
+	if c.IssuedAtMicro != 0 {
+		return time.UnixMicro(c.IssuedAtMicro), true
+	}
+	if c.IssuedAt != nil {
+		return c.IssuedAt.Time, true
+	}
+	return time.Time{}, false
 }
 
 // Authorized returns true if the claims has at least one of the provided roles.
@@ -31,6 +63,38 @@ func (c Claims) Authorized(roles ...string) bool {
 	return false
 }
 
+// SameRoles reports whether both lists hold the same roles, in any order.
+func SameRoles(a []string, b []string) bool {
+	in := func(roles []string) map[string]bool {
+		set := make(map[string]bool, len(roles))
+		for _, role := range roles {
+			set[role] = true
+		}
+		return set
+	}
+
+	setA, setB := in(a), in(b)
+	if len(setA) != len(setB) {
+		return false
+	}
+	for role := range setA {
+		if !setB[role] {
+			return false
+		}
+	}
+	return true
+}
+
+// HasPermission returns true if the claims grant the permission.
+func (c Claims) HasPermission(permission string) bool {
+	for _, has := range c.Permissions {
+		if has == permission {
+			return true
+		}
+	}
+	return false
+}
+
 // ctxKey represents the type of value for the context key.
 type ctxKey int
 
@@ -64,5 +128,11 @@ func (a *Auth) ValidateToken(tokenStr string) (Claims, error) {
 		return Claims{}, errors.New("invalid token")
 	}
 
+	// Scoped tokens always carry an audience and must never be accepted as
+	// user tokens.
+	if len(claims.Audience) != 0 {
+		return Claims{}, errors.New("invalid token, scoped tokens can't be used for authentication")
+	}
+
 	return claims, nil
 }
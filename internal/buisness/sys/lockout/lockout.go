@@ -0,0 +1,130 @@
+// Package lockout provides support for locking out clients after repeated
+// failures, like failed logins.
+package lockout
+
+import (
+	"sync"
+	"time"
+)
+
+// Policy holds the rules for locking out a key.
+type Policy struct {
+
+	// Threshold is the number of failures in a row that locks the key out.
+	Threshold int
+
+	// Base is how long the key is locked out when it reaches the threshold.
+	// Every further failure doubles the lockout up to Max.
+	Base time.Duration
+	Max  time.Duration
+
+	// Reset is how long after the last failure the failures are forgotten.
+	Reset time.Duration
+}
+
+// entry holds the failures of a key.
+type entry struct {
+	failures    int
+	lastFailure time.Time
+	lockedUntil time.Time
+}
+
+// Counter counts the failures of keys and locks them out according to the
+// policy. The counts are kept in memory, every instance of the service
+// counts on its own.
+type Counter struct {
+	policy Policy
+
+	mu        sync.Mutex
+	entries   map[string]entry
+	lastSweep time.Time
+}
+
+// NewCounter constructs a counter enforcing the policy.
+func NewCounter(policy Policy) *Counter {
+	return &Counter{
+		policy:  policy,
+		entries: make(map[string]entry),
+	}
+}
+
+// Locked reports until when the key is locked out. The returned time is
+// only meaningful when the key is locked.
+func (c *Counter) Locked(key string, now time.Time) (time.Time, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, exists := c.entries[key]
+	if !exists || !now.Before(e.lockedUntil) {
+		return time.Time{}, false
+	}
+
+	return e.lockedUntil, true
+}
+
+// Fail records a failure of the key. It returns until when the key is
+// locked out when the failure reached the threshold.
+func (c *Counter) Fail(key string, now time.Time) (time.Time, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.sweep(now)
+
+	e := c.entries[key]
+	if c.expired(e, now) {
+		e = entry{}
+	}
+
+	e.failures++
+	e.lastFailure = now
+
+	locked := c.policy.Threshold > 0 && e.failures >= c.policy.Threshold
+	if locked {
+		e.lockedUntil = now.Add(c.lockout(e.failures - c.policy.Threshold))
+	}
+	c.entries[key] = e
+
+	return e.lockedUntil, locked
+}
+
+// Clear forgets the failures of the key and lifts its lockout.
+func (c *Counter) Clear(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.entries, key)
+}
+
+// lockout computes the lockout after the number of failures beyond the
+// threshold.
+func (c *Counter) lockout(beyond int) time.Duration {
+	d := c.policy.Base
+	for i := 0; i < beyond; i++ {
+		d *= 2
+		if c.policy.Max > 0 && d >= c.policy.Max {
+			return c.policy.Max
+		}
+	}
+
+	return d
+}
+
+// expired reports whether the failures of the entry can be forgotten.
+func (c *Counter) expired(e entry, now time.Time) bool {
+	return now.After(e.lockedUntil) && now.Sub(e.lastFailure) > c.policy.Reset
+}
+
+// sweep removes the expired entries once per reset period so keys that are
+// never seen again don't pile up. The caller must hold the lock.
+func (c *Counter) sweep(now time.Time) {
+	if now.Sub(c.lastSweep) < c.policy.Reset {
+		return
+	}
+	c.lastSweep = now
+
+	for key, e := range c.entries {
+		if c.expired(e, now) {
+			delete(c.entries, key)
+		}
+	}
+}
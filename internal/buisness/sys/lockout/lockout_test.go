@@ -0,0 +1,120 @@
+package lockout_test
+
+import (
+	"github.com/AgeroFlynn/crud/internal/buisness/sys/lockout"
+	"github.com/AgeroFlynn/crud/internal/buisness/sys/tests"
+	"testing"
+	"time"
+)
+
+func TestCounter(t *testing.T) {
+	policy := lockout.Policy{Threshold: 3, Base: time.Minute, Max: 4 * time.Minute, Reset: 15 * time.Minute}
+	now := time.Date(2026, time.January, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Log("Given the need to lock out keys after repeated failures.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen failing below the threshold.", testID)
+		{
+			c := lockout.NewCounter(policy)
+
+			for i := 0; i < policy.Threshold-1; i++ {
+				if _, locked := c.Fail("bill", now); locked {
+					t.Fatalf("\t%s\tTest %d:\tShould NOT lock the key out after %d failures.", tests.Failed, testID, i+1)
+				}
+			}
+			if _, locked := c.Locked("bill", now); locked {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT report the key as locked.", tests.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT lock the key out.", tests.Success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen failing up to the threshold.", testID)
+		{
+			c := lockout.NewCounter(policy)
+
+			for i := 0; i < policy.Threshold-1; i++ {
+				c.Fail("bill", now)
+			}
+			until, locked := c.Fail("bill", now)
+			if !locked || !until.Equal(now.Add(policy.Base)) {
+				t.Fatalf("\t%s\tTest %d:\tShould lock the key out for the base duration : got %v, %v.", tests.Failed, testID, until, locked)
+			}
+			t.Logf("\t%s\tTest %d:\tShould lock the key out for the base duration.", tests.Success, testID)
+
+			if got, locked := c.Locked("bill", now.Add(time.Second)); !locked || !got.Equal(until) {
+				t.Fatalf("\t%s\tTest %d:\tShould report the key as locked : got %v, %v.", tests.Failed, testID, got, locked)
+			}
+			if _, locked := c.Locked("bill", until); locked {
+				t.Fatalf("\t%s\tTest %d:\tShould lift the lockout when it ends.", tests.Failed, testID)
+			}
+			if _, locked := c.Locked("jill", now); locked {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT lock out other keys.", tests.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould only lock the key out until the lockout ends.", tests.Success, testID)
+		}
+
+		testID = 2
+		t.Logf("\tTest %d:\tWhen failing beyond the threshold.", testID)
+		{
+			c := lockout.NewCounter(policy)
+
+			for i := 0; i < policy.Threshold-1; i++ {
+				c.Fail("bill", now)
+			}
+
+			// Every further failure doubles the lockout up to the maximum.
+			for _, exp := range []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute, 4 * time.Minute} {
+				until, locked := c.Fail("bill", now)
+				if !locked || until.Sub(now) != exp {
+					t.Fatalf("\t%s\tTest %d:\tShould back off up to the maximum : got %v, exp %v.", tests.Failed, testID, until.Sub(now), exp)
+				}
+			}
+			t.Logf("\t%s\tTest %d:\tShould back off up to the maximum.", tests.Success, testID)
+		}
+
+		testID = 3
+		t.Logf("\tTest %d:\tWhen failing again after the reset period.", testID)
+		{
+			c := lockout.NewCounter(policy)
+
+			for i := 0; i < policy.Threshold-1; i++ {
+				c.Fail("bill", now)
+			}
+
+			later := now.Add(policy.Reset + time.Second)
+			if _, locked := c.Fail("bill", later); locked {
+				t.Fatalf("\t%s\tTest %d:\tShould forget the earlier failures.", tests.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould forget the earlier failures.", tests.Success, testID)
+
+			if _, locked := c.Fail("bill", later); locked {
+				t.Fatalf("\t%s\tTest %d:\tShould count the failures from the start.", tests.Failed, testID)
+			}
+			if _, locked := c.Fail("bill", later); !locked {
+				t.Fatalf("\t%s\tTest %d:\tShould lock the key out at the threshold again.", tests.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould count the failures from the start.", tests.Success, testID)
+		}
+
+		testID = 4
+		t.Logf("\tTest %d:\tWhen clearing a locked out key.", testID)
+		{
+			c := lockout.NewCounter(policy)
+
+			for i := 0; i < policy.Threshold; i++ {
+				c.Fail("bill", now)
+			}
+			c.Clear("bill")
+
+			if _, locked := c.Locked("bill", now); locked {
+				t.Fatalf("\t%s\tTest %d:\tShould lift the lockout.", tests.Failed, testID)
+			}
+			if _, locked := c.Fail("bill", now); locked {
+				t.Fatalf("\t%s\tTest %d:\tShould forget the failures.", tests.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould lift the lockout and forget the failures.", tests.Success, testID)
+		}
+	}
+}
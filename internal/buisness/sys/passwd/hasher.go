@@ -0,0 +1,267 @@
+package passwd
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+	"strings"
+)
+
+// Set of error variables for comparing passwords with hashes.
+var (
+	ErrMismatch      = errors.New("password does not match the hash")
+	ErrUnknownHash   = errors.New("hash algorithm is not supported")
+	ErrMalformedHash = errors.New("hash is malformed")
+)
+
+// Hasher declares the behavior for hashing passwords. Hashes of every
+// supported algorithm are compared with Compare, whichever hasher made them.
+type Hasher interface {
+
+	// Hash hashes the password with a random salt.
+	Hash(password string) ([]byte, error)
+
+	// NeedsRehash reports whether the hash was made by another algorithm or
+	// with other parameters than the ones of the hasher.
+	NeedsRehash(hash []byte) bool
+}
+
+// NewHasher constructs the hasher for the algorithm, argon2id or bcrypt.
+func NewHasher(algorithm string, params Argon2Params, bcryptCost int) (Hasher, error) {
+	switch algorithm {
+	case "argon2id":
+		return Argon2id{Params: params}, nil
+	case "bcrypt":
+		return Bcrypt{Cost: bcryptCost}, nil
+	default:
+		return nil, fmt.Errorf("unknown hash algorithm %q", algorithm)
+	}
+}
+
+// Compare checks the password against the hash. Argon2id hashes in the PHC
+// string format and bcrypt hashes are supported. ErrMismatch is returned
+// when the password is wrong.
+func Compare(hash []byte, password string) error {
+	switch {
+	case bytes.HasPrefix(hash, []byte(argon2Prefix)):
+		params, salt, key, err := decodeArgon2(hash)
+		if err != nil {
+			return err
+		}
+		got := argon2.IDKey([]byte(password), salt, params.Time, params.Memory, params.Threads, uint32(len(key)))
+		if subtle.ConstantTimeCompare(got, key) != 1 {
+			return ErrMismatch
+		}
+		return nil
+
+	case isBcrypt(hash):
+		if err := bcrypt.CompareHashAndPassword(hash, []byte(password)); err != nil {
+			if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+				return ErrMismatch
+			}
+			return fmt.Errorf("%w: %v", ErrMalformedHash, err)
+		}
+		return nil
+
+	default:
+		return ErrUnknownHash
+	}
+}
+
+// DummyHash hashes a throwaway password with the hasher. Comparing a
+// password against it takes as long as against the hash of a real user, so
+// the time taken doesn't tell whether a user exists. Argon2id with the
+// default parameters is used when the hasher is nil.
+func DummyHash(h Hasher) []byte {
+	if h == nil {
+		h = Argon2id{Params: DefaultArgon2Params}
+	}
+
+	hash, err := h.Hash("dummy password of unknown users")
+	if err != nil {
+		return nil
+	}
+	return hash
+}
+
+// =============================================================================
+
+// argon2Prefix starts the PHC strings of argon2id hashes.
+const argon2Prefix = "$argon2id$"
+
+// Argon2Params holds the cost parameters of argon2id. Memory is in KiB.
+type Argon2Params struct {
+	Time    uint32
+	Memory  uint32
+	Threads uint8
+	SaltLen uint32
+	KeyLen  uint32
+}
+
+// DefaultArgon2Params are the parameters recommended by OWASP.
+var DefaultArgon2Params = Argon2Params{
+	Time:    2,
+	Memory:  19 * 1024,
+	Threads: 1,
+	SaltLen: 16,
+	KeyLen:  32,
+}
+
+// Argon2id hashes passwords with argon2id. Parameters left at zero take
+// their value from DefaultArgon2Params.
+type Argon2id struct {
+	Params Argon2Params
+}
+
+// Hash implements the Hasher interface. The hash is a PHC string like
+// `$argon2id$v=19$m=19456,t=2,p=1$<salt>$<key>`.
+func (a Argon2id) Hash(password string) ([]byte, error) {
+	p := a.params()
+
+	salt := make([]byte, p.SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("generating salt: %w", err)
+	}
+
+	key := argon2.IDKey([]byte(password), salt, p.Time, p.Memory, p.Threads, p.KeyLen)
+
+	phc := fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2Prefix,
+		argon2.Version,
+		p.Memory, p.Time, p.Threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	)
+
+	return []byte(phc), nil
+}
+
+// NeedsRehash implements the Hasher interface.
+func (a Argon2id) NeedsRehash(hash []byte) bool {
+	if !bytes.HasPrefix(hash, []byte(argon2Prefix)) {
+		return true
+	}
+
+	params, salt, key, err := decodeArgon2(hash)
+	if err != nil {
+		return true
+	}
+
+	p := a.params()
+	return params.Time < p.Time ||
+		params.Memory < p.Memory ||
+		params.Threads < p.Threads ||
+		uint32(len(salt)) < p.SaltLen ||
+		uint32(len(key)) < p.KeyLen
+}
+
+// params returns the parameters of the hasher with the defaults filled in.
+func (a Argon2id) params() Argon2Params {
+	p := a.Params
+	if p.Time == 0 {
+		p.Time = DefaultArgon2Params.Time
+	}
+	if p.Memory == 0 {
+		p.Memory = DefaultArgon2Params.Memory
+	}
+	if p.Threads == 0 {
+		p.Threads = DefaultArgon2Params.Threads
+	}
+	if p.SaltLen == 0 {
+		p.SaltLen = DefaultArgon2Params.SaltLen
+	}
+	if p.KeyLen == 0 {
+		p.KeyLen = DefaultArgon2Params.KeyLen
+	}
+	return p
+}
+
+// decodeArgon2 reads the parameters, salt and key from an argon2id PHC
+// string.
+func decodeArgon2(hash []byte) (Argon2Params, []byte, []byte, error) {
+
+	// "", "argon2id", "v=19", "m=...,t=...,p=...", salt, key
+	parts := strings.Split(string(hash), "$")
+	if len(parts) != 6 {
+		return Argon2Params{}, nil, nil, ErrMalformedHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return Argon2Params{}, nil, nil, ErrMalformedHash
+	}
+	if version != argon2.Version {
+		return Argon2Params{}, nil, nil, fmt.Errorf("%w: argon2 version %d", ErrUnknownHash, version)
+	}
+
+	var p Argon2Params
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Time, &p.Threads); err != nil {
+		return Argon2Params{}, nil, nil, ErrMalformedHash
+	}
+	if p.Time == 0 || p.Threads == 0 {
+		return Argon2Params{}, nil, nil, ErrMalformedHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return Argon2Params{}, nil, nil, ErrMalformedHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return Argon2Params{}, nil, nil, ErrMalformedHash
+	}
+
+	p.SaltLen = uint32(len(salt))
+	p.KeyLen = uint32(len(key))
+
+	return p, salt, key, nil
+}
+
+// =============================================================================
+
+// Bcrypt hashes passwords with bcrypt.
+type Bcrypt struct {
+	Cost int
+}
+
+// Hash implements the Hasher interface.
+func (b Bcrypt) Hash(password string) ([]byte, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), b.cost())
+	if err != nil {
+		return nil, fmt.Errorf("generating password hash: %w", err)
+	}
+	return hash, nil
+}
+
+// NeedsRehash implements the Hasher interface.
+func (b Bcrypt) NeedsRehash(hash []byte) bool {
+	if !isBcrypt(hash) {
+		return true
+	}
+
+	cost, err := bcrypt.Cost(hash)
+	if err != nil {
+		return true
+	}
+	return cost < b.cost()
+}
+
+// cost returns the cost of the hasher, the bcrypt default when not set.
+func (b Bcrypt) cost() int {
+	if b.Cost == 0 {
+		return bcrypt.DefaultCost
+	}
+	return b.Cost
+}
+
+// isBcrypt reports whether the hash is in the modular crypt format of bcrypt.
+func isBcrypt(hash []byte) bool {
+	return bytes.HasPrefix(hash, []byte("$2a$")) ||
+		bytes.HasPrefix(hash, []byte("$2b$")) ||
+		bytes.HasPrefix(hash, []byte("$2y$"))
+}
@@ -0,0 +1,98 @@
+package passwd_test
+
+import (
+	"errors"
+	"github.com/AgeroFlynn/crud/internal/buisness/sys/passwd"
+	"github.com/AgeroFlynn/crud/internal/buisness/sys/tests"
+	"golang.org/x/crypto/bcrypt"
+	"strings"
+	"testing"
+)
+
+func TestHasher(t *testing.T) {
+	weak := passwd.Argon2id{Params: passwd.Argon2Params{Time: 1, Memory: 64, Threads: 1}}
+	strong := passwd.Argon2id{Params: passwd.Argon2Params{Time: 2, Memory: 128, Threads: 1}}
+	bcr := passwd.Bcrypt{Cost: bcrypt.MinCost}
+
+	t.Log("Given the need to hash passwords with several algorithms.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen hashing with argon2id.", testID)
+		{
+			hash, err := weak.Hash("gophers")
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to hash the password : %s.", tests.Failed, testID, err)
+			}
+			if !strings.HasPrefix(string(hash), "$argon2id$v=19$m=64,t=1,p=1$") {
+				t.Fatalf("\t%s\tTest %d:\tShould get a PHC string : %s.", tests.Failed, testID, hash)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get a PHC string.", tests.Success, testID)
+
+			if err := passwd.Compare(hash, "gophers"); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould match the password : %s.", tests.Failed, testID, err)
+			}
+			if err := passwd.Compare(hash, "rustaceans"); !errors.Is(err, passwd.ErrMismatch) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT match another password : %v.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould only match the password.", tests.Success, testID)
+
+			if weak.NeedsRehash(hash) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT need a rehash with the same parameters.", tests.Failed, testID)
+			}
+			if !strong.NeedsRehash(hash) {
+				t.Fatalf("\t%s\tTest %d:\tShould need a rehash with stronger parameters.", tests.Failed, testID)
+			}
+			if !bcr.NeedsRehash(hash) {
+				t.Fatalf("\t%s\tTest %d:\tShould need a rehash with another algorithm.", tests.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould need a rehash only for other parameters.", tests.Success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen hashing with bcrypt.", testID)
+		{
+			hash, err := bcr.Hash("gophers")
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to hash the password : %s.", tests.Failed, testID, err)
+			}
+
+			if err := passwd.Compare(hash, "gophers"); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould match the password : %s.", tests.Failed, testID, err)
+			}
+			if err := passwd.Compare(hash, "rustaceans"); !errors.Is(err, passwd.ErrMismatch) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT match another password : %v.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould only match the password.", tests.Success, testID)
+
+			if !weak.NeedsRehash(hash) {
+				t.Fatalf("\t%s\tTest %d:\tShould need a rehash with argon2id.", tests.Failed, testID)
+			}
+			if !(passwd.Bcrypt{Cost: bcrypt.MinCost + 1}).NeedsRehash(hash) {
+				t.Fatalf("\t%s\tTest %d:\tShould need a rehash with a higher cost.", tests.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould need a rehash for argon2id and higher costs.", tests.Success, testID)
+		}
+
+		testID = 2
+		t.Logf("\tTest %d:\tWhen comparing with an unknown hash.", testID)
+		{
+			if err := passwd.Compare([]byte("gophers"), "gophers"); !errors.Is(err, passwd.ErrUnknownHash) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT accept a plain text hash : %v.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT accept a plain text hash.", tests.Success, testID)
+		}
+
+		testID = 3
+		t.Logf("\tTest %d:\tWhen making a dummy hash without a hasher.", testID)
+		{
+			hash := passwd.DummyHash(nil)
+			if !strings.HasPrefix(string(hash), "$argon2id$") {
+				t.Fatalf("\t%s\tTest %d:\tShould get an argon2id hash : %s.", tests.Failed, testID, hash)
+			}
+			if err := passwd.Compare(hash, "gophers"); !errors.Is(err, passwd.ErrMismatch) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT match a password : %v.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get an argon2id hash no password matches.", tests.Success, testID)
+		}
+	}
+}
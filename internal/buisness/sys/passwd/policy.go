@@ -0,0 +1,129 @@
+// Package passwd provides support for the rules passwords have to follow.
+package passwd
+
+import (
+	"fmt"
+	"github.com/AgeroFlynn/crud/internal/buisness/sys/validate"
+	"strings"
+	"unicode"
+)
+
+// minPersonalLength is the length below which parts of a name or email are
+// too common to be kept out of passwords.
+const minPersonalLength = 3
+
+// BreachedList declares the behavior for looking up passwords known from
+// data breaches, like a bloom filter of a breach corpus.
+type BreachedList interface {
+	Contains(password string) bool
+}
+
+// Policy holds the rules new passwords have to follow. The zero value
+// accepts every password.
+type Policy struct {
+
+	// MinLength is the minimum number of characters.
+	MinLength int
+
+	// MinClasses is the number of character classes out of lowercase,
+	// uppercase, digits and symbols the password has to use.
+	MinClasses int
+
+	// History is the number of the most recent passwords of the user that
+	// can't be used again. It is applied by the caller, who knows them.
+	History int
+
+	// ForbidPersonal refuses passwords containing the name or the email of
+	// the user.
+	ForbidPersonal bool
+
+	// Breached refuses passwords from the list when set.
+	Breached BreachedList
+}
+
+// Check validates the password against the rules of the policy. The name and
+// email are the ones of the user the password is for. Every broken rule is
+// reported as an error of the password field.
+func (p Policy) Check(password string, name string, email string) validate.FieldErrors {
+	var fields validate.FieldErrors
+	violation := func(msg string) {
+		fields = append(fields, validate.FieldError{Field: "password", Error: msg})
+	}
+
+	if n := len([]rune(password)); n < p.MinLength {
+		violation(fmt.Sprintf("password must be at least %d characters in length", p.MinLength))
+	}
+
+	if classes(password) < p.MinClasses {
+		violation(fmt.Sprintf("password must use %d of lowercase letters, uppercase letters, digits and symbols", p.MinClasses))
+	}
+
+	if p.ForbidPersonal && personal(password, name, email) {
+		violation("password must not contain the name or email")
+	}
+
+	if p.Breached != nil && p.Breached.Contains(password) {
+		violation("password appeared in a data breach")
+	}
+
+	return fields
+}
+
+// CheckHistory validates the password isn't one of the previous passwords of
+// the user, given by their hashes from the most recent one.
+func (p Policy) CheckHistory(password string, hashes [][]byte) validate.FieldErrors {
+	for _, hash := range hashes {
+		if Compare(hash, password) == nil {
+			msg := fmt.Sprintf("password must differ from the last %d passwords", p.History)
+			return validate.FieldErrors{{Field: "password", Error: msg}}
+		}
+	}
+
+	return nil
+}
+
+// classes counts the character classes used in the password.
+func classes(password string) int {
+	var lower, upper, digit, symbol int
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = 1
+		case unicode.IsUpper(r):
+			upper = 1
+		case unicode.IsDigit(r):
+			digit = 1
+		default:
+			symbol = 1
+		}
+	}
+
+	return lower + upper + digit + symbol
+}
+
+// personal reports whether the password contains a part of the name or of
+// the local part of the email, ignoring case.
+func personal(password string, name string, email string) bool {
+	password = strings.ToLower(password)
+
+	local := email
+	if at := strings.LastIndex(email, "@"); at >= 0 {
+		local = email[:at]
+	}
+
+	split := func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}
+	parts := append(strings.FieldsFunc(name, split), strings.FieldsFunc(local, split)...)
+
+	for _, part := range parts {
+		if len([]rune(part)) < minPersonalLength {
+			continue
+		}
+		if strings.Contains(password, strings.ToLower(part)) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,112 @@
+package passwd_test
+
+import (
+	"github.com/AgeroFlynn/crud/internal/buisness/sys/passwd"
+	"github.com/AgeroFlynn/crud/internal/buisness/sys/tests"
+	"github.com/AgeroFlynn/crud/internal/buisness/sys/validate"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// breachedList is a breach corpus of a few passwords.
+type breachedList map[string]bool
+
+func (l breachedList) Contains(password string) bool {
+	return l[password]
+}
+
+func TestPolicy(t *testing.T) {
+	policy := passwd.Policy{
+		MinLength:      8,
+		MinClasses:     3,
+		History:        2,
+		ForbidPersonal: true,
+		Breached:       breachedList{"Password123": true},
+	}
+
+	violation := func(msg string) validate.FieldErrors {
+		return validate.FieldErrors{{Field: "password", Error: msg}}
+	}
+
+	table := []struct {
+		name     string
+		password string
+		exp      validate.FieldErrors
+	}{
+		{"a password following every rule", "Blue-Horse7", nil},
+		{"a short password", "Bl-7", violation("password must be at least 8 characters in length")},
+		{"a password of multibyte characters", "Grün-Äpfel7", nil},
+		{"a password with too few character classes", "bluehorses7", violation("password must use 3 of lowercase letters, uppercase letters, digits and symbols")},
+		{"a password containing the name", "Jill-Horse7", violation("password must not contain the name or email")},
+		{"a password containing the email", "Jsmith-Horse7", violation("password must not contain the name or email")},
+		{"a password containing a short part of the name", "Horse-St7-Blue", nil},
+		{"a breached password", "Password123", violation("password appeared in a data breach")},
+		{"a password breaking several rules", "jill", validate.FieldErrors{
+			{Field: "password", Error: "password must be at least 8 characters in length"},
+			{Field: "password", Error: "password must use 3 of lowercase letters, uppercase letters, digits and symbols"},
+			{Field: "password", Error: "password must not contain the name or email"},
+		}},
+	}
+
+	t.Log("Given the need to validate passwords against a password policy.")
+	{
+		for testID, tt := range table {
+			t.Logf("\tTest %d:\tWhen checking %s.", testID, tt.name)
+			{
+				got := policy.Check(tt.password, "Jill St", "jsmith@example.com")
+				if diff := cmp.Diff(got, tt.exp); diff != "" {
+					t.Fatalf("\t%s\tTest %d:\tShould get the expected violations. Diff:\n%s", tests.Failed, testID, diff)
+				}
+				t.Logf("\t%s\tTest %d:\tShould get the expected violations.", tests.Success, testID)
+			}
+		}
+
+		testID := len(table)
+		t.Logf("\tTest %d:\tWhen checking with the zero policy.", testID)
+		{
+			if got := (passwd.Policy{}).Check("a", "a", "a@example.com"); got != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould accept every password : %v.", tests.Failed, testID, got)
+			}
+			t.Logf("\t%s\tTest %d:\tShould accept every password.", tests.Success, testID)
+		}
+	}
+}
+
+func TestPolicyHistory(t *testing.T) {
+	policy := passwd.Policy{History: 2}
+	hasher := passwd.Argon2id{Params: passwd.Argon2Params{Time: 1, Memory: 64, Threads: 1}}
+
+	var history [][]byte
+	for _, password := range []string{"Blue-Horse7", "Red-Horse7"} {
+		hash, err := hasher.Hash(password)
+		if err != nil {
+			t.Fatalf("hashing password: %s", err)
+		}
+		history = append(history, hash)
+	}
+
+	table := []struct {
+		name     string
+		password string
+		exp      validate.FieldErrors
+	}{
+		{"the most recent password", "Blue-Horse7", validate.FieldErrors{{Field: "password", Error: "password must differ from the last 2 passwords"}}},
+		{"an older password", "Red-Horse7", validate.FieldErrors{{Field: "password", Error: "password must differ from the last 2 passwords"}}},
+		{"a new password", "Green-Horse7", nil},
+	}
+
+	t.Log("Given the need to keep users from reusing their passwords.")
+	{
+		for testID, tt := range table {
+			t.Logf("\tTest %d:\tWhen checking %s.", testID, tt.name)
+			{
+				got := policy.CheckHistory(tt.password, history)
+				if diff := cmp.Diff(got, tt.exp); diff != "" {
+					t.Fatalf("\t%s\tTest %d:\tShould get the expected violations. Diff:\n%s", tests.Failed, testID, diff)
+				}
+				t.Logf("\t%s\tTest %d:\tShould get the expected violations.", tests.Success, testID)
+			}
+		}
+	}
+}
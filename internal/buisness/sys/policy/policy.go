@@ -0,0 +1,312 @@
+// Package policy provides a declarative authorization engine. Policies are
+// loaded from YAML and decide whether a subject may take an action on a
+// resource. A request is denied when a deny policy matches it, allowed when
+// an allow policy matches it and denied when no policy matches.
+package policy
+
+import (
+	"bytes"
+	_ "embed"
+	"errors"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"io"
+	"os"
+)
+
+// Set of policy effects.
+const (
+	EffectAllow = "allow"
+	EffectDeny  = "deny"
+)
+
+// Set of machine-readable reasons of decisions. Deny policies can declare
+// their own reason.
+const (
+	ReasonAllowed   = "allowed"
+	ReasonDenied    = "denied_by_policy"
+	ReasonNoPolicy  = "no_matching_policy"
+	ReasonNoSubject = "no_subject"
+)
+
+// wildcard matches any action or resource type.
+const wildcard = "*"
+
+// ErrDenied is reported by a DeniedError that doesn't wrap another error.
+var ErrDenied = errors.New("denied by policy")
+
+//go:embed default.yaml
+var defaultDoc []byte
+
+// Subject is who asks to take an action.
+type Subject struct {
+	ID          string
+	Roles       []string
+	Permissions []string
+}
+
+// Resource is what the action is taken on. OwnerID is the id of the subject
+// owning the resource, for users it is the id of the user.
+type Resource struct {
+	Type    string
+	ID      string
+	OwnerID string
+}
+
+// Request is what the engine decides on. Attributes describe the action
+// further, like the status a user is moved to.
+type Request struct {
+	Subject    Subject
+	Action     string
+	Resource   Resource
+	Attributes map[string]string
+}
+
+// Decision is the outcome of evaluating a request. Policy is the name of the
+// deciding policy, it is empty when no policy matched.
+type Decision struct {
+	Allowed bool
+	Policy  string
+	Reason  string
+}
+
+// Step is how a single policy fared against a request. Mismatch names the
+// first part of the policy the request didn't match.
+type Step struct {
+	Policy   string
+	Effect   string
+	Matched  bool
+	Mismatch string
+}
+
+// Explanation is a decision together with the steps that led to it.
+type Explanation struct {
+	Decision Decision
+	Steps    []Step
+}
+
+// DeniedError is returned when a request is denied. It wraps Err, so callers
+// can report denials like their other authorization failures.
+type DeniedError struct {
+	Decision Decision
+	Err      error
+}
+
+// Error implements the error interface.
+func (e *DeniedError) Error() string {
+	if e.Decision.Policy == "" {
+		return fmt.Sprintf("%s: %s", e.Unwrap(), e.Decision.Reason)
+	}
+	return fmt.Sprintf("%s: %s[%s]", e.Unwrap(), e.Decision.Reason, e.Decision.Policy)
+}
+
+// Unwrap returns the wrapped error, ErrDenied when there is none.
+func (e *DeniedError) Unwrap() error {
+	if e.Err == nil {
+		return ErrDenied
+	}
+	return e.Err
+}
+
+// Conditions narrow down the requests a policy applies to. All conditions
+// that are set have to hold. Owner requires the subject to own the resource
+// or, when false, not to own it. Roles and Permissions require the subject
+// to have one of them and Attributes require the request to carry the same
+// values. Always has to be set on allow policies without other conditions,
+// so allowing everyone is never an accident.
+type Conditions struct {
+	Always      bool              `yaml:"always"`
+	Owner       *bool             `yaml:"owner"`
+	Roles       []string          `yaml:"roles"`
+	Permissions []string          `yaml:"permissions"`
+	Attributes  map[string]string `yaml:"attributes"`
+}
+
+// Policy allows or denies the actions on the resource types it lists, `*`
+// matches any of them.
+type Policy struct {
+	Name      string     `yaml:"name"`
+	Effect    string     `yaml:"effect"`
+	Reason    string     `yaml:"reason"`
+	Actions   []string   `yaml:"actions"`
+	Resources []string   `yaml:"resources"`
+	When      Conditions `yaml:"when"`
+}
+
+// Engine evaluates requests against a set of policies.
+type Engine struct {
+	policies []Policy
+}
+
+// New constructs an engine for the policies.
+func New(policies []Policy) (*Engine, error) {
+	names := make(map[string]bool, len(policies))
+	for i, p := range policies {
+		switch {
+		case p.Name == "":
+			return nil, fmt.Errorf("policy %d: name is required", i)
+		case names[p.Name]:
+			return nil, fmt.Errorf("policy %q: name is used twice", p.Name)
+		case p.Effect != EffectAllow && p.Effect != EffectDeny:
+			return nil, fmt.Errorf("policy %q: effect must be %s or %s", p.Name, EffectAllow, EffectDeny)
+		case len(p.Actions) == 0 || len(p.Resources) == 0:
+			return nil, fmt.Errorf("policy %q: actions and resources are required", p.Name)
+		case p.Effect == EffectAllow && p.When.empty():
+			return nil, fmt.Errorf("policy %q: allow policies need conditions, set always to allow every subject", p.Name)
+		}
+		names[p.Name] = true
+	}
+
+	return &Engine{policies: policies}, nil
+}
+
+// Parse constructs an engine for the policies of the YAML document.
+func Parse(data []byte) (*Engine, error) {
+	var doc struct {
+		Policies []Policy `yaml:"policies"`
+	}
+
+	// Misspelled keys would silently drop conditions and widen policies.
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&doc); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("unmarshal yaml: %w", err)
+	}
+
+	return New(doc.Policies)
+}
+
+// Load constructs an engine for the policies of the YAML file.
+func Load(path string) (*Engine, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading policies: %w", err)
+	}
+
+	e, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+
+	return e, nil
+}
+
+// Default constructs an engine for the policies the service ships with.
+func Default() *Engine {
+	e, err := Parse(defaultDoc)
+	if err != nil {
+		panic(fmt.Sprintf("default policies: %s", err))
+	}
+	return e
+}
+
+// Evaluate decides on the request.
+func (e *Engine) Evaluate(req Request) Decision {
+	return e.Explain(req).Decision
+}
+
+// Explain decides on the request and reports how every policy fared. It has
+// no side effects, so it can be used to dry-run requests.
+func (e *Engine) Explain(req Request) Explanation {
+	if req.Subject.ID == "" {
+		return Explanation{Decision: Decision{Reason: ReasonNoSubject}}
+	}
+
+	steps := make([]Step, len(e.policies))
+
+	var allow, deny *Policy
+	for i := range e.policies {
+		p := &e.policies[i]
+
+		mismatch := p.match(req)
+		steps[i] = Step{
+			Policy:   p.Name,
+			Effect:   p.Effect,
+			Matched:  mismatch == "",
+			Mismatch: mismatch,
+		}
+		if mismatch != "" {
+			continue
+		}
+
+		switch {
+		case p.Effect == EffectDeny && deny == nil:
+			deny = p
+		case p.Effect == EffectAllow && allow == nil:
+			allow = p
+		}
+	}
+
+	var d Decision
+	switch {
+	case deny != nil:
+		d = Decision{Policy: deny.Name, Reason: deny.Reason}
+		if d.Reason == "" {
+			d.Reason = ReasonDenied
+		}
+	case allow != nil:
+		d = Decision{Allowed: true, Policy: allow.Name, Reason: ReasonAllowed}
+	default:
+		d = Decision{Reason: ReasonNoPolicy}
+	}
+
+	return Explanation{Decision: d, Steps: steps}
+}
+
+// match returns the first part of the policy the request doesn't match, it
+// is empty when the policy applies to the request.
+func (p *Policy) match(req Request) string {
+	if !contains(p.Actions, req.Action, true) {
+		return "action"
+	}
+	if !contains(p.Resources, req.Resource.Type, true) {
+		return "resource"
+	}
+
+	w := p.When
+	if w.Owner != nil {
+		owner := req.Resource.OwnerID != "" && req.Resource.OwnerID == req.Subject.ID
+		if owner != *w.Owner {
+			return "owner"
+		}
+	}
+	if w.Roles != nil && !containsAny(w.Roles, req.Subject.Roles) {
+		return "roles"
+	}
+	if w.Permissions != nil && !containsAny(w.Permissions, req.Subject.Permissions) {
+		return "permissions"
+	}
+	for key, value := range w.Attributes {
+		if got, ok := req.Attributes[key]; !ok || got != value {
+			return "attributes." + key
+		}
+	}
+
+	return ""
+}
+
+// empty reports whether none of the conditions is set.
+func (w Conditions) empty() bool {
+	return !w.Always && w.Owner == nil && w.Roles == nil && w.Permissions == nil && w.Attributes == nil
+}
+
+// contains reports whether the value is in the list, `*` in the list
+// matches any value when wild is set.
+func contains(list []string, value string, wild bool) bool {
+	for _, v := range list {
+		if v == value || (wild && v == wildcard) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsAny reports whether one of the values is in the list.
+func containsAny(list []string, values []string) bool {
+	for _, v := range values {
+		if contains(list, v, false) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,145 @@
+package policy_test
+
+import (
+	"errors"
+	"github.com/AgeroFlynn/crud/internal/buisness/sys/policy"
+	"github.com/AgeroFlynn/crud/internal/buisness/sys/tests"
+	"testing"
+)
+
+const doc = `
+policies:
+  - name: own-account
+    effect: allow
+    actions: [read, update]
+    resources: [user]
+    when:
+      owner: true
+  - name: support
+    effect: allow
+    actions: ["*"]
+    resources: [user]
+    when:
+      roles: [SUPPORT]
+  - name: no-disabling
+    effect: deny
+    reason: disabling_forbidden
+    actions: [set_status]
+    resources: [user]
+    when:
+      attributes:
+        status: DISABLED
+`
+
+func TestEngine(t *testing.T) {
+	e, err := policy.Parse([]byte(doc))
+	if err != nil {
+		t.Fatalf("parsing policies: %s", err)
+	}
+
+	request := func(subject policy.Subject, action string, ownerID string, attrs map[string]string) policy.Request {
+		return policy.Request{
+			Subject:    subject,
+			Action:     action,
+			Resource:   policy.Resource{Type: "user", ID: ownerID, OwnerID: ownerID},
+			Attributes: attrs,
+		}
+	}
+
+	user := policy.Subject{ID: "user"}
+	support := policy.Subject{ID: "support", Roles: []string{"SUPPORT"}}
+
+	t.Log("Given the need to decide requests with policies.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen a subject acts on what they own.", testID)
+		{
+			d := e.Evaluate(request(user, "update", "user", nil))
+			if !d.Allowed || d.Policy != "own-account" || d.Reason != policy.ReasonAllowed {
+				t.Fatalf("\t%s\tTest %d:\tShould be allowed by the own-account policy : %+v.", tests.Failed, testID, d)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be allowed by the own-account policy.", tests.Success, testID)
+
+			d = e.Evaluate(request(user, "delete", "user", nil))
+			if d.Allowed || d.Reason != policy.ReasonNoPolicy {
+				t.Fatalf("\t%s\tTest %d:\tShould be denied actions no policy allows : %+v.", tests.Failed, testID, d)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be denied actions no policy allows.", tests.Success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen a subject acts on what someone else owns.", testID)
+		{
+			d := e.Evaluate(request(user, "read", "other", nil))
+			if d.Allowed || d.Reason != policy.ReasonNoPolicy {
+				t.Fatalf("\t%s\tTest %d:\tShould be denied without a role : %+v.", tests.Failed, testID, d)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be denied without a role.", tests.Success, testID)
+
+			d = e.Evaluate(request(support, "set_status", "other", map[string]string{"status": "SUSPENDED"}))
+			if !d.Allowed || d.Policy != "support" {
+				t.Fatalf("\t%s\tTest %d:\tShould be allowed any action with the role : %+v.", tests.Failed, testID, d)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be allowed any action with the role.", tests.Success, testID)
+		}
+
+		testID = 2
+		t.Logf("\tTest %d:\tWhen a deny policy matches.", testID)
+		{
+			exp := e.Explain(request(support, "set_status", "other", map[string]string{"status": "DISABLED"}))
+			if exp.Decision.Allowed || exp.Decision.Policy != "no-disabling" || exp.Decision.Reason != "disabling_forbidden" {
+				t.Fatalf("\t%s\tTest %d:\tShould be denied with the reason of the policy : %+v.", tests.Failed, testID, exp.Decision)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be denied with the reason of the policy.", tests.Success, testID)
+
+			if len(exp.Steps) != 3 {
+				t.Fatalf("\t%s\tTest %d:\tShould explain every policy : %+v.", tests.Failed, testID, exp.Steps)
+			}
+			if s := exp.Steps[0]; s.Matched || s.Mismatch != "action" {
+				t.Fatalf("\t%s\tTest %d:\tShould tell why a policy didn't match : %+v.", tests.Failed, testID, s)
+			}
+			if !exp.Steps[1].Matched || !exp.Steps[2].Matched {
+				t.Fatalf("\t%s\tTest %d:\tShould tell which policies matched : %+v.", tests.Failed, testID, exp.Steps)
+			}
+			t.Logf("\t%s\tTest %d:\tShould explain every policy.", tests.Success, testID)
+
+			err := &policy.DeniedError{Decision: exp.Decision}
+			if !errors.Is(err, policy.ErrDenied) {
+				t.Fatalf("\t%s\tTest %d:\tShould report the denial as ErrDenied : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould report the denial as ErrDenied.", tests.Success, testID)
+		}
+
+		testID = 3
+		t.Logf("\tTest %d:\tWhen loading invalid policies.", testID)
+		{
+			for _, bad := range []string{
+				"policies:\n  - effect: allow\n    actions: [read]\n    resources: [user]\n",
+				"policies:\n  - name: a\n    effect: maybe\n    actions: [read]\n    resources: [user]\n",
+				"policies:\n  - name: a\n    effect: allow\n    resources: [user]\n",
+				"policies:\n  - name: a\n    effect: allow\n    actions: [read]\n    resources: [user]\n",
+				"policies:\n  - name: a\n    effect: allow\n    actions: [read]\n    resources: [user]\n    when: {}\n",
+				"policies:\n  - name: a\n    effect: allow\n    actions: [read]\n    resources: [user]\n    when:\n      permision: [users:read]\n",
+				"policies:\n  - name: a\n    effect: allow\n    actions: [read]\n    resources: [user]\n    whn:\n      owner: true\n",
+			} {
+				if _, err := policy.Parse([]byte(bad)); err == nil {
+					t.Fatalf("\t%s\tTest %d:\tShould reject the policies :\n%s", tests.Failed, testID, bad)
+				}
+			}
+			t.Logf("\t%s\tTest %d:\tShould reject invalid policies.", tests.Success, testID)
+
+			always := "policies:\n  - name: a\n    effect: allow\n    actions: [read]\n    resources: [user]\n    when:\n      always: true\n"
+			e, err := policy.Parse([]byte(always))
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould accept allowing every subject explicitly : %s.", tests.Failed, testID, err)
+			}
+			if d := e.Evaluate(request(user, "read", "other", nil)); !d.Allowed {
+				t.Fatalf("\t%s\tTest %d:\tShould allow every subject : %+v.", tests.Failed, testID, d)
+			}
+			t.Logf("\t%s\tTest %d:\tShould accept allowing every subject explicitly.", tests.Success, testID)
+
+			policy.Default()
+			t.Logf("\t%s\tTest %d:\tShould load the default policies.", tests.Success, testID)
+		}
+	}
+}
@@ -7,9 +7,31 @@ import (
 	"crypto/rand"
 	"crypto/rsa"
 	"fmt"
+	auditCore "github.com/AgeroFlynn/crud/internal/buisness/core/audit"
+	"github.com/AgeroFlynn/crud/internal/buisness/core/dto"
+	outboxCore "github.com/AgeroFlynn/crud/internal/buisness/core/outbox"
+	roleCore "github.com/AgeroFlynn/crud/internal/buisness/core/role"
+	shareCore "github.com/AgeroFlynn/crud/internal/buisness/core/sharelink"
+	userCore "github.com/AgeroFlynn/crud/internal/buisness/core/user"
+	webhookCore "github.com/AgeroFlynn/crud/internal/buisness/core/webhook"
 	"github.com/AgeroFlynn/crud/internal/buisness/repository/dbschema"
+	"github.com/AgeroFlynn/crud/internal/buisness/repository/store/audit"
+	"github.com/AgeroFlynn/crud/internal/buisness/repository/store/auditmem"
+	"github.com/AgeroFlynn/crud/internal/buisness/repository/store/login"
+	"github.com/AgeroFlynn/crud/internal/buisness/repository/store/loginmem"
+	"github.com/AgeroFlynn/crud/internal/buisness/repository/store/outbox"
+	"github.com/AgeroFlynn/crud/internal/buisness/repository/store/outboxmem"
+	"github.com/AgeroFlynn/crud/internal/buisness/repository/store/role"
+	"github.com/AgeroFlynn/crud/internal/buisness/repository/store/rolemem"
+	"github.com/AgeroFlynn/crud/internal/buisness/repository/store/sharelink"
+	"github.com/AgeroFlynn/crud/internal/buisness/repository/store/sharelinkmem"
 	"github.com/AgeroFlynn/crud/internal/buisness/repository/store/user"
+	"github.com/AgeroFlynn/crud/internal/buisness/repository/store/usermem"
+	"github.com/AgeroFlynn/crud/internal/buisness/repository/store/webhook"
+	"github.com/AgeroFlynn/crud/internal/buisness/repository/store/webhookmem"
 	"github.com/AgeroFlynn/crud/internal/buisness/sys/auth"
+	"github.com/AgeroFlynn/crud/internal/buisness/sys/passwd"
+	"github.com/AgeroFlynn/crud/internal/foundation/database"
 	"github.com/AgeroFlynn/crud/internal/foundation/docker"
 	"github.com/AgeroFlynn/crud/internal/foundation/keystore"
 	"github.com/AgeroFlynn/crud/internal/foundation/logger"
@@ -27,6 +49,12 @@ const (
 	Failed  = "\u2717"
 )
 
+// Hasher hashes passwords in tests. Its parameters are far too weak for
+// production but keep the tests fast.
+var Hasher = passwd.Argon2id{
+	Params: passwd.Argon2Params{Time: 1, Memory: 64, Threads: 1},
+}
+
 // DBContainer provides configuration for a container to run.
 type DBContainer struct {
 	Image string
@@ -60,6 +88,7 @@ func NewUnit(t *testing.T, dbc DBContainer) (*zap.SugaredLogger, *pg.DB, func())
 	}
 
 	db := pg.Connect(pgOpt)
+	database.WithQueryTimeout(db, 5*time.Second)
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
@@ -108,6 +137,13 @@ type Test struct {
 	DB       *pg.DB
 	Log      *zap.SugaredLogger
 	Auth     *auth.Auth
+	Users    userCore.UserStorer
+	Roles    roleCore.RoleStorer
+	Audit    auditCore.AuditStorer
+	Outbox   outboxCore.OutboxStorer
+	Webhooks webhookCore.WebhookStorer
+	Logins   userCore.LoginStorer
+	Shares   shareCore.ShareStorer
 	Teardown func()
 
 	t *testing.T
@@ -117,6 +153,109 @@ type Test struct {
 func NewIntegration(t *testing.T, dbc DBContainer) *Test {
 	log, db, teardown := NewUnit(t, dbc)
 
+	test := Test{
+		DB:       db,
+		Log:      log,
+		Auth:     newAuth(t),
+		Users:    user.NewStore(log, db, Hasher),
+		Roles:    role.NewStore(log, db),
+		Audit:    audit.NewStore(log, db),
+		Outbox:   outbox.NewStore(log, db),
+		Webhooks: webhook.NewStore(log, db),
+		Logins:   login.NewStore(log, db),
+		Shares:   sharelink.NewStore(log, db),
+		t:        t,
+		Teardown: teardown,
+	}
+
+	return &test
+}
+
+// NewMemory constructs an authenticator and an in-memory user store seeded
+// with the same users as the database. It doesn't need Docker so it can be
+// used to run the handler tests quickly.
+func NewMemory(t *testing.T) *Test {
+	log, err := logger.New("TEST")
+	if err != nil {
+		t.Fatalf("logger error: %s", err)
+	}
+
+	// Same users and password (gophers) as the seed of the database.
+	const hash = "$2a$10$pDrzO6UaEHJMb8nniy4QNOkZLOK09.HqTJrTQTBnEIoFNMwMvqn3a"
+	created := time.Date(2019, time.March, 24, 0, 0, 0, 0, time.UTC)
+
+	users := usermem.NewStore(log, Hasher)
+	users.Seed(
+		dto.User{
+			ID:           "5cf37266-3473-4006-984f-9325122678b7",
+			Name:         "Admin Gopher",
+			Email:        "admin@example.com",
+			Roles:        []string{auth.RoleAdmin, auth.RoleUser},
+			PasswordHash: []byte(hash),
+			DateCreated:  created,
+			DateUpdated:  created,
+			DateVerified: &created,
+			Status:       dto.StatusActive,
+			Version:      1,
+		},
+		dto.User{
+			ID:           "45b5fbd3-755f-4379-8f07-a58d4a30fa2f",
+			Name:         "User Gopher",
+			Email:        "user@example.com",
+			Roles:        []string{auth.RoleUser},
+			PasswordHash: []byte(hash),
+			DateCreated:  created,
+			DateUpdated:  created,
+			DateVerified: &created,
+			Status:       dto.StatusActive,
+			Version:      1,
+		},
+	)
+
+	// Same permissions and built-in roles as the schema of the database.
+	permissions := []dto.Permission{
+		{Name: auth.PermUsersRead, Description: "Read the accounts of other users"},
+		{Name: auth.PermUsersWrite, Description: "Create, modify and delete the accounts of other users"},
+		{Name: auth.PermDirectoryExport, Description: "Export the contact cards of other users"},
+		{Name: auth.PermRolesRead, Description: "Read roles and permissions"},
+		{Name: auth.PermRolesWrite, Description: "Create, modify and delete roles"},
+		{Name: auth.PermRolesAssign, Description: "Assign roles to users"},
+		{Name: auth.PermAuditRead, Description: "Read and export the audit log"},
+		{Name: auth.PermWebhooksManage, Description: "Manage the webhooks and their deliveries"},
+	}
+	all := make([]string, len(permissions))
+	for i, p := range permissions {
+		all[i] = p.Name
+	}
+
+	roles := rolemem.NewStore(log)
+	roles.Seed(permissions,
+		dto.Role{Name: auth.RoleAdmin, Description: "Administrators", Permissions: all, DateCreated: created, DateUpdated: created},
+		dto.Role{Name: auth.RoleUser, Description: "Users", DateCreated: created, DateUpdated: created},
+	)
+
+	test := Test{
+		Log:      log,
+		Auth:     newAuth(t),
+		Users:    users,
+		Roles:    roles,
+		Audit:    auditmem.NewStore(log),
+		Outbox:   outboxmem.NewStore(log),
+		Webhooks: webhookmem.NewStore(log),
+		Logins:   loginmem.NewStore(log, users),
+		Shares:   sharelinkmem.NewStore(log),
+		t:        t,
+		Teardown: func() {
+			log.Sync()
+		},
+	}
+
+	return &test
+}
+
+// newAuth builds an authenticator with a freshly generated key.
+func newAuth(t *testing.T) *auth.Auth {
+
 	// Create RSA keys to enable authentication in our service.
 	keyID := "4754d86b-7a6d-4df5-9c65-224741361492"
 	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
@@ -125,28 +264,24 @@ func NewIntegration(t *testing.T, dbc DBContainer) *Test {
 	}
 
 	// Build an authenticator using this private key and id for the key store.
-	auth, err := auth.New(keyID, keystore.NewMap(map[string]*rsa.PrivateKey{keyID: privateKey}))
+	a, err := auth.New(keyID, keystore.NewMap(map[string]*rsa.PrivateKey{keyID: privateKey}))
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	test := Test{
-		DB:       db,
-		Log:      log,
-		Auth:     auth,
-		t:        t,
-		Teardown: teardown,
-	}
-
-	return &test
+	return a
 }
 
 // Token generates an authenticated token for a user.
 func (test *Test) Token(email, pass string) string {
 	test.t.Log("Generating token for test ...")
 
-	store := user.NewStore(test.Log, test.DB)
-	claims, err := store.Authenticate(context.Background(), time.Now(), email, pass)
+	claims, err := test.Users.Authenticate(context.Background(), time.Now(), email, pass)
+	if err != nil {
+		test.t.Fatal(err)
+	}
+
+	claims.Permissions, err = test.Roles.Resolve(context.Background(), claims.Roles)
 	if err != nil {
 		test.t.Fatal(err)
 	}
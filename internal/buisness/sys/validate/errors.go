@@ -9,9 +9,11 @@ import (
 var ErrInvalidID = errors.New("ID is not in its proper form")
 
 // ErrorResponse is the form used  for API responses from failures in the API.
+// Reason is the machine-readable reason of authorization denials.
 type ErrorResponse struct {
 	Error  string `json:"error"`
 	Fields string `json:"fields,omitempty"`
+	Reason string `json:"reason,omitempty"`
 }
 
 // RequestError is used to pass an error during the request through the
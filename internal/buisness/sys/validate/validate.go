@@ -77,3 +77,19 @@ func CheckID(id string) error {
 	}
 	return nil
 }
+
+// NormalizeEmail trims the email and lowercases its domain, which is case
+// insensitive. The local part is only lowercased when lowerLocal is set
+// since some mail servers treat it as case sensitive.
+func NormalizeEmail(email string, lowerLocal bool) string {
+	email = strings.TrimSpace(email)
+	if lowerLocal {
+		return strings.ToLower(email)
+	}
+
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return email
+	}
+	return email[:at] + strings.ToLower(email[at:])
+}
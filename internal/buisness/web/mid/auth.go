@@ -11,8 +11,12 @@ import (
 	"strings"
 )
 
-// Authenticate validates a JWT from the `Authorization` header.
-func Authenticate(a *auth.Auth) web2.Middleware {
+// Authenticate validates a JWT from the `Authorization` header. Tokens the
+// revoker reports as revoked are rejected, the check is skipped when the
+// revoker is nil. The permissions of the roles in the token are resolved
+// through the resolver unless it is nil, the ones the token was issued with
+// are used then.
+func Authenticate(a *auth.Auth, rv auth.Revoker, rs auth.Resolver) web2.Middleware {
 
 	// This is the actual middleware function to be executed.
 	m := func(handler web2.Handler) web2.Handler {
@@ -36,6 +40,26 @@ func Authenticate(a *auth.Auth) web2.Middleware {
 				return validate.NewRequestError(err, http.StatusUnauthorized)
 			}
 
+			// Reject tokens issued before the tokens of the user were revoked.
+			if rv != nil {
+				revoked, err := rv.Revoked(ctx, claims)
+				if err != nil {
+					return fmt.Errorf("checking revocation: %w", err)
+				}
+				if revoked {
+					return validate.NewRequestError(errors.New("token was revoked"), http.StatusUnauthorized)
+				}
+			}
+
+			// The permissions granted by the roles may have changed since the
+			// token was issued.
+			if rs != nil {
+				claims.Permissions, err = rs.Resolve(ctx, claims.Roles)
+				if err != nil {
+					return fmt.Errorf("resolving permissions: %w", err)
+				}
+			}
+
 			// Add claims to the context, so they can be retrieved later.
 			ctx = auth.SetClaims(ctx, claims)
 
@@ -49,9 +73,9 @@ func Authenticate(a *auth.Auth) web2.Middleware {
 	return m
 }
 
-// Authorize validates that an authenticated user has at least one role from a
-// specified list. This method constructs the actual function that is used.
-func Authorize(roles ...string) web2.Middleware {
+// RequirePermission validates that an authenticated user was granted the
+// permission through one of their roles.
+func RequirePermission(permission string) web2.Middleware {
 
 	// This is the actual middleware function to be executed.
 	m := func(handler web2.Handler) web2.Handler {
@@ -68,9 +92,9 @@ func Authorize(roles ...string) web2.Middleware {
 				)
 			}
 
-			if !claims.Authorized(roles...) {
+			if !claims.HasPermission(permission) {
 				return validate.NewRequestError(
-					fmt.Errorf("you are not authorized for that action, claims[%v] roles[%v]", claims.Roles, roles),
+					fmt.Errorf("you are not authorized for that action, claims[%v] permission[%s]", claims.Permissions, permission),
 					http.StatusForbidden,
 				)
 			}
@@ -2,12 +2,19 @@ package mid
 
 import (
 	"context"
+	"errors"
+	"github.com/AgeroFlynn/crud/internal/buisness/sys/policy"
 	"github.com/AgeroFlynn/crud/internal/buisness/sys/validate"
+	"github.com/AgeroFlynn/crud/internal/foundation/database"
 	web2 "github.com/AgeroFlynn/crud/internal/foundation/web"
 	"go.uber.org/zap"
 	"net/http"
 )
 
+// statusClientClosedRequest is the non standard status used when the client
+// went away before the response was ready.
+const statusClientClosedRequest = 499
+
 // Errors handles errors coming out of the call chain. It detects normal
 // application errors which are used to respond to the client in a uniform way.
 // Unexpected errors (status >= 500) are logged.
@@ -48,13 +55,36 @@ func Errors(log *zap.SugaredLogger) web2.Middleware {
 					er = validate.ErrorResponse{
 						Error: act.Error(),
 					}
+					if act.Fields != nil {
+						er.Fields = act.Fields.Error()
+					}
 					status = act.Status
 
 				default:
-					er = validate.ErrorResponse{
-						Error: http.StatusText(http.StatusInternalServerError),
+					switch {
+					case errors.Is(err, database.ErrTimeout):
+						er = validate.ErrorResponse{
+							Error: http.StatusText(http.StatusServiceUnavailable),
+						}
+						status = http.StatusServiceUnavailable
+
+					case errors.Is(err, database.ErrCanceled):
+						er = validate.ErrorResponse{
+							Error: "client closed request",
+						}
+						status = statusClientClosedRequest
+
+					default:
+						er = validate.ErrorResponse{
+							Error: http.StatusText(http.StatusInternalServerError),
+						}
+						status = http.StatusInternalServerError
 					}
-					status = http.StatusInternalServerError
+				}
+
+				// Tell clients why the policies denied the request.
+				if d, ok := denial(err); ok {
+					er.Reason = d.Reason
 				}
 
 				// Respond with the error back to the client.
@@ -77,3 +107,18 @@ func Errors(log *zap.SugaredLogger) web2.Middleware {
 
 	return m
 }
+
+// denial returns the decision of the policies when the error is a denial,
+// also when a handler wrapped it in a request error.
+func denial(err error) (policy.Decision, bool) {
+	var re *validate.RequestError
+	if errors.As(err, &re) {
+		err = re.Err
+	}
+
+	var de *policy.DeniedError
+	if !errors.As(err, &de) {
+		return policy.Decision{}, false
+	}
+	return de.Decision, true
+}
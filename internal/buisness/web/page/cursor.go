@@ -0,0 +1,203 @@
+package page
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/AgeroFlynn/crud/internal/buisness/repository/order"
+	"github.com/AgeroFlynn/crud/internal/buisness/sys/auth"
+	"github.com/golang-jwt/jwt/v4"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// CursorAudience is the audience of the tokens used as cursors.
+const CursorAudience = "cursor"
+
+// ErrInvalidCursor occurs when a cursor can't be decoded or was tampered with.
+var ErrInvalidCursor = errors.New("cursor is not valid")
+
+// Cursor is a position inside a listing. It is handed to clients as an
+// opaque signed token so it can't be tampered with. A cursor without a key
+// is at the start of the listing, or at its end when walking backward.
+type Cursor struct {
+	OrderBy  order.By   `json:"o"`
+	Key      *order.Key `json:"k,omitempty"`
+	Backward bool       `json:"b,omitempty"`
+}
+
+// CursorPage represents the requested window of a listing in cursor mode.
+// A nil Cursor starts at the beginning of the listing.
+type CursorPage struct {
+	Cursor *Cursor
+	Limit  int
+}
+
+// IsCursor reports whether the client asked for cursor paging. The mode is
+// selected by providing the `cursor` query parameter, an empty value asks
+// for the first page.
+func IsCursor(r *http.Request) bool {
+	return r.URL.Query().Has("cursor")
+}
+
+// ParseCursor reads the cursor and the number of rows of the request.
+func ParseCursor(a *auth.Auth, r *http.Request) (CursorPage, error) {
+	q := r.URL.Query()
+
+	rows, err := parseInt(q, "rows", DefaultRows, 1, MaxRows)
+	if err != nil {
+		return CursorPage{}, err
+	}
+
+	cp := CursorPage{Limit: rows}
+
+	if token := q.Get("cursor"); token != "" {
+		c, err := DecodeCursor(a, token)
+		if err != nil {
+			return CursorPage{}, err
+		}
+		cp.Cursor = &c
+	}
+
+	return cp, nil
+}
+
+// EncodeCursor converts the cursor into a signed token.
+func EncodeCursor(a *auth.Auth, c Cursor) (string, error) {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return "", fmt.Errorf("encoding cursor: %w", err)
+	}
+
+	claims := jwt.RegisteredClaims{
+		Subject: base64.RawURLEncoding.EncodeToString(data),
+	}
+
+	token, err := a.GenerateScopedToken(CursorAudience, claims)
+	if err != nil {
+		return "", fmt.Errorf("signing cursor: %w", err)
+	}
+
+	return token, nil
+}
+
+// DecodeCursor validates the signature of the token and recreates the cursor.
+func DecodeCursor(a *auth.Auth, token string) (Cursor, error) {
+	claims, err := a.ValidateScopedToken(CursorAudience, token)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(claims.Subject)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	var c Cursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	return c, nil
+}
+
+// Cursors returns the next and prev cursors around a page of a listing
+// walked from the cursor, a nil cursor being the start of the listing. The
+// keys are those of the first and last items of the page, nil when the page
+// is empty. Empty pages point back the way they came so clients don't get
+// stuck.
+func Cursors(a *auth.Auth, orderBy order.By, from *Cursor, more bool, first *order.Key, last *order.Key) (next string, prev string, err error) {
+	var key *order.Key
+	var backward bool
+	if from != nil {
+		key = from.Key
+		backward = from.Backward
+	}
+
+	var nc, pc *Cursor
+	switch {
+	case first != nil:
+
+		// Going forward there is a previous page unless we are at the start,
+		// going backward there is a next page unless we are at the end.
+		if more || (backward && key != nil) {
+			nc = &Cursor{OrderBy: orderBy, Key: last}
+		}
+		if (!backward && key != nil) || (backward && more) {
+			pc = &Cursor{OrderBy: orderBy, Key: first, Backward: true}
+		}
+
+	case key != nil && backward:
+
+		// Nothing is left before the cursor, the listing starts right after
+		// it.
+		nc = &Cursor{OrderBy: orderBy}
+
+	case key != nil:
+
+		// Nothing is left after the cursor, the end of the listing precedes
+		// it.
+		pc = &Cursor{OrderBy: orderBy, Backward: true}
+	}
+
+	if nc != nil {
+		if next, err = EncodeCursor(a, *nc); err != nil {
+			return "", "", err
+		}
+	}
+	if pc != nil {
+		if prev, err = EncodeCursor(a, *pc); err != nil {
+			return "", "", err
+		}
+	}
+
+	return next, prev, nil
+}
+
+// CursorResponse is the envelope used to return a page of a listing in
+// cursor mode.
+type CursorResponse[T any] struct {
+	Items []T    `json:"items"`
+	Next  string `json:"next,omitempty"`
+	Prev  string `json:"prev,omitempty"`
+}
+
+// NewCursorResponse constructs the envelope for a page of items.
+func NewCursorResponse[T any](items []T, next string, prev string) CursorResponse[T] {
+	if items == nil {
+		items = []T{}
+	}
+
+	return CursorResponse[T]{
+		Items: items,
+		Next:  next,
+		Prev:  prev,
+	}
+}
+
+// SetCursorLinks sets the RFC 8288 Link header with the next and prev
+// relations of the listing. The links keep all other query parameters of
+// the request.
+func SetCursorLinks(w http.ResponseWriter, r *http.Request, cp CursorPage, next string, prev string) {
+	link := func(cursor string) string {
+		q := r.URL.Query()
+		q.Set("cursor", cursor)
+		q.Set("rows", strconv.Itoa(cp.Limit))
+
+		u := url.URL{Path: r.URL.Path, RawQuery: q.Encode()}
+		return u.String()
+	}
+
+	links := []string{fmt.Sprintf(`<%s>; rel="first"`, link(""))}
+	if prev != "" {
+		links = append(links, fmt.Sprintf(`<%s>; rel="prev"`, link(prev)))
+	}
+	if next != "" {
+		links = append(links, fmt.Sprintf(`<%s>; rel="next"`, link(next)))
+	}
+
+	w.Header().Set("Link", strings.Join(links, ", "))
+}
@@ -0,0 +1,165 @@
+// Package page provides support for paging through listings.
+package page
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Set of limits for the number of rows returned in a single page.
+const (
+	DefaultRows = 20
+	MaxRows     = 100
+)
+
+// Set of limits for how deep a listing can be paged into. They keep the
+// offset from overflowing.
+const (
+	MaxPage   = 100000
+	MaxOffset = MaxPage * MaxRows
+)
+
+// Page represents the requested window of a listing. Clients either ask for
+// a page number with a number of rows per page or for a limit and an offset,
+// and links are built using the same style.
+type Page struct {
+	Offset int
+	Limit  int
+
+	byNumber bool
+}
+
+// Parse reads the paging parameters of the request. It accepts either the
+// `page` and `rows` or the `limit` and `offset` query parameters.
+func Parse(r *http.Request) (Page, error) {
+	q := r.URL.Query()
+
+	if q.Has("limit") || q.Has("offset") {
+		if q.Has("page") || q.Has("rows") {
+			return Page{}, errors.New("page/rows and limit/offset can't be mixed")
+		}
+
+		limit, err := parseInt(q, "limit", DefaultRows, 1, MaxRows)
+		if err != nil {
+			return Page{}, err
+		}
+		offset, err := parseInt(q, "offset", 0, 0, MaxOffset)
+		if err != nil {
+			return Page{}, err
+		}
+
+		return Page{Offset: offset, Limit: limit}, nil
+	}
+
+	number, err := parseInt(q, "page", 1, 1, MaxPage)
+	if err != nil {
+		return Page{}, err
+	}
+	rows, err := parseInt(q, "rows", DefaultRows, 1, MaxRows)
+	if err != nil {
+		return Page{}, err
+	}
+
+	return Page{Offset: (number - 1) * rows, Limit: rows, byNumber: true}, nil
+}
+
+// Number returns the page number the offset falls into.
+func (p Page) Number() int {
+	return p.Offset/p.Limit + 1
+}
+
+// Response is the envelope used to return a page of a listing.
+type Response[T any] struct {
+	Items  []T `json:"items"`
+	Total  int `json:"total"`
+	Offset int `json:"offset"`
+	Limit  int `json:"limit"`
+	Page   int `json:"page"`
+}
+
+// NewResponse constructs the envelope for a page of items.
+func NewResponse[T any](items []T, total int, p Page) Response[T] {
+	if items == nil {
+		items = []T{}
+	}
+
+	return Response[T]{
+		Items:  items,
+		Total:  total,
+		Offset: p.Offset,
+		Limit:  p.Limit,
+		Page:   p.Number(),
+	}
+}
+
+// SetLinks sets the RFC 8288 Link header with the first, prev, next and last
+// relations of the listing. The links keep all other query parameters of the
+// request.
+func SetLinks(w http.ResponseWriter, r *http.Request, p Page, total int) {
+	last := 0
+	if total > 0 {
+		last = (total - 1) / p.Limit * p.Limit
+	}
+
+	var links []string
+	add := func(rel string, offset int) {
+		links = append(links, fmt.Sprintf(`<%s>; rel="%s"`, p.link(r.URL, offset), rel))
+	}
+
+	add("first", 0)
+	if p.Offset > 0 {
+		prev := p.Offset - p.Limit
+		if prev < 0 {
+			prev = 0
+		}
+		add("prev", prev)
+	}
+	if p.Offset+p.Limit < total {
+		add("next", p.Offset+p.Limit)
+	}
+	add("last", last)
+
+	w.Header().Set("Link", strings.Join(links, ", "))
+}
+
+// link returns the url of the listing starting at the specified offset.
+func (p Page) link(u *url.URL, offset int) string {
+	q := u.Query()
+
+	if p.byNumber {
+		q.Set("page", strconv.Itoa(offset/p.Limit+1))
+		q.Set("rows", strconv.Itoa(p.Limit))
+	} else {
+		q.Set("offset", strconv.Itoa(offset))
+		q.Set("limit", strconv.Itoa(p.Limit))
+	}
+
+	link := url.URL{Path: u.Path, RawQuery: q.Encode()}
+	return link.String()
+}
+
+// parseInt reads an integer query parameter. A negative max means there is
+// no upper bound.
+func parseInt(q url.Values, key string, def int, min int, max int) (int, error) {
+	s := q.Get(key)
+	if s == "" {
+		return def, nil
+	}
+
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("%s must be a number", key)
+	}
+	if n < min || (max >= 0 && n > max) {
+		if max < 0 {
+			return 0, fmt.Errorf("%s must be at least %d", key, min)
+		}
+		return 0, fmt.Errorf("%s must be between %d and %d", key, min, max)
+	}
+
+	return n, nil
+}
@@ -0,0 +1,159 @@
+package page_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"github.com/AgeroFlynn/crud/internal/buisness/repository/order"
+	"github.com/AgeroFlynn/crud/internal/buisness/sys/auth"
+	"github.com/AgeroFlynn/crud/internal/buisness/sys/tests"
+	"github.com/AgeroFlynn/crud/internal/buisness/web/page"
+	"github.com/AgeroFlynn/crud/internal/foundation/keystore"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestPage(t *testing.T) {
+	t.Log("Given the need to page through listings.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen using page and rows.", testID)
+		{
+			r := httptest.NewRequest(http.MethodGet, "/v1/users?page=2&rows=10&name=go", nil)
+			w := httptest.NewRecorder()
+
+			p, err := page.Parse(r)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to parse the page : %s.", tests.Failed, testID, err)
+			}
+			if p.Offset != 10 || p.Limit != 10 {
+				t.Fatalf("\t%s\tTest %d:\tShould get offset 10 and limit 10 : got %d and %d.", tests.Failed, testID, p.Offset, p.Limit)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get offset 10 and limit 10.", tests.Success, testID)
+
+			page.SetLinks(w, r, p, 35)
+
+			exp := `</v1/users?name=go&page=1&rows=10>; rel="first", ` +
+				`</v1/users?name=go&page=1&rows=10>; rel="prev", ` +
+				`</v1/users?name=go&page=3&rows=10>; rel="next", ` +
+				`</v1/users?name=go&page=4&rows=10>; rel="last"`
+			if got := w.Header().Get("Link"); got != exp {
+				t.Logf("\t\tTest %d:\tGot : %v", testID, got)
+				t.Logf("\t\tTest %d:\tExp : %v", testID, exp)
+				t.Fatalf("\t%s\tTest %d:\tShould get the expected links.", tests.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get the expected links.", tests.Success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen using limit and offset on the last page.", testID)
+		{
+			r := httptest.NewRequest(http.MethodGet, "/v1/users?limit=5&offset=30", nil)
+			w := httptest.NewRecorder()
+
+			p, err := page.Parse(r)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to parse the page : %s.", tests.Failed, testID, err)
+			}
+
+			page.SetLinks(w, r, p, 35)
+
+			exp := `</v1/users?limit=5&offset=0>; rel="first", ` +
+				`</v1/users?limit=5&offset=25>; rel="prev", ` +
+				`</v1/users?limit=5&offset=30>; rel="last"`
+			if got := w.Header().Get("Link"); got != exp {
+				t.Logf("\t\tTest %d:\tGot : %v", testID, got)
+				t.Logf("\t\tTest %d:\tExp : %v", testID, exp)
+				t.Fatalf("\t%s\tTest %d:\tShould get the expected links without next.", tests.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get the expected links without next.", tests.Success, testID)
+		}
+
+		testID = 2
+		t.Logf("\tTest %d:\tWhen asking for too many rows.", testID)
+		{
+			r := httptest.NewRequest(http.MethodGet, "/v1/users?rows=1000", nil)
+
+			if _, err := page.Parse(r); err == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould reject the request.", tests.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould reject the request.", tests.Success, testID)
+		}
+
+		testID = 3
+		t.Logf("\tTest %d:\tWhen paging past the deepest page.", testID)
+		{
+			for _, target := range []string{"/v1/users?page=9223372036854775807&rows=100", "/v1/users?page=100001", "/v1/users?offset=9223372036854775807"} {
+				r := httptest.NewRequest(http.MethodGet, target, nil)
+
+				if p, err := page.Parse(r); err == nil {
+					t.Fatalf("\t%s\tTest %d:\tShould reject %s : got offset %d.", tests.Failed, testID, target, p.Offset)
+				}
+			}
+			t.Logf("\t%s\tTest %d:\tShould reject the request.", tests.Success, testID)
+
+			r := httptest.NewRequest(http.MethodGet, "/v1/users?page=100000&rows=100", nil)
+
+			p, err := page.Parse(r)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould accept the deepest page : %s.", tests.Failed, testID, err)
+			}
+			if p.Offset != (page.MaxPage-1)*page.MaxRows {
+				t.Fatalf("\t%s\tTest %d:\tShould get the offset of the deepest page : got %d.", tests.Failed, testID, p.Offset)
+			}
+			t.Logf("\t%s\tTest %d:\tShould accept the deepest page.", tests.Success, testID)
+		}
+	}
+}
+
+func TestCursor(t *testing.T) {
+	const keyID = "4754d86b-7a6d-4df5-9c65-224741361492"
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := auth.New(keyID, keystore.NewMap(map[string]*rsa.PrivateKey{keyID: privateKey}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Log("Given the need to hand out opaque cursors.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen encoding and decoding a cursor.", testID)
+		{
+			exp := page.Cursor{
+				OrderBy:  order.NewBy("email", order.DESC),
+				Key:      &order.Key{Value: "admin@example.com", ID: "5cf37266-3473-4006-984f-9325122678b7"},
+				Backward: true,
+			}
+
+			token, err := page.EncodeCursor(a, exp)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to encode the cursor : %s.", tests.Failed, testID, err)
+			}
+
+			got, err := page.DecodeCursor(a, token)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to decode the cursor : %s.", tests.Failed, testID, err)
+			}
+			if diff := cmp.Diff(exp, got); diff != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould get back the same cursor. Diff:\n%s", tests.Failed, testID, diff)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get back the same cursor.", tests.Success, testID)
+
+			if _, err := page.DecodeCursor(a, token[:len(token)-2]+"xx"); err == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould reject a tampered cursor.", tests.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould reject a tampered cursor.", tests.Success, testID)
+
+			if _, err := a.ValidateToken(token); err == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould not accept the cursor as a user token.", tests.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould not accept the cursor as a user token.", tests.Success, testID)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+// Package param provides support for reading the filters of listings from
+// the query parameters.
+package param
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// String returns the value of the parameter without the surrounding white
+// space, or nil when the parameter is missing or blank.
+func String(q url.Values, key string) *string {
+	if s := strings.TrimSpace(q.Get(key)); s != "" {
+		return &s
+	}
+	return nil
+}
+
+// Date returns the time of the parameter, or nil when it is missing. It
+// accepts RFC 3339 timestamps and dates. A date is the start of the day, or
+// the last moment of the day when endOfDay is set so the day is included in
+// a range ending with it.
+func Date(q url.Values, key string, endOfDay bool) (*time.Time, error) {
+	s := q.Get(key)
+	if s == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return &t, nil
+	}
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		if endOfDay {
+			t = t.Add(24*time.Hour - time.Nanosecond)
+		}
+		return &t, nil
+	}
+	return nil, fmt.Errorf("%s must be a RFC 3339 timestamp or a date", key)
+}
@@ -0,0 +1,74 @@
+package param_test
+
+import (
+	"github.com/AgeroFlynn/crud/internal/buisness/sys/tests"
+	"github.com/AgeroFlynn/crud/internal/buisness/web/param"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestDate(t *testing.T) {
+	table := []struct {
+		name     string
+		value    string
+		endOfDay bool
+		exp      *time.Time
+		fails    bool
+	}{
+		{"a missing date", "", false, nil, false},
+		{"a timestamp", "2026-03-04T05:06:07Z", true, ptr(time.Date(2026, 3, 4, 5, 6, 7, 0, time.UTC)), false},
+		{"a date", "2026-03-04", false, ptr(time.Date(2026, 3, 4, 0, 0, 0, 0, time.UTC)), false},
+		{"a date ending a range", "2026-03-04", true, ptr(time.Date(2026, 3, 4, 23, 59, 59, 999999999, time.UTC)), false},
+		{"something else", "yesterday", false, nil, true},
+	}
+
+	t.Log("Given the need to filter listings by dates.")
+	{
+		for testID, tt := range table {
+			t.Logf("\tTest %d:\tWhen parsing %s.", testID, tt.name)
+			{
+				q := url.Values{}
+				if tt.value != "" {
+					q.Set("start_date", tt.value)
+				}
+
+				got, err := param.Date(q, "start_date", tt.endOfDay)
+				if (err != nil) != tt.fails {
+					t.Fatalf("\t%s\tTest %d:\tShould fail only for invalid dates : %v.", tests.Failed, testID, err)
+				}
+				if (got == nil) != (tt.exp == nil) || (got != nil && !got.Equal(*tt.exp)) {
+					t.Fatalf("\t%s\tTest %d:\tShould get the expected time : got %v, exp %v.", tests.Failed, testID, got, tt.exp)
+				}
+				t.Logf("\t%s\tTest %d:\tShould get the expected time.", tests.Success, testID)
+			}
+		}
+	}
+}
+
+func TestString(t *testing.T) {
+	q := url.Values{"name": {"  Bill  "}, "blank": {"   "}}
+
+	t.Log("Given the need to filter listings by values.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen reading the parameters.", testID)
+		{
+			if got := param.String(q, "name"); got == nil || *got != "Bill" {
+				t.Fatalf("\t%s\tTest %d:\tShould trim the value : got %v.", tests.Failed, testID, got)
+			}
+			if got := param.String(q, "blank"); got != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould ignore a blank value : got %q.", tests.Failed, testID, *got)
+			}
+			if got := param.String(q, "missing"); got != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould ignore a missing value : got %q.", tests.Failed, testID, *got)
+			}
+			t.Logf("\t%s\tTest %d:\tShould read the trimmed value.", tests.Success, testID)
+		}
+	}
+}
+
+// ptr returns a pointer to the time.
+func ptr(t time.Time) *time.Time {
+	return &t
+}
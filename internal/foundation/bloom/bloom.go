@@ -0,0 +1,151 @@
+// Package bloom provides a bloom filter that can be stored in a file. It
+// answers if an item is in a large set without keeping the set in memory,
+// at the price of false positives.
+package bloom
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"os"
+)
+
+// magic identifies the file format, the hashing of the items is part of the
+// format so stored filters stay valid.
+var magic = []byte("BLM1")
+
+// ErrFormat is returned when reading data that isn't a stored filter.
+var ErrFormat = errors.New("not a bloom filter")
+
+// Filter is a bloom filter of m bits set by k hash functions.
+type Filter struct {
+	m    uint64
+	k    uint32
+	bits []uint64
+}
+
+// New constructs a filter sized for n items with the false positive rate p.
+func New(n int, p float64) *Filter {
+	if n < 1 {
+		n = 1
+	}
+	if p <= 0 || p >= 1 {
+		p = 0.001
+	}
+
+	m := uint64(math.Ceil(-float64(n) * math.Log(p) / (math.Ln2 * math.Ln2)))
+	k := uint32(math.Max(1, math.Round(float64(m)/float64(n)*math.Ln2)))
+
+	return &Filter{
+		m:    m,
+		k:    k,
+		bits: make([]uint64, (m+63)/64),
+	}
+}
+
+// Load reads a filter stored with WriteTo from the file.
+func Load(path string) (*Filter, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening filter: %w", err)
+	}
+	defer file.Close()
+
+	var f Filter
+	if _, err := f.ReadFrom(bufio.NewReader(file)); err != nil {
+		return nil, fmt.Errorf("reading filter %s: %w", path, err)
+	}
+
+	return &f, nil
+}
+
+// Add puts the item into the filter.
+func (f *Filter) Add(item string) {
+	h1, h2 := hashes(item)
+	for i := uint32(0); i < f.k; i++ {
+		bit := (h1 + uint64(i)*h2) % f.m
+		f.bits[bit/64] |= 1 << (bit % 64)
+	}
+}
+
+// Contains reports whether the item was probably added to the filter. An
+// item that was added is always reported.
+func (f *Filter) Contains(item string) bool {
+	h1, h2 := hashes(item)
+	for i := uint32(0); i < f.k; i++ {
+		bit := (h1 + uint64(i)*h2) % f.m
+		if f.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// WriteTo implements the io.WriterTo interface.
+func (f *Filter) WriteTo(w io.Writer) (int64, error) {
+	header := make([]byte, 16)
+	copy(header, magic)
+	binary.LittleEndian.PutUint32(header[4:], f.k)
+	binary.LittleEndian.PutUint64(header[8:], f.m)
+
+	n, err := w.Write(header)
+	if err != nil {
+		return int64(n), err
+	}
+
+	data := make([]byte, 8*len(f.bits))
+	for i, word := range f.bits {
+		binary.LittleEndian.PutUint64(data[8*i:], word)
+	}
+
+	written, err := w.Write(data)
+	return int64(n + written), err
+}
+
+// ReadFrom implements the io.ReaderFrom interface.
+func (f *Filter) ReadFrom(r io.Reader) (int64, error) {
+	header := make([]byte, 16)
+	n, err := io.ReadFull(r, header)
+	if err != nil {
+		return int64(n), ErrFormat
+	}
+	if !bytes.Equal(header[:4], magic) {
+		return int64(n), ErrFormat
+	}
+
+	k := binary.LittleEndian.Uint32(header[4:])
+	m := binary.LittleEndian.Uint64(header[8:])
+	if k == 0 || m == 0 {
+		return int64(n), ErrFormat
+	}
+
+	data := make([]byte, 8*((m+63)/64))
+	read, err := io.ReadFull(r, data)
+	if err != nil {
+		return int64(n + read), ErrFormat
+	}
+
+	f.m = m
+	f.k = k
+	f.bits = make([]uint64, len(data)/8)
+	for i := range f.bits {
+		f.bits[i] = binary.LittleEndian.Uint64(data[8*i:])
+	}
+
+	return int64(n + read), nil
+}
+
+// hashes derives the two hashes the bit positions are computed from.
+func hashes(item string) (uint64, uint64) {
+	h := fnv.New64a()
+	h.Write([]byte(item))
+	sum := h.Sum64()
+
+	// The second hash has to be odd so it doesn't collapse the positions.
+	return sum, (sum>>32 | sum<<32) | 1
+}
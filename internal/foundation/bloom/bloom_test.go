@@ -0,0 +1,115 @@
+package bloom_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/AgeroFlynn/crud/internal/foundation/bloom"
+	"testing"
+)
+
+// Success and failure markers.
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+func TestFilter(t *testing.T) {
+	const (
+		items = 10000
+		rate  = 0.01
+	)
+
+	f := bloom.New(items, rate)
+	for i := 0; i < items; i++ {
+		f.Add(fmt.Sprintf("added-%d", i))
+	}
+
+	t.Log("Given the need to look up items in a bloom filter.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen looking up the added items.", testID)
+		{
+			for i := 0; i < items; i++ {
+				if item := fmt.Sprintf("added-%d", i); !f.Contains(item) {
+					t.Fatalf("\t%s\tTest %d:\tShould report every added item : missing %s.", failed, testID, item)
+				}
+			}
+			t.Logf("\t%s\tTest %d:\tShould report every added item.", success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen looking up items that weren't added.", testID)
+		{
+			var positives int
+			for i := 0; i < items; i++ {
+				if f.Contains(fmt.Sprintf("other-%d", i)) {
+					positives++
+				}
+			}
+
+			// Leave room for the randomness of the items.
+			if got := float64(positives) / items; got > 2*rate {
+				t.Fatalf("\t%s\tTest %d:\tShould keep the false positive rate near %v : got %v.", failed, testID, rate, got)
+			}
+			t.Logf("\t%s\tTest %d:\tShould keep the false positive rate near %v.", success, testID, rate)
+		}
+
+		testID = 2
+		t.Logf("\tTest %d:\tWhen storing the filter.", testID)
+		{
+			var buf bytes.Buffer
+			written, err := f.WriteTo(&buf)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to write the filter : %s.", failed, testID, err)
+			}
+			if written != int64(buf.Len()) {
+				t.Fatalf("\t%s\tTest %d:\tShould count the written bytes : got %d, exp %d.", failed, testID, written, buf.Len())
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to write the filter.", success, testID)
+
+			stored := buf.Bytes()
+
+			var got bloom.Filter
+			read, err := got.ReadFrom(bytes.NewReader(stored))
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to read the filter : %s.", failed, testID, err)
+			}
+			if read != written {
+				t.Fatalf("\t%s\tTest %d:\tShould count the read bytes : got %d, exp %d.", failed, testID, read, written)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to read the filter.", success, testID)
+
+			for i := 0; i < items; i++ {
+				if item := fmt.Sprintf("added-%d", i); !got.Contains(item) {
+					t.Fatalf("\t%s\tTest %d:\tShould report every added item after reading : missing %s.", failed, testID, item)
+				}
+			}
+			for i := 0; i < items; i++ {
+				if item := fmt.Sprintf("other-%d", i); got.Contains(item) != f.Contains(item) {
+					t.Fatalf("\t%s\tTest %d:\tShould answer like the stored filter : differs for %s.", failed, testID, item)
+				}
+			}
+			t.Logf("\t%s\tTest %d:\tShould answer like the stored filter.", success, testID)
+		}
+
+		testID = 3
+		t.Logf("\tTest %d:\tWhen reading data that isn't a filter.", testID)
+		{
+			var got bloom.Filter
+			if _, err := got.ReadFrom(bytes.NewReader([]byte("not a filter at all"))); !errors.Is(err, bloom.ErrFormat) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT accept the data : %v.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT accept the data.", success, testID)
+
+			var buf bytes.Buffer
+			if _, err := f.WriteTo(&buf); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := got.ReadFrom(bytes.NewReader(buf.Bytes()[:buf.Len()-1])); !errors.Is(err, bloom.ErrFormat) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT accept a truncated filter : %v.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT accept a truncated filter.", success, testID)
+		}
+	}
+}
@@ -20,21 +20,80 @@ type Config struct {
 		ShutdownTimeout time.Duration `conf:"default:20s" yaml:"shutdownTimeout"`
 		APIHost         string        `conf:"default:0.0.0.0:3000" yaml:"APIHost"`
 		DebugHost       string        `conf:"default:0.0.0.0:4000" yaml:"debugHost"`
+		PublicURL       string        `conf:"default:http://localhost:3000" yaml:"publicURL"`
+		RequireIfMatch  bool          `conf:"default:false" yaml:"requireIfMatch"`
 	}
 	Auth struct {
-		KeysFolder string `conf:"default:resources/keys/" yaml:"keysFolder"`
-		ActiveKID  string `conf:"default:54bb2165-71e1-41a6-af3e-7da4a0e1e2c1" yaml:"activeKID"`
+		KeysFolder      string        `conf:"default:resources/keys/" yaml:"keysFolder"`
+		ActiveKID       string        `conf:"default:54bb2165-71e1-41a6-af3e-7da4a0e1e2c1" yaml:"activeKID"`
+		LowercaseEmails bool          `conf:"default:false" yaml:"lowercaseEmails"`
+		RequireVerified bool          `conf:"default:false" yaml:"requireVerified"`
+		StatusCacheTTL  time.Duration `conf:"default:30s" yaml:"statusCacheTTL"`
+		PolicyFile      string        `yaml:"policyFile"`
+	}
+	Lockout struct {
+		AccountThreshold    int           `conf:"default:5" yaml:"accountThreshold"`
+		AddrThreshold       int           `conf:"default:50" yaml:"addrThreshold"`
+		Base                time.Duration `conf:"default:30s" yaml:"base"`
+		Max                 time.Duration `conf:"default:1h" yaml:"max"`
+		Reset               time.Duration `conf:"default:15m" yaml:"reset"`
+		ResetEmailThreshold int           `conf:"default:3" yaml:"resetEmailThreshold"`
+		ResetAddrThreshold  int           `conf:"default:20" yaml:"resetAddrThreshold"`
+	}
+	Password struct {
+		MinLength      int    `conf:"default:8" yaml:"minLength"`
+		MinClasses     int    `conf:"default:2" yaml:"minClasses"`
+		History        int    `conf:"default:5" yaml:"history"`
+		ForbidPersonal bool   `conf:"default:true" yaml:"forbidPersonal"`
+		BreachedFile   string `yaml:"breachedFile"`
+		Hasher         string `conf:"default:argon2id,help:argon2id or bcrypt" yaml:"hasher"`
+		Argon2Time     uint32 `conf:"default:2" yaml:"argon2Time"`
+		Argon2Memory   uint32 `conf:"default:19456" yaml:"argon2Memory"`
+		Argon2Threads  uint8  `conf:"default:1" yaml:"argon2Threads"`
+		BcryptCost     int    `conf:"default:10" yaml:"bcryptCost"`
+	}
+	Mail struct {
+		Sender       string        `conf:"default:stdout,help:smtp stdout or file" yaml:"sender"`
+		From         string        `conf:"default:noreply@localhost" yaml:"from"`
+		SMTPHost     string        `conf:"default:localhost" yaml:"smtpHost"`
+		SMTPPort     int           `conf:"default:587" yaml:"smtpPort"`
+		SMTPUser     string        `yaml:"smtpUser"`
+		SMTPPassword string        `conf:"mask" yaml:"smtpPassword"`
+		DropFolder   string        `conf:"default:mail/" yaml:"dropFolder"`
+		VerifyURL    string        `conf:"default:http://localhost:3000/verify" yaml:"verifyURL"`
+		ResetURL     string        `conf:"default:http://localhost:3000/reset" yaml:"resetURL"`
+		QueueSize    int           `conf:"default:100" yaml:"queueSize"`
+		Workers      int           `conf:"default:2" yaml:"workers"`
+		Timeout      time.Duration `conf:"default:30s" yaml:"timeout"`
+	}
+	Outbox struct {
+		Interval    time.Duration `conf:"default:1s" yaml:"interval"`
+		BatchSize   int           `conf:"default:100" yaml:"batchSize"`
+		MaxAttempts int           `conf:"default:10" yaml:"maxAttempts"`
+		Backoff     time.Duration `conf:"default:1s" yaml:"backoff"`
+		MaxBackoff  time.Duration `conf:"default:5m" yaml:"maxBackoff"`
+		Lease       time.Duration `conf:"default:1m" yaml:"lease"`
+	}
+	Webhook struct {
+		Interval     time.Duration `conf:"default:1s" yaml:"interval"`
+		BatchSize    int           `conf:"default:50" yaml:"batchSize"`
+		MaxAttempts  int           `conf:"default:8" yaml:"maxAttempts"`
+		Backoff      time.Duration `conf:"default:10s" yaml:"backoff"`
+		MaxBackoff   time.Duration `conf:"default:1h" yaml:"maxBackoff"`
+		Timeout      time.Duration `conf:"default:10s" yaml:"timeout"`
+		AllowPrivate bool          `conf:"default:false" yaml:"allowPrivate"`
 	}
 	DB struct {
-		User        string `conf:"default:postgres"`
-		Password    string `conf:"default:postgres,mask"`
-		Host        string `conf:"default:localhost"`
-		Port        string `conf:"default:5432"`
-		Name        string `conf:"default:postgres"`
-		PoolSize    int    `conf:"default:50"`
-		MaxIdleCons int    `conf:"default:0" yaml:"maxIdleCons"`
-		MaxOpenCons int    `conf:"default:0" yaml:"maxOpenCons"`
-		DisableTLS  bool   `conf:"default:true"`
+		User         string        `conf:"default:postgres"`
+		Password     string        `conf:"default:postgres,mask"`
+		Host         string        `conf:"default:localhost"`
+		Port         string        `conf:"default:5432"`
+		Name         string        `conf:"default:postgres"`
+		PoolSize     int           `conf:"default:50"`
+		MaxIdleCons  int           `conf:"default:0" yaml:"maxIdleCons"`
+		MaxOpenCons  int           `conf:"default:0" yaml:"maxOpenCons"`
+		QueryTimeout time.Duration `conf:"default:5s" yaml:"queryTimeout"`
+		DisableTLS   bool          `conf:"default:true"`
 	}
 }
 
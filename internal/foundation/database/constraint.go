@@ -0,0 +1,89 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"github.com/go-pg/pg/v10"
+	"regexp"
+)
+
+// Set of error variables for violated integrity constraints.
+var (
+	ErrDuplicate  = errors.New("duplicate value")
+	ErrForeignKey = errors.New("referenced value does not exist")
+	ErrCheck      = errors.New("value is not allowed")
+)
+
+// Set of Postgres error codes of integrity constraint violations.
+const (
+	codeForeignKeyViolation = "23503"
+	codeUniqueViolation     = "23505"
+	codeCheckViolation      = "23514"
+)
+
+// Set of fields of a Postgres error.
+const (
+	fieldCode       = 'C'
+	fieldDetail     = 'D'
+	fieldConstraint = 'n'
+)
+
+// detailKey extracts the first column from the detail of a violation, for
+// example `Key (email)=(admin@example.com) already exists.`. Columns of an
+// index on an expression are reported inside the expression, like
+// `Key (lower(email))=(...)`.
+var detailKey = regexp.MustCompile(`^Key \((?:\w+\()*(\w+)`)
+
+// ConstraintError is returned when a query violates an integrity constraint.
+// It wraps one of ErrDuplicate, ErrForeignKey or ErrCheck and carries the
+// column the constraint is on when Postgres reports it.
+type ConstraintError struct {
+	Err        error
+	Field      string
+	Constraint string
+}
+
+// Error implements the error interface.
+func (e *ConstraintError) Error() string {
+	if e.Field == "" {
+		return fmt.Sprintf("%s: %s", e.Err, e.Constraint)
+	}
+	return fmt.Sprintf("%s: %s", e.Err, e.Field)
+}
+
+// Unwrap returns the kind of the violation so it can be checked with
+// errors.Is.
+func (e *ConstraintError) Unwrap() error {
+	return e.Err
+}
+
+// MapError converts integrity constraint violations reported by Postgres
+// into a ConstraintError. Any other error is returned as is.
+func MapError(err error) error {
+	var pgErr pg.Error
+	if !errors.As(err, &pgErr) {
+		return err
+	}
+
+	var kind error
+	switch pgErr.Field(fieldCode) {
+	case codeUniqueViolation:
+		kind = ErrDuplicate
+	case codeForeignKeyViolation:
+		kind = ErrForeignKey
+	case codeCheckViolation:
+		kind = ErrCheck
+	default:
+		return err
+	}
+
+	ce := ConstraintError{
+		Err:        kind,
+		Constraint: pgErr.Field(fieldConstraint),
+	}
+	if m := detailKey.FindStringSubmatch(pgErr.Field(fieldDetail)); m != nil {
+		ce.Field = m[1]
+	}
+
+	return &ce
+}
@@ -15,6 +15,7 @@ var (
 	ErrInvalidID             = errors.New("ID is not in its proper form")
 	ErrAuthenticationFailure = errors.New("authentication failed")
 	ErrForbidden             = errors.New("attempted action is not allowed")
+	ErrConflict              = errors.New("data was modified by someone else")
 )
 
 func NewPostgresConnection(options *pg.Options) (*pg.DB, error) {
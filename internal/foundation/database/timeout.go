@@ -0,0 +1,72 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/go-pg/pg/v10"
+	"time"
+)
+
+// Set of error variables for queries that didn't run to completion.
+var (
+	ErrTimeout  = errors.New("query deadline exceeded")
+	ErrCanceled = errors.New("query canceled")
+)
+
+// cancelKey is the key the cancel function of a query deadline is stashed
+// under in the query event.
+type cancelKey struct{}
+
+// queryTimeout is a query hook binding a deadline to every query that was
+// started with a context without one.
+type queryTimeout struct {
+	timeout time.Duration
+}
+
+// WithQueryTimeout registers a hook on the db that applies the default
+// timeout to queries whose context has no deadline and maps queries stopped
+// by their context to ErrTimeout or ErrCanceled. A zero timeout only maps
+// the errors.
+func WithQueryTimeout(db *pg.DB, timeout time.Duration) {
+	db.AddQueryHook(queryTimeout{timeout: timeout})
+}
+
+// BeforeQuery applies the default deadline to the query context.
+func (h queryTimeout) BeforeQuery(ctx context.Context, evt *pg.QueryEvent) (context.Context, error) {
+	if h.timeout <= 0 {
+		return ctx, nil
+	}
+	if _, ok := ctx.Deadline(); ok {
+		return ctx, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	if evt.Stash == nil {
+		evt.Stash = make(map[interface{}]interface{})
+	}
+	evt.Stash[cancelKey{}] = cancel
+
+	return ctx, nil
+}
+
+// AfterQuery releases the deadline and replaces the error of a query that
+// was stopped by its context.
+func (h queryTimeout) AfterQuery(ctx context.Context, evt *pg.QueryEvent) error {
+	if cancel, ok := evt.Stash[cancelKey{}].(context.CancelFunc); ok {
+		defer cancel()
+	}
+
+	if evt.Err == nil {
+		return nil
+	}
+
+	switch ctx.Err() {
+	case context.DeadlineExceeded:
+		return fmt.Errorf("%w: %v", ErrTimeout, evt.Err)
+	case context.Canceled:
+		return fmt.Errorf("%w: %v", ErrCanceled, evt.Err)
+	}
+
+	return nil
+}
@@ -0,0 +1,61 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+	"go.uber.org/zap"
+)
+
+// txKey is the key the running transaction is stored under in a context.
+type txKey struct{}
+
+// WithinTran runs fn inside a transaction. The context handed to fn carries
+// the transaction so every store using Conn joins it. The transaction is
+// committed when fn returns nil and rolled back when fn fails or panics.
+// Calling WithinTran with a context already carrying a transaction runs fn
+// inside that transaction.
+func WithinTran(ctx context.Context, log *zap.SugaredLogger, db *pg.DB, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(*pg.Tx); ok {
+		return fn(ctx)
+	}
+
+	tx, err := db.BeginContext(ctx)
+	if err != nil {
+		return fmt.Errorf("begin tran: %w", err)
+	}
+
+	// Rollback on failure or panic, the panic carries on up the stack.
+	committed := false
+	defer func() {
+		if committed {
+			return
+		}
+		if rerr := tx.RollbackContext(context.Background()); rerr != nil {
+			log.Errorw("rollback tran", "ERROR", rerr)
+		}
+	}()
+
+	if err := fn(context.WithValue(ctx, txKey{}, tx)); err != nil {
+		return fmt.Errorf("exec tran: %w", err)
+	}
+
+	// A failed commit leaves nothing to roll back.
+	committed = true
+	if err := tx.CommitContext(ctx); err != nil {
+		return fmt.Errorf("commit tran: %w", err)
+	}
+
+	return nil
+}
+
+// Conn returns the transaction carried by the context or the db when there
+// is none. Stores use it to run their queries inside a unit of work opened
+// with WithinTran.
+func Conn(ctx context.Context, db *pg.DB) orm.DB {
+	if tx, ok := ctx.Value(txKey{}).(*pg.Tx); ok {
+		return tx
+	}
+	return db
+}
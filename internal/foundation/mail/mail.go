@@ -0,0 +1,155 @@
+// Package mail provides support for sending emails. Messages are sent over
+// SMTP in production, and printed or dropped into a folder to work offline.
+package mail
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"io"
+	"mime"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Message is an email with a plain text body.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Sender is the behavior required to deliver emails.
+type Sender interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// =============================================================================
+
+// SMTP delivers emails to an SMTP server.
+type SMTP struct {
+	addr string
+	from string
+	auth smtp.Auth
+}
+
+// NewSMTP constructs a sender for the SMTP server. The server is only
+// authenticated against when a username is provided.
+func NewSMTP(host string, port int, username string, password string, from string) *SMTP {
+	s := SMTP{
+		addr: host + ":" + strconv.Itoa(port),
+		from: from,
+	}
+	if username != "" {
+		s.auth = smtp.PlainAuth("", username, password, host)
+	}
+
+	return &s
+}
+
+// Send implements the Sender interface.
+func (s *SMTP) Send(ctx context.Context, msg Message) error {
+	data := format(s.from, msg, time.Now())
+
+	// The smtp package doesn't support contexts, at least don't start
+	// sending a message nobody waits for anymore.
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err := smtp.SendMail(s.addr, s.auth, s.from, []string{msg.To}, data); err != nil {
+		return fmt.Errorf("sending mail to %s: %w", msg.To, err)
+	}
+
+	return nil
+}
+
+// =============================================================================
+
+// Writer writes emails to a writer, like stdout during development.
+type Writer struct {
+	mu   sync.Mutex
+	w    io.Writer
+	from string
+}
+
+// NewWriter constructs a sender writing the emails to w.
+func NewWriter(w io.Writer, from string) *Writer {
+	return &Writer{
+		w:    w,
+		from: from,
+	}
+}
+
+// NewStdout constructs a sender printing the emails to stdout.
+func NewStdout(from string) *Writer {
+	return NewWriter(os.Stdout, from)
+}
+
+// Send implements the Sender interface.
+func (s *Writer) Send(ctx context.Context, msg Message) error {
+	data := format(s.from, msg, time.Now())
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := fmt.Fprintf(s.w, "%s\r\n\r\n", data); err != nil {
+		return fmt.Errorf("writing mail to %s: %w", msg.To, err)
+	}
+
+	return nil
+}
+
+// =============================================================================
+
+// FileDrop stores every email as a file in a folder.
+type FileDrop struct {
+	dir  string
+	from string
+}
+
+// NewFileDrop constructs a sender dropping the emails as .eml files into the
+// folder.
+func NewFileDrop(dir string, from string) *FileDrop {
+	return &FileDrop{
+		dir:  dir,
+		from: from,
+	}
+}
+
+// Send implements the Sender interface.
+func (s *FileDrop) Send(ctx context.Context, msg Message) error {
+	now := time.Now()
+	data := format(s.from, msg, now)
+
+	// The timestamp keeps the files sorted by the time they were sent.
+	name := fmt.Sprintf("%s-%s.eml", now.UTC().Format("20060102T150405.000000000"), uuid.NewString())
+	if err := os.WriteFile(filepath.Join(s.dir, name), data, 0o600); err != nil {
+		return fmt.Errorf("dropping mail to %s: %w", msg.To, err)
+	}
+
+	return nil
+}
+
+// =============================================================================
+
+// format renders the message in the internet message format.
+func format(from string, msg Message, now time.Time) []byte {
+	var b bytes.Buffer
+
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(msg.Body)
+
+	return b.Bytes()
+}
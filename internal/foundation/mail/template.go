@@ -0,0 +1,73 @@
+package mail
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+	"text/template"
+)
+
+// Templates renders messages from text templates. Every template defines
+// the subject of the message in a nested "subject" template, the rest of
+// the template is the body:
+//
+//	{{define "subject"}}Welcome {{.Name}}{{end}}
+//	Hello {{.Name}}, ...
+type Templates struct {
+	set map[string]*template.Template
+}
+
+// ParseTemplates reads the templates matching the pattern from fsys. The
+// templates are addressed by their file name.
+func ParseTemplates(fsys fs.FS, pattern string) (*Templates, error) {
+	files, err := fs.Glob(fsys, pattern)
+	if err != nil {
+		return nil, fmt.Errorf("listing templates: %w", err)
+	}
+
+	// Every template defines its own subject so they are parsed separately.
+	t := Templates{
+		set: make(map[string]*template.Template),
+	}
+	for _, file := range files {
+		tmpl, err := template.ParseFS(fsys, file)
+		if err != nil {
+			return nil, fmt.Errorf("parsing template %s: %w", file, err)
+		}
+		if tmpl.Lookup("subject") == nil {
+			return nil, fmt.Errorf("template %s has no subject", file)
+		}
+		t.set[path.Base(file)] = tmpl
+	}
+
+	return &t, nil
+}
+
+// Render executes the named template with the data and builds the message
+// to the recipient.
+func (t *Templates) Render(name string, to string, data interface{}) (Message, error) {
+	tmpl, exists := t.set[name]
+	if !exists {
+		return Message{}, fmt.Errorf("template %s not found", name)
+	}
+
+	var subject bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return Message{}, fmt.Errorf("rendering subject of %s: %w", name, err)
+	}
+
+	var body bytes.Buffer
+	if err := tmpl.Execute(&body, data); err != nil {
+		return Message{}, fmt.Errorf("rendering body of %s: %w", name, err)
+	}
+
+	msg := Message{
+		To:      to,
+		Subject: strings.TrimSpace(subject.String()),
+		Body:    strings.TrimLeft(body.String(), "\r\n"),
+	}
+
+	return msg, nil
+}
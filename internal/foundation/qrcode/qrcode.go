@@ -0,0 +1,127 @@
+// Package qrcode provides support for rendering QR codes as PNG or SVG
+// images. The encoding is done in process.
+package qrcode
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/skip2/go-qrcode"
+)
+
+// Set of content types for the supported image formats.
+const (
+	ContentTypePNG = "image/png"
+	ContentTypeSVG = "image/svg+xml"
+)
+
+// Size limits in pixels for the rendered images.
+const (
+	MinSize     = 64
+	MaxSize     = 2048
+	DefaultSize = 256
+)
+
+// levels maps the error correction level names used by the QR specification
+// to the recovery levels of the encoder.
+var levels = map[string]qrcode.RecoveryLevel{
+	"L": qrcode.Low,
+	"M": qrcode.Medium,
+	"Q": qrcode.High,
+	"H": qrcode.Highest,
+}
+
+// Options controls how a QR code is rendered.
+type Options struct {
+	Size  int
+	Level string
+}
+
+// PNG renders the content as a QR code in the PNG format.
+func PNG(content string, opt Options) ([]byte, error) {
+	q, err := encode(content, opt)
+	if err != nil {
+		return nil, err
+	}
+
+	png, err := q.PNG(size(opt))
+	if err != nil {
+		return nil, fmt.Errorf("rendering png: %w", err)
+	}
+
+	return png, nil
+}
+
+// SVG renders the content as a QR code in the SVG format.
+func SVG(content string, opt Options) ([]byte, error) {
+	q, err := encode(content, opt)
+	if err != nil {
+		return nil, err
+	}
+
+	bitmap := q.Bitmap()
+	modules := len(bitmap)
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`,
+		size(opt), size(opt), modules, modules)
+	fmt.Fprintf(&b, `<rect width="%d" height="%d" fill="#ffffff"/>`, modules, modules)
+
+	// Draw every dark module as part of a single path to keep the document
+	// small.
+	var path strings.Builder
+	for y, row := range bitmap {
+		for x, dark := range row {
+			if dark {
+				fmt.Fprintf(&path, "M%d %dh1v1h-1z", x, y)
+			}
+		}
+	}
+	fmt.Fprintf(&b, `<path d="%s" fill="#000000"/>`, path.String())
+	b.WriteString(`</svg>`)
+
+	return b.Bytes(), nil
+}
+
+// ValidLevel reports whether the error correction level is supported. An
+// empty level is valid and uses the default.
+func ValidLevel(level string) bool {
+	if level == "" {
+		return true
+	}
+	_, ok := levels[strings.ToUpper(level)]
+	return ok
+}
+
+// encode constructs the QR code for the content.
+func encode(content string, opt Options) (*qrcode.QRCode, error) {
+	level := qrcode.Medium
+	if opt.Level != "" {
+		l, ok := levels[strings.ToUpper(opt.Level)]
+		if !ok {
+			return nil, fmt.Errorf("unknown error correction level %q", opt.Level)
+		}
+		level = l
+	}
+
+	q, err := qrcode.New(content, level)
+	if err != nil {
+		return nil, fmt.Errorf("encoding content: %w", err)
+	}
+
+	return q, nil
+}
+
+// size returns the requested size clamped to the supported range.
+func size(opt Options) int {
+	switch {
+	case opt.Size == 0:
+		return DefaultSize
+	case opt.Size < MinSize:
+		return MinSize
+	case opt.Size > MaxSize:
+		return MaxSize
+	}
+	return opt.Size
+}
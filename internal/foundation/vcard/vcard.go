@@ -0,0 +1,72 @@
+// Package vcard provides support for encoding contact cards in the vCard
+// format (RFC 6350).
+package vcard
+
+import (
+	"bytes"
+	"strings"
+)
+
+// ContentType is the media type to use when responding with a vCard.
+const ContentType = "text/vcard; charset=utf-8"
+
+// Card represents the set of contact information that can be encoded.
+type Card struct {
+	UID   string
+	Name  string
+	Email string
+}
+
+// Encode converts the card into a vCard 4.0 document.
+func Encode(c Card) []byte {
+	var b bytes.Buffer
+
+	writeLine(&b, "BEGIN", "VCARD")
+	writeLine(&b, "VERSION", "4.0")
+	writeLine(&b, "FN", escape(c.Name))
+	writeLine(&b, "N", escape(c.Name)+";;;;")
+	if c.Email != "" {
+		writeLine(&b, "EMAIL", escape(c.Email))
+	}
+	if c.UID != "" {
+		writeLine(&b, "UID", "urn:uuid:"+c.UID)
+	}
+	writeLine(&b, "END", "VCARD")
+
+	return b.Bytes()
+}
+
+// writeLine writes a single content line terminated by CRLF. Lines longer
+// than 75 octets are folded as required by the specification.
+func writeLine(b *bytes.Buffer, name string, value string) {
+	line := name + ":" + value
+
+	const limit = 75
+	for len(line) > limit {
+		cut := limit
+
+		// Don't split a multi-byte UTF-8 sequence.
+		for cut > 0 && line[cut]&0xC0 == 0x80 {
+			cut--
+		}
+
+		b.WriteString(line[:cut])
+		b.WriteString("\r\n ")
+		line = line[cut:]
+	}
+
+	b.WriteString(line)
+	b.WriteString("\r\n")
+}
+
+// escape escapes the characters that have a special meaning inside a text
+// value.
+func escape(s string) string {
+	r := strings.NewReplacer(
+		`\`, `\\`,
+		",", `\,`,
+		";", `\;`,
+		"\n", `\n`,
+	)
+	return r.Replace(s)
+}
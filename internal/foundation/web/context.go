@@ -17,6 +17,7 @@ type Values struct {
 	TraceID    string
 	Now        time.Time
 	StatusCode int
+	RemoteAddr string
 }
 
 // GetValues returns the values from the context.
@@ -0,0 +1,37 @@
+package web
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ETag formats a version number as a strong entity tag.
+func ETag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// SetETag sets the entity tag of the response.
+func SetETag(w http.ResponseWriter, version int) {
+	w.Header().Set("ETag", ETag(version))
+}
+
+// IfMatch returns the entity tags listed in the If-Match header of the
+// request with the quotes and weak markers removed. The second value is
+// false when the header is missing.
+func IfMatch(r *http.Request) ([]string, bool) {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		return nil, false
+	}
+
+	var tags []string
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		tag = strings.TrimPrefix(tag, "W/")
+		tag = strings.Trim(tag, `"`)
+		tags = append(tags, tag)
+	}
+
+	return tags, true
+}
@@ -0,0 +1,60 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	jsonpatch "github.com/evanphx/json-patch"
+	"io"
+	"mime"
+	"net/http"
+)
+
+// Set of media types accepted for patch requests.
+const (
+	MergePatchType = "application/merge-patch+json"
+	JSONPatchType  = "application/json-patch+json"
+)
+
+// Set of error variables for applying patches.
+var (
+	ErrUnsupportedPatch = errors.New("unsupported patch media type")
+	ErrInvalidPatch     = errors.New("patch document is not valid")
+	ErrPatchFailed      = errors.New("patch can't be applied")
+)
+
+// ApplyPatch reads the patch document from the body of the request and
+// applies it to the provided JSON document. The Content-Type of the request
+// selects between JSON Merge Patch (RFC 7396) and JSON Patch (RFC 6902).
+func ApplyPatch(r *http.Request, doc []byte) ([]byte, error) {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedPatch, err)
+	}
+
+	patch, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading patch: %w", err)
+	}
+
+	switch mediaType {
+	case MergePatchType:
+		patched, err := jsonpatch.MergePatch(doc, patch)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+		}
+		return patched, nil
+
+	case JSONPatchType:
+		ops, err := jsonpatch.DecodePatch(patch)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+		}
+		patched, err := ops.Apply(doc)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrPatchFailed, err)
+		}
+		return patched, nil
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrUnsupportedPatch, mediaType)
+}
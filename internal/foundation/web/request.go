@@ -11,12 +11,12 @@ import (
 func Param(r *http.Request, key string) (string, error) {
 	vars := mux.Vars(r)
 
-	id, ok := vars["id"]
+	val, ok := vars[key]
 	if !ok {
-		return "", fmt.Errorf("id is missing in path parameters")
+		return "", fmt.Errorf("%s is missing in path parameters", key)
 	}
 
-	return id, nil
+	return val, nil
 }
 
 // Decode reads the body of an HTTP request looking for a JSON document. The
@@ -37,3 +37,25 @@ func Respond(ctx context.Context, w http.ResponseWriter, data any, statusCode in
 
 	return nil
 }
+
+// RespondRaw sends already encoded data to the client using the provided
+// content type. It is used for responses that are not JSON like images or
+// vCards.
+func RespondRaw(ctx context.Context, w http.ResponseWriter, data []byte, contentType string, statusCode int) error {
+
+	// Set the status code for the request logger middleware.
+	SetStatusCode(ctx, statusCode)
+
+	// Set the content type and headers.
+	w.Header().Set("Content-Type", contentType)
+
+	// Write the status code to the response.
+	w.WriteHeader(statusCode)
+
+	// Send the result back to the client.
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+
+	return nil
+}
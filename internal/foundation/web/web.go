@@ -65,8 +65,9 @@ func (a *App) Handle(method string, group string, path string, handler Handler,
 		// Set the context with the required values to
 		// process the request.
 		v := Values{
-			TraceID: uuid.New().String(),
-			Now:     time.Now().UTC(),
+			TraceID:    uuid.New().String(),
+			Now:        time.Now().UTC(),
+			RemoteAddr: r.RemoteAddr,
 		}
 		ctx = context.WithValue(ctx, key, &v)
 
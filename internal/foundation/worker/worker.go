@@ -0,0 +1,95 @@
+// Package worker provides a bounded queue of jobs which are run in the
+// background after the requests submitting them are answered.
+package worker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrFull is returned when a job is submitted to a full queue.
+var ErrFull = errors.New("queue is full")
+
+// Job is the work submitted to the queue. The context is canceled when the
+// job runs out of time.
+type Job func(ctx context.Context)
+
+// Queue holds up to a fixed number of jobs which a fixed number of workers
+// run. Jobs are dropped rather than piling up when the queue is full.
+type Queue struct {
+	jobs    chan Job
+	workers int
+	timeout time.Duration
+}
+
+// NewQueue constructs a queue holding up to size jobs run by the workers.
+// Every job is given up to timeout to run.
+func NewQueue(size int, workers int, timeout time.Duration) *Queue {
+	if size < 1 {
+		size = 1
+	}
+	if workers < 1 {
+		workers = 1
+	}
+
+	return &Queue{
+		jobs:    make(chan Job, size),
+		workers: workers,
+		timeout: timeout,
+	}
+}
+
+// Submit queues the job, it fails with ErrFull when the queue is full.
+func (q *Queue) Submit(job Job) error {
+	select {
+	case q.jobs <- job:
+		return nil
+	default:
+		return ErrFull
+	}
+}
+
+// Run runs the queued jobs until the context is canceled. The jobs still
+// queued by then are run before it returns, so they aren't lost when the
+// service shuts down. Stop submitting jobs before canceling the context.
+func (q *Queue) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	wg.Add(q.workers)
+	for i := 0; i < q.workers; i++ {
+		go func() {
+			defer wg.Done()
+			q.work(ctx)
+		}()
+	}
+	wg.Wait()
+}
+
+// work runs jobs until the context is canceled and the queue is drained.
+func (q *Queue) work(ctx context.Context) {
+	for {
+		select {
+		case job := <-q.jobs:
+			q.run(job)
+		case <-ctx.Done():
+			for {
+				select {
+				case job := <-q.jobs:
+					q.run(job)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// run runs the job within the timeout. The job isn't bound to the context
+// of Run, jobs drained during a shutdown get their full time.
+func (q *Queue) run(job Job) {
+	ctx, cancel := context.WithTimeout(context.Background(), q.timeout)
+	defer cancel()
+
+	job(ctx)
+}
@@ -0,0 +1,62 @@
+package worker_test
+
+import (
+	"context"
+	"errors"
+	"github.com/AgeroFlynn/crud/internal/foundation/worker"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Success and failure markers.
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+func TestQueue(t *testing.T) {
+	t.Log("Given the need to run jobs in the background.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen submitting more jobs than the queue holds.", testID)
+		{
+			q := worker.NewQueue(2, 1, time.Second)
+			job := func(ctx context.Context) {}
+
+			for i := 0; i < 2; i++ {
+				if err := q.Submit(job); err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould accept jobs while there is room : %v", failed, testID, err)
+				}
+			}
+			t.Logf("\t%s\tTest %d:\tShould accept jobs while there is room.", success, testID)
+
+			if err := q.Submit(job); !errors.Is(err, worker.ErrFull) {
+				t.Fatalf("\t%s\tTest %d:\tShould refuse jobs when full : %v", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould refuse jobs when full.", success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen shutting down with jobs still queued.", testID)
+		{
+			q := worker.NewQueue(10, 2, time.Second)
+
+			var ran int32
+			for i := 0; i < 10; i++ {
+				q.Submit(func(ctx context.Context) {
+					atomic.AddInt32(&ran, 1)
+				})
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			q.Run(ctx)
+
+			if n := atomic.LoadInt32(&ran); n != 10 {
+				t.Fatalf("\t%s\tTest %d:\tShould run every queued job : ran %d.", failed, testID, n)
+			}
+			t.Logf("\t%s\tTest %d:\tShould run every queued job.", success, testID)
+		}
+	}
+}
@@ -1,16 +1,40 @@
 package handlers
 
 import (
+	auditCore "github.com/AgeroFlynn/crud/internal/buisness/core/audit"
+	outboxCore "github.com/AgeroFlynn/crud/internal/buisness/core/outbox"
+	roleCore "github.com/AgeroFlynn/crud/internal/buisness/core/role"
+	shareCore "github.com/AgeroFlynn/crud/internal/buisness/core/sharelink"
 	userCore "github.com/AgeroFlynn/crud/internal/buisness/core/user"
+	webhookCore "github.com/AgeroFlynn/crud/internal/buisness/core/webhook"
+	"github.com/AgeroFlynn/crud/internal/buisness/repository/store/audit"
+	"github.com/AgeroFlynn/crud/internal/buisness/repository/store/login"
+	"github.com/AgeroFlynn/crud/internal/buisness/repository/store/outbox"
+	"github.com/AgeroFlynn/crud/internal/buisness/repository/store/role"
+	"github.com/AgeroFlynn/crud/internal/buisness/repository/store/sharelink"
+	"github.com/AgeroFlynn/crud/internal/buisness/repository/store/user"
+	"github.com/AgeroFlynn/crud/internal/buisness/repository/store/webhook"
 	"github.com/AgeroFlynn/crud/internal/buisness/sys/auth"
+	"github.com/AgeroFlynn/crud/internal/buisness/sys/lockout"
+	"github.com/AgeroFlynn/crud/internal/buisness/sys/passwd"
+	"github.com/AgeroFlynn/crud/internal/buisness/sys/policy"
 	"github.com/AgeroFlynn/crud/internal/buisness/web/mid"
+	"github.com/AgeroFlynn/crud/internal/foundation/mail"
 	"github.com/AgeroFlynn/crud/internal/foundation/web"
+	"github.com/AgeroFlynn/crud/internal/foundation/worker"
+	"github.com/AgeroFlynn/crud/internal/transport/rest/handlers/v1/auditgrp"
+	"github.com/AgeroFlynn/crud/internal/transport/rest/handlers/v1/logingrp"
+	"github.com/AgeroFlynn/crud/internal/transport/rest/handlers/v1/qrgrp"
+	"github.com/AgeroFlynn/crud/internal/transport/rest/handlers/v1/rolegrp"
+	"github.com/AgeroFlynn/crud/internal/transport/rest/handlers/v1/sharegrp"
 	"github.com/AgeroFlynn/crud/internal/transport/rest/handlers/v1/testgrp"
 	"github.com/AgeroFlynn/crud/internal/transport/rest/handlers/v1/usergrp"
+	"github.com/AgeroFlynn/crud/internal/transport/rest/handlers/v1/webhookgrp"
 	"github.com/go-pg/pg/v10"
 	"go.uber.org/zap"
 	"net/http"
 	"os"
+	"time"
 )
 
 // APIMuxConfig contains all the mandatory systems required by handlers.
@@ -19,6 +43,82 @@ type APIMuxConfig struct {
 	Log      *zap.SugaredLogger
 	Auth     *auth.Auth
 	DB       *pg.DB
+
+	// UserStore replaces the database backed user store when set.
+	UserStore userCore.UserStorer
+
+	// RoleStore replaces the database backed role store when set.
+	RoleStore roleCore.RoleStorer
+
+	// AuditStore replaces the database backed audit store when set.
+	AuditStore auditCore.AuditStorer
+
+	// LoginStore replaces the database backed login history store when set.
+	LoginStore userCore.LoginStorer
+
+	// ShareStore replaces the database backed share link store when set.
+	ShareStore shareCore.ShareStorer
+
+	// OutboxStore replaces the database backed outbox store when set. It
+	// has to be the store the relay dispatches the events from.
+	OutboxStore outboxCore.OutboxStorer
+
+	// WebhookStore replaces the database backed webhook store when set. It
+	// has to be the store the webhook sender sends the deliveries from.
+	WebhookStore webhookCore.WebhookStorer
+
+	// Relay dispatches the events of the outbox. The webhooks are subscribed
+	// to it when it is set, no deliveries are queued otherwise.
+	Relay *outboxCore.Relay
+
+	// Hasher hashes new passwords in the database backed user store. Argon2id
+	// with the default parameters is used when it is nil.
+	Hasher passwd.Hasher
+
+	// PublicURL is the address clients reach the service at. The share links
+	// encoded in QR codes point to it.
+	PublicURL string
+
+	// RequireIfMatch makes the If-Match header mandatory on modifications.
+	RequireIfMatch bool
+
+	// LowercaseEmails stores emails fully lowercased instead of only their
+	// domain.
+	LowercaseEmails bool
+
+	// Mail delivers the verification links to the VerifyURL page. Unverified
+	// users can't get a token when RequireVerified is set.
+	Mail            mail.Sender
+	VerifyURL       string
+	RequireVerified bool
+
+	// ResetURL is the page the password reset links point to. The links
+	// are mailed by the Background queue, which the caller runs and drains
+	// on shutdown. No links are sent without it.
+	ResetURL   string
+	Background *worker.Queue
+
+	// ResetEmailLimit and ResetAddrLimit throttle the requests for reset
+	// links per email and per remote address. A zero threshold disables
+	// them.
+	ResetEmailLimit lockout.Policy
+	ResetAddrLimit  lockout.Policy
+
+	// PasswordPolicy holds the rules new passwords have to follow.
+	PasswordPolicy passwd.Policy
+
+	// AccountLockout and AddrLockout lock out logins per email and per
+	// remote address after failures. A zero threshold disables them.
+	AccountLockout lockout.Policy
+	AddrLockout    lockout.Policy
+
+	// StatusCacheTTL is how long the status of users is cached when their
+	// tokens are checked. Nothing is cached when it is zero.
+	StatusCacheTTL time.Duration
+
+	// Policy decides who may act on users. The policies the service ships
+	// with are used when it is nil.
+	Policy *policy.Engine
 }
 
 // APIMux constructs a http.Handler with all application routes defined.
@@ -47,16 +147,180 @@ func v1(app *web.App, cfg APIMuxConfig) {
 	}
 	app.Handle(http.MethodGet, version, "/test", tgh.Test)
 
+	roles := cfg.RoleStore
+	if roles == nil {
+		roles = role.NewStore(cfg.Log, cfg.DB)
+	}
+	outboxes := cfg.OutboxStore
+	if outboxes == nil {
+		outboxes = outbox.NewStore(cfg.Log, cfg.DB)
+	}
+	evtCore := outboxCore.NewCore(cfg.Log, outboxes)
+
+	rolCore := roleCore.NewCore(cfg.Log, roles, evtCore)
+
+	webhooks := cfg.WebhookStore
+	if webhooks == nil {
+		webhooks = webhook.NewStore(cfg.Log, cfg.DB)
+	}
+	whkCore := webhookCore.NewCore(cfg.Log, webhooks)
+
+	if cfg.Relay != nil {
+		cfg.Relay.Subscribe(outboxCore.AllEvents, "webhooks", whkCore.Enqueue)
+	}
+
+	audits := cfg.AuditStore
+	if audits == nil {
+		audits = audit.NewStore(cfg.Log, cfg.DB)
+	}
+	audCore := auditCore.NewCore(cfg.Log, audits)
+
+	hasher := cfg.Hasher
+	if hasher == nil {
+		hasher = passwd.Argon2id{Params: passwd.DefaultArgon2Params}
+	}
+	users := cfg.UserStore
+	if users == nil {
+		users = user.NewStore(cfg.Log, cfg.DB, hasher)
+	}
+	logins := cfg.LoginStore
+	if logins == nil {
+		logins = login.NewStore(cfg.Log, cfg.DB)
+	}
+
+	// A nil queue must not end up as a non nil interface.
+	var background userCore.Queuer
+	if cfg.Background != nil {
+		background = cfg.Background
+	}
+
+	usrCore := userCore.NewCore(cfg.Log, users, userCore.Config{
+		LowercaseEmails: cfg.LowercaseEmails,
+		RequireVerified: cfg.RequireVerified,
+		Auth:            cfg.Auth,
+		Mail:            cfg.Mail,
+		VerifyURL:       cfg.VerifyURL,
+		ResetURL:        cfg.ResetURL,
+		Background:      background,
+		ResetEmailLimit: newCounter(cfg.ResetEmailLimit),
+		ResetAddrLimit:  newCounter(cfg.ResetAddrLimit),
+		Password:        cfg.PasswordPolicy,
+		AccountLockout:  newCounter(cfg.AccountLockout),
+		AddrLockout:     newCounter(cfg.AddrLockout),
+		StatusCacheTTL:  cfg.StatusCacheTTL,
+		Roles:           rolCore,
+		Policy:          cfg.Policy,
+		Audit:           audCore,
+		Events:          evtCore,
+		Logins:          logins,
+	})
+
+	// Tokens issued before a password reset or a change of roles and tokens
+	// of users who aren't active are rejected. The permissions of the roles
+	// are resolved for every request, so changes of roles apply right away.
+	authen := mid.Authenticate(cfg.Auth, usrCore, rolCore)
+
 	// Register user management and authentication endpoints.
 	ugh := usergrp.Handlers{
-		User: userCore.NewCore(cfg.Log, cfg.DB),
-		Auth: cfg.Auth,
+		Log:            cfg.Log,
+		User:           usrCore,
+		Auth:           cfg.Auth,
+		RequireIfMatch: cfg.RequireIfMatch,
 	}
 
+	// Register share link endpoints. Opening a share link doesn't require
+	// authentication, the signed token in the path is the credential.
+	shares := cfg.ShareStore
+	if shares == nil {
+		shares = sharelink.NewStore(cfg.Log, cfg.DB)
+	}
+	shrCore := shareCore.NewCore(cfg.Log, shares, cfg.Auth, users)
+	sgh := sharegrp.Handlers{
+		ShareLink: shrCore,
+	}
+
+	app.Handle(http.MethodPost, version, "/users/me/share", sgh.Create, authen)
+	app.Handle(http.MethodGet, version, "/users/me/share", sgh.FindMine, authen)
+	app.Handle(http.MethodDelete, version, "/users/me/share/{id}", sgh.Revoke, authen)
+	app.Handle(http.MethodGet, version, "/users/me/share/{id}/accesses", sgh.FindAccesses, authen)
+	app.Handle(http.MethodGet, version, "/share/{token}", sgh.Open)
+
+	// Register QR code rendering of contact cards.
+	qgh := qrgrp.Handlers{
+		User:      usrCore,
+		ShareLink: shrCore,
+		PublicURL: cfg.PublicURL,
+	}
+
+	app.Handle(http.MethodGet, version, "/users/{id}/qr.{format:png|svg}", qgh.QR, authen)
+
 	app.Handle(http.MethodGet, version, "/users/token", ugh.Token)
-	app.Handle(http.MethodGet, version, "/users", ugh.FindAll, mid.Authenticate(cfg.Auth), mid.Authorize(auth.RoleAdmin))
-	app.Handle(http.MethodGet, version, "/users/{id}", ugh.FindByID, mid.Authenticate(cfg.Auth))
-	app.Handle(http.MethodPost, version, "/users", ugh.Create, mid.Authenticate(cfg.Auth), mid.Authorize(auth.RoleAdmin))
-	app.Handle(http.MethodPut, version, "/users/{id}", ugh.Update, mid.Authenticate(cfg.Auth), mid.Authorize(auth.RoleAdmin))
-	app.Handle(http.MethodDelete, version, "/users/{id}", ugh.Delete, mid.Authenticate(cfg.Auth), mid.Authorize(auth.RoleAdmin))
+	app.Handle(http.MethodPost, version, "/users/verify", ugh.Verify)
+	app.Handle(http.MethodPost, version, "/auth/password/forgot", ugh.ForgotPassword)
+	app.Handle(http.MethodPost, version, "/auth/password/reset", ugh.ResetPassword)
+	app.Handle(http.MethodGet, version, "/users/me", ugh.FindMe, authen)
+	app.Handle(http.MethodPut, version, "/users/me", ugh.UpdateMe, authen)
+	app.Handle(http.MethodPost, version, "/users/me/password", ugh.ChangePassword, authen)
+	app.Handle(http.MethodGet, version, "/users", ugh.Query, authen, mid.RequirePermission(auth.PermUsersRead))
+	app.Handle(http.MethodGet, version, "/directory", ugh.Directory, authen)
+	app.Handle(http.MethodGet, version, "/users/{id}", ugh.FindByID, authen)
+	app.Handle(http.MethodPost, version, "/users", ugh.Create, authen, mid.RequirePermission(auth.PermUsersWrite))
+	app.Handle(http.MethodPost, version, "/users:batch", ugh.Batch, authen, mid.RequirePermission(auth.PermUsersWrite))
+	app.Handle(http.MethodPut, version, "/users/{id}", ugh.Update, authen, mid.RequirePermission(auth.PermUsersWrite))
+	app.Handle(http.MethodPatch, version, "/users/{id}", ugh.Patch, authen, mid.RequirePermission(auth.PermUsersWrite))
+	app.Handle(http.MethodDelete, version, "/users/{id}", ugh.Delete, authen, mid.RequirePermission(auth.PermUsersWrite))
+	app.Handle(http.MethodPost, version, "/users/{id}/unlock", ugh.Unlock, authen, mid.RequirePermission(auth.PermUsersWrite))
+	app.Handle(http.MethodPut, version, "/users/{id}/status", ugh.SetStatus, authen, mid.RequirePermission(auth.PermUsersWrite))
+	app.Handle(http.MethodPost, version, "/policy/explain", ugh.Explain, authen, mid.RequirePermission(auth.PermRolesRead))
+
+	// Register role management endpoints.
+	rgh := rolegrp.Handlers{
+		Role: rolCore,
+	}
+
+	app.Handle(http.MethodGet, version, "/permissions", rgh.Permissions, authen, mid.RequirePermission(auth.PermRolesRead))
+	app.Handle(http.MethodGet, version, "/roles", rgh.Query, authen, mid.RequirePermission(auth.PermRolesRead))
+	app.Handle(http.MethodGet, version, "/roles/{name}", rgh.FindByName, authen, mid.RequirePermission(auth.PermRolesRead))
+	app.Handle(http.MethodPost, version, "/roles", rgh.Create, authen, mid.RequirePermission(auth.PermRolesWrite))
+	app.Handle(http.MethodPut, version, "/roles/{name}", rgh.Update, authen, mid.RequirePermission(auth.PermRolesWrite))
+	app.Handle(http.MethodDelete, version, "/roles/{name}", rgh.Delete, authen, mid.RequirePermission(auth.PermRolesWrite))
+
+	// Register the audit log endpoints, the log is read-only through the API.
+	agh := auditgrp.Handlers{
+		Audit: audCore,
+	}
+
+	app.Handle(http.MethodGet, version, "/audit", agh.Query, authen, mid.RequirePermission(auth.PermAuditRead))
+	app.Handle(http.MethodGet, version, "/audit/export", agh.Export, authen, mid.RequirePermission(auth.PermAuditRead))
+
+	// Register the login history endpoints. Users see their own logins,
+	// everyone's are read with the audit log permission.
+	lgh := logingrp.Handlers{
+		User: usrCore,
+	}
+
+	app.Handle(http.MethodGet, version, "/users/me/logins", lgh.QueryMine, authen)
+	app.Handle(http.MethodGet, version, "/logins", lgh.Query, authen, mid.RequirePermission(auth.PermAuditRead))
+
+	// Register the webhook endpoints.
+	wgh := webhookgrp.Handlers{
+		Webhook: whkCore,
+	}
+
+	app.Handle(http.MethodGet, version, "/webhooks", wgh.Query, authen, mid.RequirePermission(auth.PermWebhooksManage))
+	app.Handle(http.MethodPost, version, "/webhooks", wgh.Create, authen, mid.RequirePermission(auth.PermWebhooksManage))
+	app.Handle(http.MethodGet, version, "/webhooks/{id}", wgh.QueryByID, authen, mid.RequirePermission(auth.PermWebhooksManage))
+	app.Handle(http.MethodPut, version, "/webhooks/{id}", wgh.Update, authen, mid.RequirePermission(auth.PermWebhooksManage))
+	app.Handle(http.MethodDelete, version, "/webhooks/{id}", wgh.Delete, authen, mid.RequirePermission(auth.PermWebhooksManage))
+	app.Handle(http.MethodGet, version, "/webhooks/{id}/deliveries", wgh.QueryDeliveries, authen, mid.RequirePermission(auth.PermWebhooksManage))
+	app.Handle(http.MethodPost, version, "/webhooks/{id}/deliveries/{delivery_id}/redeliver", wgh.Redeliver, authen, mid.RequirePermission(auth.PermWebhooksManage))
+}
+
+// newCounter constructs the failure counter of the policy, none when the
+// policy has no threshold.
+func newCounter(policy lockout.Policy) *lockout.Counter {
+	if policy.Threshold <= 0 {
+		return nil
+	}
+	return lockout.NewCounter(policy)
 }
@@ -0,0 +1,170 @@
+// Package auditgrp maintains the group of handlers for reading the audit
+// log. There are no handlers to modify it, entries can only be appended by
+// the cores.
+package auditgrp
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	auditCore "github.com/AgeroFlynn/crud/internal/buisness/core/audit"
+	"github.com/AgeroFlynn/crud/internal/buisness/core/dto"
+	"github.com/AgeroFlynn/crud/internal/buisness/sys/validate"
+	"github.com/AgeroFlynn/crud/internal/buisness/web/page"
+	"github.com/AgeroFlynn/crud/internal/buisness/web/param"
+	"github.com/AgeroFlynn/crud/internal/foundation/web"
+	"github.com/AgeroFlynn/crud/internal/transport/rest/incoming"
+	"net/http"
+	"time"
+)
+
+// MaxExport is the most entries a single export holds. Larger exports have
+// to be split with the date filters.
+const MaxExport = 10000
+
+// Handlers manages the set of audit log endpoints.
+type Handlers struct {
+	Audit auditCore.Core
+}
+
+// Query returns a page of the audit log entries matching the filter, newest
+// first.
+func (h Handlers) Query(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	filter, err := parseFilter(r)
+	if err != nil {
+		return validate.NewRequestError(err, http.StatusBadRequest)
+	}
+
+	pg, err := page.Parse(r)
+	if err != nil {
+		return validate.NewRequestError(err, http.StatusBadRequest)
+	}
+
+	entries, total, err := h.Audit.Query(ctx, filter, pg.Offset, pg.Limit)
+	if err != nil {
+		return fmt.Errorf("unable to query the audit log: %w", err)
+	}
+
+	page.SetLinks(w, r, pg, total)
+
+	return web.Respond(ctx, w, page.NewResponse(incoming.FromDTOAuditEntrySlice(entries), total, pg), http.StatusOK)
+}
+
+// Export returns all audit log entries matching the filter as a CSV file, or
+// as a JSON array when the format query parameter is json.
+func (h Handlers) Export(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	filter, err := parseFilter(r)
+	if err != nil {
+		return validate.NewRequestError(err, http.StatusBadRequest)
+	}
+
+	format := r.URL.Query().Get("format")
+	switch format {
+	case "":
+		format = "csv"
+	case "csv", "json":
+	default:
+		return validate.NewRequestError(errors.New("format must be csv or json"), http.StatusBadRequest)
+	}
+
+	entries, total, err := h.Audit.Query(ctx, filter, 0, MaxExport)
+	if err != nil {
+		return fmt.Errorf("unable to export the audit log: %w", err)
+	}
+	if total > MaxExport {
+		err := fmt.Errorf("%d entries match, narrow the filter to export at most %d", total, MaxExport)
+		return validate.NewRequestError(err, http.StatusBadRequest)
+	}
+
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=audit.%s", format))
+
+	if format == "json" {
+		return web.Respond(ctx, w, incoming.FromDTOAuditEntrySlice(entries), http.StatusOK)
+	}
+
+	data, err := encodeCSV(entries)
+	if err != nil {
+		return fmt.Errorf("encoding the audit log: %w", err)
+	}
+
+	return web.RespondRaw(ctx, w, data, "text/csv; charset=utf-8", http.StatusOK)
+}
+
+// encodeCSV writes the entries with a header row. The changes are encoded
+// as JSON in a single column.
+func encodeCSV(entries []dto.AuditEntry) ([]byte, error) {
+	var buf bytes.Buffer
+	cw := csv.NewWriter(&buf)
+
+	header := []string{"id", "date_created", "actor_id", "action", "target_type", "target_id", "outcome", "reason", "trace_id", "remote_addr", "changes"}
+	if err := cw.Write(header); err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		changes, err := json.Marshal(incoming.FromDTOAuditEntry(entry).Changes)
+		if err != nil {
+			return nil, err
+		}
+
+		record := []string{
+			entry.ID,
+			entry.DateCreated.Format(time.RFC3339Nano),
+			entry.ActorID,
+			entry.Action,
+			entry.TargetType,
+			entry.TargetID,
+			entry.Outcome,
+			entry.Reason,
+			entry.TraceID,
+			entry.RemoteAddr,
+			string(changes),
+		}
+		if err := cw.Write(record); err != nil {
+			return nil, err
+		}
+	}
+
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// parseFilter reads the audit filter from the query string. Dates are
+// accepted in the RFC 3339 format or as plain dates, a plain end date
+// includes the whole day.
+func parseFilter(r *http.Request) (dto.AuditFilter, error) {
+	q := r.URL.Query()
+
+	filter := dto.AuditFilter{
+		ActorID:  param.String(q, "actor_id"),
+		Action:   param.String(q, "action"),
+		TargetID: param.String(q, "target_id"),
+		Outcome:  param.String(q, "outcome"),
+		TraceID:  param.String(q, "trace_id"),
+	}
+
+	if filter.Outcome != nil {
+		switch *filter.Outcome {
+		case dto.OutcomeSuccess, dto.OutcomeFailure, dto.OutcomeDenied:
+		default:
+			return dto.AuditFilter{}, fmt.Errorf("outcome must be one of %s, %s or %s", dto.OutcomeSuccess, dto.OutcomeFailure, dto.OutcomeDenied)
+		}
+	}
+
+	var err error
+	if filter.StartDate, err = param.Date(q, "start_date", false); err != nil {
+		return dto.AuditFilter{}, err
+	}
+	if filter.EndDate, err = param.Date(q, "end_date", true); err != nil {
+		return dto.AuditFilter{}, err
+	}
+
+	return filter, nil
+}
@@ -0,0 +1,106 @@
+// Package logingrp maintains the group of handlers for reading the login
+// history. Attempts are only recorded by the user core when users log in.
+package logingrp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/AgeroFlynn/crud/internal/buisness/core/dto"
+	userCore "github.com/AgeroFlynn/crud/internal/buisness/core/user"
+	"github.com/AgeroFlynn/crud/internal/buisness/sys/auth"
+	"github.com/AgeroFlynn/crud/internal/buisness/sys/validate"
+	"github.com/AgeroFlynn/crud/internal/buisness/web/page"
+	"github.com/AgeroFlynn/crud/internal/buisness/web/param"
+	"github.com/AgeroFlynn/crud/internal/foundation/web"
+	"github.com/AgeroFlynn/crud/internal/transport/rest/incoming"
+	"net/http"
+)
+
+// Handlers manages the set of login history endpoints.
+type Handlers struct {
+	User userCore.Core
+}
+
+// QueryMine returns a page of the login attempts of the authenticated user,
+// newest first.
+func (h Handlers) QueryMine(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	claims, err := auth.GetClaims(ctx)
+	if err != nil {
+		return errors.New("claims missing from context")
+	}
+
+	filter, err := parseFilter(r)
+	if err != nil {
+		return validate.NewRequestError(err, http.StatusBadRequest)
+	}
+	filter.UserID = &claims.Subject
+	filter.Email = nil
+
+	return h.query(ctx, w, r, filter)
+}
+
+// Query returns a page of the login attempts of all users matching the
+// filter, newest first.
+func (h Handlers) Query(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	filter, err := parseFilter(r)
+	if err != nil {
+		return validate.NewRequestError(err, http.StatusBadRequest)
+	}
+
+	return h.query(ctx, w, r, filter)
+}
+
+func (h Handlers) query(ctx context.Context, w http.ResponseWriter, r *http.Request, filter dto.LoginFilter) error {
+	pg, err := page.Parse(r)
+	if err != nil {
+		return validate.NewRequestError(err, http.StatusBadRequest)
+	}
+
+	logins, total, err := h.User.QueryLogins(ctx, filter, pg.Offset, pg.Limit)
+	if err != nil {
+		return fmt.Errorf("unable to query the login history: %w", err)
+	}
+
+	page.SetLinks(w, r, pg, total)
+
+	return web.Respond(ctx, w, page.NewResponse(incoming.FromDTOLoginSlice(logins), total, pg), http.StatusOK)
+}
+
+// parseFilter reads the login filter from the query string. Dates are
+// accepted in the RFC 3339 format or as plain dates, a plain end date
+// includes the whole day.
+func parseFilter(r *http.Request) (dto.LoginFilter, error) {
+	q := r.URL.Query()
+
+	filter := dto.LoginFilter{
+		UserID:     param.String(q, "user_id"),
+		Email:      param.String(q, "email"),
+		Outcome:    param.String(q, "outcome"),
+		RemoteAddr: param.String(q, "remote_addr"),
+	}
+
+	if filter.UserID != nil {
+		if err := validate.CheckID(*filter.UserID); err != nil {
+			return dto.LoginFilter{}, fmt.Errorf("user_id: %w", err)
+		}
+	}
+
+	if filter.Outcome != nil {
+		switch *filter.Outcome {
+		case dto.OutcomeSuccess, dto.OutcomeFailure:
+		default:
+			return dto.LoginFilter{}, fmt.Errorf("outcome must be %s or %s", dto.OutcomeSuccess, dto.OutcomeFailure)
+		}
+	}
+
+	var err error
+	if filter.StartDate, err = param.Date(q, "start_date", false); err != nil {
+		return dto.LoginFilter{}, err
+	}
+	if filter.EndDate, err = param.Date(q, "end_date", true); err != nil {
+		return dto.LoginFilter{}, err
+	}
+
+	return filter, nil
+}
@@ -0,0 +1,159 @@
+// Package qrgrp maintains the group of handlers rendering contact cards as
+// QR codes.
+package qrgrp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/AgeroFlynn/crud/internal/buisness/core/dto"
+	shareCore "github.com/AgeroFlynn/crud/internal/buisness/core/sharelink"
+	userCore "github.com/AgeroFlynn/crud/internal/buisness/core/user"
+	"github.com/AgeroFlynn/crud/internal/buisness/sys/auth"
+	"github.com/AgeroFlynn/crud/internal/buisness/sys/validate"
+	"github.com/AgeroFlynn/crud/internal/foundation/database"
+	"github.com/AgeroFlynn/crud/internal/foundation/qrcode"
+	"github.com/AgeroFlynn/crud/internal/foundation/vcard"
+	"github.com/AgeroFlynn/crud/internal/foundation/web"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Set of values for the content query parameter.
+const (
+	contentVCard = "vcard"
+	contentShare = "share"
+)
+
+// Handlers manages the set of QR code endpoints.
+type Handlers struct {
+	User      userCore.Core
+	ShareLink shareCore.Core
+
+	// PublicURL is the address the share links point to. The host of the
+	// request is never used, it is controlled by the client.
+	PublicURL string
+}
+
+// QR renders the contact card of a user as a QR code. The code encodes the
+// vCard by default or a freshly minted share link with `?content=share`. The
+// image size in pixels and the error correction level (L, M, Q, H) are set
+// with the `size` and `ecc` query parameters.
+func (h Handlers) QR(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, err := auth.GetClaims(ctx)
+	if err != nil {
+		return errors.New("claims missing from context")
+	}
+
+	//receive and validate path parameters
+	id, err := web.Param(r, "id")
+	if err != nil {
+		return validate.NewRequestError(err, http.StatusBadRequest)
+	}
+	err = validate.CheckID(id)
+	if err != nil {
+		return validate.NewRequestError(err, http.StatusBadRequest)
+	}
+	format, err := web.Param(r, "format")
+	if err != nil {
+		return validate.NewRequestError(err, http.StatusBadRequest)
+	}
+
+	opt, content, err := parseQuery(r)
+	if err != nil {
+		return validate.NewRequestError(err, http.StatusBadRequest)
+	}
+
+	// Exporting the card of someone else takes the directory:export
+	// permission, being allowed to read their account isn't enough.
+	usr, err := h.User.Export(ctx, claims, id)
+	if err != nil {
+		switch validate.Cause(err) {
+		case database.ErrInvalidID:
+			return validate.NewRequestError(err, http.StatusBadRequest)
+		case database.ErrNotFound:
+			return validate.NewRequestError(err, http.StatusNotFound)
+		case database.ErrForbidden:
+			return validate.NewRequestError(err, http.StatusForbidden)
+		default:
+			return fmt.Errorf("ID[%s]: %w", id, err)
+		}
+	}
+
+	var payload string
+	switch content {
+	case contentShare:
+		_, token, err := h.ShareLink.Create(ctx, dto.NewShareLink{UserID: usr.ID}, v.Now)
+		if err != nil {
+			return fmt.Errorf("creating share link: %w", err)
+		}
+		payload = shareURL(h.PublicURL, token)
+
+	default:
+		vc := vcard.Card{
+			UID:   usr.ID,
+			Name:  usr.Name,
+			Email: usr.Email,
+		}
+		payload = string(vcard.Encode(vc))
+	}
+
+	switch format {
+	case "svg":
+		img, err := qrcode.SVG(payload, opt)
+		if err != nil {
+			return fmt.Errorf("rendering svg: %w", err)
+		}
+		return web.RespondRaw(ctx, w, img, qrcode.ContentTypeSVG, http.StatusOK)
+
+	default:
+		img, err := qrcode.PNG(payload, opt)
+		if err != nil {
+			return fmt.Errorf("rendering png: %w", err)
+		}
+		return web.RespondRaw(ctx, w, img, qrcode.ContentTypePNG, http.StatusOK)
+	}
+}
+
+// parseQuery reads the rendering options and the content to encode from the
+// query string.
+func parseQuery(r *http.Request) (qrcode.Options, string, error) {
+	q := r.URL.Query()
+
+	var opt qrcode.Options
+	if s := q.Get("size"); s != "" {
+		size, err := strconv.Atoi(s)
+		if err != nil || size < qrcode.MinSize || size > qrcode.MaxSize {
+			return qrcode.Options{}, "", fmt.Errorf("size must be a number between %d and %d", qrcode.MinSize, qrcode.MaxSize)
+		}
+		opt.Size = size
+	}
+
+	opt.Level = q.Get("ecc")
+	if !qrcode.ValidLevel(opt.Level) {
+		return qrcode.Options{}, "", errors.New("ecc must be one of L, M, Q or H")
+	}
+
+	content := q.Get("content")
+	switch content {
+	case "":
+		content = contentVCard
+	case contentVCard, contentShare:
+	default:
+		return qrcode.Options{}, "", fmt.Errorf("content must be %q or %q", contentVCard, contentShare)
+	}
+
+	return opt, content, nil
+}
+
+// shareURL builds the absolute url to open a share link from the public url
+// of the service.
+func shareURL(publicURL string, token string) string {
+	return strings.TrimSuffix(publicURL, "/") + "/v1/share/" + token
+}
@@ -0,0 +1,149 @@
+// Package rolegrp maintains the group of handlers for role and permission
+// access.
+package rolegrp
+
+import (
+	"context"
+	"fmt"
+	roleCore "github.com/AgeroFlynn/crud/internal/buisness/core/role"
+	"github.com/AgeroFlynn/crud/internal/buisness/sys/validate"
+	"github.com/AgeroFlynn/crud/internal/foundation/database"
+	"github.com/AgeroFlynn/crud/internal/foundation/web"
+	"github.com/AgeroFlynn/crud/internal/transport/rest/incoming"
+	"net/http"
+)
+
+// Handlers manages the set of role endpoints.
+type Handlers struct {
+	Role roleCore.Core
+}
+
+// Query returns all roles with the permissions they grant.
+func (h Handlers) Query(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	roles, err := h.Role.Query(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to query for roles: %w", err)
+	}
+
+	return web.Respond(ctx, w, incoming.FromDTORoleSlice(roles), http.StatusOK)
+}
+
+// FindByName returns the specified role.
+func (h Handlers) FindByName(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+
+	//receive name path parameter
+	name, err := web.Param(r, "name")
+	if err != nil {
+		return validate.NewRequestError(err, http.StatusBadRequest)
+	}
+
+	role, err := h.Role.FindByName(ctx, name)
+	if err != nil {
+		switch validate.Cause(err) {
+		case database.ErrNotFound:
+			return validate.NewRequestError(err, http.StatusNotFound)
+		default:
+			return fmt.Errorf("name[%s]: %w", name, err)
+		}
+	}
+
+	return web.Respond(ctx, w, incoming.FromDTORole(role), http.StatusOK)
+}
+
+// Create adds a new role to the system.
+func (h Handlers) Create(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	//decoding and validating json payload
+	var nr incoming.NewRole
+	if err := web.Decode(r, &nr); err != nil {
+		return validate.NewRequestError(fmt.Errorf("unable to decode payload: %w", err), http.StatusBadRequest)
+	}
+	if err := validate.Check(nr); err != nil {
+		return fmt.Errorf("validating data: %w", err)
+	}
+
+	role, err := h.Role.Create(ctx, nr.ToDTONewRole(), v.Now)
+	if err != nil {
+		switch validate.Cause(err) {
+		case database.ErrDuplicate:
+			return validate.NewRequestError(err, http.StatusConflict)
+		default:
+			return fmt.Errorf("role[%+v]: %w", &nr, err)
+		}
+	}
+
+	return web.Respond(ctx, w, incoming.FromDTORole(role), http.StatusCreated)
+}
+
+// Update modifies the description and the permissions of a role.
+func (h Handlers) Update(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	//decode and validate json payload
+	var ur incoming.UpdateRole
+	if err := web.Decode(r, &ur); err != nil {
+		return validate.NewRequestError(fmt.Errorf("unable to decode payload: %w", err), http.StatusBadRequest)
+	}
+	if err := validate.Check(ur); err != nil {
+		return fmt.Errorf("validating data: %w", err)
+	}
+
+	//receive name path parameter
+	name, err := web.Param(r, "name")
+	if err != nil {
+		return validate.NewRequestError(err, http.StatusBadRequest)
+	}
+
+	if err := h.Role.Update(ctx, name, ur.ToDTOUpdateRole(), v.Now); err != nil {
+		switch validate.Cause(err) {
+		case database.ErrNotFound:
+			return validate.NewRequestError(err, http.StatusNotFound)
+		case roleCore.ErrBuiltin:
+			return validate.NewRequestError(err, http.StatusForbidden)
+		default:
+			return fmt.Errorf("name[%s] role[%+v]: %w", name, &ur, err)
+		}
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+// Delete removes a role from the system.
+func (h Handlers) Delete(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+
+	//receive name path parameter
+	name, err := web.Param(r, "name")
+	if err != nil {
+		return validate.NewRequestError(err, http.StatusBadRequest)
+	}
+
+	if err := h.Role.Delete(ctx, name); err != nil {
+		switch validate.Cause(err) {
+		case database.ErrNotFound:
+			return validate.NewRequestError(err, http.StatusNotFound)
+		case roleCore.ErrBuiltin:
+			return validate.NewRequestError(err, http.StatusForbidden)
+		default:
+			return fmt.Errorf("name[%s]: %w", name, err)
+		}
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+// Permissions returns all permissions roles can grant.
+func (h Handlers) Permissions(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	permissions, err := h.Role.Permissions(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to query for permissions: %w", err)
+	}
+
+	return web.Respond(ctx, w, incoming.FromDTOPermissionSlice(permissions), http.StatusOK)
+}
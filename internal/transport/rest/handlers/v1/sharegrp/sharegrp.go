@@ -0,0 +1,196 @@
+// Package sharegrp maintains the group of handlers for share link access.
+package sharegrp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/AgeroFlynn/crud/internal/buisness/core/dto"
+	shareCore "github.com/AgeroFlynn/crud/internal/buisness/core/sharelink"
+	"github.com/AgeroFlynn/crud/internal/buisness/repository/store/sharelink"
+	"github.com/AgeroFlynn/crud/internal/buisness/sys/auth"
+	"github.com/AgeroFlynn/crud/internal/buisness/sys/validate"
+	"github.com/AgeroFlynn/crud/internal/foundation/database"
+	"github.com/AgeroFlynn/crud/internal/foundation/vcard"
+	"github.com/AgeroFlynn/crud/internal/foundation/web"
+	"github.com/AgeroFlynn/crud/internal/transport/rest/incoming"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Handlers manages the set of share link endpoints.
+type Handlers struct {
+	ShareLink shareCore.Core
+}
+
+// Create mints a new share link for the authenticated user.
+func (h Handlers) Create(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, err := auth.GetClaims(ctx)
+	if err != nil {
+		return errors.New("claims missing from context")
+	}
+
+	//decoding and validating json payload, an empty body uses the defaults
+	var nsl incoming.NewShareLink
+	if err := web.Decode(r, &nsl); err != nil && !errors.Is(err, io.EOF) {
+		return validate.NewRequestError(fmt.Errorf("unable to decode payload: %w", err), http.StatusBadRequest)
+	}
+	if err := validate.Check(nsl); err != nil {
+		return fmt.Errorf("validating data: %w", err)
+	}
+
+	sl, token, err := h.ShareLink.Create(ctx, nsl.ToDTONewShareLink(claims.Subject), v.Now)
+	if err != nil {
+		return fmt.Errorf("share link[%+v]: %w", &nsl, err)
+	}
+
+	resp := incoming.FromDTOShareLink(sl)
+	resp.Token = token
+
+	return web.Respond(ctx, w, resp, http.StatusCreated)
+}
+
+// FindMine returns the share links of the authenticated user.
+func (h Handlers) FindMine(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	claims, err := auth.GetClaims(ctx)
+	if err != nil {
+		return errors.New("claims missing from context")
+	}
+
+	links, err := h.ShareLink.FindByUser(ctx, claims, claims.Subject)
+	if err != nil {
+		return fmt.Errorf("unable to query for share links: %w", err)
+	}
+
+	return web.Respond(ctx, w, incoming.FromDTOShareLinkSlice(links), http.StatusOK)
+}
+
+// Revoke makes a share link of the authenticated user unusable.
+func (h Handlers) Revoke(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, err := auth.GetClaims(ctx)
+	if err != nil {
+		return errors.New("claims missing from context")
+	}
+
+	//receive and validate id path parameter
+	id, err := web.Param(r, "id")
+	if err != nil {
+		return validate.NewRequestError(err, http.StatusBadRequest)
+	}
+	err = validate.CheckID(id)
+	if err != nil {
+		return validate.NewRequestError(err, http.StatusBadRequest)
+	}
+
+	if err := h.ShareLink.Revoke(ctx, claims, id, v.Now); err != nil {
+		switch validate.Cause(err) {
+		case database.ErrInvalidID:
+			return validate.NewRequestError(err, http.StatusBadRequest)
+		case database.ErrNotFound:
+			return validate.NewRequestError(err, http.StatusNotFound)
+		case database.ErrForbidden:
+			return validate.NewRequestError(err, http.StatusForbidden)
+		default:
+			return fmt.Errorf("ID[%s]: %w", id, err)
+		}
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+// FindAccesses returns the access log of a share link.
+func (h Handlers) FindAccesses(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	claims, err := auth.GetClaims(ctx)
+	if err != nil {
+		return errors.New("claims missing from context")
+	}
+
+	//receive and validate id path parameter
+	id, err := web.Param(r, "id")
+	if err != nil {
+		return validate.NewRequestError(err, http.StatusBadRequest)
+	}
+	err = validate.CheckID(id)
+	if err != nil {
+		return validate.NewRequestError(err, http.StatusBadRequest)
+	}
+
+	accesses, err := h.ShareLink.FindAccesses(ctx, claims, id)
+	if err != nil {
+		switch validate.Cause(err) {
+		case database.ErrInvalidID:
+			return validate.NewRequestError(err, http.StatusBadRequest)
+		case database.ErrNotFound:
+			return validate.NewRequestError(err, http.StatusNotFound)
+		case database.ErrForbidden:
+			return validate.NewRequestError(err, http.StatusForbidden)
+		default:
+			return fmt.Errorf("ID[%s]: %w", id, err)
+		}
+	}
+
+	return web.Respond(ctx, w, incoming.FromDTOShareLinkAccessSlice(accesses), http.StatusOK)
+}
+
+// Open returns the public view of the contact card behind a share link. No
+// authentication is required, the signed token is the credential. The card
+// is returned as a vCard when asked for with `?format=vcard` or the Accept
+// header.
+func (h Handlers) Open(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	token, err := web.Param(r, "token")
+	if err != nil {
+		return validate.NewRequestError(err, http.StatusBadRequest)
+	}
+
+	access := dto.ShareLinkAccess{
+		RemoteAddr: r.RemoteAddr,
+		UserAgent:  r.UserAgent(),
+	}
+
+	card, err := h.ShareLink.Open(ctx, token, access, v.Now)
+	if err != nil {
+		switch validate.Cause(err) {
+		case sharelink.ErrUnavailable:
+			return validate.NewRequestError(sharelink.ErrUnavailable, http.StatusGone)
+		case database.ErrNotFound:
+			return validate.NewRequestError(sharelink.ErrUnavailable, http.StatusGone)
+		default:
+			return fmt.Errorf("opening share link: %w", err)
+		}
+	}
+
+	if wantsVCard(r) {
+		vc := vcard.Card{
+			UID:   card.ID,
+			Name:  card.Name,
+			Email: card.Email,
+		}
+		return web.RespondRaw(ctx, w, vcard.Encode(vc), vcard.ContentType, http.StatusOK)
+	}
+
+	return web.Respond(ctx, w, incoming.FromDTOPublicCard(card), http.StatusOK)
+}
+
+// wantsVCard reports whether the client asked for the vCard representation.
+func wantsVCard(r *http.Request) bool {
+	if r.URL.Query().Get("format") == "vcard" {
+		return true
+	}
+	return strings.Contains(r.Header.Get("Accept"), "text/vcard")
+}
@@ -2,22 +2,38 @@
 package usergrp
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/AgeroFlynn/crud/internal/buisness/core/dto"
 	userCore "github.com/AgeroFlynn/crud/internal/buisness/core/user"
+	"github.com/AgeroFlynn/crud/internal/buisness/repository/order"
 	"github.com/AgeroFlynn/crud/internal/buisness/sys/auth"
 	"github.com/AgeroFlynn/crud/internal/buisness/sys/validate"
+	"github.com/AgeroFlynn/crud/internal/buisness/web/page"
+	"github.com/AgeroFlynn/crud/internal/buisness/web/param"
 	"github.com/AgeroFlynn/crud/internal/foundation/database"
 	"github.com/AgeroFlynn/crud/internal/foundation/web"
 	"github.com/AgeroFlynn/crud/internal/transport/rest/incoming"
+	"go.uber.org/zap"
+	"math"
+	"net"
 	"net/http"
+	"strconv"
+	"time"
 )
 
 // Handlers manages the set of user enpoints.
 type Handlers struct {
+	Log  *zap.SugaredLogger
 	User userCore.Core
 	Auth *auth.Auth
+
+	// RequireIfMatch rejects modifications that don't provide the version of
+	// the user they are based on through the If-Match header.
+	RequireIfMatch bool
 }
 
 // Create adds a new user to the system.
@@ -30,7 +46,7 @@ func (h Handlers) Create(ctx context.Context, w http.ResponseWriter, r *http.Req
 	//decoding and validating json payload
 	var nu incoming.NewUser
 	if err := web.Decode(r, &nu); err != nil {
-		return fmt.Errorf("unable to decode payload: %w", err)
+		return validate.NewRequestError(fmt.Errorf("unable to decode payload: %w", err), http.StatusBadRequest)
 	}
 	if err := validate.Check(nu); err != nil {
 		return fmt.Errorf("validating data: %w", err)
@@ -38,7 +54,14 @@ func (h Handlers) Create(ctx context.Context, w http.ResponseWriter, r *http.Req
 
 	usr, err := h.User.Create(ctx, nu.ToDTONewUser(), v.Now)
 	if err != nil {
-		return fmt.Errorf("user[%+v]: %w", &usr, err)
+		switch validate.Cause(err) {
+		case database.ErrForbidden:
+			return validate.NewRequestError(err, http.StatusForbidden)
+		case database.ErrDuplicate, database.ErrForeignKey, database.ErrCheck:
+			return constraintError(err)
+		default:
+			return fmt.Errorf("user[%+v]: %w", &usr, err)
+		}
 	}
 
 	return web.Respond(ctx, w, incoming.FromDTOUser(usr), http.StatusCreated)
@@ -59,7 +82,7 @@ func (h Handlers) Update(ctx context.Context, w http.ResponseWriter, r *http.Req
 	//decode and validate json payload
 	var upd incoming.UpdateUser
 	if err := web.Decode(r, &upd); err != nil {
-		return fmt.Errorf("unable to decode payload: %w", err)
+		return validate.NewRequestError(fmt.Errorf("unable to decode payload: %w", err), http.StatusBadRequest)
 	}
 	if err := validate.Check(upd); err != nil {
 		return fmt.Errorf("validating data: %w", err)
@@ -75,7 +98,12 @@ func (h Handlers) Update(ctx context.Context, w http.ResponseWriter, r *http.Req
 		return validate.NewRequestError(err, http.StatusBadRequest)
 	}
 
-	if err := h.User.Update(ctx, claims, id, upd.ToDTOUpdateUser(), v.Now); err != nil {
+	version, err := h.ifMatch(r)
+	if err != nil {
+		return err
+	}
+
+	if err := h.User.Update(ctx, claims, id, upd.ToDTOUpdateUser(), version, v.Now); err != nil {
 		switch validate.Cause(err) {
 		case database.ErrInvalidID:
 			return validate.NewRequestError(err, http.StatusBadRequest)
@@ -83,6 +111,10 @@ func (h Handlers) Update(ctx context.Context, w http.ResponseWriter, r *http.Req
 			return validate.NewRequestError(err, http.StatusNotFound)
 		case database.ErrForbidden:
 			return validate.NewRequestError(err, http.StatusForbidden)
+		case database.ErrConflict:
+			return conflictError(err, version)
+		case database.ErrDuplicate, database.ErrForeignKey, database.ErrCheck:
+			return constraintError(err)
 		default:
 			return fmt.Errorf("ID[%s] User[%+v]: %w", id, &upd, err)
 		}
@@ -91,6 +123,103 @@ func (h Handlers) Update(ctx context.Context, w http.ResponseWriter, r *http.Req
 	return web.Respond(ctx, w, nil, http.StatusNoContent)
 }
 
+// Patch modifies a user in the system using either a JSON Merge Patch or a
+// JSON Patch document. The patch is applied to the current version of the
+// user and the result is validated before it is stored.
+func (h Handlers) Patch(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, err := auth.GetClaims(ctx)
+	if err != nil {
+		return errors.New("claims missing from context")
+	}
+
+	//receive and validate id path parameter
+	id, err := web.Param(r, "id")
+	if err != nil {
+		return validate.NewRequestError(err, http.StatusBadRequest)
+	}
+	err = validate.CheckID(id)
+	if err != nil {
+		return validate.NewRequestError(err, http.StatusBadRequest)
+	}
+
+	version, err := h.ifMatch(r)
+	if err != nil {
+		return err
+	}
+
+	usr, err := h.User.FindByID(ctx, claims, id)
+	if err != nil {
+		switch validate.Cause(err) {
+		case database.ErrInvalidID:
+			return validate.NewRequestError(err, http.StatusBadRequest)
+		case database.ErrNotFound:
+			return validate.NewRequestError(err, http.StatusNotFound)
+		case database.ErrForbidden:
+			return validate.NewRequestError(err, http.StatusForbidden)
+		default:
+			return fmt.Errorf("ID[%s]: %w", id, err)
+		}
+	}
+
+	if version != nil && *version != usr.Version {
+		return validate.NewRequestError(database.ErrConflict, http.StatusPreconditionFailed)
+	}
+
+	//apply the patch and validate the resulting document
+	doc, err := json.Marshal(incoming.NewPatchUser(usr))
+	if err != nil {
+		return fmt.Errorf("encoding user: %w", err)
+	}
+
+	patched, err := web.ApplyPatch(r, doc)
+	if err != nil {
+		switch validate.Cause(err) {
+		case web.ErrUnsupportedPatch:
+			return validate.NewRequestError(err, http.StatusUnsupportedMediaType)
+		case web.ErrInvalidPatch:
+			return validate.NewRequestError(err, http.StatusBadRequest)
+		case web.ErrPatchFailed:
+			return validate.NewRequestError(err, http.StatusUnprocessableEntity)
+		default:
+			return fmt.Errorf("applying patch: %w", err)
+		}
+	}
+
+	var pu incoming.PatchUser
+	decoder := json.NewDecoder(bytes.NewReader(patched))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&pu); err != nil {
+		return validate.NewRequestError(fmt.Errorf("patched user is not valid: %w", err), http.StatusUnprocessableEntity)
+	}
+	if err := validate.Check(pu); err != nil {
+		return fmt.Errorf("validating data: %w", err)
+	}
+
+	// The patch was applied to the version read above, so it is only stored
+	// if nobody modified the user in the meantime.
+	if err := h.User.Update(ctx, claims, id, pu.ToDTOUpdateUser(), &usr.Version, v.Now); err != nil {
+		switch validate.Cause(err) {
+		case database.ErrNotFound:
+			return validate.NewRequestError(err, http.StatusNotFound)
+		case database.ErrForbidden:
+			return validate.NewRequestError(err, http.StatusForbidden)
+		case database.ErrConflict:
+			return validate.NewRequestError(err, http.StatusPreconditionFailed)
+		case database.ErrDuplicate, database.ErrForeignKey, database.ErrCheck:
+			return constraintError(err)
+		default:
+			return fmt.Errorf("ID[%s] User[%+v]: %w", id, &pu, err)
+		}
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
 // Delete removes a user from the system.
 func (h Handlers) Delete(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
 	claims, err := auth.GetClaims(ctx)
@@ -108,7 +237,28 @@ func (h Handlers) Delete(ctx context.Context, w http.ResponseWriter, r *http.Req
 		return validate.NewRequestError(err, http.StatusBadRequest)
 	}
 
-	if err := h.User.Delete(ctx, claims, id); err != nil {
+	version, err := h.ifMatch(r)
+	if err != nil {
+		return err
+	}
+
+	// Deleting a missing user is idempotent, unless the request was
+	// conditional. If-Match: * only holds while the user exists, a version
+	// can't match a missing user either and fails in the store.
+	if _, conditional := web.IfMatch(r); conditional && version == nil {
+		if _, err := h.User.FindByID(ctx, claims, id); err != nil {
+			switch validate.Cause(err) {
+			case database.ErrNotFound:
+				return validate.NewRequestError(errors.New("the user does not exist"), http.StatusPreconditionFailed)
+			case database.ErrForbidden:
+				return validate.NewRequestError(err, http.StatusForbidden)
+			default:
+				return fmt.Errorf("ID[%s]: %w", id, err)
+			}
+		}
+	}
+
+	if err := h.User.Delete(ctx, claims, id, version); err != nil {
 		switch validate.Cause(err) {
 		case database.ErrInvalidID:
 			return validate.NewRequestError(err, http.StatusBadRequest)
@@ -116,6 +266,10 @@ func (h Handlers) Delete(ctx context.Context, w http.ResponseWriter, r *http.Req
 			return validate.NewRequestError(err, http.StatusNotFound)
 		case database.ErrForbidden:
 			return validate.NewRequestError(err, http.StatusForbidden)
+		case database.ErrConflict:
+			return conflictError(err, version)
+		case database.ErrForeignKey:
+			return validate.NewRequestError(errors.New("the user is still referenced"), http.StatusConflict)
 		default:
 			return fmt.Errorf("ID[%s]: %w", id, err)
 		}
@@ -124,15 +278,171 @@ func (h Handlers) Delete(ctx context.Context, w http.ResponseWriter, r *http.Req
 	return web.Respond(ctx, w, nil, http.StatusNoContent)
 }
 
-// FindAll returns a list of users.
-func (h Handlers) FindAll(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+// Query returns a page of users. The listing can be filtered with the
+// `name`, `email`, `role`, `start_created_date` and `end_created_date` query
+// parameters and ordered with `orderBy`. Providing the `cursor` parameter
+// switches from offset to cursor paging.
+func (h Handlers) Query(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	claims, err := auth.GetClaims(ctx)
+	if err != nil {
+		return errors.New("claims missing from context")
+	}
+
+	filter, err := parseFilter(r)
+	if err != nil {
+		return validate.NewRequestError(err, http.StatusBadRequest)
+	}
+
+	orderBy, err := order.Parse(r.URL.Query().Get("orderBy"), userCore.OrderByFields, userCore.DefaultOrderBy)
+	if err != nil {
+		return validate.NewRequestError(err, http.StatusBadRequest)
+	}
+
+	if page.IsCursor(r) {
+		return h.queryCursor(ctx, w, r, claims, filter, orderBy)
+	}
+
+	pg, err := page.Parse(r)
+	if err != nil {
+		return validate.NewRequestError(err, http.StatusBadRequest)
+	}
+
+	users, total, err := h.User.Query(ctx, claims, filter, orderBy, pg.Offset, pg.Limit)
+	if err != nil {
+		switch validate.Cause(err) {
+		case database.ErrForbidden:
+			return validate.NewRequestError(err, http.StatusForbidden)
+		default:
+			return fmt.Errorf("unable to query for users: %w", err)
+		}
+	}
+
+	page.SetLinks(w, r, pg, total)
+
+	return web.Respond(ctx, w, page.NewResponse(incoming.FromDTOUserSlice(users), total, pg), http.StatusOK)
+}
+
+// queryCursor returns a page of users using keyset paging. Pages stay stable
+// while users are being added or removed. The ordering is carried by the
+// cursor once paging has started.
+func (h Handlers) queryCursor(ctx context.Context, w http.ResponseWriter, r *http.Request, claims auth.Claims, filter dto.UserFilter, orderBy order.By) error {
+	cp, err := page.ParseCursor(h.Auth, r)
+	if err != nil {
+		return validate.NewRequestError(err, http.StatusBadRequest)
+	}
+
+	var key *order.Key
+	var backward bool
+	if cp.Cursor != nil {
+		orderBy = cp.Cursor.OrderBy
+		key = cp.Cursor.Key
+		backward = cp.Cursor.Backward
+	}
+
+	users, more, err := h.User.QueryAfter(ctx, claims, filter, orderBy, key, backward, cp.Limit)
+	if err != nil {
+		switch validate.Cause(err) {
+		case database.ErrForbidden:
+			return validate.NewRequestError(err, http.StatusForbidden)
+		default:
+			return fmt.Errorf("unable to query for users: %w", err)
+		}
+	}
+
+	var first, last *order.Key
+	if len(users) > 0 {
+		f, l := userCore.KeyOf(users[0], orderBy.Field), userCore.KeyOf(users[len(users)-1], orderBy.Field)
+		first, last = &f, &l
+	}
+
+	next, prev, err := page.Cursors(h.Auth, orderBy, cp.Cursor, more, first, last)
+	if err != nil {
+		return err
+	}
+
+	page.SetCursorLinks(w, r, cp, next, prev)
+
+	return web.Respond(ctx, w, page.NewCursorResponse(incoming.FromDTOUserSlice(users), next, prev), http.StatusOK)
+}
+
+// Directory returns a page of the contact cards of the active users. The
+// listing can be filtered with the `name` and `email` query parameters and
+// ordered with `orderBy`. It is always paged with cursors, the ordering is
+// carried by the cursor once paging has started.
+func (h Handlers) Directory(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	claims, err := auth.GetClaims(ctx)
+	if err != nil {
+		return errors.New("claims missing from context")
+	}
+
+	q := r.URL.Query()
+
+	var filter dto.UserFilter
+	if name := q.Get("name"); name != "" {
+		filter.Name = &name
+	}
+	if email := q.Get("email"); email != "" {
+		filter.Email = &email
+	}
+
+	orderBy, err := order.Parse(q.Get("orderBy"), userCore.DirectoryOrderByFields, userCore.DefaultDirectoryOrderBy)
+	if err != nil {
+		return validate.NewRequestError(err, http.StatusBadRequest)
+	}
+
+	cp, err := page.ParseCursor(h.Auth, r)
+	if err != nil {
+		return validate.NewRequestError(err, http.StatusBadRequest)
+	}
+
+	var key *order.Key
+	var backward bool
+	if cp.Cursor != nil {
+
+		// Cursors of other listings may be ordered by fields the cards
+		// don't have.
+		if !directoryField(cp.Cursor.OrderBy.Field) {
+			return validate.NewRequestError(page.ErrInvalidCursor, http.StatusBadRequest)
+		}
+		orderBy = cp.Cursor.OrderBy
+		key = cp.Cursor.Key
+		backward = cp.Cursor.Backward
+	}
 
-	users, err := h.User.FindAll(ctx)
+	cards, more, err := h.User.QueryDirectory(ctx, claims, filter, orderBy, key, backward, cp.Limit)
 	if err != nil {
-		return fmt.Errorf("unable to query for users: %w", err)
+		switch validate.Cause(err) {
+		case database.ErrForbidden:
+			return validate.NewRequestError(err, http.StatusForbidden)
+		default:
+			return fmt.Errorf("unable to query the directory: %w", err)
+		}
 	}
 
-	return web.Respond(ctx, w, incoming.FromDTOUserSlice(users), http.StatusOK)
+	var first, last *order.Key
+	if len(cards) > 0 {
+		f, l := userCore.KeyOfCard(cards[0], orderBy.Field), userCore.KeyOfCard(cards[len(cards)-1], orderBy.Field)
+		first, last = &f, &l
+	}
+
+	next, prev, err := page.Cursors(h.Auth, orderBy, cp.Cursor, more, first, last)
+	if err != nil {
+		return err
+	}
+
+	page.SetCursorLinks(w, r, cp, next, prev)
+
+	return web.Respond(ctx, w, page.NewCursorResponse(incoming.FromDTOPublicCardSlice(cards), next, prev), http.StatusOK)
+}
+
+// directoryField reports whether the directory can be ordered by the column.
+func directoryField(column string) bool {
+	for _, c := range userCore.DirectoryOrderByFields {
+		if c == column {
+			return true
+		}
+	}
+	return false
 }
 
 // FindByID returns a user by its ID.
@@ -166,6 +476,8 @@ func (h Handlers) FindByID(ctx context.Context, w http.ResponseWriter, r *http.R
 		}
 	}
 
+	web.SetETag(w, usr.Version)
+
 	return web.Respond(ctx, w, incoming.FromDTOUser(usr), http.StatusOK)
 }
 
@@ -182,13 +494,18 @@ func (h Handlers) Token(ctx context.Context, w http.ResponseWriter, r *http.Requ
 		return validate.NewRequestError(err, http.StatusUnauthorized)
 	}
 
-	claims, err := h.User.Authenticate(ctx, v.Now, email, pass)
+	claims, err := h.User.Authenticate(ctx, v.Now, email, pass, remoteHost(r), r.UserAgent())
 	if err != nil {
+		var locked *userCore.LockedError
+		if errors.As(err, &locked) {
+			return lockedError(w, locked, v.Now)
+		}
+
 		switch validate.Cause(err) {
-		case database.ErrNotFound:
-			return validate.NewRequestError(err, http.StatusNotFound)
 		case database.ErrAuthenticationFailure:
 			return validate.NewRequestError(err, http.StatusUnauthorized)
+		case userCore.ErrUnverified, userCore.ErrInactive:
+			return validate.NewRequestError(err, http.StatusForbidden)
 		default:
 			return fmt.Errorf("authenticating: %w", err)
 		}
@@ -204,3 +521,623 @@ func (h Handlers) Token(ctx context.Context, w http.ResponseWriter, r *http.Requ
 
 	return web.Respond(ctx, w, tkn, http.StatusOK)
 }
+
+// Verify marks the email of a user as verified using the token of the link
+// the user was mailed.
+func (h Handlers) Verify(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	//decode and validate json payload
+	var vu incoming.VerifyUser
+	if err := web.Decode(r, &vu); err != nil {
+		return validate.NewRequestError(fmt.Errorf("unable to decode payload: %w", err), http.StatusBadRequest)
+	}
+	if err := validate.Check(vu); err != nil {
+		return fmt.Errorf("validating data: %w", err)
+	}
+
+	if err := h.User.Verify(ctx, vu.Token, v.Now); err != nil {
+		switch validate.Cause(err) {
+		case userCore.ErrInvalidToken:
+			return validate.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return fmt.Errorf("verifying: %w", err)
+		}
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+// ForgotPassword mails a password reset link to the user with the email. The
+// response is the same whether such a user exists or not.
+func (h Handlers) ForgotPassword(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	//decode and validate json payload
+	var fp incoming.ForgotPassword
+	if err := web.Decode(r, &fp); err != nil {
+		return validate.NewRequestError(fmt.Errorf("unable to decode payload: %w", err), http.StatusBadRequest)
+	}
+	if err := validate.Check(fp); err != nil {
+		return fmt.Errorf("validating data: %w", err)
+	}
+
+	if err := h.User.ForgotPassword(ctx, fp.Email, remoteHost(r), v.Now); err != nil {
+		var locked *userCore.LockedError
+		if errors.As(err, &locked) {
+			return lockedError(w, locked, v.Now)
+		}
+		return fmt.Errorf("forgot password: %w", err)
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusAccepted)
+}
+
+// ResetPassword replaces the password of a user using the token of the link
+// the user was mailed.
+func (h Handlers) ResetPassword(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	//decode and validate json payload
+	var rp incoming.ResetPassword
+	if err := web.Decode(r, &rp); err != nil {
+		return validate.NewRequestError(fmt.Errorf("unable to decode payload: %w", err), http.StatusBadRequest)
+	}
+	if err := validate.Check(rp); err != nil {
+		return fmt.Errorf("validating data: %w", err)
+	}
+
+	if err := h.User.ResetPassword(ctx, rp.Token, rp.Password, v.Now); err != nil {
+		switch validate.Cause(err) {
+		case userCore.ErrInvalidToken:
+			return validate.NewRequestError(err, http.StatusBadRequest)
+		case userCore.ErrInactive:
+			return validate.NewRequestError(err, http.StatusForbidden)
+		default:
+			return fmt.Errorf("resetting password: %w", err)
+		}
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+// Unlock lifts the lockout of a user after failed logins.
+func (h Handlers) Unlock(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	claims, err := auth.GetClaims(ctx)
+	if err != nil {
+		return errors.New("claims missing from context")
+	}
+
+	//receive and validate id path parameter
+	id, err := web.Param(r, "id")
+	if err != nil {
+		return validate.NewRequestError(err, http.StatusBadRequest)
+	}
+	err = validate.CheckID(id)
+	if err != nil {
+		return validate.NewRequestError(err, http.StatusBadRequest)
+	}
+
+	if err := h.User.Unlock(ctx, claims, id); err != nil {
+		switch validate.Cause(err) {
+		case database.ErrInvalidID:
+			return validate.NewRequestError(err, http.StatusBadRequest)
+		case database.ErrNotFound:
+			return validate.NewRequestError(err, http.StatusNotFound)
+		case database.ErrForbidden:
+			return validate.NewRequestError(err, http.StatusForbidden)
+		default:
+			return fmt.Errorf("ID[%s]: %w", id, err)
+		}
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+// SetStatus moves a user to another status, like suspending or disabling
+// the account.
+func (h Handlers) SetStatus(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, err := auth.GetClaims(ctx)
+	if err != nil {
+		return errors.New("claims missing from context")
+	}
+
+	//decode and validate json payload
+	var ss incoming.SetStatus
+	if err := web.Decode(r, &ss); err != nil {
+		return validate.NewRequestError(fmt.Errorf("unable to decode payload: %w", err), http.StatusBadRequest)
+	}
+	if err := validate.Check(ss); err != nil {
+		return fmt.Errorf("validating data: %w", err)
+	}
+
+	//receive and validate id path parameter
+	id, err := web.Param(r, "id")
+	if err != nil {
+		return validate.NewRequestError(err, http.StatusBadRequest)
+	}
+	err = validate.CheckID(id)
+	if err != nil {
+		return validate.NewRequestError(err, http.StatusBadRequest)
+	}
+
+	version, err := h.ifMatch(r)
+	if err != nil {
+		return err
+	}
+
+	if err := h.User.SetStatus(ctx, claims, id, ss.Status, ss.Reason, version, v.Now); err != nil {
+		switch validate.Cause(err) {
+		case database.ErrInvalidID:
+			return validate.NewRequestError(err, http.StatusBadRequest)
+		case database.ErrNotFound:
+			return validate.NewRequestError(err, http.StatusNotFound)
+		case database.ErrForbidden:
+			return validate.NewRequestError(err, http.StatusForbidden)
+		case database.ErrConflict:
+			return conflictError(err, version)
+		case userCore.ErrStatusTransition:
+			return validate.NewRequestError(err, http.StatusConflict)
+		default:
+			return fmt.Errorf("ID[%s] Status[%+v]: %w", id, &ss, err)
+		}
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+// Explain dry-runs the authorization policies for a user taking an action
+// on another user and reports how every policy fared. Nothing is changed.
+func (h Handlers) Explain(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+
+	//decode and validate json payload
+	var ex incoming.Explain
+	if err := web.Decode(r, &ex); err != nil {
+		return validate.NewRequestError(fmt.Errorf("unable to decode payload: %w", err), http.StatusBadRequest)
+	}
+	if err := validate.Check(ex); err != nil {
+		return fmt.Errorf("validating data: %w", err)
+	}
+	if err := validate.CheckID(ex.SubjectID); err != nil {
+		return validate.NewRequestError(err, http.StatusBadRequest)
+	}
+	if err := validate.CheckID(ex.UserID); err != nil {
+		return validate.NewRequestError(err, http.StatusBadRequest)
+	}
+
+	e, err := h.User.Explain(ctx, ex.SubjectID, ex.Action, ex.UserID, ex.Attributes)
+	if err != nil {
+		switch validate.Cause(err) {
+		case database.ErrInvalidID:
+			return validate.NewRequestError(err, http.StatusBadRequest)
+		case database.ErrNotFound:
+			return validate.NewRequestError(err, http.StatusNotFound)
+		default:
+			return fmt.Errorf("explain[%+v]: %w", &ex, err)
+		}
+	}
+
+	return web.Respond(ctx, w, incoming.FromExplanation(e), http.StatusOK)
+}
+
+// Batch applies a batch of create, update, delete and set_roles operations
+// and reports the outcome of every operation. A failed all-or-nothing batch
+// is answered with a 422, nothing of it was applied.
+func (h Handlers) Batch(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, err := auth.GetClaims(ctx)
+	if err != nil {
+		return errors.New("claims missing from context")
+	}
+
+	//decode and validate json payload
+	var bu incoming.BatchUsers
+	if err := web.Decode(r, &bu); err != nil {
+		return validate.NewRequestError(fmt.Errorf("unable to decode payload: %w", err), http.StatusBadRequest)
+	}
+	if err := validate.Check(bu); err != nil {
+		return fmt.Errorf("validating data: %w", err)
+	}
+
+	atomic := bu.Mode == "atomic"
+
+	ids := make([]string, len(bu.Operations))
+	errs := make([]error, len(bu.Operations))
+
+	// Invalid operations are reported without being passed on.
+	var ops []dto.BatchOperation
+	var index []int
+	for i, bo := range bu.Operations {
+		ids[i] = bo.ID
+
+		op, err := toBatchOperation(bo)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		ops = append(ops, op)
+		index = append(index, i)
+	}
+
+	// An invalid operation fails an all-or-nothing batch before anything
+	// is applied.
+	if atomic && len(ops) < len(bu.Operations) {
+		for i := range errs {
+			if errs[i] == nil {
+				errs[i] = userCore.ErrBatchAborted
+			}
+		}
+	} else {
+		results, err := h.User.Batch(ctx, claims, ops, atomic, v.Now)
+		if err != nil {
+			return fmt.Errorf("batch: %w", err)
+		}
+		for j, res := range results {
+			ids[index[j]] = res.UserID
+			errs[index[j]] = res.Err
+		}
+	}
+
+	status := http.StatusOK
+	resp := incoming.BatchResponse{
+		Results: make([]incoming.BatchResult, len(bu.Operations)),
+	}
+	for i, bo := range bu.Operations {
+		resp.Results[i] = h.batchResult(i, bo, ids[i], errs[i])
+		if atomic && errs[i] != nil {
+			status = http.StatusUnprocessableEntity
+		}
+	}
+
+	return web.Respond(ctx, w, resp, status)
+}
+
+// toBatchOperation validates the operation of a batch and decodes the user
+// it carries.
+func toBatchOperation(bo incoming.BatchOperation) (dto.BatchOperation, error) {
+	if err := validate.Check(bo); err != nil {
+		return dto.BatchOperation{}, err
+	}
+
+	op := dto.BatchOperation{
+		Op:      bo.Op,
+		UserID:  bo.ID,
+		Version: bo.Version,
+	}
+
+	if bo.Op != dto.BatchCreate {
+		if err := validate.CheckID(bo.ID); err != nil {
+			return dto.BatchOperation{}, validate.FieldErrors{{Field: "id", Error: err.Error()}}
+		}
+	}
+
+	switch bo.Op {
+	case dto.BatchCreate:
+		var nu incoming.NewUser
+		if err := decodeBatchUser(bo.User, &nu); err != nil {
+			return dto.BatchOperation{}, err
+		}
+		op.NewUser = nu.ToDTONewUser()
+
+	case dto.BatchUpdate:
+		var uu incoming.UpdateUser
+		if err := decodeBatchUser(bo.User, &uu); err != nil {
+			return dto.BatchOperation{}, err
+		}
+		op.UpdateUser = uu.ToDTOUpdateUser()
+
+	case dto.BatchSetRoles:
+		op.UpdateUser = dto.UpdateUser{Roles: bo.Roles}
+	}
+
+	return op, nil
+}
+
+// decodeBatchUser decodes and validates the user of a batch operation.
+// Failures are reported as errors of the user field.
+func decodeBatchUser(data json.RawMessage, val interface{}) error {
+	if len(data) == 0 {
+		return validate.FieldErrors{{Field: "user", Error: "user is a required field"}}
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(val); err != nil {
+		return validate.FieldErrors{{Field: "user", Error: err.Error()}}
+	}
+
+	return validate.Check(val)
+}
+
+// batchResult reports the outcome of an operation of a batch the way the
+// endpoint of the single operation would.
+func (h Handlers) batchResult(i int, bo incoming.BatchOperation, id string, err error) incoming.BatchResult {
+	res := incoming.BatchResult{
+		Index: i,
+		Op:    bo.Op,
+		ID:    id,
+	}
+
+	if err == nil {
+		res.Status = http.StatusNoContent
+		if bo.Op == dto.BatchCreate {
+			res.Status = http.StatusCreated
+		}
+		return res
+	}
+
+	switch validate.Cause(err) {
+	case database.ErrInvalidID:
+		err = validate.NewRequestError(err, http.StatusBadRequest)
+	case database.ErrNotFound:
+		err = validate.NewRequestError(err, http.StatusNotFound)
+	case database.ErrForbidden:
+		err = validate.NewRequestError(err, http.StatusForbidden)
+	case database.ErrConflict:
+		err = conflictError(err, bo.Version)
+	case database.ErrDuplicate, database.ErrForeignKey, database.ErrCheck:
+		err = constraintError(err)
+	case userCore.ErrBatchAborted:
+		err = validate.NewRequestError(err, http.StatusFailedDependency)
+	}
+
+	var fields validate.FieldErrors
+	var re *validate.RequestError
+	switch {
+	case errors.As(err, &fields):
+		res.Status = http.StatusBadRequest
+		res.Error = "data validation error"
+		res.Fields = fields
+	case errors.As(err, &re):
+		res.Status = re.Status
+		res.Error = re.Error()
+		res.Fields = validate.GetFieldErrors(re.Fields)
+	default:
+		h.Log.Errorw("batch", "index", i, "op", bo.Op, "ERROR", err)
+		res.Status = http.StatusInternalServerError
+		res.Error = http.StatusText(http.StatusInternalServerError)
+	}
+
+	return res
+}
+
+// FindMe returns the profile of the authenticated user.
+func (h Handlers) FindMe(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	claims, err := auth.GetClaims(ctx)
+	if err != nil {
+		return errors.New("claims missing from context")
+	}
+
+	usr, err := h.User.FindByID(ctx, claims, claims.Subject)
+	if err != nil {
+		switch validate.Cause(err) {
+		case database.ErrInvalidID:
+			return validate.NewRequestError(err, http.StatusBadRequest)
+		case database.ErrForbidden:
+			return validate.NewRequestError(err, http.StatusForbidden)
+		case database.ErrNotFound:
+			return validate.NewRequestError(err, http.StatusNotFound)
+		default:
+			return fmt.Errorf("ID[%s]: %w", claims.Subject, err)
+		}
+	}
+
+	web.SetETag(w, usr.Version)
+
+	return web.Respond(ctx, w, incoming.FromDTOUser(usr), http.StatusOK)
+}
+
+// UpdateMe updates the profile of the authenticated user. Only the name and
+// the email can be changed, payloads with other fields like roles are
+// rejected.
+func (h Handlers) UpdateMe(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, err := auth.GetClaims(ctx)
+	if err != nil {
+		return errors.New("claims missing from context")
+	}
+
+	//decode and validate json payload
+	var um incoming.UpdateMe
+	if err := web.Decode(r, &um); err != nil {
+		return validate.NewRequestError(fmt.Errorf("unable to decode payload: %w", err), http.StatusBadRequest)
+	}
+	if err := validate.Check(um); err != nil {
+		return fmt.Errorf("validating data: %w", err)
+	}
+
+	version, err := h.ifMatch(r)
+	if err != nil {
+		return err
+	}
+
+	if err := h.User.Update(ctx, claims, claims.Subject, um.ToDTOUpdateUser(), version, v.Now); err != nil {
+		switch validate.Cause(err) {
+		case database.ErrInvalidID:
+			return validate.NewRequestError(err, http.StatusBadRequest)
+		case database.ErrForbidden:
+			return validate.NewRequestError(err, http.StatusForbidden)
+		case database.ErrNotFound:
+			return validate.NewRequestError(err, http.StatusNotFound)
+		case database.ErrConflict:
+			return conflictError(err, version)
+		case database.ErrDuplicate, database.ErrForeignKey, database.ErrCheck:
+			return constraintError(err)
+		default:
+			return fmt.Errorf("ID[%s] User[%+v]: %w", claims.Subject, &um, err)
+		}
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+// ChangePassword replaces the password of the authenticated user. The
+// current password has to be provided. Every token of the user is revoked,
+// the client has to request a new one.
+func (h Handlers) ChangePassword(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, err := auth.GetClaims(ctx)
+	if err != nil {
+		return errors.New("claims missing from context")
+	}
+
+	//decode and validate json payload
+	var cp incoming.ChangePassword
+	if err := web.Decode(r, &cp); err != nil {
+		return validate.NewRequestError(fmt.Errorf("unable to decode payload: %w", err), http.StatusBadRequest)
+	}
+	if err := validate.Check(cp); err != nil {
+		return fmt.Errorf("validating data: %w", err)
+	}
+
+	if err := h.User.ChangePassword(ctx, claims, cp.CurrentPassword, cp.Password, v.Now); err != nil {
+		var locked *userCore.LockedError
+		if errors.As(err, &locked) {
+			return lockedError(w, locked, v.Now)
+		}
+
+		switch validate.Cause(err) {
+		case database.ErrNotFound:
+			return validate.NewRequestError(err, http.StatusNotFound)
+		case database.ErrConflict:
+			return validate.NewRequestError(err, http.StatusConflict)
+		default:
+			return fmt.Errorf("ID[%s]: %w", claims.Subject, err)
+		}
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+// lockedError answers a lockout with a 429 that tells the client when to
+// try again.
+func lockedError(w http.ResponseWriter, locked *userCore.LockedError, now time.Time) error {
+	retry := int(math.Ceil(locked.Until.Sub(now).Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(retry))
+	return validate.NewRequestError(locked, http.StatusTooManyRequests)
+}
+
+// remoteHost returns the address of the client without the port.
+func remoteHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// parseFilter reads the user filter from the query string. Dates are
+// accepted in the RFC 3339 format or as plain dates, a plain end date
+// includes the whole day.
+func parseFilter(r *http.Request) (dto.UserFilter, error) {
+	q := r.URL.Query()
+
+	filter := dto.UserFilter{
+		Name:  param.String(q, "name"),
+		Email: param.String(q, "email"),
+		Role:  param.String(q, "role"),
+	}
+
+	var err error
+	if filter.StartCreatedDate, err = param.Date(q, "start_created_date", false); err != nil {
+		return dto.UserFilter{}, err
+	}
+	if filter.EndCreatedDate, err = param.Date(q, "end_created_date", true); err != nil {
+		return dto.UserFilter{}, err
+	}
+
+	return filter, nil
+}
+
+// ifMatch returns the version of the user the modification is based on from
+// the If-Match header. A nil version means the modification is applied to
+// whatever version is stored.
+func (h Handlers) ifMatch(r *http.Request) (*int, error) {
+	tags, ok := web.IfMatch(r)
+	if !ok {
+		if h.RequireIfMatch {
+			err := errors.New("the If-Match header with the version of the user is required")
+			return nil, validate.NewRequestError(err, http.StatusPreconditionRequired)
+		}
+		return nil, nil
+	}
+
+	if len(tags) == 1 && tags[0] == "*" {
+		return nil, nil
+	}
+
+	if len(tags) != 1 {
+		err := errors.New("the If-Match header must hold a single entity tag")
+		return nil, validate.NewRequestError(err, http.StatusPreconditionFailed)
+	}
+
+	version, err := strconv.Atoi(tags[0])
+	if err != nil {
+		err := fmt.Errorf("entity tag %q does not match", tags[0])
+		return nil, validate.NewRequestError(err, http.StatusPreconditionFailed)
+	}
+
+	return &version, nil
+}
+
+// conflictError reports a concurrent modification. A failed If-Match
+// precondition is answered with 412, otherwise the write lost a race and is
+// answered with 409.
+func conflictError(err error, version *int) error {
+	if version != nil {
+		return validate.NewRequestError(err, http.StatusPreconditionFailed)
+	}
+	return validate.NewRequestError(err, http.StatusConflict)
+}
+
+// constraintError answers a violated integrity constraint with a 409 that
+// names the offending field.
+func constraintError(err error) error {
+	var ce *database.ConstraintError
+	if !errors.As(err, &ce) {
+		return validate.NewRequestError(err, http.StatusConflict)
+	}
+
+	var msg string
+	switch ce.Err {
+	case database.ErrDuplicate:
+		msg = fmt.Sprintf("%s is already in use", ce.Field)
+	case database.ErrForeignKey:
+		msg = fmt.Sprintf("%s does not exist", ce.Field)
+	default:
+		msg = fmt.Sprintf("%s is not allowed", ce.Field)
+	}
+
+	re := validate.NewRequestError(ce, http.StatusConflict)
+	if ce.Field != "" {
+		re.Fields = validate.FieldErrors{{Field: ce.Field, Error: msg}}
+	}
+
+	return re
+}
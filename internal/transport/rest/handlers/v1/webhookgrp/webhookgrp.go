@@ -0,0 +1,207 @@
+// Package webhookgrp maintains the group of handlers for managing the
+// webhooks and their deliveries.
+package webhookgrp
+
+import (
+	"context"
+	"fmt"
+	webhookCore "github.com/AgeroFlynn/crud/internal/buisness/core/webhook"
+	"github.com/AgeroFlynn/crud/internal/buisness/sys/validate"
+	"github.com/AgeroFlynn/crud/internal/buisness/web/page"
+	"github.com/AgeroFlynn/crud/internal/foundation/database"
+	"github.com/AgeroFlynn/crud/internal/foundation/web"
+	"github.com/AgeroFlynn/crud/internal/transport/rest/incoming"
+	"net/http"
+)
+
+// Handlers manages the set of webhook endpoints.
+type Handlers struct {
+	Webhook webhookCore.Core
+}
+
+// Query returns all webhooks.
+func (h Handlers) Query(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	webhooks, err := h.Webhook.Query(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to query for webhooks: %w", err)
+	}
+
+	return web.Respond(ctx, w, incoming.FromDTOWebhookSlice(webhooks), http.StatusOK)
+}
+
+// QueryByID returns the specified webhook.
+func (h Handlers) QueryByID(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	id, err := pathID(r, "id")
+	if err != nil {
+		return err
+	}
+
+	webhook, err := h.Webhook.QueryByID(ctx, id)
+	if err != nil {
+		switch validate.Cause(err) {
+		case database.ErrNotFound:
+			return validate.NewRequestError(err, http.StatusNotFound)
+		default:
+			return fmt.Errorf("ID[%s]: %w", id, err)
+		}
+	}
+
+	return web.Respond(ctx, w, incoming.FromDTOWebhook(webhook), http.StatusOK)
+}
+
+// Create adds a new webhook. The response holds the secret the deliveries
+// are signed with, it isn't returned afterwards.
+func (h Handlers) Create(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	//decoding and validating json payload
+	var nw incoming.NewWebhook
+	if err := web.Decode(r, &nw); err != nil {
+		return validate.NewRequestError(fmt.Errorf("unable to decode payload: %w", err), http.StatusBadRequest)
+	}
+	if err := validate.Check(nw); err != nil {
+		return fmt.Errorf("validating data: %w", err)
+	}
+
+	webhook, err := h.Webhook.Create(ctx, nw.ToDTONewWebhook(), v.Now)
+	if err != nil {
+		switch validate.Cause(err) {
+		case webhookCore.ErrUnknownEvent, webhookCore.ErrInvalidURL:
+			return validate.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return fmt.Errorf("webhook[%s]: %w", nw.URL, err)
+		}
+	}
+
+	resp := incoming.FromDTOWebhook(webhook)
+	resp.Secret = webhook.Secret
+
+	return web.Respond(ctx, w, resp, http.StatusCreated)
+}
+
+// Update modifies a webhook.
+func (h Handlers) Update(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	//decode and validate json payload
+	var uw incoming.UpdateWebhook
+	if err := web.Decode(r, &uw); err != nil {
+		return validate.NewRequestError(fmt.Errorf("unable to decode payload: %w", err), http.StatusBadRequest)
+	}
+	if err := validate.Check(uw); err != nil {
+		return fmt.Errorf("validating data: %w", err)
+	}
+
+	id, err := pathID(r, "id")
+	if err != nil {
+		return err
+	}
+
+	if err := h.Webhook.Update(ctx, id, uw.ToDTOUpdateWebhook(), v.Now); err != nil {
+		switch validate.Cause(err) {
+		case database.ErrNotFound:
+			return validate.NewRequestError(err, http.StatusNotFound)
+		case webhookCore.ErrUnknownEvent, webhookCore.ErrInvalidURL:
+			return validate.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return fmt.Errorf("ID[%s]: %w", id, err)
+		}
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+// Delete removes a webhook together with its deliveries.
+func (h Handlers) Delete(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	id, err := pathID(r, "id")
+	if err != nil {
+		return err
+	}
+
+	if err := h.Webhook.Delete(ctx, id); err != nil {
+		switch validate.Cause(err) {
+		case database.ErrNotFound:
+			return validate.NewRequestError(err, http.StatusNotFound)
+		default:
+			return fmt.Errorf("ID[%s]: %w", id, err)
+		}
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+// QueryDeliveries returns a page of the delivery log of a webhook, newest
+// first.
+func (h Handlers) QueryDeliveries(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	id, err := pathID(r, "id")
+	if err != nil {
+		return err
+	}
+
+	pg, err := page.Parse(r)
+	if err != nil {
+		return validate.NewRequestError(err, http.StatusBadRequest)
+	}
+
+	deliveries, total, err := h.Webhook.QueryDeliveries(ctx, id, pg.Offset, pg.Limit)
+	if err != nil {
+		switch validate.Cause(err) {
+		case database.ErrNotFound:
+			return validate.NewRequestError(err, http.StatusNotFound)
+		default:
+			return fmt.Errorf("ID[%s]: %w", id, err)
+		}
+	}
+
+	page.SetLinks(w, r, pg, total)
+
+	return web.Respond(ctx, w, page.NewResponse(incoming.FromDTODeliverySlice(deliveries), total, pg), http.StatusOK)
+}
+
+// Redeliver queues a delivery again, the sender picks it up right away.
+func (h Handlers) Redeliver(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	id, err := pathID(r, "id")
+	if err != nil {
+		return err
+	}
+	deliveryID, err := pathID(r, "delivery_id")
+	if err != nil {
+		return err
+	}
+
+	delivery, err := h.Webhook.Redeliver(ctx, id, deliveryID, v.Now)
+	if err != nil {
+		switch validate.Cause(err) {
+		case database.ErrNotFound:
+			return validate.NewRequestError(err, http.StatusNotFound)
+		default:
+			return fmt.Errorf("ID[%s] deliveryID[%s]: %w", id, deliveryID, err)
+		}
+	}
+
+	return web.Respond(ctx, w, incoming.FromDTODelivery(delivery), http.StatusAccepted)
+}
+
+// pathID receives and validates the id path parameter with the name.
+func pathID(r *http.Request, name string) (string, error) {
+	id, err := web.Param(r, name)
+	if err != nil {
+		return "", validate.NewRequestError(err, http.StatusBadRequest)
+	}
+	if err := validate.CheckID(id); err != nil {
+		return "", validate.NewRequestError(err, http.StatusBadRequest)
+	}
+
+	return id, nil
+}
@@ -0,0 +1,59 @@
+package incoming
+
+import (
+	"github.com/AgeroFlynn/crud/internal/buisness/core/dto"
+	"time"
+)
+
+// AuditEntry records who did what to which target and how it went.
+type AuditEntry struct {
+	ID          string        `json:"id"`
+	ActorID     string        `json:"actor_id"`
+	Action      string        `json:"action"`
+	TargetType  string        `json:"target_type"`
+	TargetID    string        `json:"target_id"`
+	Changes     []AuditChange `json:"changes"`
+	TraceID     string        `json:"trace_id"`
+	RemoteAddr  string        `json:"remote_addr"`
+	Outcome     string        `json:"outcome"`
+	Reason      string        `json:"reason,omitempty"`
+	DateCreated time.Time     `json:"date_created"`
+}
+
+// AuditChange is the value of a field of the target before and after the
+// operation.
+type AuditChange struct {
+	Field  string `json:"field"`
+	Before string `json:"before,omitempty"`
+	After  string `json:"after,omitempty"`
+}
+
+func FromDTOAuditEntry(entry dto.AuditEntry) AuditEntry {
+	changes := []AuditChange{}
+	for _, c := range entry.Changes {
+		changes = append(changes, AuditChange{Field: c.Field, Before: c.Before, After: c.After})
+	}
+
+	return AuditEntry{
+		ID:          entry.ID,
+		ActorID:     entry.ActorID,
+		Action:      entry.Action,
+		TargetType:  entry.TargetType,
+		TargetID:    entry.TargetID,
+		Changes:     changes,
+		TraceID:     entry.TraceID,
+		RemoteAddr:  entry.RemoteAddr,
+		Outcome:     entry.Outcome,
+		Reason:      entry.Reason,
+		DateCreated: entry.DateCreated,
+	}
+}
+
+func FromDTOAuditEntrySlice(entries []dto.AuditEntry) []AuditEntry {
+	incomingEntries := []AuditEntry{}
+
+	for _, entry := range entries {
+		incomingEntries = append(incomingEntries, FromDTOAuditEntry(entry))
+	}
+	return incomingEntries
+}
@@ -0,0 +1,41 @@
+package incoming
+
+import (
+	"github.com/AgeroFlynn/crud/internal/buisness/core/dto"
+	"time"
+)
+
+// Login is an attempt to log in and how it went. The user id is empty when
+// the email doesn't belong to any user.
+type Login struct {
+	ID          string    `json:"id"`
+	UserID      string    `json:"user_id,omitempty"`
+	Email       string    `json:"email"`
+	RemoteAddr  string    `json:"remote_addr"`
+	UserAgent   string    `json:"user_agent"`
+	Outcome     string    `json:"outcome"`
+	Reason      string    `json:"reason,omitempty"`
+	DateCreated time.Time `json:"date_created"`
+}
+
+func FromDTOLogin(login dto.Login) Login {
+	return Login{
+		ID:          login.ID,
+		UserID:      login.UserID,
+		Email:       login.Email,
+		RemoteAddr:  login.RemoteAddr,
+		UserAgent:   login.UserAgent,
+		Outcome:     login.Outcome,
+		Reason:      login.Reason,
+		DateCreated: login.DateCreated,
+	}
+}
+
+func FromDTOLoginSlice(logins []dto.Login) []Login {
+	incomingLogins := []Login{}
+
+	for _, login := range logins {
+		incomingLogins = append(incomingLogins, FromDTOLogin(login))
+	}
+	return incomingLogins
+}
@@ -0,0 +1,49 @@
+package incoming
+
+import "github.com/AgeroFlynn/crud/internal/buisness/sys/policy"
+
+// Explain asks how the policies decide on a user taking an action on
+// another user.
+type Explain struct {
+	SubjectID  string            `json:"subject_id" validate:"required"`
+	Action     string            `json:"action" validate:"required"`
+	UserID     string            `json:"user_id" validate:"required"`
+	Attributes map[string]string `json:"attributes"`
+}
+
+// Explanation is the decision of the policies together with how every
+// policy fared.
+type Explanation struct {
+	Allowed bool          `json:"allowed"`
+	Policy  string        `json:"policy,omitempty"`
+	Reason  string        `json:"reason"`
+	Steps   []ExplainStep `json:"steps"`
+}
+
+// ExplainStep is how a single policy fared. Mismatch names the first part of
+// the policy the request didn't match.
+type ExplainStep struct {
+	Policy   string `json:"policy"`
+	Effect   string `json:"effect"`
+	Matched  bool   `json:"matched"`
+	Mismatch string `json:"mismatch,omitempty"`
+}
+
+func FromExplanation(e policy.Explanation) Explanation {
+	steps := []ExplainStep{}
+	for _, s := range e.Steps {
+		steps = append(steps, ExplainStep{
+			Policy:   s.Policy,
+			Effect:   s.Effect,
+			Matched:  s.Matched,
+			Mismatch: s.Mismatch,
+		})
+	}
+
+	return Explanation{
+		Allowed: e.Decision.Allowed,
+		Policy:  e.Decision.Policy,
+		Reason:  e.Decision.Reason,
+		Steps:   steps,
+	}
+}
@@ -0,0 +1,84 @@
+package incoming
+
+import (
+	"github.com/AgeroFlynn/crud/internal/buisness/core/dto"
+	"time"
+)
+
+// Role is a named set of permissions that can be assigned to users.
+type Role struct {
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Permissions []string  `json:"permissions"`
+	DateCreated time.Time `json:"date_created"`
+	DateUpdated time.Time `json:"date_updated"`
+}
+
+func FromDTORole(role dto.Role) Role {
+	permissions := role.Permissions
+	if permissions == nil {
+		permissions = []string{}
+	}
+
+	return Role{
+		Name:        role.Name,
+		Description: role.Description,
+		Permissions: permissions,
+		DateCreated: role.DateCreated,
+		DateUpdated: role.DateUpdated,
+	}
+}
+
+func FromDTORoleSlice(roles []dto.Role) []Role {
+	incomingRoles := []Role{}
+
+	for _, role := range roles {
+		incomingRoles = append(incomingRoles, FromDTORole(role))
+	}
+	return incomingRoles
+}
+
+// NewRole contains information needed to create a new Role. Names are
+// uppercase like the built-in ADMIN and USER roles.
+type NewRole struct {
+	Name        string   `json:"name" validate:"required,max=64,uppercase"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions" validate:"dive,required"`
+}
+
+func (nr *NewRole) ToDTONewRole() dto.NewRole {
+	return dto.NewRole{
+		Name:        nr.Name,
+		Description: nr.Description,
+		Permissions: nr.Permissions,
+	}
+}
+
+// UpdateRole defines what information may be provided to modify an existing
+// Role. Permissions replaces all permissions of the role when it is set.
+type UpdateRole struct {
+	Description *string  `json:"description"`
+	Permissions []string `json:"permissions" validate:"omitempty,dive,required"`
+}
+
+func (ur *UpdateRole) ToDTOUpdateRole() dto.UpdateRole {
+	return dto.UpdateRole{
+		Description: ur.Description,
+		Permissions: ur.Permissions,
+	}
+}
+
+// Permission is an action roles can grant.
+type Permission struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+func FromDTOPermissionSlice(permissions []dto.Permission) []Permission {
+	incomingPermissions := []Permission{}
+
+	for _, p := range permissions {
+		incomingPermissions = append(incomingPermissions, Permission{Name: p.Name, Description: p.Description})
+	}
+	return incomingPermissions
+}
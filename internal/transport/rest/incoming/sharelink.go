@@ -0,0 +1,108 @@
+package incoming
+
+import (
+	"github.com/AgeroFlynn/crud/internal/buisness/core/dto"
+	"time"
+)
+
+// ShareLink represents a signed link that exposes a user's contact card.
+type ShareLink struct {
+	ID          string     `json:"id"`
+	UserID      string     `json:"user_id"`
+	Token       string     `json:"token,omitempty"`
+	SingleUse   bool       `json:"single_use"`
+	UseCount    int        `json:"use_count"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	DateRevoked *time.Time `json:"date_revoked,omitempty"`
+	DateCreated time.Time  `json:"date_created"`
+}
+
+func FromDTOShareLink(sl dto.ShareLink) ShareLink {
+	return ShareLink{
+		ID:          sl.ID,
+		UserID:      sl.UserID,
+		SingleUse:   sl.SingleUse,
+		UseCount:    sl.UseCount,
+		ExpiresAt:   sl.ExpiresAt,
+		DateRevoked: sl.DateRevoked,
+		DateCreated: sl.DateCreated,
+	}
+}
+
+func FromDTOShareLinkSlice(links []dto.ShareLink) []ShareLink {
+	var incomingLinks []ShareLink
+
+	for _, link := range links {
+		incomingLinks = append(incomingLinks, FromDTOShareLink(link))
+	}
+	return incomingLinks
+}
+
+// NewShareLink contains information needed to create a new ShareLink. The
+// lifetime of the link is provided in seconds.
+type NewShareLink struct {
+	ExpiresIn int  `json:"expires_in" validate:"omitempty,min=60,max=2592000"`
+	SingleUse bool `json:"single_use"`
+}
+
+func (nsl *NewShareLink) ToDTONewShareLink(userID string) dto.NewShareLink {
+	return dto.NewShareLink{
+		UserID:    userID,
+		SingleUse: nsl.SingleUse,
+		ExpiresIn: time.Duration(nsl.ExpiresIn) * time.Second,
+	}
+}
+
+// ShareLinkAccess represents a single attempt to open a ShareLink.
+type ShareLinkAccess struct {
+	ID           string    `json:"id"`
+	ShareLinkID  string    `json:"share_link_id"`
+	RemoteAddr   string    `json:"remote_addr"`
+	UserAgent    string    `json:"user_agent"`
+	Granted      bool      `json:"granted"`
+	DateAccessed time.Time `json:"date_accessed"`
+}
+
+func FromDTOShareLinkAccess(a dto.ShareLinkAccess) ShareLinkAccess {
+	return ShareLinkAccess{
+		ID:           a.ID,
+		ShareLinkID:  a.ShareLinkID,
+		RemoteAddr:   a.RemoteAddr,
+		UserAgent:    a.UserAgent,
+		Granted:      a.Granted,
+		DateAccessed: a.DateAccessed,
+	}
+}
+
+func FromDTOShareLinkAccessSlice(accesses []dto.ShareLinkAccess) []ShareLinkAccess {
+	var incomingAccesses []ShareLinkAccess
+
+	for _, access := range accesses {
+		incomingAccesses = append(incomingAccesses, FromDTOShareLinkAccess(access))
+	}
+	return incomingAccesses
+}
+
+// PublicCard is the restricted view of a user exposed through a ShareLink.
+type PublicCard struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+func FromDTOPublicCard(c dto.PublicCard) PublicCard {
+	return PublicCard{
+		ID:    c.ID,
+		Name:  c.Name,
+		Email: c.Email,
+	}
+}
+
+func FromDTOPublicCardSlice(cards []dto.PublicCard) []PublicCard {
+	incomingCards := []PublicCard{}
+
+	for _, card := range cards {
+		incomingCards = append(incomingCards, FromDTOPublicCard(card))
+	}
+	return incomingCards
+}
@@ -2,43 +2,63 @@
 package incoming
 
 import (
+	"encoding/json"
 	"github.com/AgeroFlynn/crud/internal/buisness/core/dto"
+	"github.com/AgeroFlynn/crud/internal/buisness/sys/validate"
 	"github.com/lib/pq"
 	"time"
 )
 
 // User represents an individual user.
 type User struct {
-	ID           string         `json:"id"`
-	Name         string         `json:"name"`
-	Email        string         `json:"email"`
-	Roles        pq.StringArray `json:"roles"`
-	PasswordHash []byte         `json:"-"`
-	DateCreated  time.Time      `json:"date_created"`
-	DateUpdated  time.Time      `json:"date_updated"`
+	ID                string         `json:"id"`
+	Name              string         `json:"name"`
+	Email             string         `json:"email"`
+	Roles             pq.StringArray `json:"roles"`
+	PasswordHash      []byte         `json:"-"`
+	DateCreated       time.Time      `json:"date_created"`
+	DateUpdated       time.Time      `json:"date_updated"`
+	DateVerified      *time.Time     `json:"date_verified,omitempty"`
+	Status            string         `json:"status"`
+	StatusReason      string         `json:"status_reason,omitempty"`
+	DateStatusChanged *time.Time     `json:"date_status_changed,omitempty"`
+	LastLoginAt       *time.Time     `json:"last_login_at,omitempty"`
+	Version           int            `json:"version"`
 }
 
 func (u *User) ToDTOUser() dto.User {
 	return dto.User{
-		ID:           u.ID,
-		Name:         u.Name,
-		Email:        u.Email,
-		Roles:        u.Roles,
-		PasswordHash: u.PasswordHash,
-		DateCreated:  u.DateCreated,
-		DateUpdated:  u.DateUpdated,
+		ID:                u.ID,
+		Name:              u.Name,
+		Email:             u.Email,
+		Roles:             u.Roles,
+		PasswordHash:      u.PasswordHash,
+		DateCreated:       u.DateCreated,
+		DateUpdated:       u.DateUpdated,
+		DateVerified:      u.DateVerified,
+		Status:            u.Status,
+		StatusReason:      u.StatusReason,
+		DateStatusChanged: u.DateStatusChanged,
+		LastLoginAt:       u.LastLoginAt,
+		Version:           u.Version,
 	}
 }
 
 func FromDTOUser(user dto.User) User {
 	return User{
-		ID:           user.ID,
-		Name:         user.Name,
-		Email:        user.Email,
-		Roles:        user.Roles,
-		PasswordHash: user.PasswordHash,
-		DateCreated:  user.DateCreated,
-		DateUpdated:  user.DateUpdated,
+		ID:                user.ID,
+		Name:              user.Name,
+		Email:             user.Email,
+		Roles:             user.Roles,
+		PasswordHash:      user.PasswordHash,
+		DateCreated:       user.DateCreated,
+		DateUpdated:       user.DateUpdated,
+		DateVerified:      user.DateVerified,
+		Status:            user.Status,
+		StatusReason:      user.StatusReason,
+		DateStatusChanged: user.DateStatusChanged,
+		LastLoginAt:       user.LastLoginAt,
+		Version:           user.Version,
 	}
 }
 
@@ -67,6 +87,7 @@ type NewUser struct {
 	Roles           []string `json:"roles" validate:"required"`
 	Password        string   `json:"password" validate:"required"`
 	PasswordConfirm string   `json:"password_confirm" validate:"eqfield=Password"`
+	Status          string   `json:"status" validate:"omitempty,oneof=ACTIVE PENDING"`
 }
 
 func (nu *NewUser) ToDTONewUser() dto.NewUser {
@@ -76,6 +97,7 @@ func (nu *NewUser) ToDTONewUser() dto.NewUser {
 		Roles:           nu.Roles,
 		Password:        nu.Password,
 		PasswordConfirm: nu.PasswordConfirm,
+		Status:          nu.Status,
 	}
 }
 
@@ -86,6 +108,7 @@ func FromDTONewUser(nu dto.NewUser) NewUser {
 		Roles:           nu.Roles,
 		Password:        nu.Password,
 		PasswordConfirm: nu.PasswordConfirm,
+		Status:          nu.Status,
 	}
 }
 
@@ -140,3 +163,120 @@ func ToDTOUpdateUserSlice(users []UpdateUser) []dto.UpdateUser {
 	}
 	return dtoNewUsers
 }
+
+// PatchUser is the document patch requests are applied to. It holds the
+// fields of a User that can be modified through a patch. After the patch is
+// applied the whole document is validated again.
+type PatchUser struct {
+	Name  string   `json:"name" validate:"required"`
+	Email string   `json:"email" validate:"required,email"`
+	Roles []string `json:"roles"`
+}
+
+func NewPatchUser(user dto.User) PatchUser {
+	roles := []string(user.Roles)
+	if roles == nil {
+		roles = []string{}
+	}
+
+	return PatchUser{
+		Name:  user.Name,
+		Email: user.Email,
+		Roles: roles,
+	}
+}
+
+func (pu *PatchUser) ToDTOUpdateUser() dto.UpdateUser {
+	roles := pu.Roles
+	if roles == nil {
+		roles = []string{}
+	}
+
+	return dto.UpdateUser{
+		Name:  &pu.Name,
+		Email: &pu.Email,
+		Roles: roles,
+	}
+}
+
+// VerifyUser contains the token of a verification link.
+type VerifyUser struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// ForgotPassword contains the email to send a password reset link to.
+type ForgotPassword struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+// ResetPassword contains the token of a password reset link and the new
+// password.
+type ResetPassword struct {
+	Token           string `json:"token" validate:"required"`
+	Password        string `json:"password" validate:"required"`
+	PasswordConfirm string `json:"password_confirm" validate:"eqfield=Password"`
+}
+
+// UpdateMe defines what users may modify of their own profile. Roles and the
+// password are left out, the password has its own endpoint and roles are
+// granted by admins only.
+type UpdateMe struct {
+	Name  *string `json:"name"`
+	Email *string `json:"email" validate:"omitempty,email"`
+}
+
+func (um *UpdateMe) ToDTOUpdateUser() dto.UpdateUser {
+	return dto.UpdateUser{
+		Name:  um.Name,
+		Email: um.Email,
+	}
+}
+
+// SetStatus contains the status an admin moves a user to and why.
+type SetStatus struct {
+	Status string `json:"status" validate:"required,oneof=ACTIVE PENDING SUSPENDED DISABLED"`
+	Reason string `json:"reason" validate:"required"`
+}
+
+// BatchUsers contains a batch of user modifications. In the atomic mode
+// either all operations are applied or none, in the best_effort mode every
+// operation that succeeds is applied.
+type BatchUsers struct {
+	Mode       string           `json:"mode" validate:"required,oneof=atomic best_effort"`
+	Operations []BatchOperation `json:"operations" validate:"required,min=1,max=100"`
+}
+
+// BatchOperation is one operation of a batch. Create takes a NewUser as the
+// user and update takes an UpdateUser. Set_roles takes the roles. Every
+// operation but create needs the id of the user.
+type BatchOperation struct {
+	Op      string          `json:"op" validate:"required,oneof=create update delete set_roles"`
+	ID      string          `json:"id" validate:"required_unless=Op create"`
+	User    json.RawMessage `json:"user"`
+	Roles   []string        `json:"roles" validate:"required_if=Op set_roles"`
+	Version *int            `json:"version"`
+}
+
+// BatchResult is the outcome of an operation of a batch. Failed operations
+// carry the error and the fields that failed validation.
+type BatchResult struct {
+	Index  int                  `json:"index"`
+	Op     string               `json:"op"`
+	ID     string               `json:"id,omitempty"`
+	Status int                  `json:"status"`
+	Error  string               `json:"error,omitempty"`
+	Fields validate.FieldErrors `json:"fields,omitempty"`
+}
+
+// BatchResponse holds the outcome of every operation in the order of the
+// batch.
+type BatchResponse struct {
+	Results []BatchResult `json:"results"`
+}
+
+// ChangePassword contains the current password of a user and the new one.
+type ChangePassword struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+	Password        string `json:"password" validate:"required"`
+	PasswordConfirm string `json:"password_confirm" validate:"eqfield=Password"`
+}
@@ -0,0 +1,134 @@
+package incoming
+
+import (
+	"encoding/json"
+	"github.com/AgeroFlynn/crud/internal/buisness/core/dto"
+	"time"
+)
+
+// Webhook subscribes a downstream system to domain events. The secret is
+// only returned when the webhook is created.
+type Webhook struct {
+	ID          string    `json:"id"`
+	URL         string    `json:"url"`
+	Secret      string    `json:"secret,omitempty"`
+	Events      []string  `json:"events"`
+	Description string    `json:"description"`
+	Active      bool      `json:"active"`
+	DateCreated time.Time `json:"date_created"`
+	DateUpdated time.Time `json:"date_updated"`
+}
+
+func FromDTOWebhook(webhook dto.Webhook) Webhook {
+	events := webhook.Events
+	if events == nil {
+		events = []string{}
+	}
+
+	return Webhook{
+		ID:          webhook.ID,
+		URL:         webhook.URL,
+		Events:      events,
+		Description: webhook.Description,
+		Active:      webhook.Active,
+		DateCreated: webhook.DateCreated,
+		DateUpdated: webhook.DateUpdated,
+	}
+}
+
+func FromDTOWebhookSlice(webhooks []dto.Webhook) []Webhook {
+	incomingWebhooks := []Webhook{}
+
+	for _, webhook := range webhooks {
+		incomingWebhooks = append(incomingWebhooks, FromDTOWebhook(webhook))
+	}
+	return incomingWebhooks
+}
+
+// NewWebhook contains information needed to create a new Webhook. A secret is
+// generated when none is provided.
+type NewWebhook struct {
+	URL         string   `json:"url" validate:"required,url"`
+	Secret      string   `json:"secret" validate:"omitempty,min=16"`
+	Events      []string `json:"events" validate:"required,min=1,dive,required"`
+	Description string   `json:"description"`
+}
+
+func (nw *NewWebhook) ToDTONewWebhook() dto.NewWebhook {
+	return dto.NewWebhook{
+		URL:         nw.URL,
+		Secret:      nw.Secret,
+		Events:      nw.Events,
+		Description: nw.Description,
+	}
+}
+
+// UpdateWebhook defines what information may be provided to modify an
+// existing Webhook. All fields are optional.
+type UpdateWebhook struct {
+	URL         *string  `json:"url" validate:"omitempty,url"`
+	Secret      *string  `json:"secret" validate:"omitempty,min=16"`
+	Events      []string `json:"events" validate:"omitempty,min=1,dive,required"`
+	Description *string  `json:"description"`
+	Active      *bool    `json:"active"`
+}
+
+func (uw *UpdateWebhook) ToDTOUpdateWebhook() dto.UpdateWebhook {
+	return dto.UpdateWebhook{
+		URL:         uw.URL,
+		Secret:      uw.Secret,
+		Events:      uw.Events,
+		Description: uw.Description,
+		Active:      uw.Active,
+	}
+}
+
+// Delivery is an event queued for a webhook together with the outcome of the
+// attempts to send it.
+type Delivery struct {
+	ID              string          `json:"id"`
+	WebhookID       string          `json:"webhook_id"`
+	EventID         string          `json:"event_id"`
+	EventType       string          `json:"event_type"`
+	Payload         json.RawMessage `json:"payload"`
+	Status          string          `json:"status"`
+	Attempts        int             `json:"attempts"`
+	ResponseCode    int             `json:"response_code,omitempty"`
+	LastError       string          `json:"last_error,omitempty"`
+	DateCreated     time.Time       `json:"date_created"`
+	DateNextAttempt *time.Time      `json:"date_next_attempt,omitempty"`
+	DateDelivered   *time.Time      `json:"date_delivered,omitempty"`
+}
+
+func FromDTODelivery(delivery dto.Delivery) Delivery {
+	d := Delivery{
+		ID:            delivery.ID,
+		WebhookID:     delivery.WebhookID,
+		EventID:       delivery.EventID,
+		EventType:     delivery.EventType,
+		Payload:       delivery.Payload,
+		Status:        delivery.Status,
+		Attempts:      delivery.Attempts,
+		ResponseCode:  delivery.ResponseCode,
+		LastError:     delivery.LastError,
+		DateCreated:   delivery.DateCreated,
+		DateDelivered: delivery.DateDelivered,
+	}
+
+	// The next attempt only means something while the delivery is pending.
+	if delivery.Status == dto.DeliveryPending {
+		next := delivery.DateNextAttempt
+		d.DateNextAttempt = &next
+	}
+
+	return d
+}
+
+func FromDTODeliverySlice(deliveries []dto.Delivery) []Delivery {
+	incomingDeliveries := []Delivery{}
+
+	for _, delivery := range deliveries {
+		incomingDeliveries = append(incomingDeliveries, FromDTODelivery(delivery))
+	}
+	return incomingDeliveries
+}
@@ -0,0 +1,191 @@
+package tests
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"github.com/AgeroFlynn/crud/internal/buisness/sys/tests"
+	"github.com/AgeroFlynn/crud/internal/buisness/web/page"
+	"github.com/AgeroFlynn/crud/internal/transport/rest/incoming"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// auditLog validates modifications and denials are recorded in the audit
+// log and that admins can read and export it but not change it.
+func (ut *UserTests) auditLog(t *testing.T) {
+	const (
+		adminID = "5cf37266-3473-4006-984f-9325122678b7"
+		userID  = "45b5fbd3-755f-4379-8f07-a58d4a30fa2f"
+	)
+
+	send := func(method string, target string, body string, token string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, target, strings.NewReader(body))
+		w := httptest.NewRecorder()
+
+		r.Header.Set("Authorization", "Bearer "+token)
+		ut.app.ServeHTTP(w, r)
+
+		return w
+	}
+
+	query := func(target string) page.Response[incoming.AuditEntry] {
+		w := send(http.MethodGet, target, "", ut.adminToken)
+		if w.Code != http.StatusOK {
+			t.Fatalf("querying the audit log: status %d", w.Code)
+		}
+
+		var got page.Response[incoming.AuditEntry]
+		if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+			t.Fatalf("decoding the audit log: %s", err)
+		}
+		return got
+	}
+
+	t.Log("Given the need to audit the operations on users.")
+	{
+		w := send(http.MethodPost, "/v1/users", `{"name": "Audrey Smith", "email": "audrey@example.com", "roles": ["USER"], "password": "gophers", "password_confirm": "gophers"}`, ut.adminToken)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("creating user: status %d", w.Code)
+		}
+
+		var usr incoming.User
+		if err := json.NewDecoder(w.Body).Decode(&usr); err != nil {
+			t.Fatalf("decoding user: %s", err)
+		}
+
+		if w := send(http.MethodPut, "/v1/users/"+usr.ID, `{"name": "Audrey Hopper"}`, ut.adminToken); w.Code != http.StatusNoContent {
+			t.Fatalf("updating user: status %d", w.Code)
+		}
+		if w := send(http.MethodDelete, "/v1/users/"+usr.ID, "", ut.adminToken); w.Code != http.StatusNoContent {
+			t.Fatalf("deleting user: status %d", w.Code)
+		}
+
+		testID := 0
+		t.Logf("\tTest %d:\tWhen an admin creates, updates and deletes a user.", testID)
+		{
+			got := query("/v1/audit?target_id=" + usr.ID)
+
+			var actions []string
+			for _, entry := range got.Items {
+				actions = append(actions, entry.Action)
+			}
+			if diff := cmp.Diff(actions, []string{"delete", "update", "create"}); diff != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould record every operation, newest first. Diff:\n%s", tests.Failed, testID, diff)
+			}
+			t.Logf("\t%s\tTest %d:\tShould record every operation, newest first.", tests.Success, testID)
+
+			for _, entry := range got.Items {
+				if entry.ActorID != adminID || entry.Outcome != "success" || entry.TraceID == "" || entry.RemoteAddr != "192.0.2.1" {
+					t.Fatalf("\t%s\tTest %d:\tShould record the actor, outcome, trace and address : %+v", tests.Failed, testID, entry)
+				}
+			}
+			t.Logf("\t%s\tTest %d:\tShould record the actor, outcome, trace and address.", tests.Success, testID)
+
+			exp := []incoming.AuditChange{{Field: "name", Before: "Audrey Smith", After: "Audrey Hopper"}}
+			if diff := cmp.Diff(got.Items[1].Changes, exp); diff != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould record what the update changed. Diff:\n%s", tests.Failed, testID, diff)
+			}
+			t.Logf("\t%s\tTest %d:\tShould record what the update changed.", tests.Success, testID)
+
+			for _, change := range got.Items[2].Changes {
+				if change.Field == "password" && (change.Before != "" || change.After != "") {
+					t.Fatalf("\t%s\tTest %d:\tShould NOT record the password hash : %+v", tests.Failed, testID, change)
+				}
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT record the password hash.", tests.Success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen a user is denied by the policies.", testID)
+		{
+			if w := send(http.MethodGet, "/v1/users/"+adminID, "", ut.userToken); w.Code != http.StatusForbidden {
+				t.Fatalf("reading admin: status %d", w.Code)
+			}
+
+			got := query("/v1/audit?actor_id=" + userID + "&outcome=denied")
+			if len(got.Items) == 0 || got.Items[0].Action != "read" || got.Items[0].TargetID != adminID || got.Items[0].Reason != "no_matching_policy" {
+				t.Fatalf("\t%s\tTest %d:\tShould record the denial with its reason : %+v", tests.Failed, testID, got.Items)
+			}
+			t.Logf("\t%s\tTest %d:\tShould record the denial with its reason.", tests.Success, testID)
+		}
+
+		testID = 2
+		t.Logf("\tTest %d:\tWhen exporting the audit log.", testID)
+		{
+			w := send(http.MethodGet, "/v1/audit/export?target_id="+usr.ID, "", ut.adminToken)
+			if w.Code != http.StatusOK {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 200 for the response : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 200 for the response.", tests.Success, testID)
+
+			records, err := csv.NewReader(w.Body).ReadAll()
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to read the CSV : %v", tests.Failed, testID, err)
+			}
+			if len(records) != 4 || records[0][0] != "id" || records[1][3] != "delete" {
+				t.Fatalf("\t%s\tTest %d:\tShould export a header and every entry : %v", tests.Failed, testID, records)
+			}
+			t.Logf("\t%s\tTest %d:\tShould export a header and every entry.", tests.Success, testID)
+
+			if w := send(http.MethodGet, "/v1/audit/export?format=xml", "", ut.adminToken); w.Code != http.StatusBadRequest {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 400 for an unknown format : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 400 for an unknown format.", tests.Success, testID)
+		}
+
+		testID = 3
+		t.Logf("\tTest %d:\tWhen the audit log is accessed without the audit:read permission.", testID)
+		{
+			if w := send(http.MethodGet, "/v1/audit", "", ut.userToken); w.Code != http.StatusForbidden {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 403 for the response : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 403 for the response.", tests.Success, testID)
+		}
+
+		testID = 4
+		t.Logf("\tTest %d:\tWhen modifying the audit log.", testID)
+		{
+			if w := send(http.MethodDelete, "/v1/audit", "", ut.adminToken); w.Code != http.StatusMethodNotAllowed {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 405 for the response : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 405 for the response.", tests.Success, testID)
+		}
+
+		testID = 5
+		t.Logf("\tTest %d:\tWhen an atomic batch is rolled back.", testID)
+		{
+			w := send(http.MethodPost, "/v1/users", `{"name": "Agnes Wright", "email": "agnes@example.com", "roles": ["USER"], "password": "gophers", "password_confirm": "gophers"}`, ut.adminToken)
+			if w.Code != http.StatusCreated {
+				t.Fatalf("creating user: status %d", w.Code)
+			}
+
+			var usr incoming.User
+			if err := json.NewDecoder(w.Body).Decode(&usr); err != nil {
+				t.Fatalf("decoding user: %s", err)
+			}
+
+			body := `{"mode": "atomic", "operations": [
+				{"op": "update", "id": "` + usr.ID + `", "user": {"name": "Agnes Brown"}},
+				{"op": "update", "id": "00000000-0000-4000-8000-000000000000", "user": {"name": "Nobody"}}
+			]}`
+			if w := send(http.MethodPost, "/v1/users:batch", body, ut.adminToken); w.Code != http.StatusUnprocessableEntity {
+				t.Fatalf("applying batch: status %d", w.Code)
+			}
+
+			got := query("/v1/audit?target_id=" + usr.ID)
+
+			var outcomes []string
+			for _, entry := range got.Items {
+				outcomes = append(outcomes, entry.Action+":"+entry.Outcome)
+			}
+			if diff := cmp.Diff(outcomes, []string{"update:failure", "create:success"}); diff != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould only keep the failure of the operations rolled back. Diff:\n%s", tests.Failed, testID, diff)
+			}
+			t.Logf("\t%s\tTest %d:\tShould only keep the failure of the operations rolled back.", tests.Success, testID)
+		}
+	}
+}
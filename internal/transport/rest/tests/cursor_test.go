@@ -0,0 +1,209 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/AgeroFlynn/crud/internal/buisness/sys/tests"
+	"github.com/AgeroFlynn/crud/internal/buisness/web/page"
+	"github.com/AgeroFlynn/crud/internal/transport/rest/incoming"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// cursorPaging validates the user and directory listings can be walked
+// forward and backward with cursors, and that clients are never left
+// without a way back from an empty page.
+func (ut *UserTests) cursorPaging(t *testing.T) {
+	send := func(method string, target string, body string, token string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, target, strings.NewReader(body))
+		w := httptest.NewRecorder()
+
+		r.Header.Set("Authorization", "Bearer "+token)
+		ut.app.ServeHTTP(w, r)
+
+		return w
+	}
+
+	// walk follows the links of the relation from the target and returns
+	// the names of the items of every page.
+	walk := func(target string, rel string, token string) [][]string {
+		var pages [][]string
+		for target != "" {
+			w := send(http.MethodGet, target, "", token)
+			if w.Code != http.StatusOK {
+				t.Fatalf("listing %s: status %d", target, w.Code)
+			}
+
+			var got page.CursorResponse[incoming.PublicCard]
+			if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+				t.Fatalf("decoding %s: %s", target, err)
+			}
+
+			var names []string
+			for _, item := range got.Items {
+				names = append(names, item.Name)
+			}
+			pages = append(pages, names)
+
+			target = link(w.Header(), rel)
+			if len(pages) > 10 {
+				t.Fatalf("walking %s: too many pages", rel)
+			}
+		}
+		return pages
+	}
+
+	names := []string{"Cursor Anna", "Cursor Boris", "Cursor Chloe", "Cursor Dmitri", "Cursor Elena"}
+	ids := make(map[string]string)
+	for _, name := range names {
+		first := strings.ToLower(strings.Fields(name)[1])
+		body := fmt.Sprintf(`{"name": %q, "email": "%s.cursor@example.com", "roles": ["USER"], "password": "gophers", "password_confirm": "gophers"}`, name, first)
+
+		w := send(http.MethodPost, "/v1/users", body, ut.adminToken)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("creating %s: status %d", name, w.Code)
+		}
+
+		var usr incoming.User
+		if err := json.NewDecoder(w.Body).Decode(&usr); err != nil {
+			t.Fatalf("decoding user: %s", err)
+		}
+		ids[name] = usr.ID
+	}
+
+	t.Log("Given the need to page through listings with cursors.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen walking the users forward and backward.", testID)
+		{
+			pages := walk("/v1/users?cursor=&rows=2&name=cursor&orderBy=name", "next", ut.adminToken)
+			exp := [][]string{names[0:2], names[2:4], names[4:5]}
+			if diff := cmp.Diff(exp, pages); diff != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould get every user once in order. Diff:\n%s", tests.Failed, testID, diff)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get every user once in order.", tests.Success, testID)
+
+			// Start from the last page and walk back to the first one.
+			w := send(http.MethodGet, "/v1/users?cursor=&rows=2&name=cursor&orderBy=name", "", ut.adminToken)
+			second := link(w.Header(), "next")
+			w = send(http.MethodGet, second, "", ut.adminToken)
+			third := link(w.Header(), "next")
+
+			pages = walk(third, "prev", ut.adminToken)
+			exp = [][]string{names[4:5], names[2:4], names[0:2]}
+			if diff := cmp.Diff(exp, pages); diff != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould get the same pages walking back. Diff:\n%s", tests.Failed, testID, diff)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get the same pages walking back.", tests.Success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen the directory is walked by a user.", testID)
+		{
+			w := send(http.MethodPut, "/v1/users/"+ids["Cursor Chloe"]+"/status", `{"status": "SUSPENDED", "reason": "away"}`, ut.adminToken)
+			if w.Code != http.StatusNoContent {
+				t.Fatalf("suspending user: status %d", w.Code)
+			}
+
+			pages := walk("/v1/directory?rows=2&name=cursor", "next", ut.userToken)
+			exp := [][]string{{"Cursor Anna", "Cursor Boris"}, {"Cursor Dmitri", "Cursor Elena"}}
+			if diff := cmp.Diff(exp, pages); diff != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould get the active users ordered by name. Diff:\n%s", tests.Failed, testID, diff)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get the active users ordered by name.", tests.Success, testID)
+
+			w = send(http.MethodGet, "/v1/directory?rows=1&name=cursor&orderBy=email,DESC", "", ut.userToken)
+			var got map[string]any
+			if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+				t.Fatalf("decoding the directory: %s", err)
+			}
+			items, _ := got["items"].([]any)
+			if len(items) != 1 {
+				t.Fatalf("\t%s\tTest %d:\tShould get a single card : got %v", tests.Failed, testID, got["items"])
+			}
+			card := items[0].(map[string]any)
+			if card["email"] != "elena.cursor@example.com" || len(card) != 3 {
+				t.Fatalf("\t%s\tTest %d:\tShould only expose the id, name and email : got %v", tests.Failed, testID, card)
+			}
+			t.Logf("\t%s\tTest %d:\tShould only expose the id, name and email.", tests.Success, testID)
+
+			// A cursor of the users listing ordered by a field the cards don't
+			// have can't be used.
+			w = send(http.MethodGet, "/v1/users?cursor=&rows=2&orderBy=date_created", "", ut.adminToken)
+			next := link(w.Header(), "next")
+			cursor := next[strings.Index(next, "cursor=")+len("cursor="):]
+			cursor = cursor[:strings.IndexAny(cursor+"&", "&")]
+			if w := send(http.MethodGet, "/v1/directory?cursor="+cursor, "", ut.userToken); w.Code != http.StatusBadRequest {
+				t.Fatalf("\t%s\tTest %d:\tShould reject the cursor of another ordering : status %d", tests.Failed, testID, w.Code)
+			}
+			if w := send(http.MethodGet, "/v1/directory?cursor=not-a-cursor", "", ut.userToken); w.Code != http.StatusBadRequest {
+				t.Fatalf("\t%s\tTest %d:\tShould reject a tampered cursor : status %d", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould reject foreign and tampered cursors.", tests.Success, testID)
+		}
+
+		testID = 2
+		t.Logf("\tTest %d:\tWhen the users before a cursor are removed.", testID)
+		{
+			w := send(http.MethodGet, "/v1/users?cursor=&rows=2&name=cursor&orderBy=name", "", ut.adminToken)
+			w = send(http.MethodGet, link(w.Header(), "next"), "", ut.adminToken)
+			prev := link(w.Header(), "prev")
+
+			for _, name := range names[0:2] {
+				if w := send(http.MethodDelete, "/v1/users/"+ids[name], "", ut.adminToken); w.Code != http.StatusNoContent {
+					t.Fatalf("deleting %s: status %d", name, w.Code)
+				}
+			}
+
+			pages := walk(prev, "next", ut.adminToken)
+			exp := [][]string{nil, names[2:4], names[4:5]}
+			if diff := cmp.Diff(exp, pages); diff != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould lead from the empty page back to the users. Diff:\n%s", tests.Failed, testID, diff)
+			}
+			t.Logf("\t%s\tTest %d:\tShould lead from the empty page back to the users.", tests.Success, testID)
+		}
+
+		testID = 3
+		t.Logf("\tTest %d:\tWhen the users after a cursor are removed.", testID)
+		{
+			w := send(http.MethodGet, "/v1/users?cursor=&rows=2&name=cursor&orderBy=name", "", ut.adminToken)
+			next := link(w.Header(), "next")
+
+			for _, name := range names[4:5] {
+				if w := send(http.MethodDelete, "/v1/users/"+ids[name], "", ut.adminToken); w.Code != http.StatusNoContent {
+					t.Fatalf("deleting %s: status %d", name, w.Code)
+				}
+			}
+
+			pages := walk(next, "prev", ut.adminToken)
+			exp := [][]string{nil, names[2:4]}
+			if diff := cmp.Diff(exp, pages); diff != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould lead from the empty page back to the users. Diff:\n%s", tests.Failed, testID, diff)
+			}
+			t.Logf("\t%s\tTest %d:\tShould lead from the empty page back to the users.", tests.Success, testID)
+		}
+
+		for _, name := range names[2:4] {
+			ut.deleteUser204(t, ids[name])
+		}
+	}
+}
+
+// linkRe matches the relations of a Link header.
+var linkRe = regexp.MustCompile(`<([^>]*)>; rel="([^"]*)"`)
+
+// link returns the target of the relation in the Link header, empty when
+// there is none.
+func link(h http.Header, rel string) string {
+	for _, m := range linkRe.FindAllStringSubmatch(h.Get("Link"), -1) {
+		if m[2] == rel {
+			return m[1]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,194 @@
+package tests
+
+import (
+	"encoding/json"
+	"github.com/AgeroFlynn/crud/internal/buisness/core/dto"
+	userCore "github.com/AgeroFlynn/crud/internal/buisness/core/user"
+	"github.com/AgeroFlynn/crud/internal/buisness/sys/tests"
+	"github.com/AgeroFlynn/crud/internal/buisness/web/page"
+	"github.com/AgeroFlynn/crud/internal/transport/rest/incoming"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// loginHistory validates successful and failed logins are recorded, that
+// users see their own logins and that admins can query everyone's.
+func (ut *UserTests) loginHistory(t *testing.T) {
+	const userAgent = "login-history-test/1.0"
+
+	send := func(method string, target string, body string, token string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, target, strings.NewReader(body))
+		w := httptest.NewRecorder()
+
+		r.Header.Set("Authorization", "Bearer "+token)
+		ut.app.ServeHTTP(w, r)
+
+		return w
+	}
+
+	loginAs := func(email string, password string, userAgent string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/v1/users/token", nil)
+		w := httptest.NewRecorder()
+
+		r.SetBasicAuth(email, password)
+		r.Header.Set("User-Agent", userAgent)
+		ut.app.ServeHTTP(w, r)
+
+		return w
+	}
+	login := func(email string, password string) *httptest.ResponseRecorder {
+		return loginAs(email, password, userAgent)
+	}
+
+	query := func(target string, token string) page.Response[incoming.Login] {
+		w := send(http.MethodGet, target, "", token)
+		if w.Code != http.StatusOK {
+			t.Fatalf("querying the login history: status %d", w.Code)
+		}
+
+		var got page.Response[incoming.Login]
+		if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+			t.Fatalf("decoding the login history: %s", err)
+		}
+		return got
+	}
+
+	t.Log("Given the need to keep the history of the logins.")
+	{
+		w := send(http.MethodPost, "/v1/users", `{"name": "Lena Logan", "email": "lena@example.com", "roles": ["USER"], "password": "gophers", "password_confirm": "gophers"}`, ut.adminToken)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("creating user: status %d", w.Code)
+		}
+
+		var usr incoming.User
+		if err := json.NewDecoder(w.Body).Decode(&usr); err != nil {
+			t.Fatalf("decoding user: %s", err)
+		}
+		if usr.LastLoginAt != nil {
+			t.Fatalf("new user has a last login: %v", usr.LastLoginAt)
+		}
+
+		if w := login("lena@example.com", "wrong-password"); w.Code != http.StatusUnauthorized {
+			t.Fatalf("logging in with a wrong password: status %d", w.Code)
+		}
+		if w := login("nobody-logs-in@example.com", "gophers"); w.Code != http.StatusUnauthorized {
+			t.Fatalf("logging in with an unknown email: status %d", w.Code)
+		}
+
+		w = login("lena@example.com", "gophers")
+		if w.Code != http.StatusOK {
+			t.Fatalf("logging in: status %d", w.Code)
+		}
+
+		var tkn struct {
+			Token string `json:"token"`
+		}
+		if err := json.NewDecoder(w.Body).Decode(&tkn); err != nil {
+			t.Fatalf("decoding token: %s", err)
+		}
+
+		testID := 0
+		t.Logf("\tTest %d:\tWhen a user logs in.", testID)
+		{
+			w := send(http.MethodGet, "/v1/users/me", "", tkn.Token)
+			if w.Code != http.StatusOK {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve the user : status %d", tests.Failed, testID, w.Code)
+			}
+
+			var me incoming.User
+			if err := json.NewDecoder(w.Body).Decode(&me); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to unmarshal the response : %v", tests.Failed, testID, err)
+			}
+			if me.LastLoginAt == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould get the time of the last login.", tests.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get the time of the last login.", tests.Success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen users read their own logins.", testID)
+		{
+			got := query("/v1/users/me/logins", tkn.Token)
+			if got.Total != 2 || len(got.Items) != 2 {
+				t.Fatalf("\t%s\tTest %d:\tShould get the failed and the successful login : total %d", tests.Failed, testID, got.Total)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get the failed and the successful login.", tests.Success, testID)
+
+			success, failure := got.Items[0], got.Items[1]
+			if success.Outcome != dto.OutcomeSuccess || failure.Outcome != dto.OutcomeFailure || failure.Reason != dto.LoginInvalidCredentials {
+				t.Fatalf("\t%s\tTest %d:\tShould get the newest login first : got %+v", tests.Failed, testID, got.Items)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get the newest login first.", tests.Success, testID)
+
+			for _, l := range got.Items {
+				if l.UserID != usr.ID || l.Email != "lena@example.com" || l.UserAgent != userAgent || l.RemoteAddr == "" {
+					t.Fatalf("\t%s\tTest %d:\tShould record the user, the user agent and the address : got %+v", tests.Failed, testID, l)
+				}
+			}
+			t.Logf("\t%s\tTest %d:\tShould record the user, the user agent and the address.", tests.Success, testID)
+
+			// Other users' logins aren't visible, even when asked for.
+			got = query("/v1/users/me/logins?email=nobody-logs-in@example.com", tkn.Token)
+			if got.Total != 2 {
+				t.Fatalf("\t%s\tTest %d:\tShould only get their own logins : total %d", tests.Failed, testID, got.Total)
+			}
+			t.Logf("\t%s\tTest %d:\tShould only get their own logins.", tests.Success, testID)
+		}
+
+		testID = 2
+		t.Logf("\tTest %d:\tWhen an admin queries the logins of everyone.", testID)
+		{
+			got := query("/v1/logins?outcome=failure&email=nobody-logs-in@example.com", ut.adminToken)
+			if got.Total != 1 || len(got.Items) != 1 {
+				t.Fatalf("\t%s\tTest %d:\tShould get the failed login of the unknown email : total %d", tests.Failed, testID, got.Total)
+			}
+			if l := got.Items[0]; l.UserID != "" || l.Reason != dto.LoginInvalidCredentials {
+				t.Fatalf("\t%s\tTest %d:\tShould not attribute the login to a user : got %+v", tests.Failed, testID, l)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get the failed login of the unknown email.", tests.Success, testID)
+
+			got = query("/v1/logins?user_id="+usr.ID, ut.adminToken)
+			if got.Total != 2 {
+				t.Fatalf("\t%s\tTest %d:\tShould filter the logins by user : total %d", tests.Failed, testID, got.Total)
+			}
+			t.Logf("\t%s\tTest %d:\tShould filter the logins by user.", tests.Success, testID)
+
+			if w := send(http.MethodGet, "/v1/logins?outcome=maybe", "", ut.adminToken); w.Code != http.StatusBadRequest {
+				t.Fatalf("\t%s\tTest %d:\tShould reject an unknown outcome : status %d", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould reject an unknown outcome.", tests.Success, testID)
+		}
+
+		testID = 3
+		t.Logf("\tTest %d:\tWhen the logins of everyone are read without the audit:read permission.", testID)
+		{
+			if w := send(http.MethodGet, "/v1/logins", "", ut.userToken); w.Code != http.StatusForbidden {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 403 for the response : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 403 for the response.", tests.Success, testID)
+		}
+
+		testID = 4
+		t.Logf("\tTest %d:\tWhen the user agent is too long.", testID)
+		{
+			// The two byte characters don't line up with the limit.
+			long := "x" + strings.Repeat("\u00e9", userCore.MaxUserAgent)
+			if w := loginAs("lena@example.com", "wrong-password", long); w.Code != http.StatusUnauthorized {
+				t.Fatalf("logging in with a wrong password: status %d", w.Code)
+			}
+
+			got := query("/v1/logins?email=lena@example.com&rows=1", ut.adminToken)
+			if len(got.Items) != 1 {
+				t.Fatalf("\t%s\tTest %d:\tShould record the login : total %d", tests.Failed, testID, got.Total)
+			}
+			ua := got.Items[0].UserAgent
+			if len(ua) > userCore.MaxUserAgent || len(ua) < userCore.MaxUserAgent-1 || !utf8.ValidString(ua) || !strings.HasPrefix(long, ua) {
+				t.Fatalf("\t%s\tTest %d:\tShould cut the user agent on a character boundary : %d bytes, valid %v", tests.Failed, testID, len(ua), utf8.ValidString(ua))
+			}
+			t.Logf("\t%s\tTest %d:\tShould cut the user agent on a character boundary.", tests.Success, testID)
+		}
+	}
+}
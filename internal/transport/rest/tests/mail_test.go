@@ -0,0 +1,43 @@
+package tests
+
+import (
+	"bytes"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// mailbox collects the mails sent during the tests. Some mails are sent in
+// the background, so the mailbox is safe for concurrent use.
+type mailbox struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+// Write appends a mail to the mailbox.
+func (m *mailbox) Write(p []byte) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.buf.Write(p)
+}
+
+// String returns all the mails received so far.
+func (m *mailbox) String() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.buf.String()
+}
+
+// wait returns the first match of the expression in the mailbox, waiting
+// for a mail sent in the background to arrive. It returns nil if nothing
+// matches within a few seconds.
+func (m *mailbox) wait(re *regexp.Regexp) []string {
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if match := re.FindStringSubmatch(m.String()); match != nil {
+			return match
+		}
+	}
+	return nil
+}
@@ -0,0 +1,250 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/AgeroFlynn/crud/internal/buisness/core/dto"
+	outboxCore "github.com/AgeroFlynn/crud/internal/buisness/core/outbox"
+	"github.com/AgeroFlynn/crud/internal/buisness/sys/tests"
+	"github.com/AgeroFlynn/crud/internal/transport/rest/incoming"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// outboxEvents validates modifications of users and roles publish domain
+// events which the relay dispatches, retries and dead-letters.
+func (ut *UserTests) outboxEvents(t *testing.T) {
+	send := func(method string, target string, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, target, strings.NewReader(body))
+		w := httptest.NewRecorder()
+
+		r.Header.Set("Authorization", "Bearer "+ut.adminToken)
+		ut.app.ServeHTTP(w, r)
+
+		return w
+	}
+
+	var received []dto.Event
+	relay := outboxCore.NewRelay(ut.log, ut.outbox, outboxCore.RelayConfig{MaxAttempts: 2, Backoff: time.Minute})
+	relay.Subscribe(outboxCore.AllEvents, "recorder", func(ctx context.Context, e dto.Event) error {
+		received = append(received, e)
+		return nil
+	})
+
+	// dispatch hands every event available at the time to the subscribers.
+	dispatch := func(now time.Time) {
+		for {
+			n, err := relay.Dispatch(context.Background(), now)
+			if err != nil {
+				t.Fatalf("dispatching events: %s", err)
+			}
+			if n == 0 {
+				return
+			}
+		}
+	}
+
+	t.Log("Given the need to publish domain events of the modifications.")
+	{
+		dispatch(time.Now().UTC())
+		received = nil
+
+		w := send(http.MethodPost, "/v1/users", `{"name": "Evan Stone", "email": "evan@example.com", "roles": ["USER"], "password": "gophers", "password_confirm": "gophers"}`)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("creating user: status %d", w.Code)
+		}
+
+		var usr incoming.User
+		if err := json.NewDecoder(w.Body).Decode(&usr); err != nil {
+			t.Fatalf("decoding user: %s", err)
+		}
+
+		if w := send(http.MethodPut, "/v1/users/"+usr.ID, `{"name": "Evan Brook"}`); w.Code != http.StatusNoContent {
+			t.Fatalf("updating user: status %d", w.Code)
+		}
+		if w := send(http.MethodDelete, "/v1/users/"+usr.ID, ""); w.Code != http.StatusNoContent {
+			t.Fatalf("deleting user: status %d", w.Code)
+		}
+		if w := send(http.MethodPost, "/v1/roles", `{"name": "AUDITOR", "permissions": ["users:read"]}`); w.Code != http.StatusCreated {
+			t.Fatalf("creating role: status %d", w.Code)
+		}
+		if w := send(http.MethodPut, "/v1/roles/AUDITOR", `{"permissions": ["users:read", "audit:read"]}`); w.Code != http.StatusNoContent {
+			t.Fatalf("updating role: status %d", w.Code)
+		}
+		if w := send(http.MethodDelete, "/v1/roles/AUDITOR", ""); w.Code != http.StatusNoContent {
+			t.Fatalf("deleting role: status %d", w.Code)
+		}
+
+		testID := 0
+		t.Logf("\tTest %d:\tWhen users and roles are created, updated and deleted.", testID)
+		{
+			dispatch(time.Now().UTC())
+
+			var got []string
+			for _, e := range received {
+				got = append(got, e.Type+" "+e.AggregateID)
+			}
+			exp := []string{
+				dto.EventUserCreated + " " + usr.ID,
+				dto.EventUserUpdated + " " + usr.ID,
+				dto.EventEntryChanged + " " + usr.ID,
+				dto.EventUserDeleted + " " + usr.ID,
+				dto.EventRoleChanged + " AUDITOR",
+				dto.EventRoleChanged + " AUDITOR",
+				dto.EventRoleChanged + " AUDITOR",
+			}
+			if diff := cmp.Diff(got, exp); diff != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould dispatch an event for every modification in order. Diff:\n%s", tests.Failed, testID, diff)
+			}
+			t.Logf("\t%s\tTest %d:\tShould dispatch an event for every modification in order.", tests.Success, testID)
+
+			var updated dto.UserEvent
+			if err := json.Unmarshal(received[1].Payload, &updated); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to decode the payload : %s", tests.Failed, testID, err)
+			}
+			changes := []dto.AuditChange{{Field: "name", Before: "Evan Stone", After: "Evan Brook"}}
+			if diff := cmp.Diff(updated.Changes, changes); diff != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould describe what the update changed. Diff:\n%s", tests.Failed, testID, diff)
+			}
+			t.Logf("\t%s\tTest %d:\tShould describe what the update changed.", tests.Success, testID)
+
+			var entry dto.UserEvent
+			if err := json.Unmarshal(received[2].Payload, &entry); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to decode the payload : %s", tests.Failed, testID, err)
+			}
+			if diff := cmp.Diff(entry.Changes, changes); diff != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould describe what changed in the directory. Diff:\n%s", tests.Failed, testID, diff)
+			}
+			t.Logf("\t%s\tTest %d:\tShould describe what changed in the directory.", tests.Success, testID)
+
+			var role dto.RoleEvent
+			if err := json.Unmarshal(received[5].Payload, &role); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to decode the payload : %s", tests.Failed, testID, err)
+			}
+			if role.Change != dto.RoleUpdated || len(role.Permissions) != 2 {
+				t.Fatalf("\t%s\tTest %d:\tShould describe the permissions of the updated role : %+v", tests.Failed, testID, role)
+			}
+			t.Logf("\t%s\tTest %d:\tShould describe the permissions of the updated role.", tests.Success, testID)
+
+			received = nil
+			dispatch(time.Now().UTC())
+			if len(received) != 0 {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT dispatch the events again : %d", tests.Failed, testID, len(received))
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT dispatch the events again.", tests.Success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen a subscriber keeps failing.", testID)
+		{
+			failures := 0
+			relay.Subscribe(dto.EventRoleChanged, "failing", func(ctx context.Context, e dto.Event) error {
+				failures++
+				return errors.New("unavailable")
+			})
+
+			if w := send(http.MethodPost, "/v1/roles", `{"name": "FLAKY"}`); w.Code != http.StatusCreated {
+				t.Fatalf("creating role: status %d", w.Code)
+			}
+
+			now := time.Now().UTC()
+			received = nil
+			dispatch(now)
+			dispatch(now.Add(30 * time.Second))
+			if failures != 1 {
+				t.Fatalf("\t%s\tTest %d:\tShould wait for the backoff before retrying : %d", tests.Failed, testID, failures)
+			}
+			t.Logf("\t%s\tTest %d:\tShould wait for the backoff before retrying.", tests.Success, testID)
+
+			dispatch(now.Add(2 * time.Minute))
+			if failures != 2 || len(received) != 2 {
+				t.Fatalf("\t%s\tTest %d:\tShould deliver the event again after the backoff : %d %d", tests.Failed, testID, failures, len(received))
+			}
+			t.Logf("\t%s\tTest %d:\tShould deliver the event again after the backoff.", tests.Success, testID)
+
+			dead, err := ut.outbox.QueryDead(context.Background(), 0, 10)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to query the dead letters : %s", tests.Failed, testID, err)
+			}
+			if len(dead) != 1 || dead[0].AggregateID != "FLAKY" || dead[0].Attempts != 2 || !strings.Contains(dead[0].LastError, "failing: unavailable") {
+				t.Fatalf("\t%s\tTest %d:\tShould dead-letter the event after the max attempts : %+v", tests.Failed, testID, dead)
+			}
+			t.Logf("\t%s\tTest %d:\tShould dead-letter the event after the max attempts.", tests.Success, testID)
+
+			dispatch(now.Add(time.Hour))
+			if failures != 2 {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT dispatch dead letters : %d", tests.Failed, testID, failures)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT dispatch dead letters.", tests.Success, testID)
+		}
+
+		testID = 2
+		t.Logf("\tTest %d:\tWhen a modification fails or is rolled back.", testID)
+		{
+			w := send(http.MethodPost, "/v1/users", `{"name": "Fred Moss", "email": "fred@example.com", "roles": ["USER"], "password": "gophers", "password_confirm": "gophers"}`)
+			if w.Code != http.StatusCreated {
+				t.Fatalf("creating user: status %d", w.Code)
+			}
+			if err := json.NewDecoder(w.Body).Decode(&usr); err != nil {
+				t.Fatalf("decoding user: %s", err)
+			}
+
+			dispatch(time.Now().Add(2 * time.Hour).UTC())
+			received = nil
+
+			if w := send(http.MethodPut, "/v1/users/00000000-0000-4000-8000-000000000000", `{"name": "Nobody"}`); w.Code != http.StatusNotFound {
+				t.Fatalf("updating user: status %d", w.Code)
+			}
+
+			body := `{"mode": "atomic", "operations": [
+				{"op": "create", "user": {"name": "Gina Moss", "email": "gina@example.com", "roles": ["USER"], "password": "gophers", "password_confirm": "gophers"}},
+				{"op": "set_roles", "id": "` + usr.ID + `", "roles": ["USER", "ADMIN"]},
+				{"op": "update", "id": "00000000-0000-4000-8000-000000000000", "user": {"name": "Nobody"}}
+			]}`
+			if w := send(http.MethodPost, "/v1/users:batch", body); w.Code != http.StatusUnprocessableEntity {
+				t.Fatalf("applying batch: status %d", w.Code)
+			}
+
+			dispatch(time.Now().Add(2 * time.Hour).UTC())
+			if len(received) != 0 {
+				var got []string
+				for _, e := range received {
+					got = append(got, e.Type+" "+e.AggregateID)
+				}
+				t.Fatalf("\t%s\tTest %d:\tShould NOT dispatch events of changes that weren't made : %v", tests.Failed, testID, got)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT dispatch events of changes that weren't made.", tests.Success, testID)
+		}
+
+		testID = 3
+		t.Logf("\tTest %d:\tWhen the roles of a user are changed.", testID)
+		{
+			body := `{"mode": "atomic", "operations": [{"op": "set_roles", "id": "` + usr.ID + `", "roles": ["USER", "ADMIN"]}]}`
+			if w := send(http.MethodPost, "/v1/users:batch", body); w.Code != http.StatusOK {
+				t.Fatalf("applying batch: status %d", w.Code)
+			}
+
+			received = nil
+			dispatch(time.Now().Add(2 * time.Hour).UTC())
+			if len(received) != 1 || received[0].Type != dto.EventUserUpdated || received[0].AggregateID != usr.ID {
+				t.Fatalf("\t%s\tTest %d:\tShould dispatch the change as an update of the user : %+v", tests.Failed, testID, received)
+			}
+
+			var updated dto.UserEvent
+			if err := json.Unmarshal(received[0].Payload, &updated); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to decode the payload : %s", tests.Failed, testID, err)
+			}
+			changes := []dto.AuditChange{{Field: "roles", Before: "USER", After: "USER,ADMIN"}}
+			if diff := cmp.Diff(updated.Changes, changes); diff != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould describe the roles change. Diff:\n%s", tests.Failed, testID, diff)
+			}
+			t.Logf("\t%s\tTest %d:\tShould dispatch the change as an update of the user.", tests.Success, testID)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package tests
+
+import (
+	"encoding/json"
+	"github.com/AgeroFlynn/crud/internal/buisness/sys/tests"
+	"github.com/AgeroFlynn/crud/internal/transport/rest/incoming"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// explainPolicy validates admins can dry-run the authorization policies.
+func (ut *UserTests) explainPolicy(t *testing.T) {
+	const (
+		adminID = "5cf37266-3473-4006-984f-9325122678b7"
+		userID  = "45b5fbd3-755f-4379-8f07-a58d4a30fa2f"
+	)
+
+	explain := func(body string, token string) (incoming.Explanation, int) {
+		r := httptest.NewRequest(http.MethodPost, "/v1/policy/explain", strings.NewReader(body))
+		w := httptest.NewRecorder()
+
+		r.Header.Set("Authorization", "Bearer "+token)
+		ut.app.ServeHTTP(w, r)
+
+		var got incoming.Explanation
+		if w.Code == http.StatusOK {
+			if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+				t.Fatalf("decoding explanation: %s", err)
+			}
+		}
+		return got, w.Code
+	}
+
+	t.Log("Given the need to explain the decisions of the authorization policies.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen a regular user reads another user.", testID)
+		{
+			got, code := explain(`{"subject_id": "`+userID+`", "action": "read", "user_id": "`+adminID+`"}`, ut.adminToken)
+			if code != http.StatusOK {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 200 for the response : %v", tests.Failed, testID, code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 200 for the response.", tests.Success, testID)
+
+			if got.Allowed || got.Reason != "no_matching_policy" {
+				t.Fatalf("\t%s\tTest %d:\tShould be denied without a matching policy : %+v", tests.Failed, testID, got)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be denied without a matching policy.", tests.Success, testID)
+
+			if len(got.Steps) == 0 || got.Steps[0].Policy != "own-account" || got.Steps[0].Mismatch != "owner" {
+				t.Fatalf("\t%s\tTest %d:\tShould tell why the policies didn't match : %+v", tests.Failed, testID, got.Steps)
+			}
+			t.Logf("\t%s\tTest %d:\tShould tell why the policies didn't match.", tests.Success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen an admin changes the own status.", testID)
+		{
+			got, code := explain(`{"subject_id": "`+adminID+`", "action": "set_status", "user_id": "`+adminID+`"}`, ut.adminToken)
+			if code != http.StatusOK {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 200 for the response : %v", tests.Failed, testID, code)
+			}
+
+			if got.Allowed || got.Policy != "own-status" || got.Reason != "own_status" {
+				t.Fatalf("\t%s\tTest %d:\tShould be denied by the own-status policy : %+v", tests.Failed, testID, got)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be denied by the own-status policy.", tests.Success, testID)
+		}
+
+		testID = 2
+		t.Logf("\tTest %d:\tWhen an admin manages another user.", testID)
+		{
+			got, code := explain(`{"subject_id": "`+adminID+`", "action": "delete", "user_id": "`+userID+`"}`, ut.adminToken)
+			if code != http.StatusOK {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 200 for the response : %v", tests.Failed, testID, code)
+			}
+
+			if !got.Allowed || got.Policy != "manage-accounts" {
+				t.Fatalf("\t%s\tTest %d:\tShould be allowed by the manage-accounts policy : %+v", tests.Failed, testID, got)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be allowed by the manage-accounts policy.", tests.Success, testID)
+		}
+
+		testID = 3
+		t.Logf("\tTest %d:\tWhen a regular user asks for an explanation.", testID)
+		{
+			if _, code := explain(`{"subject_id": "`+userID+`", "action": "read", "user_id": "`+adminID+`"}`, ut.userToken); code != http.StatusForbidden {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 403 for the response : %v", tests.Failed, testID, code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 403 for the response.", tests.Success, testID)
+		}
+
+		testID = 4
+		t.Logf("\tTest %d:\tWhen a regular user deletes the own account.", testID)
+		{
+			got, code := explain(`{"subject_id": "`+userID+`", "action": "delete", "user_id": "`+userID+`"}`, ut.adminToken)
+			if code != http.StatusOK {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 200 for the response : %v", tests.Failed, testID, code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 200 for the response.", tests.Success, testID)
+
+			if got.Allowed {
+				t.Fatalf("\t%s\tTest %d:\tShould be denied : %+v", tests.Failed, testID, got)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be denied.", tests.Success, testID)
+		}
+
+		testID = 5
+		t.Logf("\tTest %d:\tWhen the subject is not a valid id.", testID)
+		{
+			if _, code := explain(`{"subject_id": "abc", "action": "read", "user_id": "`+adminID+`"}`, ut.adminToken); code != http.StatusBadRequest {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 400 for the response : %v", tests.Failed, testID, code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 400 for the response.", tests.Success, testID)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package tests
+
+import (
+	"bytes"
+	"github.com/AgeroFlynn/crud/internal/buisness/sys/tests"
+	"github.com/AgeroFlynn/crud/internal/foundation/qrcode"
+	"github.com/AgeroFlynn/crud/internal/foundation/vcard"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// qrCodes validates the contact cards are rendered as QR codes encoding the
+// vCard of the user and that only the user and holders of directory:export
+// can render them.
+func (ut *UserTests) qrCodes(t *testing.T) {
+	const (
+		adminID = "5cf37266-3473-4006-984f-9325122678b7"
+		userID  = "45b5fbd3-755f-4379-8f07-a58d4a30fa2f"
+	)
+
+	send := func(target string, token string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, target, nil)
+		w := httptest.NewRecorder()
+
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		ut.app.ServeHTTP(w, r)
+
+		return w
+	}
+
+	// The encoder is deterministic, the expected images are rendered from
+	// the vCard the code has to hold.
+	card := string(vcard.Encode(vcard.Card{UID: userID, Name: "User Gopher", Email: "user@example.com"}))
+
+	t.Log("Given the need to render contact cards as QR codes.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen users render their own card as a PNG.", testID)
+		{
+			w := send("/v1/users/"+userID+"/qr.png", ut.userToken)
+			if w.Code != http.StatusOK {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 200 for the response : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 200 for the response.", tests.Success, testID)
+
+			if ct := w.Header().Get("Content-Type"); ct != qrcode.ContentTypePNG {
+				t.Fatalf("\t%s\tTest %d:\tShould get a PNG image : got %q", tests.Failed, testID, ct)
+			}
+			exp, err := qrcode.PNG(card, qrcode.Options{})
+			if err != nil {
+				t.Fatalf("rendering the expected image: %s", err)
+			}
+			if !bytes.Equal(w.Body.Bytes(), exp) {
+				t.Fatalf("\t%s\tTest %d:\tShould encode the vCard of the user.", tests.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould encode the vCard of the user.", tests.Success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen rendering a card as an SVG with a size and an error correction level.", testID)
+		{
+			w := send("/v1/users/"+userID+"/qr.svg?size=128&ecc=H", ut.userToken)
+			if w.Code != http.StatusOK {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 200 for the response : %v", tests.Failed, testID, w.Code)
+			}
+
+			if ct := w.Header().Get("Content-Type"); ct != qrcode.ContentTypeSVG {
+				t.Fatalf("\t%s\tTest %d:\tShould get an SVG image : got %q", tests.Failed, testID, ct)
+			}
+			exp, err := qrcode.SVG(card, qrcode.Options{Size: 128, Level: "H"})
+			if err != nil {
+				t.Fatalf("rendering the expected image: %s", err)
+			}
+			if !bytes.Equal(w.Body.Bytes(), exp) {
+				t.Fatalf("\t%s\tTest %d:\tShould render the vCard with the options.", tests.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould render the vCard with the options.", tests.Success, testID)
+		}
+
+		testID = 2
+		t.Logf("\tTest %d:\tWhen rendering the card of someone else.", testID)
+		{
+			if w := send("/v1/users/"+adminID+"/qr.png", ut.userToken); w.Code != http.StatusForbidden {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 403 without directory:export : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 403 without directory:export.", tests.Success, testID)
+
+			w := send("/v1/users/"+userID+"/qr.png", ut.adminToken)
+			if w.Code != http.StatusOK {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 200 with directory:export : %v", tests.Failed, testID, w.Code)
+			}
+			exp, err := qrcode.PNG(card, qrcode.Options{})
+			if err != nil {
+				t.Fatalf("rendering the expected image: %s", err)
+			}
+			if !bytes.Equal(w.Body.Bytes(), exp) {
+				t.Fatalf("\t%s\tTest %d:\tShould encode the vCard of the other user.", tests.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive the card with directory:export.", tests.Success, testID)
+
+			if w := send("/v1/users/"+userID+"/qr.png", ""); w.Code != http.StatusUnauthorized {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 401 without a token : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 401 without a token.", tests.Success, testID)
+
+			if w := send("/v1/users/c50a5d66-3c4d-453f-af3f-bc960ed1a503/qr.png", ut.adminToken); w.Code != http.StatusNotFound {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 404 for an unknown user : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 404 for an unknown user.", tests.Success, testID)
+		}
+
+		testID = 3
+		t.Logf("\tTest %d:\tWhen the rendering options are invalid.", testID)
+		{
+			for _, query := range []string{"size=10", "size=big", "ecc=Z", "content=photo"} {
+				if w := send("/v1/users/"+userID+"/qr.png?"+query, ut.userToken); w.Code != http.StatusBadRequest {
+					t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 400 for %s : %v", tests.Failed, testID, query, w.Code)
+				}
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 400 for the response.", tests.Success, testID)
+		}
+	}
+}
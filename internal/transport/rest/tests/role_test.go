@@ -0,0 +1,307 @@
+package tests
+
+import (
+	"encoding/json"
+	"github.com/AgeroFlynn/crud/internal/buisness/sys/auth"
+	"github.com/AgeroFlynn/crud/internal/buisness/sys/tests"
+	"github.com/AgeroFlynn/crud/internal/transport/rest/incoming"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// roles validates roles are managed through the API and that the
+// permissions they grant decide what their users can do.
+func (ut *UserTests) roles(t *testing.T) {
+	send := func(method string, target string, body string, token string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, target, strings.NewReader(body))
+		w := httptest.NewRecorder()
+
+		r.Header.Set("Authorization", "Bearer "+token)
+		ut.app.ServeHTTP(w, r)
+
+		return w
+	}
+
+	t.Log("Given the need to manage roles and their permissions.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen listing the permissions.", testID)
+		{
+			w := send(http.MethodGet, "/v1/permissions", "", ut.adminToken)
+			if w.Code != http.StatusOK {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 200 for the response : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 200 for the response.", tests.Success, testID)
+
+			var got []incoming.Permission
+			if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to unmarshal the response : %v", tests.Failed, testID, err)
+			}
+
+			var names []string
+			for _, p := range got {
+				names = append(names, p.Name)
+			}
+			exp := []string{auth.PermAuditRead, auth.PermDirectoryExport, auth.PermRolesAssign, auth.PermRolesRead, auth.PermRolesWrite, auth.PermUsersRead, auth.PermUsersWrite, auth.PermWebhooksManage}
+			if diff := cmp.Diff(names, exp); diff != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould get all permissions. Diff:\n%s", tests.Failed, testID, diff)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get all permissions.", tests.Success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen listing the roles without the roles:read permission.", testID)
+		{
+			w := send(http.MethodGet, "/v1/roles", "", ut.userToken)
+			if w.Code != http.StatusForbidden {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 403 for the response : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 403 for the response.", tests.Success, testID)
+		}
+
+		testID = 2
+		t.Logf("\tTest %d:\tWhen creating a role granting an unknown permission.", testID)
+		{
+			w := send(http.MethodPost, "/v1/roles", `{"name": "SUPPORT", "permissions": ["users:read", "users:fly"]}`, ut.adminToken)
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 400 for the response : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 400 for the response.", tests.Success, testID)
+		}
+
+		testID = 3
+		t.Logf("\tTest %d:\tWhen creating a role.", testID)
+		{
+			w := send(http.MethodPost, "/v1/roles", `{"name": "SUPPORT", "description": "Support staff", "permissions": ["users:read"]}`, ut.adminToken)
+			if w.Code != http.StatusCreated {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 201 for the response : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 201 for the response.", tests.Success, testID)
+
+			w = send(http.MethodPost, "/v1/roles", `{"name": "SUPPORT"}`, ut.adminToken)
+			if w.Code != http.StatusConflict {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 409 for a second role with the name : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 409 for a second role with the name.", tests.Success, testID)
+
+			w = send(http.MethodGet, "/v1/roles/SUPPORT", "", ut.adminToken)
+			if w.Code != http.StatusOK {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve the role : %v", tests.Failed, testID, w.Code)
+			}
+
+			var got incoming.Role
+			if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to unmarshal the response : %v", tests.Failed, testID, err)
+			}
+			if diff := cmp.Diff(got.Permissions, []string{auth.PermUsersRead}); diff != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould get the permissions of the role. Diff:\n%s", tests.Failed, testID, diff)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get the permissions of the role.", tests.Success, testID)
+		}
+
+		testID = 4
+		t.Logf("\tTest %d:\tWhen assigning an unknown role to a user.", testID)
+		{
+			nu := incoming.NewUser{
+				Name:            "Nobody Gopher",
+				Email:           "nobody@example.com",
+				Roles:           []string{"ASTRONAUT"},
+				Password:        "gophers",
+				PasswordConfirm: "gophers",
+			}
+
+			body, err := json.Marshal(&nu)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			w := send(http.MethodPost, "/v1/users", string(body), ut.adminToken)
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 400 for the response : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 400 for the response.", tests.Success, testID)
+		}
+
+		testID = 5
+		t.Logf("\tTest %d:\tWhen a user only has the permissions of the new role.", testID)
+		{
+			nu := incoming.NewUser{
+				Name:            "Sam Walker",
+				Email:           "sam@example.com",
+				Roles:           []string{"SUPPORT"},
+				Password:        "gophers",
+				PasswordConfirm: "gophers",
+			}
+
+			body, err := json.Marshal(&nu)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			w := send(http.MethodPost, "/v1/users", string(body), ut.adminToken)
+			if w.Code != http.StatusCreated {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create the user : %v", tests.Failed, testID, w.Code)
+			}
+
+			var usr incoming.User
+			if err := json.NewDecoder(w.Body).Decode(&usr); err != nil {
+				t.Fatalf("decoding user: %s", err)
+			}
+			defer ut.deleteUser204(t, usr.ID)
+
+			r := httptest.NewRequest(http.MethodGet, "/v1/users/token", nil)
+			w = httptest.NewRecorder()
+
+			r.SetBasicAuth("sam@example.com", "gophers")
+			ut.app.ServeHTTP(w, r)
+
+			var tkn struct {
+				Token string `json:"token"`
+			}
+			if err := json.NewDecoder(w.Body).Decode(&tkn); err != nil {
+				t.Fatalf("decoding token: %s", err)
+			}
+
+			if w := send(http.MethodGet, "/v1/users", "", tkn.Token); w.Code != http.StatusOK {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to list users : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to list users.", tests.Success, testID)
+
+			if w := send(http.MethodGet, "/v1/users/5cf37266-3473-4006-984f-9325122678b7", "", tkn.Token); w.Code != http.StatusOK {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to read another user : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to read another user.", tests.Success, testID)
+
+			if w := send(http.MethodPost, "/v1/users", string(body), tkn.Token); w.Code != http.StatusForbidden {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to create users : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to create users.", tests.Success, testID)
+
+			if w := send(http.MethodGet, "/v1/users/5cf37266-3473-4006-984f-9325122678b7/qr.svg", "", tkn.Token); w.Code != http.StatusForbidden {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to export the card of another user : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to export the card of another user.", tests.Success, testID)
+		}
+
+		testID = 6
+		t.Logf("\tTest %d:\tWhen changing the built-in roles.", testID)
+		{
+			if w := send(http.MethodPut, "/v1/roles/ADMIN", `{"permissions": ["users:read"]}`, ut.adminToken); w.Code != http.StatusForbidden {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to modify the admin role : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to modify the admin role.", tests.Success, testID)
+
+			if w := send(http.MethodDelete, "/v1/roles/USER", "", ut.adminToken); w.Code != http.StatusForbidden {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to delete the user role : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to delete the user role.", tests.Success, testID)
+		}
+
+		testID = 7
+		t.Logf("\tTest %d:\tWhen deleting a role.", testID)
+		{
+			if w := send(http.MethodDelete, "/v1/roles/SUPPORT", "", ut.adminToken); w.Code != http.StatusNoContent {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 204 for the response : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 204 for the response.", tests.Success, testID)
+
+			if w := send(http.MethodGet, "/v1/roles/SUPPORT", "", ut.adminToken); w.Code != http.StatusNotFound {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT find the role anymore : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT find the role anymore.", tests.Success, testID)
+		}
+
+		// The user managing accounts and the token of the user.
+		var maxID, maxToken string
+
+		testID = 8
+		t.Logf("\tTest %d:\tWhen a user manages accounts without the roles:assign permission.", testID)
+		{
+			if w := send(http.MethodPost, "/v1/roles", `{"name": "MANAGER", "permissions": ["users:read", "users:write"]}`, ut.adminToken); w.Code != http.StatusCreated {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create the role : %v", tests.Failed, testID, w.Code)
+			}
+			defer send(http.MethodDelete, "/v1/roles/MANAGER", "", ut.adminToken)
+
+			w := send(http.MethodPost, "/v1/users", `{"name": "Max Walker", "email": "max@example.com", "roles": ["MANAGER"], "password": "gophers", "password_confirm": "gophers"}`, ut.adminToken)
+			if w.Code != http.StatusCreated {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create the user : %v", tests.Failed, testID, w.Code)
+			}
+
+			var usr incoming.User
+			if err := json.NewDecoder(w.Body).Decode(&usr); err != nil {
+				t.Fatalf("decoding user: %s", err)
+			}
+			defer ut.deleteUser204(t, usr.ID)
+			maxID = usr.ID
+
+			r := httptest.NewRequest(http.MethodGet, "/v1/users/token", nil)
+			w = httptest.NewRecorder()
+
+			r.SetBasicAuth("max@example.com", "gophers")
+			ut.app.ServeHTTP(w, r)
+
+			var tkn struct {
+				Token string `json:"token"`
+			}
+			if err := json.NewDecoder(w.Body).Decode(&tkn); err != nil {
+				t.Fatalf("decoding token: %s", err)
+			}
+			maxToken = tkn.Token
+
+			if w := send(http.MethodPost, "/v1/users", `{"name": "Eve Walker", "email": "eve@example.com", "roles": ["ADMIN"], "password": "gophers", "password_confirm": "gophers"}`, maxToken); w.Code != http.StatusForbidden {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to create an admin : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to create an admin.", tests.Success, testID)
+
+			if w := send(http.MethodPut, "/v1/users/"+maxID, `{"roles": ["ADMIN"]}`, maxToken); w.Code != http.StatusForbidden {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to grant roles : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to grant roles.", tests.Success, testID)
+
+			w = send(http.MethodPost, "/v1/users:batch", `{"mode": "best_effort", "operations": [{"op": "set_roles", "id": "`+maxID+`", "roles": ["ADMIN"]}]}`, maxToken)
+			var resp incoming.BatchResponse
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatalf("decoding response: %s", err)
+			}
+			if len(resp.Results) != 1 || resp.Results[0].Status != http.StatusForbidden {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to grant roles in a batch : %+v", tests.Failed, testID, resp.Results)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to grant roles in a batch.", tests.Success, testID)
+
+			if w := send(http.MethodPut, "/v1/users/"+maxID, `{"name": "Max Walker", "roles": ["MANAGER"]}`, maxToken); w.Code != http.StatusNoContent {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to keep the roles : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to keep the roles.", tests.Success, testID)
+		}
+
+		testID = 9
+		t.Logf("\tTest %d:\tWhen the permissions of the role of a user change.", testID)
+		{
+			if w := send(http.MethodPut, "/v1/roles/MANAGER", `{"permissions": ["users:read"]}`, ut.adminToken); w.Code != http.StatusNoContent {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to modify the role : %v", tests.Failed, testID, w.Code)
+			}
+
+			if w := send(http.MethodPut, "/v1/users/"+maxID, `{"name": "Max Walker"}`, maxToken); w.Code != http.StatusForbidden {
+				t.Fatalf("\t%s\tTest %d:\tShould lose the permissions right away : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould lose the permissions right away.", tests.Success, testID)
+		}
+
+		testID = 10
+		t.Logf("\tTest %d:\tWhen the roles of a user change.", testID)
+		{
+			if w := send(http.MethodPut, "/v1/users/"+maxID, `{"roles": ["USER"]}`, ut.adminToken); w.Code != http.StatusNoContent {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to change the roles : %v", tests.Failed, testID, w.Code)
+			}
+
+			if w := send(http.MethodGet, "/v1/users/me", "", maxToken); w.Code != http.StatusUnauthorized {
+				t.Fatalf("\t%s\tTest %d:\tShould revoke the tokens issued before : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould revoke the tokens issued before.", tests.Success, testID)
+		}
+	}
+}
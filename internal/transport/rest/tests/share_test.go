@@ -0,0 +1,309 @@
+package tests
+
+import (
+	"encoding/json"
+	shareCore "github.com/AgeroFlynn/crud/internal/buisness/core/sharelink"
+	"github.com/AgeroFlynn/crud/internal/buisness/sys/auth"
+	"github.com/AgeroFlynn/crud/internal/buisness/sys/tests"
+	"github.com/AgeroFlynn/crud/internal/transport/rest/handlers"
+	"github.com/AgeroFlynn/crud/internal/transport/rest/incoming"
+	"github.com/golang-jwt/jwt/v4"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// ShareTests holds methods for each share link subtest.
+type ShareTests struct {
+	app        http.Handler
+	auth       *auth.Auth
+	userToken  string
+	adminToken string
+}
+
+// TestShareLinks is the entry point for testing share link functions.
+func TestShareLinks(t *testing.T) {
+	test := tests.NewIntegration(
+		t,
+		tests.DBContainer{
+			Image: "postgres",
+			Tag:   "13-alpine",
+			Port:  "5432/tcp",
+			Args: []string{
+				"-e",
+				"POSTGRES_PASSWORD=postgres",
+				"POSTGRES_USER=postgres",
+				"POSTGRES_DB=postgres",
+				"listen_addresses = '*'",
+			},
+		},
+	)
+	t.Cleanup(test.Teardown)
+
+	runShareTests(t, test)
+}
+
+// TestShareLinksMemory runs the share link tests against the in-memory
+// stores.
+func TestShareLinksMemory(t *testing.T) {
+	test := tests.NewMemory(t)
+	t.Cleanup(test.Teardown)
+
+	runShareTests(t, test)
+}
+
+// runShareTests registers the share link subtests against the backend of
+// the test.
+func runShareTests(t *testing.T, test *tests.Test) {
+	shutdown := make(chan os.Signal, 1)
+	tests := ShareTests{
+		app: handlers.APIMux(handlers.APIMuxConfig{
+			Shutdown:     shutdown,
+			Log:          test.Log,
+			Auth:         test.Auth,
+			DB:           test.DB,
+			UserStore:    test.Users,
+			RoleStore:    test.Roles,
+			AuditStore:   test.Audit,
+			OutboxStore:  test.Outbox,
+			WebhookStore: test.Webhooks,
+			LoginStore:   test.Logins,
+			ShareStore:   test.Shares,
+		}),
+		auth:       test.Auth,
+		userToken:  test.Token("user@example.com", "gophers"),
+		adminToken: test.Token("admin@example.com", "gophers"),
+	}
+
+	t.Run("openShare410", tests.openShare410)
+	t.Run("singleUseShare", tests.singleUseShare)
+	t.Run("revokeShare", tests.revokeShare)
+	t.Run("expiredShare", tests.expiredShare)
+	t.Run("inactiveOwnerShare", tests.inactiveOwnerShare)
+}
+
+// openShare410 validates a tampered token can't be used to open a card.
+func (st *ShareTests) openShare410(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/v1/share/not-a-token", nil)
+	w := httptest.NewRecorder()
+
+	st.app.ServeHTTP(w, r)
+
+	t.Log("Given the need to deny access with invalid share tokens.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen opening a share link with a malformed token.", testID)
+		{
+			if w.Code != http.StatusGone {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 410 for the response : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 410 for the response.", tests.Success, testID)
+		}
+	}
+}
+
+// singleUseShare validates a single use link can only be opened once.
+func (st *ShareTests) singleUseShare(t *testing.T) {
+	sl := st.postShare201(t, `{"single_use": true}`)
+
+	t.Log("Given the need to open a single use share link once.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen opening the link as a vCard.", testID)
+		{
+			r := httptest.NewRequest(http.MethodGet, "/v1/share/"+sl.Token+"?format=vcard", nil)
+			w := httptest.NewRecorder()
+			st.app.ServeHTTP(w, r)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 200 for the response : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 200 for the response.", tests.Success, testID)
+
+			if !strings.Contains(w.Body.String(), "FN:User Gopher") {
+				t.Fatalf("\t%s\tTest %d:\tShould receive the vCard of the owner : %s", tests.Failed, testID, w.Body.String())
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive the vCard of the owner.", tests.Success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen opening the link a second time.", testID)
+		{
+			r := httptest.NewRequest(http.MethodGet, "/v1/share/"+sl.Token, nil)
+			w := httptest.NewRecorder()
+			st.app.ServeHTTP(w, r)
+
+			if w.Code != http.StatusGone {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 410 for the response : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 410 for the response.", tests.Success, testID)
+		}
+	}
+}
+
+// revokeShare validates a revoked link can't be opened anymore.
+func (st *ShareTests) revokeShare(t *testing.T) {
+	sl := st.postShare201(t, "")
+
+	t.Log("Given the need to revoke share links.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen opening the link before it is revoked.", testID)
+		{
+			r := httptest.NewRequest(http.MethodGet, "/v1/share/"+sl.Token, nil)
+			w := httptest.NewRecorder()
+			st.app.ServeHTTP(w, r)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 200 for the response : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 200 for the response.", tests.Success, testID)
+
+			var got incoming.PublicCard
+			if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to unmarshal the response : %v", tests.Failed, testID, err)
+			}
+			if got.Email != "user@example.com" {
+				t.Fatalf("\t%s\tTest %d:\tShould receive the card of the owner : got %q", tests.Failed, testID, got.Email)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive the card of the owner.", tests.Success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen opening the link after it is revoked.", testID)
+		{
+			r := httptest.NewRequest(http.MethodDelete, "/v1/users/me/share/"+sl.ID, nil)
+			w := httptest.NewRecorder()
+			r.Header.Set("Authorization", "Bearer "+st.userToken)
+			st.app.ServeHTTP(w, r)
+
+			if w.Code != http.StatusNoContent {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 204 for the revoke : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 204 for the revoke.", tests.Success, testID)
+
+			r = httptest.NewRequest(http.MethodGet, "/v1/share/"+sl.Token, nil)
+			w = httptest.NewRecorder()
+			st.app.ServeHTTP(w, r)
+
+			if w.Code != http.StatusGone {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 410 for the response : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 410 for the response.", tests.Success, testID)
+		}
+	}
+}
+
+// expiredShare validates the failed attempts to open a link are recorded.
+func (st *ShareTests) expiredShare(t *testing.T) {
+	sl := st.postShare201(t, "")
+
+	// The token of the link as it was issued a day ago.
+	now := time.Now()
+	token, err := st.auth.GenerateScopedToken(shareCore.Audience, jwt.RegisteredClaims{
+		ID:        sl.ID,
+		Subject:   sl.UserID,
+		ExpiresAt: jwt.NewNumericDate(now.Add(-time.Hour)),
+		IssuedAt:  jwt.NewNumericDate(now.Add(-24 * time.Hour)),
+	})
+	if err != nil {
+		t.Fatalf("generating token: %s", err)
+	}
+
+	t.Log("Given the need to record the attempts to open share links.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen opening the link with an expired token.", testID)
+		{
+			r := httptest.NewRequest(http.MethodGet, "/v1/share/"+token, nil)
+			w := httptest.NewRecorder()
+			st.app.ServeHTTP(w, r)
+
+			if w.Code != http.StatusGone {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 410 for the response : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 410 for the response.", tests.Success, testID)
+
+			accesses := st.getAccesses200(t, sl.ID)
+			if len(accesses) != 1 || accesses[0].Granted {
+				t.Fatalf("\t%s\tTest %d:\tShould record the denied access : %+v", tests.Failed, testID, accesses)
+			}
+			t.Logf("\t%s\tTest %d:\tShould record the denied access.", tests.Success, testID)
+		}
+	}
+}
+
+// inactiveOwnerShare validates the links of owners who aren't active can't
+// be opened. It disables the regular user, so it runs last.
+func (st *ShareTests) inactiveOwnerShare(t *testing.T) {
+	sl := st.postShare201(t, "")
+
+	r := httptest.NewRequest(http.MethodPut, "/v1/users/"+sl.UserID+"/status", strings.NewReader(`{"status": "DISABLED", "reason": "left the company"}`))
+	w := httptest.NewRecorder()
+	r.Header.Set("Authorization", "Bearer "+st.adminToken)
+	st.app.ServeHTTP(w, r)
+
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("disabling user: status %d", w.Code)
+	}
+
+	t.Log("Given the need to hide the cards of users who left.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen opening the link of a disabled user.", testID)
+		{
+			r := httptest.NewRequest(http.MethodGet, "/v1/share/"+sl.Token, nil)
+			w := httptest.NewRecorder()
+			st.app.ServeHTTP(w, r)
+
+			if w.Code != http.StatusGone {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 410 for the response : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 410 for the response.", tests.Success, testID)
+		}
+	}
+}
+
+// getAccesses200 retrieves the access log of a link of the regular user as
+// an admin.
+func (st *ShareTests) getAccesses200(t *testing.T, linkID string) []incoming.ShareLinkAccess {
+	r := httptest.NewRequest(http.MethodGet, "/v1/users/me/share/"+linkID+"/accesses", nil)
+	w := httptest.NewRecorder()
+
+	r.Header.Set("Authorization", "Bearer "+st.adminToken)
+	st.app.ServeHTTP(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("\t%s\tShould receive a status code of 200 for the accesses : %v", tests.Failed, w.Code)
+	}
+
+	var got []incoming.ShareLinkAccess
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatalf("\t%s\tShould be able to unmarshal the accesses : %v", tests.Failed, err)
+	}
+
+	return got
+}
+
+// postShare201 creates a share link for the regular user.
+func (st *ShareTests) postShare201(t *testing.T, body string) incoming.ShareLink {
+	r := httptest.NewRequest(http.MethodPost, "/v1/users/me/share", strings.NewReader(body))
+	w := httptest.NewRecorder()
+
+	r.Header.Set("Authorization", "Bearer "+st.userToken)
+	st.app.ServeHTTP(w, r)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("\t%s\tShould receive a status code of 201 for the share link : %v", tests.Failed, w.Code)
+	}
+
+	var got incoming.ShareLink
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatalf("\t%s\tShould be able to unmarshal the share link : %v", tests.Failed, err)
+	}
+
+	return got
+}
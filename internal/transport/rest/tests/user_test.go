@@ -2,17 +2,29 @@ package tests
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	outboxCore "github.com/AgeroFlynn/crud/internal/buisness/core/outbox"
+	webhookCore "github.com/AgeroFlynn/crud/internal/buisness/core/webhook"
 	"github.com/AgeroFlynn/crud/internal/buisness/sys/auth"
+	"github.com/AgeroFlynn/crud/internal/buisness/sys/lockout"
+	"github.com/AgeroFlynn/crud/internal/buisness/sys/passwd"
 	"github.com/AgeroFlynn/crud/internal/buisness/sys/tests"
 	"github.com/AgeroFlynn/crud/internal/buisness/sys/validate"
+	"github.com/AgeroFlynn/crud/internal/buisness/web/page"
+	"github.com/AgeroFlynn/crud/internal/foundation/bloom"
+	"github.com/AgeroFlynn/crud/internal/foundation/mail"
+	"github.com/AgeroFlynn/crud/internal/foundation/worker"
 	"github.com/AgeroFlynn/crud/internal/transport/rest/handlers"
 	"github.com/AgeroFlynn/crud/internal/transport/rest/incoming"
+	"go.uber.org/zap"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"regexp"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
@@ -25,6 +37,11 @@ type UserTests struct {
 	app        http.Handler
 	userToken  string
 	adminToken string
+	mail       *mailbox
+	outbox     outboxCore.OutboxStorer
+	relay      *outboxCore.Relay
+	webhooks   webhookCore.WebhookStorer
+	log        *zap.SugaredLogger
 }
 
 // TestUsers is the entry point for testing user management functions.
@@ -46,33 +63,113 @@ func TestUsers(t *testing.T) {
 	)
 	t.Cleanup(test.Teardown)
 
+	runUserTests(t, test)
+}
+
+// TestUsersMemory runs the user management tests against the in-memory
+// user store.
+func TestUsersMemory(t *testing.T) {
+	test := tests.NewMemory(t)
+	t.Cleanup(test.Teardown)
+
+	runUserTests(t, test)
+}
+
+// runUserTests registers the user subtests against the backend of the test.
+func runUserTests(t *testing.T, test *tests.Test) {
+	var mails mailbox
+
+	breached := bloom.New(1, 0.001)
+	breached.Add("password123")
+
+	// The subtests dispatch the events themselves.
+	relay := outboxCore.NewRelay(test.Log, test.Outbox, outboxCore.RelayConfig{})
+
+	// The reset mails are sent by the queue in the background.
+	background := worker.NewQueue(10, 1, 5*time.Second)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		background.Run(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
 	shutdown := make(chan os.Signal, 1)
 	tests := UserTests{
 		app: handlers.APIMux(handlers.APIMuxConfig{
-			Shutdown: shutdown,
-			Log:      test.Log,
-			Auth:     test.Auth,
-			DB:       test.DB,
+			Shutdown:     shutdown,
+			Log:          test.Log,
+			Auth:         test.Auth,
+			DB:           test.DB,
+			UserStore:    test.Users,
+			RoleStore:    test.Roles,
+			AuditStore:   test.Audit,
+			OutboxStore:  test.Outbox,
+			WebhookStore: test.Webhooks,
+			LoginStore:   test.Logins,
+			ShareStore:   test.Shares,
+			Relay:        relay,
+			Mail:         mail.NewWriter(&mails, "noreply@example.com"),
+			VerifyURL:    "http://localhost/verify",
+			ResetURL:     "http://localhost/reset",
+			Background:   background,
+			PasswordPolicy: passwd.Policy{
+				MinLength:      6,
+				MinClasses:     1,
+				History:        2,
+				ForbidPersonal: true,
+				Breached:       breached,
+			},
+			AccountLockout:  lockout.Policy{Threshold: 3, Base: time.Minute, Max: time.Hour, Reset: 15 * time.Minute},
+			AddrLockout:     lockout.Policy{Threshold: 100, Base: time.Minute, Max: time.Hour, Reset: 15 * time.Minute},
+			ResetEmailLimit: lockout.Policy{Threshold: 3, Base: time.Minute, Max: time.Hour, Reset: 15 * time.Minute},
+			ResetAddrLimit:  lockout.Policy{Threshold: 100, Base: time.Minute, Max: time.Hour, Reset: 15 * time.Minute},
+			StatusCacheTTL:  time.Minute,
 		}),
 		userToken:  test.Token("user@example.com", "gophers"),
 		adminToken: test.Token("admin@example.com", "gophers"),
+		mail:       &mails,
+		outbox:     test.Outbox,
+		relay:      relay,
+		webhooks:   test.Webhooks,
+		log:        test.Log,
 	}
 
-	t.Run("getToken404", tests.getToken404)
+	t.Run("getToken401", tests.getToken401)
 	t.Run("getToken200", tests.getToken200)
+	t.Run("getToken429", tests.getToken429)
 	t.Run("postUser400", tests.postUser400)
+	t.Run("postUser400Password", tests.postUser400Password)
 	t.Run("postUser401", tests.postUser401)
+	t.Run("postUser409", tests.postUser409)
 	t.Run("postUser403", tests.postUser403)
 	t.Run("getUser400", tests.getUser400)
 	t.Run("getUser403", tests.getUser403)
 	t.Run("getUser404", tests.getUser404)
+	t.Run("getUsers200", tests.getUsers200)
 	t.Run("deleteUserNotFound", tests.deleteUserNotFound)
 	t.Run("putUser404", tests.putUser404)
 	t.Run("crudUsers", tests.crudUser)
+	t.Run("meUser", tests.meUser)
+	t.Run("qrCodes", tests.qrCodes)
+	t.Run("statusUser", tests.statusUser)
+	t.Run("batchUsers", tests.batchUsers)
+	t.Run("cursorPaging", tests.cursorPaging)
+	t.Run("roles", tests.roles)
+	t.Run("explainPolicy", tests.explainPolicy)
+	t.Run("auditLog", tests.auditLog)
+	t.Run("outboxEvents", tests.outboxEvents)
+	t.Run("webhooks", tests.webhookDeliveries)
+	t.Run("loginHistory", tests.loginHistory)
 }
 
-// getToken401 ensures an unknown user can't generate a token.
-func (ut *UserTests) getToken404(t *testing.T) {
+// getToken401 ensures an unknown user can't generate a token and can't be
+// told apart from a wrong password.
+func (ut *UserTests) getToken401(t *testing.T) {
 	r := httptest.NewRequest(http.MethodGet, "/v1/users/token", nil)
 	w := httptest.NewRecorder()
 
@@ -84,10 +181,88 @@ func (ut *UserTests) getToken404(t *testing.T) {
 		testID := 0
 		t.Logf("\tTest %d:\tWhen fetching a token with an unrecognized email.", testID)
 		{
-			if w.Code != http.StatusNotFound {
-				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 404 for the response : %v", tests.Failed, testID, w.Code)
+			if w.Code != http.StatusUnauthorized {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 401 for the response : %v", tests.Failed, testID, w.Code)
 			}
-			t.Logf("\t%s\tTest %d:\tShould receive a status code of 404 for the response.", tests.Success, testID)
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 401 for the response.", tests.Success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen fetching a token with a wrong password.", testID)
+		{
+			r := httptest.NewRequest(http.MethodGet, "/v1/users/token", nil)
+			wrong := httptest.NewRecorder()
+
+			r.SetBasicAuth("admin@example.com", "some-password")
+			ut.app.ServeHTTP(wrong, r)
+
+			if wrong.Code != w.Code || wrong.Body.String() != w.Body.String() {
+				t.Logf("\t\tTest %d:\tGot : %d %s", testID, wrong.Code, wrong.Body.String())
+				t.Logf("\t\tTest %d:\tExp : %d %s", testID, w.Code, w.Body.String())
+				t.Fatalf("\t%s\tTest %d:\tShould receive the same response as for an unknown email.", tests.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive the same response as for an unknown email.", tests.Success, testID)
+		}
+	}
+}
+
+// getToken429 ensures logins are locked out after repeated failures until
+// an admin unlocks the user.
+func (ut *UserTests) getToken429(t *testing.T) {
+	const userID = "45b5fbd3-755f-4379-8f07-a58d4a30fa2f"
+
+	token := func(password string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/v1/users/token", nil)
+		w := httptest.NewRecorder()
+
+		r.SetBasicAuth("user@example.com", password)
+		ut.app.ServeHTTP(w, r)
+
+		return w
+	}
+
+	t.Log("Given the need to lock out brute force attacks on logins.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen failing to log in repeatedly.", testID)
+		{
+			for i := 0; i < 3; i++ {
+				if w := token("some-password"); w.Code != http.StatusUnauthorized {
+					t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 401 for the failures : %v", tests.Failed, testID, w.Code)
+				}
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 401 for the failures.", tests.Success, testID)
+
+			w := token("gophers")
+			if w.Code != http.StatusTooManyRequests {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 429 with the right password : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 429 with the right password.", tests.Success, testID)
+
+			if w.Header().Get("Retry-After") == "" {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a Retry-After header.", tests.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a Retry-After header.", tests.Success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen an admin unlocks the user.", testID)
+		{
+			r := httptest.NewRequest(http.MethodPost, "/v1/users/"+userID+"/unlock", nil)
+			w := httptest.NewRecorder()
+
+			r.Header.Set("Authorization", "Bearer "+ut.adminToken)
+			ut.app.ServeHTTP(w, r)
+
+			if w.Code != http.StatusNoContent {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 204 for the response : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 204 for the response.", tests.Success, testID)
+
+			if w := token("gophers"); w.Code != http.StatusOK {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 200 after the unlock : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 200 after the unlock.", tests.Success, testID)
 		}
 	}
 }
@@ -119,6 +294,21 @@ func (ut *UserTests) getToken200(t *testing.T) {
 
 			// TODO(jlw) Should we ensure the token is valid?
 		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen fetching a token with a differently cased email.", testID)
+		{
+			r := httptest.NewRequest(http.MethodGet, "/v1/users/token", nil)
+			w := httptest.NewRecorder()
+
+			r.SetBasicAuth(" Admin@Example.COM", "gophers")
+			ut.app.ServeHTTP(w, r)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 200 for the response : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 200 for the response.", tests.Success, testID)
+		}
 	}
 }
 
@@ -174,6 +364,152 @@ func (ut *UserTests) postUser400(t *testing.T) {
 			}
 			t.Logf("\t%s\tTest %d:\tShould get the expected result.", tests.Success, testID)
 		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen sending a malformed document.", testID)
+		{
+			endpoints := []struct {
+				method string
+				path   string
+				token  string
+			}{
+				{http.MethodPost, "/v1/users", ut.adminToken},
+				{http.MethodPost, "/v1/users:batch", ut.adminToken},
+				{http.MethodPut, "/v1/users/45b5fbd3-755f-4379-8f07-a58d4a30fa2f", ut.adminToken},
+				{http.MethodPut, "/v1/users/me", ut.userToken},
+				{http.MethodPost, "/v1/auth/password/forgot", ""},
+			}
+
+			for _, ep := range endpoints {
+				r := httptest.NewRequest(ep.method, ep.path, strings.NewReader(`{"name": `))
+				w := httptest.NewRecorder()
+
+				if ep.token != "" {
+					r.Header.Set("Authorization", "Bearer "+ep.token)
+				}
+				ut.app.ServeHTTP(w, r)
+
+				if w.Code != http.StatusBadRequest {
+					t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 400 for %s %s : %v", tests.Failed, testID, ep.method, ep.path, w.Code)
+				}
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 400 from every endpoint.", tests.Success, testID)
+		}
+	}
+}
+
+// postUser400Password validates a user can't be created with a password
+// breaking the password policy.
+func (ut *UserTests) postUser400Password(t *testing.T) {
+	table := []struct {
+		name     string
+		password string
+		exp      string
+	}{
+		{"a breached password", "password123", "password appeared in a data breach"},
+		{"a password containing the name", "jill-rocks", "password must not contain the name or email"},
+	}
+
+	t.Log("Given the need to validate new passwords follow the password policy.")
+	{
+		for testID, tt := range table {
+			t.Logf("\tTest %d:\tWhen using %s.", testID, tt.name)
+			{
+				nu := incoming.NewUser{
+					Name:            "Jill Smith",
+					Email:           "jill@example.com",
+					Roles:           []string{auth.RoleUser},
+					Password:        tt.password,
+					PasswordConfirm: tt.password,
+				}
+
+				body, err := json.Marshal(&nu)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				r := httptest.NewRequest(http.MethodPost, "/v1/users", bytes.NewBuffer(body))
+				w := httptest.NewRecorder()
+
+				r.Header.Set("Authorization", "Bearer "+ut.adminToken)
+				ut.app.ServeHTTP(w, r)
+
+				if w.Code != http.StatusBadRequest {
+					t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 400 for the response : %v", tests.Failed, testID, w.Code)
+				}
+				t.Logf("\t%s\tTest %d:\tShould receive a status code of 400 for the response.", tests.Success, testID)
+
+				var got validate.ErrorResponse
+				if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to unmarshal the response to an error type : %v", tests.Failed, testID, err)
+				}
+
+				fields := validate.FieldErrors{{Field: "password", Error: tt.exp}}
+				exp := validate.ErrorResponse{
+					Error:  "data validation error",
+					Fields: fields.Error(),
+				}
+
+				if diff := cmp.Diff(got, exp); diff != "" {
+					t.Fatalf("\t%s\tTest %d:\tShould get the expected result. Diff:\n%s", tests.Failed, testID, diff)
+				}
+				t.Logf("\t%s\tTest %d:\tShould get the expected result.", tests.Success, testID)
+			}
+		}
+	}
+}
+
+// postUser409 validates a user can't be created with an email that is
+// already in use.
+func (ut *UserTests) postUser409(t *testing.T) {
+	nu := incoming.NewUser{
+		Name:            "Second Admin",
+		Email:           "admin@example.com",
+		Roles:           []string{auth.RoleAdmin},
+		Password:        "gophers",
+		PasswordConfirm: "gophers",
+	}
+
+	body, err := json.Marshal(&nu)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/v1/users", bytes.NewBuffer(body))
+	w := httptest.NewRecorder()
+
+	r.Header.Set("Authorization", "Bearer "+ut.adminToken)
+	ut.app.ServeHTTP(w, r)
+
+	t.Log("Given the need to validate a new user can't reuse an email.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen using the email of an existing user.", testID)
+		{
+			if w.Code != http.StatusConflict {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 409 for the response : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 409 for the response.", tests.Success, testID)
+
+			var got validate.ErrorResponse
+			if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to unmarshal the response to an error type : %v", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to unmarshal the response to an error type.", tests.Success, testID)
+
+			fields := validate.FieldErrors{
+				{Field: "email", Error: "email is already in use"},
+			}
+			exp := validate.ErrorResponse{
+				Error:  "duplicate value: email",
+				Fields: fields.Error(),
+			}
+
+			if diff := cmp.Diff(got, exp); diff != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould get the expected result. Diff:\n%s", tests.Failed, testID, diff)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get the expected result.", tests.Success, testID)
+		}
 	}
 }
 
@@ -283,7 +619,7 @@ func (ut *UserTests) getUser403(t *testing.T) {
 			t.Logf("\t%s\tTest %d:\tShould receive a status code of 403 for the response.", tests.Success, testID)
 
 			recv := w.Body.String()
-			resp := `{"error":"query: attempted action is not allowed"}`
+			resp := `{"error":"query: attempted action is not allowed: no_matching_policy","reason":"no_matching_policy"}`
 			if resp != recv {
 				t.Log("Got :", recv)
 				t.Log("Want:", resp)
@@ -342,6 +678,43 @@ func (ut *UserTests) getUser404(t *testing.T) {
 	}
 }
 
+// getUsers200 validates the listing of users can be paged and filtered.
+func (ut *UserTests) getUsers200(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/v1/users?role=ADMIN&rows=1&orderBy=email,DESC", nil)
+	w := httptest.NewRecorder()
+
+	r.Header.Set("Authorization", "Bearer "+ut.adminToken)
+	ut.app.ServeHTTP(w, r)
+
+	t.Log("Given the need to page through the users.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen filtering the users by role.", testID)
+		{
+			if w.Code != http.StatusOK {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 200 for the response : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 200 for the response.", tests.Success, testID)
+
+			var got page.Response[incoming.User]
+			if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to unmarshal the response : %v", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to unmarshal the response.", tests.Success, testID)
+
+			if got.Total != 1 || len(got.Items) != 1 || got.Items[0].Email != "admin@example.com" {
+				t.Fatalf("\t%s\tTest %d:\tShould only get the admin : %+v", tests.Failed, testID, got)
+			}
+			t.Logf("\t%s\tTest %d:\tShould only get the admin.", tests.Success, testID)
+
+			if !strings.Contains(w.Header().Get("Link"), `rel="last"`) {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a Link header : %q", tests.Failed, testID, w.Header().Get("Link"))
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a Link header.", tests.Success, testID)
+		}
+	}
+}
+
 // deleteUserNotFound validates deleting a user that does not exist is not a failure.
 func (ut *UserTests) deleteUserNotFound(t *testing.T) {
 	id := "a71f77b2-b1ae-4964-a847-f9eecba09d74"
@@ -362,6 +735,34 @@ func (ut *UserTests) deleteUserNotFound(t *testing.T) {
 			}
 			t.Logf("\t%s\tTest %d:\tShould receive a status code of 204 for the response.", tests.Success, testID)
 		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen using the new user %s with If-Match.", testID, id)
+		{
+			r := httptest.NewRequest(http.MethodDelete, "/v1/users/"+id, nil)
+			w := httptest.NewRecorder()
+
+			r.Header.Set("Authorization", "Bearer "+ut.adminToken)
+			r.Header.Set("If-Match", `"1"`)
+			ut.app.ServeHTTP(w, r)
+
+			if w.Code != http.StatusPreconditionFailed {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 412 for the response : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 412 for the response.", tests.Success, testID)
+
+			r = httptest.NewRequest(http.MethodDelete, "/v1/users/"+id, nil)
+			w = httptest.NewRecorder()
+
+			r.Header.Set("Authorization", "Bearer "+ut.adminToken)
+			r.Header.Set("If-Match", "*")
+			ut.app.ServeHTTP(w, r)
+
+			if w.Code != http.StatusPreconditionFailed {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 412 for If-Match: * : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 412 for If-Match: *.", tests.Success, testID)
+		}
 	}
 }
 
@@ -410,9 +811,14 @@ func (ut *UserTests) crudUser(t *testing.T) {
 	nu := ut.postUser201(t)
 	defer ut.deleteUser204(t, nu.ID)
 
+	ut.verifyUser204(t)
+
 	ut.getUser200(t, nu.ID)
 	ut.putUser204(t, nu.ID)
+	ut.putUser412(t, nu.ID)
+	ut.patchUser204(t, nu.ID)
 	ut.putUser403(t, nu.ID)
+	ut.resetPassword(t, nu.ID)
 }
 
 // postUser201 validates a user can be created with the endpoint.
@@ -470,100 +876,312 @@ func (ut *UserTests) postUser201(t *testing.T) incoming.User {
 	return got
 }
 
-// deleteUser204 validates deleting a user that does exist.
-func (ut *UserTests) deleteUser204(t *testing.T, id string) {
-	r := httptest.NewRequest(http.MethodDelete, "/v1/users/"+id, nil)
-	w := httptest.NewRecorder()
-
-	r.Header.Set("Authorization", "Bearer "+ut.adminToken)
-	ut.app.ServeHTTP(w, r)
-
-	t.Log("Given the need to validate deleting a user that does exist.")
+// verifyUser204 validates the link mailed to a new user verifies the email.
+func (ut *UserTests) verifyUser204(t *testing.T) {
+	t.Log("Given the need to verify the email of new users.")
 	{
 		testID := 0
-		t.Logf("\tTest %d:\tWhen using the new user %s.", testID, id)
+		t.Logf("\tTest %d:\tWhen using the token of the verification link.", testID)
 		{
+			m := regexp.MustCompile(`token=([\w.-]+)`).FindStringSubmatch(ut.mail.String())
+			if m == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a verification link : %s", tests.Failed, testID, ut.mail.String())
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a verification link.", tests.Success, testID)
+
+			body, err := json.Marshal(incoming.VerifyUser{Token: m[1]})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			r := httptest.NewRequest(http.MethodPost, "/v1/users/verify", bytes.NewBuffer(body))
+			w := httptest.NewRecorder()
+			ut.app.ServeHTTP(w, r)
+
 			if w.Code != http.StatusNoContent {
 				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 204 for the response : %v", tests.Failed, testID, w.Code)
 			}
 			t.Logf("\t%s\tTest %d:\tShould receive a status code of 204 for the response.", tests.Success, testID)
 		}
-	}
-}
-
-// getUser200 validates a user request for an existing userid.
-func (ut *UserTests) getUser200(t *testing.T, id string) {
-	r := httptest.NewRequest(http.MethodGet, "/v1/users/"+id, nil)
-	w := httptest.NewRecorder()
-
-	r.Header.Set("Authorization", "Bearer "+ut.adminToken)
-	ut.app.ServeHTTP(w, r)
 
-	t.Log("Given the need to validate getting a user that exsits.")
-	{
-		testID := 0
-		t.Logf("\tTest %d:\tWhen using the new user %s.", testID, id)
+		testID = 1
+		t.Logf("\tTest %d:\tWhen using a forged token.", testID)
 		{
-			if w.Code != http.StatusOK {
-				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 200 for the response : %v", tests.Failed, testID, w.Code)
-			}
-			t.Logf("\t%s\tTest %d:\tShould receive a status code of 200 for the response.", tests.Success, testID)
-
-			var got incoming.User
-			if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
-				t.Fatalf("\t%s\tTest %d:\tShould be able to unmarshal the response : %v", tests.Failed, testID, err)
-			}
-
-			// Define what we wanted to receive. We will just trust the generated
-			// fields like Dates so we copy p.
-			exp := got
-			exp.ID = id
-			exp.Name = "Bill Kennedy"
-			exp.Email = "bill@ardanlabs.com"
-			exp.Roles = []string{auth.RoleAdmin}
+			r := httptest.NewRequest(http.MethodPost, "/v1/users/verify", strings.NewReader(`{"token": "forged"}`))
+			w := httptest.NewRecorder()
+			ut.app.ServeHTTP(w, r)
 
-			if diff := cmp.Diff(got, exp); diff != "" {
-				t.Fatalf("\t%s\tTest %d:\tShould get the expected result. Diff:\n%s", tests.Failed, testID, diff)
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 400 for the response : %v", tests.Failed, testID, w.Code)
 			}
-			t.Logf("\t%s\tTest %d:\tShould get the expected result.", tests.Success, testID)
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 400 for the response.", tests.Success, testID)
 		}
 	}
 }
 
-// putUser204 validates updating a user that does exist.
-func (ut *UserTests) putUser204(t *testing.T, id string) {
-	body := `{"name": "Jacob Walker"}`
-
-	r := httptest.NewRequest(http.MethodPut, "/v1/users/"+id, strings.NewReader(body))
+// resetPassword validates a user can replace a forgotten password with the
+// mailed link and that the tokens issued before are revoked.
+func (ut *UserTests) resetPassword(t *testing.T, id string) {
+	r := httptest.NewRequest(http.MethodGet, "/v1/users/token", nil)
 	w := httptest.NewRecorder()
 
-	r.Header.Set("Authorization", "Bearer "+ut.adminToken)
+	r.SetBasicAuth("bill@ardanlabs.com", "gophers")
 	ut.app.ServeHTTP(w, r)
 
-	t.Log("Given the need to update a user with the users endpoint.")
+	var old struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&old); err != nil {
+		t.Fatalf("decoding token: %s", err)
+	}
+
+	t.Log("Given the need to reset forgotten passwords.")
 	{
 		testID := 0
-		t.Logf("\tTest %d:\tWhen using the modified user value.", testID)
+		t.Logf("\tTest %d:\tWhen asking for a reset link for an unknown email.", testID)
 		{
-			if w.Code != http.StatusNoContent {
-				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 204 for the response : %v", tests.Failed, testID, w.Code)
+			r := httptest.NewRequest(http.MethodPost, "/v1/auth/password/forgot", strings.NewReader(`{"email": "unknown@example.com"}`))
+			w := httptest.NewRecorder()
+			ut.app.ServeHTTP(w, r)
+
+			if w.Code != http.StatusAccepted {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 202 for the response : %v", tests.Failed, testID, w.Code)
 			}
-			t.Logf("\t%s\tTest %d:\tShould receive a status code of 204 for the response.", tests.Success, testID)
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 202 for the response.", tests.Success, testID)
+		}
 
-			r = httptest.NewRequest(http.MethodGet, "/v1/users/"+id, nil)
-			w = httptest.NewRecorder()
+		var token string
 
-			r.Header.Set("Authorization", "Bearer "+ut.adminToken)
+		testID = 1
+		t.Logf("\tTest %d:\tWhen asking for a reset link for a known email.", testID)
+		{
+			r := httptest.NewRequest(http.MethodPost, "/v1/auth/password/forgot", strings.NewReader(`{"email": "bill@ardanlabs.com"}`))
+			w := httptest.NewRecorder()
 			ut.app.ServeHTTP(w, r)
 
-			if w.Code != http.StatusOK {
-				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 200 for the retrieve : %v", tests.Failed, testID, w.Code)
+			if w.Code != http.StatusAccepted {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 202 for the response : %v", tests.Failed, testID, w.Code)
 			}
-			t.Logf("\t%s\tTest %d:\tShould receive a status code of 200 for the retrieve.", tests.Success, testID)
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 202 for the response.", tests.Success, testID)
 
-			var ru incoming.User
-			if err := json.NewDecoder(w.Body).Decode(&ru); err != nil {
-				t.Fatalf("\t%s\tTest %d:\tShould be able to unmarshal the response : %v", tests.Failed, testID, err)
+			m := ut.mail.wait(regexp.MustCompile(`reset\?token=([\w.-]+)`))
+			if m == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a reset link : %s", tests.Failed, testID, ut.mail.String())
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a reset link.", tests.Success, testID)
+			token = m[1]
+		}
+
+		testID = 2
+		t.Logf("\tTest %d:\tWhen reusing the current password.", testID)
+		{
+			body, err := json.Marshal(incoming.ResetPassword{Token: token, Password: "gophers", PasswordConfirm: "gophers"})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			r := httptest.NewRequest(http.MethodPost, "/v1/auth/password/reset", bytes.NewReader(body))
+			w := httptest.NewRecorder()
+			ut.app.ServeHTTP(w, r)
+
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 400 for the response : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 400 for the response.", tests.Success, testID)
+		}
+
+		body, err := json.Marshal(incoming.ResetPassword{Token: token, Password: "new-gophers", PasswordConfirm: "new-gophers"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		testID = 3
+		t.Logf("\tTest %d:\tWhen using the token of the reset link.", testID)
+		{
+			r := httptest.NewRequest(http.MethodPost, "/v1/auth/password/reset", bytes.NewReader(body))
+			w := httptest.NewRecorder()
+			ut.app.ServeHTTP(w, r)
+
+			if w.Code != http.StatusNoContent {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 204 for the response : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 204 for the response.", tests.Success, testID)
+		}
+
+		testID = 4
+		t.Logf("\tTest %d:\tWhen using a token issued before the reset.", testID)
+		{
+			r := httptest.NewRequest(http.MethodGet, "/v1/users/"+id, nil)
+			w := httptest.NewRecorder()
+
+			r.Header.Set("Authorization", "Bearer "+old.Token)
+			ut.app.ServeHTTP(w, r)
+
+			if w.Code != http.StatusUnauthorized {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 401 for the response : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 401 for the response.", tests.Success, testID)
+		}
+
+		testID = 5
+		t.Logf("\tTest %d:\tWhen using the token of the reset link again.", testID)
+		{
+			r := httptest.NewRequest(http.MethodPost, "/v1/auth/password/reset", bytes.NewReader(body))
+			w := httptest.NewRecorder()
+			ut.app.ServeHTTP(w, r)
+
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 400 for the response : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 400 for the response.", tests.Success, testID)
+		}
+
+		testID = 6
+		t.Logf("\tTest %d:\tWhen using a token issued right after the reset.", testID)
+		{
+			r := httptest.NewRequest(http.MethodGet, "/v1/users/token", nil)
+			w := httptest.NewRecorder()
+
+			r.SetBasicAuth("bill@ardanlabs.com", "new-gophers")
+			ut.app.ServeHTTP(w, r)
+
+			var tkn struct {
+				Token string `json:"token"`
+			}
+			if err := json.NewDecoder(w.Body).Decode(&tkn); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to log in with the new password : %v", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to log in with the new password.", tests.Success, testID)
+
+			r = httptest.NewRequest(http.MethodGet, "/v1/users/"+id, nil)
+			w = httptest.NewRecorder()
+
+			r.Header.Set("Authorization", "Bearer "+tkn.Token)
+			ut.app.ServeHTTP(w, r)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 200 for the response : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 200 for the response.", tests.Success, testID)
+		}
+
+		testID = 7
+		t.Logf("\tTest %d:\tWhen asking for reset links for the same email too often.", testID)
+		{
+			var w *httptest.ResponseRecorder
+			for i := 0; i < 4; i++ {
+				r := httptest.NewRequest(http.MethodPost, "/v1/auth/password/forgot", strings.NewReader(`{"email": "flood@example.com"}`))
+				w = httptest.NewRecorder()
+				ut.app.ServeHTTP(w, r)
+			}
+
+			if w.Code != http.StatusTooManyRequests {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 429 for the response : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 429 for the response.", tests.Success, testID)
+
+			if w.Header().Get("Retry-After") == "" {
+				t.Fatalf("\t%s\tTest %d:\tShould be told when to retry.", tests.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be told when to retry.", tests.Success, testID)
+		}
+	}
+}
+
+// deleteUser204 validates deleting a user that does exist.
+func (ut *UserTests) deleteUser204(t *testing.T, id string) {
+	r := httptest.NewRequest(http.MethodDelete, "/v1/users/"+id, nil)
+	w := httptest.NewRecorder()
+
+	r.Header.Set("Authorization", "Bearer "+ut.adminToken)
+	ut.app.ServeHTTP(w, r)
+
+	t.Log("Given the need to validate deleting a user that does exist.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen using the new user %s.", testID, id)
+		{
+			if w.Code != http.StatusNoContent {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 204 for the response : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 204 for the response.", tests.Success, testID)
+		}
+	}
+}
+
+// getUser200 validates a user request for an existing userid.
+func (ut *UserTests) getUser200(t *testing.T, id string) {
+	r := httptest.NewRequest(http.MethodGet, "/v1/users/"+id, nil)
+	w := httptest.NewRecorder()
+
+	r.Header.Set("Authorization", "Bearer "+ut.adminToken)
+	ut.app.ServeHTTP(w, r)
+
+	t.Log("Given the need to validate getting a user that exsits.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen using the new user %s.", testID, id)
+		{
+			if w.Code != http.StatusOK {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 200 for the response : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 200 for the response.", tests.Success, testID)
+
+			var got incoming.User
+			if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to unmarshal the response : %v", tests.Failed, testID, err)
+			}
+
+			// Define what we wanted to receive. We will just trust the generated
+			// fields like Dates so we copy p.
+			exp := got
+			exp.ID = id
+			exp.Name = "Bill Kennedy"
+			exp.Email = "bill@ardanlabs.com"
+			exp.Roles = []string{auth.RoleAdmin}
+
+			if diff := cmp.Diff(got, exp); diff != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould get the expected result. Diff:\n%s", tests.Failed, testID, diff)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get the expected result.", tests.Success, testID)
+		}
+	}
+}
+
+// putUser204 validates updating a user that does exist.
+func (ut *UserTests) putUser204(t *testing.T, id string) {
+	body := `{"name": "Jacob Walker"}`
+
+	r := httptest.NewRequest(http.MethodPut, "/v1/users/"+id, strings.NewReader(body))
+	w := httptest.NewRecorder()
+
+	r.Header.Set("Authorization", "Bearer "+ut.adminToken)
+	ut.app.ServeHTTP(w, r)
+
+	t.Log("Given the need to update a user with the users endpoint.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen using the modified user value.", testID)
+		{
+			if w.Code != http.StatusNoContent {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 204 for the response : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 204 for the response.", tests.Success, testID)
+
+			r = httptest.NewRequest(http.MethodGet, "/v1/users/"+id, nil)
+			w = httptest.NewRecorder()
+
+			r.Header.Set("Authorization", "Bearer "+ut.adminToken)
+			ut.app.ServeHTTP(w, r)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 200 for the retrieve : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 200 for the retrieve.", tests.Success, testID)
+
+			var ru incoming.User
+			if err := json.NewDecoder(w.Body).Decode(&ru); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to unmarshal the response : %v", tests.Failed, testID, err)
 			}
 
 			if ru.Name != "Jacob Walker" {
@@ -579,25 +1197,652 @@ func (ut *UserTests) putUser204(t *testing.T, id string) {
 	}
 }
 
-// putUser403 validates that a user can't modify users unless they are an admin.
-func (ut *UserTests) putUser403(t *testing.T, id string) {
-	body := `{"name": "Anna Walker"}`
-
-	r := httptest.NewRequest(http.MethodPut, "/v1/users/"+id, strings.NewReader(body))
+// putUser412 validates that a user based on an outdated version can't be
+// updated.
+func (ut *UserTests) putUser412(t *testing.T, id string) {
+	r := httptest.NewRequest(http.MethodGet, "/v1/users/"+id, nil)
 	w := httptest.NewRecorder()
 
-	r.Header.Set("Authorization", "Bearer "+ut.userToken)
+	r.Header.Set("Authorization", "Bearer "+ut.adminToken)
 	ut.app.ServeHTTP(w, r)
 
-	t.Log("Given the need to update a user with the users endpoint.")
+	etag := w.Header().Get("ETag")
+
+	t.Log("Given the need to prevent lost updates.")
 	{
 		testID := 0
-		t.Logf("\tTest %d:\tWhen a non-admin user makes a request", testID)
+		t.Logf("\tTest %d:\tWhen updating with the current entity tag.", testID)
 		{
-			if w.Code != http.StatusForbidden {
-				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 403 for the response : %v", tests.Failed, testID, w.Code)
+			if etag == "" {
+				t.Fatalf("\t%s\tTest %d:\tShould receive an ETag header.", tests.Failed, testID)
 			}
-			t.Logf("\t%s\tTest %d:\tShould receive a status code of 403 for the response.", tests.Success, testID)
+			t.Logf("\t%s\tTest %d:\tShould receive an ETag header.", tests.Success, testID)
+
+			r = httptest.NewRequest(http.MethodPut, "/v1/users/"+id, strings.NewReader(`{"name": "Jacob Walker"}`))
+			w = httptest.NewRecorder()
+
+			r.Header.Set("Authorization", "Bearer "+ut.adminToken)
+			r.Header.Set("If-Match", etag)
+			ut.app.ServeHTTP(w, r)
+
+			if w.Code != http.StatusNoContent {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 204 for the response : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 204 for the response.", tests.Success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen updating with the same entity tag again.", testID)
+		{
+			r = httptest.NewRequest(http.MethodPut, "/v1/users/"+id, strings.NewReader(`{"name": "Anna Walker"}`))
+			w = httptest.NewRecorder()
+
+			r.Header.Set("Authorization", "Bearer "+ut.adminToken)
+			r.Header.Set("If-Match", etag)
+			ut.app.ServeHTTP(w, r)
+
+			if w.Code != http.StatusPreconditionFailed {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 412 for the response : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 412 for the response.", tests.Success, testID)
+		}
+	}
+}
+
+// patchUser204 validates patching a user with both patch formats.
+func (ut *UserTests) patchUser204(t *testing.T, id string) {
+	t.Log("Given the need to patch a user with the users endpoint.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen using a JSON Merge Patch.", testID)
+		{
+			r := httptest.NewRequest(http.MethodPatch, "/v1/users/"+id, strings.NewReader(`{"name": "Bill Walker"}`))
+			w := httptest.NewRecorder()
+
+			r.Header.Set("Authorization", "Bearer "+ut.adminToken)
+			r.Header.Set("Content-Type", "application/merge-patch+json")
+			ut.app.ServeHTTP(w, r)
+
+			if w.Code != http.StatusNoContent {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 204 for the response : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 204 for the response.", tests.Success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen using a JSON Patch on a single role.", testID)
+		{
+			body := `[{"op": "test", "path": "/name", "value": "Bill Walker"}, {"op": "add", "path": "/roles/-", "value": "USER"}]`
+			r := httptest.NewRequest(http.MethodPatch, "/v1/users/"+id, strings.NewReader(body))
+			w := httptest.NewRecorder()
+
+			r.Header.Set("Authorization", "Bearer "+ut.adminToken)
+			r.Header.Set("Content-Type", "application/json-patch+json")
+			ut.app.ServeHTTP(w, r)
+
+			if w.Code != http.StatusNoContent {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 204 for the response : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 204 for the response.", tests.Success, testID)
+
+			r = httptest.NewRequest(http.MethodGet, "/v1/users/"+id, nil)
+			w = httptest.NewRecorder()
+
+			r.Header.Set("Authorization", "Bearer "+ut.adminToken)
+			ut.app.ServeHTTP(w, r)
+
+			var ru incoming.User
+			if err := json.NewDecoder(w.Body).Decode(&ru); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to unmarshal the response : %v", tests.Failed, testID, err)
+			}
+
+			exp := []string{auth.RoleAdmin, auth.RoleUser}
+			if diff := cmp.Diff([]string(ru.Roles), exp); diff != "" || ru.Name != "Bill Walker" {
+				t.Fatalf("\t%s\tTest %d:\tShould see the patched user. Diff:\n%s", tests.Failed, testID, diff)
+			}
+			t.Logf("\t%s\tTest %d:\tShould see the patched user.", tests.Success, testID)
+		}
+
+		testID = 2
+		t.Logf("\tTest %d:\tWhen clearing a required field.", testID)
+		{
+			r := httptest.NewRequest(http.MethodPatch, "/v1/users/"+id, strings.NewReader(`{"email": null}`))
+			w := httptest.NewRecorder()
+
+			r.Header.Set("Authorization", "Bearer "+ut.adminToken)
+			r.Header.Set("Content-Type", "application/merge-patch+json")
+			ut.app.ServeHTTP(w, r)
+
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 400 for the response : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 400 for the response.", tests.Success, testID)
+		}
+	}
+}
+
+// putUser403 validates that a user can't modify users unless they are an admin.
+func (ut *UserTests) putUser403(t *testing.T, id string) {
+	body := `{"name": "Anna Walker"}`
+
+	r := httptest.NewRequest(http.MethodPut, "/v1/users/"+id, strings.NewReader(body))
+	w := httptest.NewRecorder()
+
+	r.Header.Set("Authorization", "Bearer "+ut.userToken)
+	ut.app.ServeHTTP(w, r)
+
+	t.Log("Given the need to update a user with the users endpoint.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen a non-admin user makes a request", testID)
+		{
+			if w.Code != http.StatusForbidden {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 403 for the response : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 403 for the response.", tests.Success, testID)
+		}
+	}
+}
+
+// meUser validates users can read and modify their own profile and password
+// but can't grant themselves roles.
+func (ut *UserTests) meUser(t *testing.T) {
+	nu := incoming.NewUser{
+		Name:            "Jill Walker",
+		Email:           "jill@example.com",
+		Roles:           []string{auth.RoleUser},
+		Password:        "gophers",
+		PasswordConfirm: "gophers",
+	}
+
+	body, err := json.Marshal(&nu)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/v1/users", bytes.NewBuffer(body))
+	w := httptest.NewRecorder()
+
+	r.Header.Set("Authorization", "Bearer "+ut.adminToken)
+	ut.app.ServeHTTP(w, r)
+
+	var usr incoming.User
+	if err := json.NewDecoder(w.Body).Decode(&usr); err != nil {
+		t.Fatalf("decoding user: %s", err)
+	}
+	defer ut.deleteUser204(t, usr.ID)
+
+	token := func(password string) (string, int) {
+		r := httptest.NewRequest(http.MethodGet, "/v1/users/token", nil)
+		w := httptest.NewRecorder()
+
+		r.SetBasicAuth("jill@example.com", password)
+		ut.app.ServeHTTP(w, r)
+
+		var tkn struct {
+			Token string `json:"token"`
+		}
+		if err := json.NewDecoder(w.Body).Decode(&tkn); err != nil {
+			t.Fatalf("decoding token: %s", err)
+		}
+		return tkn.Token, w.Code
+	}
+
+	tkn, _ := token("gophers")
+
+	getMe := func() incoming.User {
+		r := httptest.NewRequest(http.MethodGet, "/v1/users/me", nil)
+		w := httptest.NewRecorder()
+
+		r.Header.Set("Authorization", "Bearer "+tkn)
+		ut.app.ServeHTTP(w, r)
+
+		var got incoming.User
+		if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+			t.Fatalf("decoding user: %s", err)
+		}
+		return got
+	}
+
+	t.Log("Given the need for users to manage their own profile.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen reading the profile.", testID)
+		{
+			r := httptest.NewRequest(http.MethodGet, "/v1/users/me", nil)
+			w := httptest.NewRecorder()
+
+			r.Header.Set("Authorization", "Bearer "+tkn)
+			ut.app.ServeHTTP(w, r)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 200 for the response : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 200 for the response.", tests.Success, testID)
+
+			var got incoming.User
+			if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to unmarshal the response : %v", tests.Failed, testID, err)
+			}
+			if got.ID != usr.ID {
+				t.Fatalf("\t%s\tTest %d:\tShould get the own profile : %s", tests.Failed, testID, got.ID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get the own profile.", tests.Success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen changing the name.", testID)
+		{
+			r := httptest.NewRequest(http.MethodPut, "/v1/users/me", strings.NewReader(`{"name": "Jill Gopher"}`))
+			w := httptest.NewRecorder()
+
+			r.Header.Set("Authorization", "Bearer "+tkn)
+			ut.app.ServeHTTP(w, r)
+
+			if w.Code != http.StatusNoContent {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 204 for the response : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 204 for the response.", tests.Success, testID)
+
+			if got := getMe(); got.Name != "Jill Gopher" {
+				t.Fatalf("\t%s\tTest %d:\tShould see the updated name : %s", tests.Failed, testID, got.Name)
+			}
+			t.Logf("\t%s\tTest %d:\tShould see the updated name.", tests.Success, testID)
+		}
+
+		testID = 2
+		t.Logf("\tTest %d:\tWhen granting roles to oneself.", testID)
+		{
+			r := httptest.NewRequest(http.MethodPut, "/v1/users/me", strings.NewReader(`{"roles": ["ADMIN"]}`))
+			w := httptest.NewRecorder()
+
+			r.Header.Set("Authorization", "Bearer "+tkn)
+			ut.app.ServeHTTP(w, r)
+
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 400 for the response : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 400 for the response.", tests.Success, testID)
+
+			if diff := cmp.Diff([]string(getMe().Roles), []string{auth.RoleUser}); diff != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould keep the roles. Diff:\n%s", tests.Failed, testID, diff)
+			}
+			t.Logf("\t%s\tTest %d:\tShould keep the roles.", tests.Success, testID)
+		}
+
+		testID = 3
+		t.Logf("\tTest %d:\tWhen changing the password with a wrong current password.", testID)
+		{
+			body := `{"current_password": "rustaceans", "password": "new-secret", "password_confirm": "new-secret"}`
+			r := httptest.NewRequest(http.MethodPost, "/v1/users/me/password", strings.NewReader(body))
+			w := httptest.NewRecorder()
+
+			r.Header.Set("Authorization", "Bearer "+tkn)
+			ut.app.ServeHTTP(w, r)
+
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 400 for the response : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 400 for the response.", tests.Success, testID)
+		}
+
+		testID = 4
+		t.Logf("\tTest %d:\tWhen changing the password with the current password.", testID)
+		{
+			body := `{"current_password": "gophers", "password": "new-secret", "password_confirm": "new-secret"}`
+			r := httptest.NewRequest(http.MethodPost, "/v1/users/me/password", strings.NewReader(body))
+			w := httptest.NewRecorder()
+
+			r.Header.Set("Authorization", "Bearer "+tkn)
+			ut.app.ServeHTTP(w, r)
+
+			if w.Code != http.StatusNoContent {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 204 for the response : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 204 for the response.", tests.Success, testID)
+
+			r = httptest.NewRequest(http.MethodGet, "/v1/users/me", nil)
+			w = httptest.NewRecorder()
+
+			r.Header.Set("Authorization", "Bearer "+tkn)
+			ut.app.ServeHTTP(w, r)
+
+			if w.Code != http.StatusUnauthorized {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT accept the token issued before : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT accept the token issued before.", tests.Success, testID)
+
+			if _, code := token("new-secret"); code != http.StatusOK {
+				t.Fatalf("\t%s\tTest %d:\tShould log in with the new password : %v", tests.Failed, testID, code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould log in with the new password.", tests.Success, testID)
+		}
+	}
+}
+
+// statusUser validates admins can suspend and reinstate users and that
+// suspended users are locked out right away.
+func (ut *UserTests) statusUser(t *testing.T) {
+	nu := incoming.NewUser{
+		Name:            "Jack Walker",
+		Email:           "jack@example.com",
+		Roles:           []string{auth.RoleUser},
+		Password:        "gophers",
+		PasswordConfirm: "gophers",
+	}
+
+	body, err := json.Marshal(&nu)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/v1/users", bytes.NewBuffer(body))
+	w := httptest.NewRecorder()
+
+	r.Header.Set("Authorization", "Bearer "+ut.adminToken)
+	ut.app.ServeHTTP(w, r)
+
+	var usr incoming.User
+	if err := json.NewDecoder(w.Body).Decode(&usr); err != nil {
+		t.Fatalf("decoding user: %s", err)
+	}
+	defer ut.deleteUser204(t, usr.ID)
+
+	login := func() (string, int) {
+		r := httptest.NewRequest(http.MethodGet, "/v1/users/token", nil)
+		w := httptest.NewRecorder()
+
+		r.SetBasicAuth("jack@example.com", "gophers")
+		ut.app.ServeHTTP(w, r)
+
+		var tkn struct {
+			Token string `json:"token"`
+		}
+		if err := json.NewDecoder(w.Body).Decode(&tkn); err != nil {
+			t.Fatalf("decoding token: %s", err)
+		}
+		return tkn.Token, w.Code
+	}
+
+	attempt := func(password string) int {
+		r := httptest.NewRequest(http.MethodGet, "/v1/users/token", nil)
+		w := httptest.NewRecorder()
+
+		r.SetBasicAuth("jack@example.com", password)
+		ut.app.ServeHTTP(w, r)
+
+		return w.Code
+	}
+
+	setStatus := func(id string, body string) int {
+		r := httptest.NewRequest(http.MethodPut, "/v1/users/"+id+"/status", strings.NewReader(body))
+		w := httptest.NewRecorder()
+
+		r.Header.Set("Authorization", "Bearer "+ut.adminToken)
+		ut.app.ServeHTTP(w, r)
+
+		return w.Code
+	}
+
+	tkn, _ := login()
+
+	// A reset link mailed while the user is still active.
+	r = httptest.NewRequest(http.MethodPost, "/v1/auth/password/forgot", strings.NewReader(`{"email": "jack@example.com"}`))
+	w = httptest.NewRecorder()
+	ut.app.ServeHTTP(w, r)
+
+	m := ut.mail.wait(regexp.MustCompile(`(?s)To: jack@example.com\r\n.*?reset\?token=([\w.-]+)`))
+	if m == nil {
+		t.Fatalf("receiving reset link: %s", ut.mail.String())
+	}
+	resetToken := m[1]
+
+	// Reading the profile caches the status of the user.
+	r = httptest.NewRequest(http.MethodGet, "/v1/users/me", nil)
+	w = httptest.NewRecorder()
+
+	r.Header.Set("Authorization", "Bearer "+tkn)
+	ut.app.ServeHTTP(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("reading profile: %d", w.Code)
+	}
+
+	t.Log("Given the need to manage the status of user accounts.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen suspending a user.", testID)
+		{
+			if code := setStatus(usr.ID, `{"status": "SUSPENDED", "reason": "spam"}`); code != http.StatusNoContent {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 204 for the response : %v", tests.Failed, testID, code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 204 for the response.", tests.Success, testID)
+
+			r := httptest.NewRequest(http.MethodGet, "/v1/users/me", nil)
+			w := httptest.NewRecorder()
+
+			r.Header.Set("Authorization", "Bearer "+tkn)
+			ut.app.ServeHTTP(w, r)
+
+			if w.Code != http.StatusUnauthorized {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT accept the token of the user : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT accept the token of the user.", tests.Success, testID)
+
+			if _, code := login(); code != http.StatusForbidden {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT let the user log in : %v", tests.Failed, testID, code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT let the user log in.", tests.Success, testID)
+
+			ut.getUser200Status(t, testID, usr.ID, "SUSPENDED", "spam")
+
+			body, err := json.Marshal(incoming.ResetPassword{Token: resetToken, Password: "new-gophers", PasswordConfirm: "new-gophers"})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			r = httptest.NewRequest(http.MethodPost, "/v1/auth/password/reset", bytes.NewReader(body))
+			w = httptest.NewRecorder()
+			ut.app.ServeHTTP(w, r)
+
+			if w.Code != http.StatusForbidden {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT let the user reset the password : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT let the user reset the password.", tests.Success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen moving a user to a status it can't reach.", testID)
+		{
+			if code := setStatus(usr.ID, `{"status": "PENDING", "reason": "oops"}`); code != http.StatusConflict {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 409 for the response : %v", tests.Failed, testID, code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 409 for the response.", tests.Success, testID)
+		}
+
+		testID = 2
+		t.Logf("\tTest %d:\tWhen an admin changes the own status.", testID)
+		{
+			if code := setStatus("5cf37266-3473-4006-984f-9325122678b7", `{"status": "DISABLED", "reason": "oops"}`); code != http.StatusForbidden {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 403 for the response : %v", tests.Failed, testID, code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 403 for the response.", tests.Success, testID)
+		}
+
+		testID = 3
+		t.Logf("\tTest %d:\tWhen reinstating a user.", testID)
+		{
+			if code := setStatus(usr.ID, `{"status": "ACTIVE", "reason": "appeal accepted"}`); code != http.StatusNoContent {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 204 for the response : %v", tests.Failed, testID, code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 204 for the response.", tests.Success, testID)
+
+			if _, code := login(); code != http.StatusOK {
+				t.Fatalf("\t%s\tTest %d:\tShould let the user log in : %v", tests.Failed, testID, code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould let the user log in.", tests.Success, testID)
+		}
+
+		testID = 4
+		t.Logf("\tTest %d:\tWhen a suspended user guesses the password between failures.", testID)
+		{
+			if code := setStatus(usr.ID, `{"status": "SUSPENDED", "reason": "spam again"}`); code != http.StatusNoContent {
+				t.Fatalf("suspending user: status %d", code)
+			}
+
+			attempt("wrong")
+			attempt("wrong")
+			if code := attempt("gophers"); code != http.StatusForbidden {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 403 with the right password : %v", tests.Failed, testID, code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 403 with the right password.", tests.Success, testID)
+
+			attempt("wrong")
+			if code := attempt("gophers"); code != http.StatusTooManyRequests {
+				t.Fatalf("\t%s\tTest %d:\tShould keep counting the failures : %v", tests.Failed, testID, code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould keep counting the failures.", tests.Success, testID)
+		}
+	}
+}
+
+// getUser200Status validates the user has the status for the reason.
+func (ut *UserTests) getUser200Status(t *testing.T, testID int, id string, status string, reason string) {
+	r := httptest.NewRequest(http.MethodGet, "/v1/users/"+id, nil)
+	w := httptest.NewRecorder()
+
+	r.Header.Set("Authorization", "Bearer "+ut.adminToken)
+	ut.app.ServeHTTP(w, r)
+
+	var got incoming.User
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to unmarshal the response : %v", tests.Failed, testID, err)
+	}
+	if got.Status != status || got.StatusReason != reason {
+		t.Fatalf("\t%s\tTest %d:\tShould be %s for %q : %s %q", tests.Failed, testID, status, reason, got.Status, got.StatusReason)
+	}
+	t.Logf("\t%s\tTest %d:\tShould be %s for %q.", tests.Success, testID, status, reason)
+}
+
+// batchUsers validates batches of operations are applied in the requested
+// mode and report the outcome of every operation.
+func (ut *UserTests) batchUsers(t *testing.T) {
+	postBatch := func(body string) (int, incoming.BatchResponse) {
+		r := httptest.NewRequest(http.MethodPost, "/v1/users:batch", strings.NewReader(body))
+		w := httptest.NewRecorder()
+
+		r.Header.Set("Authorization", "Bearer "+ut.adminToken)
+		ut.app.ServeHTTP(w, r)
+
+		var resp incoming.BatchResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("decoding response: %s", err)
+		}
+		return w.Code, resp
+	}
+
+	statuses := func(resp incoming.BatchResponse) []int {
+		var got []int
+		for _, res := range resp.Results {
+			got = append(got, res.Status)
+		}
+		return got
+	}
+
+	var carlID string
+
+	t.Log("Given the need to modify many users at once.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen applying a batch in best effort mode.", testID)
+		{
+			body := `{"mode": "best_effort", "operations": [
+				{"op": "create", "user": {"name": "Carl Walker", "email": "carl@example.com", "roles": ["USER"], "password": "gophers", "password_confirm": "gophers"}},
+				{"op": "create", "user": {"name": "Admin Twin", "email": "admin@example.com", "roles": ["USER"], "password": "gophers", "password_confirm": "gophers"}},
+				{"op": "delete", "id": "abc"},
+				{"op": "update", "id": "45b5fbd3-755f-4379-8f07-a58d4a30fa2f"}
+			]}`
+
+			code, resp := postBatch(body)
+			if code != http.StatusOK {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 200 for the response : %v", tests.Failed, testID, code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 200 for the response.", tests.Success, testID)
+
+			exp := []int{http.StatusCreated, http.StatusConflict, http.StatusBadRequest, http.StatusBadRequest}
+			if diff := cmp.Diff(statuses(resp), exp); diff != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould get the status of every operation. Diff:\n%s", tests.Failed, testID, diff)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get the status of every operation.", tests.Success, testID)
+
+			if len(resp.Results[2].Fields) == 0 || resp.Results[2].Fields[0].Field != "id" {
+				t.Fatalf("\t%s\tTest %d:\tShould get the field errors of invalid operations : %+v", tests.Failed, testID, resp.Results[2])
+			}
+			t.Logf("\t%s\tTest %d:\tShould get the field errors of invalid operations.", tests.Success, testID)
+
+			carlID = resp.Results[0].ID
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen an operation of an atomic batch fails.", testID)
+		{
+			body := `{"mode": "atomic", "operations": [
+				{"op": "create", "user": {"name": "Dave Walker", "email": "dave@example.com", "roles": ["USER"], "password": "gophers", "password_confirm": "gophers"}},
+				{"op": "update", "id": "00000000-0000-4000-8000-000000000000", "user": {"name": "Nobody"}}
+			]}`
+
+			code, resp := postBatch(body)
+			if code != http.StatusUnprocessableEntity {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 422 for the response : %v", tests.Failed, testID, code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 422 for the response.", tests.Success, testID)
+
+			exp := []int{http.StatusFailedDependency, http.StatusNotFound}
+			if diff := cmp.Diff(statuses(resp), exp); diff != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould get the status of every operation. Diff:\n%s", tests.Failed, testID, diff)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get the status of every operation.", tests.Success, testID)
+
+			if strings.Contains(ut.mail.String(), "dave@example.com") {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT mail users that weren't created.", tests.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT mail users that weren't created.", tests.Success, testID)
+
+			r := httptest.NewRequest(http.MethodGet, "/v1/users?email=dave@example.com", nil)
+			w := httptest.NewRecorder()
+
+			r.Header.Set("Authorization", "Bearer "+ut.adminToken)
+			ut.app.ServeHTTP(w, r)
+
+			var pg page.Response[incoming.User]
+			if err := json.NewDecoder(w.Body).Decode(&pg); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to unmarshal the response : %v", tests.Failed, testID, err)
+			}
+			if len(pg.Items) != 0 {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT find the user of the failed batch : %d", tests.Failed, testID, len(pg.Items))
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT find the user of the failed batch.", tests.Success, testID)
+		}
+
+		testID = 2
+		t.Logf("\tTest %d:\tWhen applying an atomic batch.", testID)
+		{
+			body := `{"mode": "atomic", "operations": [
+				{"op": "set_roles", "id": "` + carlID + `", "roles": ["ADMIN"]},
+				{"op": "delete", "id": "` + carlID + `"}
+			]}`
+
+			code, resp := postBatch(body)
+			if code != http.StatusOK {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 200 for the response : %v", tests.Failed, testID, code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 200 for the response.", tests.Success, testID)
+
+			exp := []int{http.StatusNoContent, http.StatusNoContent}
+			if diff := cmp.Diff(statuses(resp), exp); diff != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould get the status of every operation. Diff:\n%s", tests.Failed, testID, diff)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get the status of every operation.", tests.Success, testID)
 		}
 	}
 }
@@ -0,0 +1,357 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	webhookCore "github.com/AgeroFlynn/crud/internal/buisness/core/webhook"
+	"github.com/AgeroFlynn/crud/internal/buisness/sys/tests"
+	"github.com/AgeroFlynn/crud/internal/buisness/web/page"
+	"github.com/AgeroFlynn/crud/internal/transport/rest/incoming"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// webhookDeliveries validates admins can subscribe webhooks to the events and
+// that the deliveries are signed, retried, logged and can be redelivered.
+func (ut *UserTests) webhookDeliveries(t *testing.T) {
+	send := func(method string, target string, body string, token string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, target, strings.NewReader(body))
+		w := httptest.NewRecorder()
+
+		r.Header.Set("Authorization", "Bearer "+token)
+		ut.app.ServeHTTP(w, r)
+
+		return w
+	}
+
+	// The receiver records the deliveries and answers with the status.
+	type received struct {
+		header http.Header
+		body   []byte
+	}
+	var (
+		mu         sync.Mutex
+		deliveries []received
+		status     = http.StatusOK
+	)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		deliveries = append(deliveries, received{header: r.Header, body: body})
+		w.WriteHeader(status)
+	}))
+	defer receiver.Close()
+
+	respond := func(code int) {
+		mu.Lock()
+		defer mu.Unlock()
+		status = code
+	}
+	count := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(deliveries)
+	}
+
+	// The receivers of the tests listen on the loopback address.
+	sender := webhookCore.NewSender(ut.log, ut.webhooks, webhookCore.SenderConfig{MaxAttempts: 3, Backoff: time.Minute, AllowPrivate: true})
+
+	// relay queues the deliveries of the published events and returns the
+	// time they are due.
+	relay := func() time.Time {
+		for {
+			n, err := ut.relay.Dispatch(context.Background(), time.Now().UTC())
+			if err != nil {
+				t.Fatalf("dispatching events: %s", err)
+			}
+			if n == 0 {
+				return time.Now().UTC()
+			}
+		}
+	}
+
+	// deliver sends the deliveries due at the time.
+	deliver := func(now time.Time) {
+		if _, err := sender.Deliver(context.Background(), now); err != nil {
+			t.Fatalf("sending deliveries: %s", err)
+		}
+	}
+
+	createUser := func(name string, email string) incoming.User {
+		w := send(http.MethodPost, "/v1/users", `{"name": "`+name+`", "email": "`+email+`", "roles": ["USER"], "password": "gophers", "password_confirm": "gophers"}`, ut.adminToken)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("creating user: status %d", w.Code)
+		}
+
+		var usr incoming.User
+		if err := json.NewDecoder(w.Body).Decode(&usr); err != nil {
+			t.Fatalf("decoding user: %s", err)
+		}
+		return usr
+	}
+
+	queryDeliveries := func(webhookID string) []incoming.Delivery {
+		w := send(http.MethodGet, "/v1/webhooks/"+webhookID+"/deliveries", "", ut.adminToken)
+		if w.Code != http.StatusOK {
+			t.Fatalf("querying deliveries: status %d", w.Code)
+		}
+
+		var got page.Response[incoming.Delivery]
+		if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+			t.Fatalf("decoding deliveries: %s", err)
+		}
+		return got.Items
+	}
+
+	t.Log("Given the need to notify downstream systems of the changes.")
+	{
+		var webhook incoming.Webhook
+
+		testID := 0
+		t.Logf("\tTest %d:\tWhen an admin subscribes a webhook to UserCreated.", testID)
+		{
+			w := send(http.MethodPost, "/v1/webhooks", `{"url": "`+receiver.URL+`/hooks", "events": ["UserCreated"], "description": "HR"}`, ut.adminToken)
+			if w.Code != http.StatusCreated {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 201 for the response : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 201 for the response.", tests.Success, testID)
+
+			if err := json.NewDecoder(w.Body).Decode(&webhook); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to unmarshal the response : %v", tests.Failed, testID, err)
+			}
+			if webhook.Secret == "" || !webhook.Active {
+				t.Fatalf("\t%s\tTest %d:\tShould get an active webhook with its secret : %+v", tests.Failed, testID, webhook)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get an active webhook with its secret.", tests.Success, testID)
+
+			w = send(http.MethodGet, "/v1/webhooks/"+webhook.ID, "", ut.adminToken)
+			if w.Code != http.StatusOK || strings.Contains(w.Body.String(), webhook.Secret) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT return the secret afterwards : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT return the secret afterwards.", tests.Success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen a webhook is invalid or created by a regular user.", testID)
+		{
+			if w := send(http.MethodPost, "/v1/webhooks", `{"url": "`+receiver.URL+`", "events": ["UserRenamed"]}`, ut.adminToken); w.Code != http.StatusBadRequest {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 400 for an unknown event : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 400 for an unknown event.", tests.Success, testID)
+
+			if w := send(http.MethodPost, "/v1/webhooks", `{"url": "ftp://example.com/hooks", "events": ["*"]}`, ut.adminToken); w.Code != http.StatusBadRequest {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 400 for a non http url : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 400 for a non http url.", tests.Success, testID)
+
+			if w := send(http.MethodGet, "/v1/webhooks", "", ut.userToken); w.Code != http.StatusForbidden {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 403 for a regular user : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 403 for a regular user.", tests.Success, testID)
+		}
+
+		testID = 2
+		t.Logf("\tTest %d:\tWhen a subscribed event is published.", testID)
+		{
+			usr := createUser("Wanda Brook", "wanda@example.com")
+			if w := send(http.MethodPut, "/v1/users/"+usr.ID, `{"name": "Wanda Stone"}`, ut.adminToken); w.Code != http.StatusNoContent {
+				t.Fatalf("updating user: status %d", w.Code)
+			}
+
+			deliver(relay())
+			if count() != 1 {
+				t.Fatalf("\t%s\tTest %d:\tShould deliver only the subscribed event : %d", tests.Failed, testID, count())
+			}
+			t.Logf("\t%s\tTest %d:\tShould deliver only the subscribed event.", tests.Success, testID)
+
+			got := deliveries[0]
+			if got.header.Get(webhookCore.HeaderEvent) != "UserCreated" || got.header.Get(webhookCore.HeaderDelivery) == "" {
+				t.Fatalf("\t%s\tTest %d:\tShould tell the event and the delivery : %v", tests.Failed, testID, got.header)
+			}
+			t.Logf("\t%s\tTest %d:\tShould tell the event and the delivery.", tests.Success, testID)
+
+			err := webhookCore.Verify(webhook.Secret, got.header.Get(webhookCore.HeaderTimestamp), got.header.Get(webhookCore.HeaderSignature), got.body, time.Now(), 5*time.Minute)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould sign the delivery with the secret : %v", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould sign the delivery with the secret.", tests.Success, testID)
+
+			if err := webhookCore.Verify("wrong secret", got.header.Get(webhookCore.HeaderTimestamp), got.header.Get(webhookCore.HeaderSignature), got.body, time.Now(), 5*time.Minute); err == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT verify with another secret.", tests.Failed, testID)
+			}
+			if err := webhookCore.Verify(webhook.Secret, got.header.Get(webhookCore.HeaderTimestamp), got.header.Get(webhookCore.HeaderSignature), got.body, time.Now().Add(time.Hour), 5*time.Minute); err == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT verify an old timestamp.", tests.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT verify with another secret or an old timestamp.", tests.Success, testID)
+
+			var body struct {
+				Type string `json:"type"`
+				Data struct {
+					UserID string `json:"user_id"`
+				} `json:"data"`
+			}
+			if err := json.Unmarshal(got.body, &body); err != nil || body.Type != "UserCreated" || body.Data.UserID != usr.ID {
+				t.Fatalf("\t%s\tTest %d:\tShould send the event : %s", tests.Failed, testID, got.body)
+			}
+			t.Logf("\t%s\tTest %d:\tShould send the event.", tests.Success, testID)
+		}
+
+		testID = 3
+		t.Logf("\tTest %d:\tWhen the receiver keeps failing.", testID)
+		{
+			respond(http.StatusInternalServerError)
+			createUser("Wade Brook", "wade@example.com")
+
+			now := relay()
+			deliver(now)
+			deliver(now.Add(30 * time.Second))
+			if count() != 2 {
+				t.Fatalf("\t%s\tTest %d:\tShould wait for the backoff before retrying : %d", tests.Failed, testID, count())
+			}
+			t.Logf("\t%s\tTest %d:\tShould wait for the backoff before retrying.", tests.Success, testID)
+
+			deliver(now.Add(time.Minute))
+			deliver(now.Add(3 * time.Minute))
+			deliver(now.Add(time.Hour))
+			if count() != 4 {
+				t.Fatalf("\t%s\tTest %d:\tShould stop after the max attempts : %d", tests.Failed, testID, count())
+			}
+			t.Logf("\t%s\tTest %d:\tShould stop after the max attempts.", tests.Success, testID)
+
+			got := queryDeliveries(webhook.ID)
+			if len(got) != 2 || got[0].Status != "failed" || got[0].Attempts != 3 || got[0].ResponseCode != http.StatusInternalServerError || got[1].Status != "succeeded" {
+				t.Fatalf("\t%s\tTest %d:\tShould log the deliveries, newest first : %+v", tests.Failed, testID, got)
+			}
+			t.Logf("\t%s\tTest %d:\tShould log the deliveries, newest first.", tests.Success, testID)
+		}
+
+		testID = 4
+		t.Logf("\tTest %d:\tWhen an admin redelivers a failed delivery.", testID)
+		{
+			respond(http.StatusNoContent)
+			failed := queryDeliveries(webhook.ID)[0]
+
+			w := send(http.MethodPost, "/v1/webhooks/"+webhook.ID+"/deliveries/"+failed.ID+"/redeliver", "", ut.adminToken)
+			if w.Code != http.StatusAccepted {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 202 for the response : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 202 for the response.", tests.Success, testID)
+
+			deliver(time.Now().UTC())
+
+			got := queryDeliveries(webhook.ID)[0]
+			if count() != 5 || got.ID != failed.ID || got.Status != "succeeded" || got.DateDelivered == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould deliver it again : %d %+v", tests.Failed, testID, count(), got)
+			}
+			t.Logf("\t%s\tTest %d:\tShould deliver it again.", tests.Success, testID)
+
+			if w := send(http.MethodPost, "/v1/webhooks/"+webhook.ID+"/deliveries/"+webhook.ID+"/redeliver", "", ut.adminToken); w.Code != http.StatusNotFound {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 404 for an unknown delivery : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 404 for an unknown delivery.", tests.Success, testID)
+		}
+
+		testID = 5
+		t.Logf("\tTest %d:\tWhen an admin deletes the webhook.", testID)
+		{
+			if w := send(http.MethodDelete, "/v1/webhooks/"+webhook.ID, "", ut.adminToken); w.Code != http.StatusNoContent {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 204 for the response : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 204 for the response.", tests.Success, testID)
+
+			createUser("Wilma Brook", "wilma@example.com")
+			deliver(relay())
+			if count() != 5 {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT deliver anymore : %d", tests.Failed, testID, count())
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT deliver anymore.", tests.Success, testID)
+
+			if w := send(http.MethodGet, "/v1/webhooks/"+webhook.ID+"/deliveries", "", ut.adminToken); w.Code != http.StatusNotFound {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 404 for its deliveries : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 404 for its deliveries.", tests.Success, testID)
+		}
+
+		testID = 6
+		t.Logf("\tTest %d:\tWhen a webhook points to the internal network or redirects.", testID)
+		{
+			redirector := httptest.NewServer(http.RedirectHandler(receiver.URL+"/hooks", http.StatusTemporaryRedirect))
+			defer redirector.Close()
+
+			w := send(http.MethodPost, "/v1/webhooks", `{"url": "`+redirector.URL+`", "events": ["UserCreated"]}`, ut.adminToken)
+			if w.Code != http.StatusCreated {
+				t.Fatalf("creating webhook: status %d", w.Code)
+			}
+			if err := json.NewDecoder(w.Body).Decode(&webhook); err != nil {
+				t.Fatalf("decoding webhook: %s", err)
+			}
+
+			createUser("Willa Brook", "willa@example.com")
+			now := relay()
+
+			guarded := webhookCore.NewSender(ut.log, ut.webhooks, webhookCore.SenderConfig{MaxAttempts: 3, Backoff: time.Minute})
+			if _, err := guarded.Deliver(context.Background(), now); err != nil {
+				t.Fatalf("sending deliveries: %s", err)
+			}
+
+			got := queryDeliveries(webhook.ID)
+			if count() != 5 || len(got) != 1 || got[0].Attempts != 1 || !strings.Contains(got[0].LastError, webhookCore.ErrForbiddenAddress.Error()) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT send deliveries to a loopback address : %d %+v", tests.Failed, testID, count(), got)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT send deliveries to a loopback address.", tests.Success, testID)
+
+			deliver(now.Add(time.Minute))
+
+			got = queryDeliveries(webhook.ID)
+			if count() != 5 || len(got) != 1 || got[0].Attempts != 2 || got[0].ResponseCode != http.StatusTemporaryRedirect {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT follow redirects : %d %+v", tests.Failed, testID, count(), got)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT follow redirects.", tests.Success, testID)
+		}
+
+		testID = 7
+		t.Logf("\tTest %d:\tWhen a webhook points to the cloud metadata or a mapped loopback address.", testID)
+		{
+			urls := []string{
+				"http://169.254.169.254/latest/meta-data",
+				"http://[::ffff:127.0.0.1]" + strings.TrimPrefix(receiver.URL, "http://127.0.0.1") + "/hooks",
+			}
+
+			var ids []string
+			for _, u := range urls {
+				w := send(http.MethodPost, "/v1/webhooks", `{"url": "`+u+`", "events": ["UserCreated"]}`, ut.adminToken)
+				if w.Code != http.StatusCreated {
+					t.Fatalf("creating webhook %s: status %d", u, w.Code)
+				}
+				if err := json.NewDecoder(w.Body).Decode(&webhook); err != nil {
+					t.Fatalf("decoding webhook: %s", err)
+				}
+				ids = append(ids, webhook.ID)
+			}
+
+			createUser("Wren Brook", "wren@example.com")
+			now := relay()
+
+			guarded := webhookCore.NewSender(ut.log, ut.webhooks, webhookCore.SenderConfig{MaxAttempts: 3, Backoff: time.Minute})
+			if _, err := guarded.Deliver(context.Background(), now); err != nil {
+				t.Fatalf("sending deliveries: %s", err)
+			}
+
+			for i, id := range ids {
+				got := queryDeliveries(id)
+				if count() != 5 || len(got) != 1 || got[0].Attempts != 1 || !strings.Contains(got[0].LastError, webhookCore.ErrForbiddenAddress.Error()) {
+					t.Fatalf("\t%s\tTest %d:\tShould NOT send deliveries to %s : %d %+v", tests.Failed, testID, urls[i], count(), got)
+				}
+				t.Logf("\t%s\tTest %d:\tShould NOT send deliveries to %s.", tests.Success, testID, urls[i])
+			}
+		}
+	}
+}